@@ -112,6 +112,9 @@ func (img *sourceImage) analyze() (err error) {
 			return fmt.Errorf("p.ParseBPC failed: %w", err)
 		}
 	}
+	if img.blend != nil {
+		return img.analyzeBlend()
+	}
 	if img.hasSpriteDimensions() {
 		return img.analyzeSprites()
 	}
@@ -285,6 +288,10 @@ func (img *sourceImage) guessPreferredBitpairColors(wantedMaxColors int, sumColo
 				c64col = byte(col)
 			}
 		}
+		if max == 0 {
+			// all used colors are preferred already, don't add duplicates.
+			break
+		}
 		col := img.p.FromC64NoErr(C64Color(c64col))
 		img.bpc = append(img.bpc, &col)
 		sumColors[c64col] = 0
@@ -335,6 +342,210 @@ func (img *sourceImage) guessPreferredBitpairColors(wantedMaxColors int, sumColo
 	}
 }
 
+// analyzeAnimationFrames analyzes a copy of each frame on its own, to find the graphics type and bitpair colors
+// suiting all frames. Frames failing analysis are left out, they are skipped with a warning during conversion.
+func analyzeAnimationFrames(imgs []sourceImage) []sourceImage {
+	frames := make([]sourceImage, 0, len(imgs))
+	for _, img := range imgs {
+		img.opt.Quiet = true
+		img.bpc = nil
+		if err := img.analyze(); err != nil {
+			continue
+		}
+		frames = append(frames, img)
+	}
+	return frames
+}
+
+// animationGraphicsType returns the graphics type suiting all frames.
+// Frames with fewer colors may be detected as a charset, if the other frames need a bitmap, all frames are converted
+// to a multicolor or singlecolor bitmap.
+func animationGraphicsType(frames []sourceImage) (GraphicsType, error) {
+	gfxtype, other := frames[0].graphicsType, frames[0].graphicsType
+	multi, single := true, true
+	for _, f := range frames {
+		switch f.graphicsType {
+		case multiColorBitmap, multiColorCharset:
+			single = false
+		case singleColorBitmap, singleColorCharset, petsciiCharset, ecmCharset:
+			multi = false
+		default:
+			multi, single = false, false
+		}
+		if f.graphicsType != frames[0].graphicsType {
+			other = f.graphicsType
+			if f.graphicsType == multiColorBitmap || f.graphicsType == singleColorBitmap {
+				gfxtype = f.graphicsType
+			}
+		}
+	}
+	switch {
+	case other == frames[0].graphicsType:
+		return gfxtype, nil
+	case multi && gfxtype == multiColorBitmap:
+		return gfxtype, nil
+	case single && gfxtype == singleColorBitmap:
+		return gfxtype, nil
+	}
+	return gfxtype, fmt.Errorf("mixed graphicsmodes detected %q != %q", other, frames[0].graphicsType)
+}
+
+// animationBitpairColors returns the preferred bitpair colors for all frames of a multicolor bitmap or hires
+// animation, guessed from the colors used in all frames combined instead of the first frame only.
+// The background color is the first frame's, if it is possible in all frames.
+func animationBitpairColors(frames []sourceImage) ([]*Color, error) {
+	guess := frames[0]
+	switch guess.graphicsType {
+	case multiColorBitmap, singleColorBitmap:
+	default:
+		return nil, fmt.Errorf("bitpair colors for %q animations are not supported", guess.graphicsType)
+	}
+	guess.p = BlankPalette("animation", false)
+	guess.bpc = nil
+	sumColors := [MaxColors]int{}
+	maxColors := 0
+	bgCandidates := []Color{}
+	for i, f := range frames {
+		guess.p.Add(f.p.Colors()...)
+		for col, sum := range f.sumColors {
+			sumColors[col] += sum
+		}
+		if n := len(f.maxColorsPerChar()); n > maxColors {
+			maxColors = n
+		}
+		f.findBgCandidates(false)
+		if i == 0 {
+			bgCandidates = f.bgCandidates
+			continue
+		}
+		common := []Color{}
+		for _, col := range bgCandidates {
+			for _, fcol := range f.bgCandidates {
+				if col.C64Color == fcol.C64Color {
+					common = append(common, col)
+					break
+				}
+			}
+		}
+		bgCandidates = common
+	}
+
+	if guess.graphicsType != singleColorBitmap {
+		if len(bgCandidates) == 0 {
+			return nil, fmt.Errorf("no background color found that is possible in all frames")
+		}
+		bg := bgCandidates[0]
+		for _, col := range bgCandidates {
+			if col.C64Color == frames[0].bg.C64Color {
+				bg = col
+				break
+			}
+			if sumColors[col.C64Color] > sumColors[bg.C64Color] {
+				bg = col
+			}
+		}
+		guess.bg = bg
+		guess.bpc = []*Color{&bg}
+	}
+	if guess.graphicsType == multiColorBitmap {
+		maxColors = 4
+	}
+	guess.guessPreferredBitpairColors(maxColors, sumColors)
+	return guess.bpc, nil
+}
+
+// animationCharsetBitpairColors returns the bitpair colors for all frames of a multicolor charset animation.
+// All 4 colors of a multicolor charset are shared by the whole screen, d021-d023 and the charcolor, so the colors of
+// all frames are assigned jointly: each color keeps its bitpair in every frame and only the changed chars differ.
+// The colors of the -bitpair-colors preset keep their bitpair, the first frame's background is preferred for bitpair 00
+// and the other colors are searched for a bitpair by frequency in the analyzed frames, bitpair 11 only fits colors 0-7.
+// The colors of imgs that were not analyzed as a frame are included, unless they do not fit.
+func animationCharsetBitpairColors(imgs, frames []sourceImage, preset string) ([]*Color, error) {
+	p := BlankPalette("animation", false)
+	for _, img := range imgs {
+		p.Add(img.p.Colors()...)
+	}
+	sumColors := [MaxColors]int{}
+	for _, f := range frames {
+		for col, sum := range f.sumColors {
+			sumColors[col] += sum
+		}
+	}
+	used, framesUsed := [MaxColors]bool{}, [MaxColors]bool{}
+	for _, col := range p.Colors() {
+		used[col.C64Color] = true
+		framesUsed[col.C64Color] = sumColors[col.C64Color] > 0
+	}
+	if len(p.Colors()) > 4 {
+		used = framesUsed
+	}
+	var presetColors []*Color
+	if preset != "" {
+		var err error
+		if presetColors, err = p.ParseBPC(preset); err != nil {
+			return nil, fmt.Errorf("p.ParseBPC %q failed: %w", preset, err)
+		}
+	}
+	if len(presetColors) > 4 {
+		presetColors = presetColors[:4]
+	}
+	bpc := make([]*Color, 4)
+	copy(bpc, presetColors)
+	free := []int{}
+	for i, col := range bpc {
+		// preset colors that are not used in any frame do not need a bitpair.
+		if col == nil || !used[col.C64Color] {
+			bpc[i] = nil
+			free = append(free, i)
+			continue
+		}
+		used[col.C64Color] = false
+	}
+	cc := []C64Color{}
+	for col := range used {
+		if used[col] {
+			cc = append(cc, C64Color(col))
+		}
+	}
+	bg := frames[0].bg.C64Color
+	sort.SliceStable(cc, func(i, j int) bool {
+		if (cc[i] == bg) != (cc[j] == bg) {
+			return cc[i] == bg
+		}
+		return sumColors[cc[i]] > sumColors[cc[j]]
+	})
+	if len(cc) > len(free) {
+		return nil, fmt.Errorf("%d colors do not fit in the %d free bitpairs of the multicolor charset %q", len(cc), len(free), preset)
+	}
+
+	var assign func(i int, charColor bool) bool
+	assign = func(i int, charColor bool) bool {
+		if i == len(cc) {
+			return true
+		}
+		for _, bitpair := range free {
+			if bpc[bitpair] != nil || (charColor && bitpair == 3 && cc[i] > 7) {
+				continue
+			}
+			col := p.FromC64NoErr(cc[i])
+			bpc[bitpair] = &col
+			if assign(i+1, charColor) {
+				return true
+			}
+			bpc[bitpair] = nil
+		}
+		return false
+	}
+	// without a charcolor 0-7, MultiColorCharset reports the colors to swap.
+	if !assign(0, true) {
+		assign(0, false)
+	}
+	for len(bpc) > len(presetColors) && bpc[len(bpc)-1] == nil {
+		bpc = bpc[:len(bpc)-1]
+	}
+	return bpc, nil
+}
+
 // countSpriteColors returns color statistics.
 func (img *sourceImage) countSpriteColors() (numColors int, usedColors []byte, sumColors [MaxColors]int) {
 	for y := 0; y < img.height; y++ {
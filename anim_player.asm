@@ -0,0 +1,280 @@
+// the animation player plays koala and hires animations from your own code, without displayer, fade or music.
+// png2prg relocates it to any page and moves its zeropage addresses, by comparing this build with the one
+// assembled from anim_player_reloc.asm. only high bytes of player addresses and zeropage addresses may differ.
+//
+// koala frames contain 10 bytes per char, hires frames 9, as set in char_length.
+//
+// player+0: init, call once before playing, the first frame must already be shown.
+// player+3: step, call once every video frame, plays the next frame when its delay is over.
+// player+6: play, plays the next frame immediately, ignoring delays.
+// all entrypoints change a, x and y.
+#if RELOC
+.const player   = $3000
+.const zp_start = $10
+#else
+.const player   = $1000
+.const zp_start = $08
+#endif
+.const colorram = $d800
+
+.const zp_anim_lo     = zp_start + 0
+.const zp_anim_hi     = zp_start + 1
+.const zp_bitmap_lo   = zp_start + 2
+.const zp_bitmap_hi   = zp_start + 3
+.const zp_char_lo     = zp_start + 4
+.const zp_char_hi     = zp_start + 5
+.const zp_d800_lo     = zp_start + 6
+.const zp_d800_hi     = zp_start + 7
+
+.pc = player "anim_player"
+player_init:
+		jmp init
+player_step:
+		jmp step
+player_play:
+		jmp anim_play
+
+// settings written by png2prg
+frames:
+		.word 0                 // address of the first frame
+bitmap_hi:
+		.byte >$2000            // the bitmap is 8K aligned
+screenram:
+		.word $0400
+frame_delay:
+		.byte 0                 // default delay, for frames with delay 0
+char_length:
+		.byte 10                // 9 for hires, without colorram
+
+delay_counter:
+		.byte 1
+cur_delay:
+		.byte 0
+
+init:
+		lda frames
+		sta zp_anim_lo
+		lda frames + 1
+		sta zp_anim_hi
+		lda #0
+		sta cur_delay
+		beq set_delay
+
+step:
+		dec delay_counter
+		bne rrts
+		jsr anim_play
+set_delay:
+		ldx cur_delay
+		bne !+
+		ldx frame_delay
+		bne !+
+		inx
+	!:	stx delay_counter
+rrts:	rts
+
+anim_play:
+next_chunk:
+		ldy #0
+		lax (zp_anim_lo),y      //  a = x = number of chars in chunk
+		bne plot_chunk          // #$00 = end of frame
+		iny
+		lda (zp_anim_lo),y      // frame delay, 0 = use frame_delay
+		sta cur_delay
+		dey
+		lda zp_anim_lo
+		clc
+		adc #2
+		sta zp_anim_lo
+		bcc !+
+		inc zp_anim_hi
+	!:
+		lda (zp_anim_lo),y
+		cmp #$ff				// #$ff = end of all frames, followed by the address of the next frame
+		bne !skip+
+		iny
+		lda (zp_anim_lo),y
+		tax
+		iny
+		lda (zp_anim_lo),y
+		sta zp_anim_hi
+		stx zp_anim_lo
+!skip:	rts
+
+plot_chunk:
+		cpx #$fe                // #$fe = masked frame
+		bne !+
+		jmp plot_masked
+	!:
+		iny
+		lda (zp_anim_lo),y
+		sta zp_bitmap_lo
+		iny
+		lda (zp_anim_lo),y
+		clc
+		adc bitmap_hi
+		sta zp_bitmap_hi
+		iny
+		lda (zp_anim_lo),y
+		sta zp_d800_lo
+		//clc
+		adc screenram
+		sta zp_char_lo
+		iny
+		lda (zp_anim_lo),y
+		adc screenram + 1
+		sta zp_char_hi
+		lda (zp_anim_lo),y
+		clc
+		adc #>colorram
+		sta zp_d800_hi
+
+		lda zp_anim_lo
+		//clc
+		adc #5
+		sta zp_anim_lo
+		bcc !+
+		inc zp_anim_hi
+		clc
+	!:
+
+plot_next_char:
+		ldy #0
+	.for (var i = 0; i < 8; i++) {
+		lda (zp_anim_lo),y
+		sta (zp_bitmap_lo),y
+		iny
+	}
+		lda (zp_anim_lo),y
+		sta smc_keep_screencol+1
+		lda char_length
+		cmp #10
+		bne !+
+		iny
+		lda (zp_anim_lo),y
+		ldy #0
+		sta (zp_d800_lo),y
+	!:	ldy #0
+smc_keep_screencol:
+		lda #0
+		sta (zp_char_lo),y
+
+		lda zp_anim_lo
+		clc
+		adc char_length
+		sta zp_anim_lo
+		bcc !+
+		clc
+		inc zp_anim_hi
+	!:
+		lda zp_bitmap_lo
+		adc #8
+		sta zp_bitmap_lo
+		bcc !+
+		clc
+		inc zp_bitmap_hi
+	!:
+		inc zp_char_lo          // screenram and colorram may have different low bytes
+		bne !+
+		inc zp_char_hi
+	!:	inc zp_d800_lo
+		bne !+
+		inc zp_d800_hi
+	!:
+		dex
+		bne plot_next_char
+		jmp next_chunk
+
+// masked frame, see readme for details on format.
+plot_masked:
+		lda #<(-8)              // start at char -1
+		sta zp_bitmap_lo
+		ldx bitmap_hi
+		dex
+		stx zp_bitmap_hi
+		lda screenram
+		sec
+		sbc #1
+		sta zp_char_lo
+		lda screenram + 1
+		sbc #0
+		sta zp_char_hi
+		lda #<(colorram - 1)
+		sta zp_d800_lo
+		lda #>(colorram - 1)
+		sta zp_d800_hi
+		ldx #1                  // skip #$fe
+next_masked:
+		txa                     // x = length of the previous char
+		clc
+		adc zp_anim_lo
+		sta zp_anim_lo
+		sta smc_masked_data + 1
+		bcc !+
+		inc zp_anim_hi
+	!:	lda zp_anim_hi
+		sta smc_masked_data + 2
+		ldy #0
+		lda (zp_anim_lo),y
+		bne !+
+		jmp next_chunk          // #$00 = end of frame
+	!:	sta masked_flags
+		and #$1f                // number of chars to skip
+		tax
+		inx
+		txa
+		clc
+		adc zp_char_lo
+		sta zp_char_lo
+		bcc !+
+		inc zp_char_hi
+		clc
+	!:	txa
+		adc zp_d800_lo
+		sta zp_d800_lo
+		bcc !+
+		inc zp_d800_hi
+	!:	txa
+		asl
+		asl
+		asl
+		bcc !+
+		inc zp_bitmap_hi
+		clc
+	!:	adc zp_bitmap_lo
+		sta zp_bitmap_lo
+		bcc !+
+		inc zp_bitmap_hi
+	!:
+		ldx #1
+		asl masked_flags        // bit 7 = screenram
+		bcc !+
+		jsr masked_data
+		sta (zp_char_lo),y
+	!:	asl masked_flags        // bit 6 = colorram
+		bcc !+
+		jsr masked_data
+		sta (zp_d800_lo),y
+	!:	asl masked_flags        // bit 5 = pixel rows, followed by the mask of changed rows
+		bcc next_masked
+		jsr masked_data
+		sta masked_rows
+	!:	asl masked_rows
+		bcc !+
+		jsr masked_data
+		sta (zp_bitmap_lo),y
+	!:	iny
+		cpy #8
+		bne !--
+		beq next_masked
+
+masked_data:
+smc_masked_data:
+		lda $ffff,x
+		inx
+		rts
+masked_flags:
+		.byte 0
+masked_rows:
+		.byte 0
+player_end:
@@ -0,0 +1,3 @@
+// animation player assembled at another page and zeropage, to find the bytes to relocate.
+#define RELOC
+#import "anim_player.asm"
@@ -6,9 +6,8 @@ import (
 	"io"
 	"log"
 	"strconv"
+	"strings"
 	"time"
-
-	"github.com/staD020/TSCrunch"
 )
 
 const (
@@ -33,6 +32,15 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 	if len(imgs) < 1 {
 		return n, fmt.Errorf("no sourceImage given")
 	}
+	if c.opt.Display && c.opt.DisplayerBank != 0 {
+		return n, fmt.Errorf("vic bank %d is not supported for animations", c.opt.DisplayerBank)
+	}
+	if c.opt.Stream && !c.opt.Display {
+		return n, fmt.Errorf("streaming animations requires the displayer")
+	}
+	if c.opt.Player && c.opt.Display {
+		return n, fmt.Errorf("the animation player replaces the displayer, use either -player or -display")
+	}
 
 	bruteforce := func(gfxtype GraphicsType, maxColors int) error {
 		if !c.opt.BruteForce {
@@ -47,6 +55,13 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 		return nil
 	}
 
+	var frames []sourceImage
+	if len(imgs) > 1 {
+		if frames, err = c.unifyAnimationGraphicsType(); err != nil {
+			return n, err
+		}
+	}
+
 	if imgs[0].graphicsType == multiColorBitmap {
 		err = bruteforce(imgs[0].graphicsType, 4)
 	}
@@ -56,11 +71,42 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 	if err != nil {
 		log.Printf("bruteforce failed: %f", err)
 	}
+	if len(frames) > 1 && !c.opt.NoGuess {
+		var bpc []*Color
+		switch {
+		case imgs[0].graphicsType == multiColorCharset:
+			if bpc, err = animationCharsetBitpairColors(imgs, frames, c.opt.BitpairColorsString); err != nil {
+				return n, fmt.Errorf("animationCharsetBitpairColors failed: %w", err)
+			}
+			// the frames use the joint colors instead of parsing the preset again.
+			for i := range imgs {
+				imgs[i].opt.BitpairColorsString = ""
+			}
+		case c.opt.BitpairColorsString == "" && (imgs[0].graphicsType == multiColorBitmap || imgs[0].graphicsType == singleColorBitmap):
+			if bpc, err = animationBitpairColors(frames); err != nil {
+				return n, fmt.Errorf("animationBitpairColors failed: %w", err)
+			}
+		}
+		if bpc != nil {
+			quiet := imgs[0].opt.Quiet
+			imgs[0].opt.Quiet = true
+			imgs[0].bpc = bpc
+			err = imgs[0].analyze()
+			imgs[0].opt.Quiet = quiet
+			if err != nil {
+				return n, fmt.Errorf("analyze %q failed: %w", imgs[0].sourceFilename, err)
+			}
+			if !c.opt.Quiet {
+				fmt.Printf("using -bitpair-colors %s for all frames\n", imgs[0].BPCString())
+			}
+		}
+	}
 
 	wantedGraphicsType := imgs[0].graphicsType
 	c.FinalGraphicsType = imgs[0].graphicsType
 	currentBitpairColors := []*Color{}
 	charset := []charBytes{}
+	var prevBpcCache *[FullScreenChars]map[C64Color]byte
 	for i, img := range imgs {
 		if !c.opt.Quiet {
 			fmt.Printf("processing %q frame %d\n", img.sourceFilename, i)
@@ -81,25 +127,49 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 		if len(currentBitpairColors) == 0 {
 			currentBitpairColors = img.bpc
 		}
-		if BPCString(currentBitpairColors) != BPCString(img.bpc) && imgs[0].graphicsType != petsciiCharset && imgs[0].graphicsType != singleColorCharset {
-			log.Printf("bitpairColors %q of the previous frame do not equal current frame %q", BPCString(currentBitpairColors), BPCString(img.bpc))
-			log.Println("this would cause huge animation frame sizes and probably crash the displayer")
-			return n, fmt.Errorf("bitpairColors differ between frames, maybe use -bitpair-colors %s to force them", BPCString(currentBitpairColors))
+		if BPCString(currentBitpairColors) != BPCString(img.bpc) {
+			switch img.graphicsType {
+			case petsciiCharset, singleColorCharset:
+			case multiColorBitmap, singleColorBitmap:
+				// only the background color is shared by all chars, the chars with other colors are re-encoded as delta.
+				if img.graphicsType == multiColorBitmap && img.bg.C64Color != imgs[0].bg.C64Color {
+					return n, fmt.Errorf("background color %d of frame %d differs from %d, maybe use -bitpair-colors %s to force it", img.bg.C64Color, i, imgs[0].bg.C64Color, BPCString(currentBitpairColors))
+				}
+				if c.opt.Verbose {
+					log.Printf("frame %d: bitpairColors %q differ from %q, the changed chars are re-encoded", i, BPCString(img.bpc), BPCString(currentBitpairColors))
+				}
+			default:
+				log.Printf("bitpairColors %q of the previous frame do not equal current frame %q", BPCString(currentBitpairColors), BPCString(img.bpc))
+				log.Println("this would cause huge animation frame sizes and probably crash the displayer")
+				return n, fmt.Errorf("bitpairColors differ between frames, maybe use -bitpair-colors %s to force them", BPCString(currentBitpairColors))
+			}
 		}
 
 		switch img.graphicsType {
 		case multiColorBitmap:
+			img.prevBpcCache = prevBpcCache
 			k, err := img.Koala()
 			if err != nil {
 				return n, fmt.Errorf("img.Koala failed: %w", err)
 			}
+			if len(kk) > 0 {
+				k.keepUnusedColors(kk[len(kk)-1])
+			}
 			kk = append(kk, k)
+			bpcCache := img.bpcCache
+			prevBpcCache = &bpcCache
 		case singleColorBitmap:
+			img.prevBpcCache = prevBpcCache
 			h, err := img.Hires()
 			if err != nil {
 				return n, fmt.Errorf("img.Hires failed: %w", err)
 			}
+			if len(hh) > 0 {
+				h.keepUnusedColors(hh[len(hh)-1])
+			}
 			hh = append(hh, h)
+			bpcCache := img.bpcCache
+			prevBpcCache = &bpcCache
 		case multiColorSprites:
 			s, err := img.MultiColorSprites()
 			if err != nil {
@@ -177,57 +247,95 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 		return n, nil
 	}
 
+	if c.opt.Player && len(kk) == 0 && len(hh) == 0 {
+		return n, fmt.Errorf("the animation player only supports koala and hires animations")
+	}
+	if c.opt.CustomLayout() && (len(mcCharsets) > 0 || len(mixCharsets) > 0 || len(scCharsets) > 0 || len(petCharsets) > 0) {
+		return n, fmt.Errorf("custom addresses are not supported for charset animations")
+	}
+
 	// export separate frame data (non displayer)
 	switch {
 	case len(kk) > 0:
-		m, err := kk[0].WriteTo(w)
-		n += m
+		tt, err := animationTransitions(c.opt, len(kk))
 		if err != nil {
-			return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
+			return n, fmt.Errorf("animationTransitions failed: %w", err)
 		}
-		if !c.opt.Quiet {
-			fmt.Printf("converted %q to %q\n", kk[0].SourceFilename, c.opt.OutFile)
+		k := kk[tt[0].from]
+		if c.opt.Player {
+			link, symbols, err := linkAnimationPlayer(c.opt, c.opt.bitmapLayout(), k.linkMap(c.opt.bitmapLayout()), makeCharer(kk), tt, koalaFrameDelays(kk), 10)
+			if err != nil {
+				return n, fmt.Errorf("linkAnimationPlayer failed: %w", err)
+			}
+			if n, err = c.writeLinkTo(w, link); err != nil {
+				return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
+			}
+			c.Symbols = append(c.Symbols, k.Symbols()...)
+			c.Symbols = append(c.Symbols, symbols...)
+			return n, nil
 		}
-		c.Symbols = append(c.Symbols, kk[0].Symbols()...)
-		c.Symbols = append(c.Symbols, c64Symbol{"animation", koalaAnimationStart})
+		buf := &bytes.Buffer{}
+		if _, err = k.WriteTo(buf); err != nil {
+			return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
+		}
+		start := NewWord(buf.Bytes()[0], buf.Bytes()[1]) + Word(buf.Len()-2)
+		c.Symbols = append(c.Symbols, k.Symbols()...)
+		c.Symbols = append(c.Symbols, c64Symbol{"animation", int(start)})
 
-		frames := makeCharer(kk)
-		prgs, err := processAnimation(c.opt, frames)
+		prgs, err := processAnimation(c.opt, makeCharer(kk), tt, koalaFrameDelays(kk))
 		if err != nil {
 			return n, fmt.Errorf("processAnimation failed: %w", err)
 		}
-
-		for i := range prgs {
-			m, err := w.Write(prgs[i])
-			n += int64(m)
-			if err != nil {
-				return n, fmt.Errorf("Write failed: %w", err)
-			}
+		end, symbols := appendFrameTable(c.opt, start, prgs, animationEnd(c.opt, start, framesLength(prgs)))
+		c.Symbols = append(c.Symbols, symbols...)
+		m, err := writeAnimationFrames(w, buf, prgs, end)
+		n += m
+		if err != nil {
+			return n, fmt.Errorf("writeAnimationFrames failed: %w", err)
+		}
+		if !c.opt.Quiet {
+			fmt.Printf("converted %q to %q\n", k.SourceFilename, c.opt.OutFile)
 		}
 		return n, nil
 	case len(hh) > 0:
-		m, err := hh[0].WriteTo(w)
-		n += int64(m)
+		tt, err := animationTransitions(c.opt, len(hh))
 		if err != nil {
-			return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
+			return n, fmt.Errorf("animationTransitions failed: %w", err)
 		}
-		if !c.opt.Quiet {
-			fmt.Printf("converted %q to %q\n", hh[0].SourceFilename, c.opt.OutFile)
+		h := hh[tt[0].from]
+		if c.opt.Player {
+			link, symbols, err := linkAnimationPlayer(c.opt, c.opt.bitmapLayout(), h.linkMap(c.opt.bitmapLayout()), makeCharer(hh), tt, hiresFrameDelays(hh), 9)
+			if err != nil {
+				return n, fmt.Errorf("linkAnimationPlayer failed: %w", err)
+			}
+			if n, err = c.writeLinkTo(w, link); err != nil {
+				return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
+			}
+			c.Symbols = append(c.Symbols, h.Symbols()...)
+			c.Symbols = append(c.Symbols, symbols...)
+			return n, nil
 		}
-		c.Symbols = append(c.Symbols, hh[0].Symbols()...)
-		c.Symbols = append(c.Symbols, c64Symbol{"animation", hiresAnimationStart})
+		buf := &bytes.Buffer{}
+		if _, err = h.WriteTo(buf); err != nil {
+			return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
+		}
+		start := NewWord(buf.Bytes()[0], buf.Bytes()[1]) + Word(buf.Len()-2)
+		c.Symbols = append(c.Symbols, h.Symbols()...)
+		c.Symbols = append(c.Symbols, c64Symbol{"animation", int(start)})
 
-		frames := makeCharer(hh)
-		prgs, err := processAnimation(c.opt, frames)
+		prgs, err := processAnimation(c.opt, makeCharer(hh), tt, hiresFrameDelays(hh))
+		if err != nil {
+			return n, fmt.Errorf("processAnimation failed: %w", err)
+		}
+		end, symbols := appendFrameTable(c.opt, start, prgs, animationEnd(c.opt, start, framesLength(prgs)))
+		c.Symbols = append(c.Symbols, symbols...)
+		m, err := writeAnimationFrames(w, buf, prgs, end)
+		n += m
 		if err != nil {
-			return n, fmt.Errorf("processHiresAnimation failed: %w", err)
+			return n, fmt.Errorf("writeAnimationFrames failed: %w", err)
 		}
-		for i := range prgs {
-			m, err := w.Write(prgs[i])
-			n += int64(m)
-			if err != nil {
-				return n, fmt.Errorf("Write failed: %w", err)
-			}
+		if !c.opt.Quiet {
+			fmt.Printf("converted %q to %q\n", h.SourceFilename, c.opt.OutFile)
 		}
 		return n, nil
 	case len(mcSprites) > 0:
@@ -281,9 +389,14 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 		for i := 0; i < len(mcCharsets); i++ {
 			c.Symbols = append(c.Symbols, c64Symbol{"screen" + strconv.Itoa(i), 0x4800 + i*0x400})
 		}
-		if _, err = WriteMultiColorCharsetAnimationTo(w, mcCharsets); err != nil {
-			return n, fmt.Errorf("WriteMultiColorCharsetAnimationTo failed: %w", err)
+		link, symbols, err := linkMultiColorCharsetAnimation(mcCharsets)
+		if err != nil {
+			return n, fmt.Errorf("linkMultiColorCharsetAnimation failed: %w", err)
+		}
+		if n, err = c.writeLinkTo(w, link); err != nil {
+			return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 		return n, nil
 	case len(mixCharsets) > 0:
 		if c.opt.NoAnimation {
@@ -314,9 +427,14 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 		for i := 0; i < len(mixCharsets); i++ {
 			c.Symbols = append(c.Symbols, c64Symbol{"screen" + strconv.Itoa(i), 0x4800 + i*0x400})
 		}
-		if _, err = WriteMixedCharsetAnimationTo(w, mixCharsets); err != nil {
-			return n, fmt.Errorf("WriteMixedCharsetAnimationTo failed: %w", err)
+		link, symbols, err := linkMixedCharsetAnimation(mixCharsets)
+		if err != nil {
+			return n, fmt.Errorf("linkMixedCharsetAnimation failed: %w", err)
+		}
+		if n, err = c.writeLinkTo(w, link); err != nil {
+			return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 		return n, nil
 	case len(scCharsets) > 0:
 		if c.opt.NoAnimation {
@@ -340,9 +458,14 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 				c64Symbol{"d021color", int(scCharsets[0].BackgroundColor)},
 			)
 		}
-		if _, err = WriteSingleColorCharsetAnimationTo(w, scCharsets); err != nil {
-			return n, fmt.Errorf("WriteSingleColorCharsetAnimationTo failed: %w", err)
+		link, symbols, err := linkSingleColorCharsetAnimation(scCharsets)
+		if err != nil {
+			return n, fmt.Errorf("linkSingleColorCharsetAnimation failed: %w", err)
 		}
+		if n, err = c.writeLinkTo(w, link); err != nil {
+			return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
+		}
+		c.Symbols = append(c.Symbols, symbols...)
 		return n, nil
 	case len(petCharsets) > 0:
 		c.Symbols = append(c.Symbols,
@@ -353,48 +476,89 @@ func (c *Converter) WriteAnimationTo(w io.Writer) (n int64, err error) {
 			c64Symbol{"d021color", int(petCharsets[0].BackgroundColor)},
 			c64Symbol{"lowercase", int(petCharsets[0].Lowercase)},
 		)
-		if _, err = WritePETSCIICharsetAnimationTo(w, petCharsets); err != nil {
-			return n, fmt.Errorf("WritePETSCIICharsetAnimationTo failed: %w", err)
+		link, symbols, err := linkPETSCIICharsetAnimation(petCharsets)
+		if err != nil {
+			return n, fmt.Errorf("linkPETSCIICharsetAnimation failed: %w", err)
+		}
+		if n, err = c.writeLinkTo(w, link); err != nil {
+			return n, fmt.Errorf("WriteTo %q failed: %w", c.opt.OutFile, err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 		return n, nil
 	}
 	return n, fmt.Errorf("handleAnimation %q failed: no frames written", imgs[0].sourceFilename)
 }
 
+// unifyAnimationGraphicsType analyzes all frames and forces the graphics type suiting all frames, if the first
+// frame was detected otherwise. It returns the analyzed frames, see analyzeAnimationFrames.
+func (c *Converter) unifyAnimationGraphicsType() ([]sourceImage, error) {
+	frames := analyzeAnimationFrames(c.images)
+	if len(frames) == 0 || c.opt.GraphicsMode != "" {
+		return frames, nil
+	}
+	gfxtype, err := animationGraphicsType(frames)
+	if err != nil {
+		return nil, err
+	}
+	if gfxtype == c.images[0].graphicsType {
+		return frames, nil
+	}
+	c.opt.GraphicsMode = gfxtype.String()
+	c.opt.CurrentGraphicsType = gfxtype
+	for i := range c.images {
+		c.images[i].opt.GraphicsMode = c.opt.GraphicsMode
+		c.images[i].opt.CurrentGraphicsType = gfxtype
+	}
+	c.images[0].bpc = nil
+	if err = c.images[0].analyze(); err != nil {
+		return nil, fmt.Errorf("analyze %q failed: %w", c.images[0].sourceFilename, err)
+	}
+	return analyzeAnimationFrames(c.images), nil
+}
+
 // writeAnimationDisplayerTo processes the images and writes the .prg including displayer to w.
 func (c *Converter) writeAnimationDisplayerTo(w io.Writer, imgs []sourceImage, kk []Koala, hh []Hires, scSprites []SingleColorSprites, mcSprites []MultiColorSprites, mcCharsets []MultiColorCharset, scCharsets []SingleColorCharset, petCharsets []PETSCIICharset, mixCharsets []MixedCharset) (n int64, err error) {
-	buf := &bytes.Buffer{}
+	var link *Linker
+	var symbols []c64Symbol
 	switch {
+	case c.opt.Stream && len(kk) > 0:
+		c.Symbols = append(c.Symbols, kk[0].Symbols()...)
+		var segments []StreamSegment
+		link, segments, symbols, err = linkKoalaStream(kk)
+		if err != nil {
+			return n, fmt.Errorf("linkKoalaStream failed: %w", err)
+		}
+		c.Segments = segments
+		c.Symbols = append(c.Symbols, symbols...)
+	case c.opt.Stream && len(hh) > 0:
+		c.Symbols = append(c.Symbols, hh[0].Symbols()...)
+		var segments []StreamSegment
+		link, segments, symbols, err = linkHiresStream(hh)
+		if err != nil {
+			return n, fmt.Errorf("linkHiresStream failed: %w", err)
+		}
+		c.Segments = segments
+		c.Symbols = append(c.Symbols, symbols...)
+	case c.opt.Stream:
+		return n, fmt.Errorf("streaming is not supported for %q animations", imgs[0].graphicsType)
 	case len(kk) > 0:
 		// handle display koala animation
 		c.Symbols = append(c.Symbols, kk[0].Symbols()...)
 		c.Symbols = append(c.Symbols, c64Symbol{"animation", koalaAnimationStart})
-		if c.opt.NoCrunch {
-			m, err := WriteKoalaDisplayAnimTo(w, kk)
-			n += m
-			if err != nil {
-				return n, fmt.Errorf("WriteKoalaDisplayAnimTo failed: %w", err)
-			}
-			return n, nil
-		}
-		if _, err = WriteKoalaDisplayAnimTo(buf, kk); err != nil {
-			return n, fmt.Errorf("WriteKoalaDisplayAnimTo buf failed: %w", err)
+		link, symbols, err = linkKoalaDisplayAnim(kk)
+		if err != nil {
+			return n, fmt.Errorf("linkKoalaDisplayAnim failed: %w", err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 	case len(hh) > 0:
 		// handle display hires animation
 		c.Symbols = append(c.Symbols, hh[0].Symbols()...)
 		c.Symbols = append(c.Symbols, c64Symbol{"animation", hiresAnimationStart})
-		if c.opt.NoCrunch {
-			m, err := WriteHiresDisplayAnimTo(w, hh)
-			n += m
-			if err != nil {
-				return n, fmt.Errorf("WriteHiresDisplayAnimTo failed: %w", err)
-			}
-			return n, nil
-		}
-		if _, err = WriteHiresDisplayAnimTo(buf, hh); err != nil {
-			return n, fmt.Errorf("WriteHiresDisplayAnimTo buf failed: %w", err)
+		link, symbols, err = linkHiresDisplayAnim(hh)
+		if err != nil {
+			return n, fmt.Errorf("linkHiresDisplayAnim failed: %w", err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 	case len(mcCharsets) > 0:
 		if c.opt.NoAnimation {
 			c.Symbols = append(c.Symbols,
@@ -420,17 +584,11 @@ func (c *Converter) writeAnimationDisplayerTo(w io.Writer, imgs []sourceImage, k
 		for i := 0; i < len(mcCharsets); i++ {
 			c.Symbols = append(c.Symbols, c64Symbol{"screen" + strconv.Itoa(i), 0x4800 + i*0x400})
 		}
-		if c.opt.NoCrunch {
-			m, err := WriteMultiColorCharsetAnimationTo(w, mcCharsets)
-			n += m
-			if err != nil {
-				return n, fmt.Errorf("WriteMultiColorCharsetAnimationTo failed: %w", err)
-			}
-			return n, nil
-		}
-		if _, err = WriteMultiColorCharsetAnimationTo(buf, mcCharsets); err != nil {
-			return n, fmt.Errorf("WriteMultiColorCharsetAnimationTo buf failed: %w", err)
+		link, symbols, err = linkMultiColorCharsetAnimation(mcCharsets)
+		if err != nil {
+			return n, fmt.Errorf("linkMultiColorCharsetAnimation failed: %w", err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 	case len(mixCharsets) > 0:
 		if c.opt.NoAnimation {
 			c.Symbols = append(c.Symbols,
@@ -456,17 +614,11 @@ func (c *Converter) writeAnimationDisplayerTo(w io.Writer, imgs []sourceImage, k
 		for i := 0; i < len(mixCharsets); i++ {
 			c.Symbols = append(c.Symbols, c64Symbol{"screen" + strconv.Itoa(i), 0x4800 + i*0x400})
 		}
-		if c.opt.NoCrunch {
-			m, err := WriteMixedCharsetAnimationTo(w, mixCharsets)
-			n += m
-			if err != nil {
-				return n, fmt.Errorf("WriteMixedCharsetAnimationTo failed: %w", err)
-			}
-			return n, nil
-		}
-		if _, err = WriteMixedCharsetAnimationTo(buf, mixCharsets); err != nil {
-			return n, fmt.Errorf("WriteMixedCharsetAnimationTo buf failed: %w", err)
+		link, symbols, err = linkMixedCharsetAnimation(mixCharsets)
+		if err != nil {
+			return n, fmt.Errorf("linkMixedCharsetAnimation failed: %w", err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 	case len(scCharsets) > 0:
 		if c.opt.NoAnimation {
 			c.Symbols = append(c.Symbols,
@@ -489,17 +641,11 @@ func (c *Converter) writeAnimationDisplayerTo(w io.Writer, imgs []sourceImage, k
 				c64Symbol{"d021color", int(scCharsets[0].BackgroundColor)},
 			)
 		}
-		if c.opt.NoCrunch {
-			m, err := WriteSingleColorCharsetAnimationTo(w, scCharsets)
-			n += m
-			if err != nil {
-				return n, fmt.Errorf("WriteSingleColorCharsetAnimationTo failed: %w", err)
-			}
-			return n, nil
-		}
-		if _, err = WriteSingleColorCharsetAnimationTo(buf, scCharsets); err != nil {
-			return n, fmt.Errorf("WriteSingleColorCharsetAnimationTo buf failed: %w", err)
+		link, symbols, err = linkSingleColorCharsetAnimation(scCharsets)
+		if err != nil {
+			return n, fmt.Errorf("linkSingleColorCharsetAnimation failed: %w", err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 	case len(petCharsets) > 0:
 		c.Symbols = append(c.Symbols,
 			c64Symbol{"screen", 0x2800},
@@ -508,37 +654,177 @@ func (c *Converter) writeAnimationDisplayerTo(w io.Writer, imgs []sourceImage, k
 			c64Symbol{"d020color", int(petCharsets[0].BorderColor)},
 			c64Symbol{"d021color", int(petCharsets[0].BackgroundColor)},
 		)
-		if c.opt.NoCrunch {
-			m, err := WritePETSCIICharsetAnimationTo(w, petCharsets)
-			n += m
-			if err != nil {
-				return n, fmt.Errorf("WritePETSCIICharsetAnimationTo failed: %w", err)
-			}
-			return n, nil
+		link, symbols, err = linkPETSCIICharsetAnimation(petCharsets)
+		if err != nil {
+			return n, fmt.Errorf("linkPETSCIICharsetAnimation failed: %w", err)
+		}
+		c.Symbols = append(c.Symbols, symbols...)
+	case len(mcSprites) > 0:
+		c.Symbols = append(c.Symbols, mcSprites[0].Symbols()...)
+		link, symbols, err = linkMultiColorSpritesAnimation(mcSprites)
+		if err != nil {
+			return n, fmt.Errorf("linkMultiColorSpritesAnimation failed: %w", err)
 		}
-		if _, err = WritePETSCIICharsetAnimationTo(buf, petCharsets); err != nil {
-			return n, fmt.Errorf("WritePETSCIICharsetAnimationTo buf failed: %w", err)
+		c.Symbols = append(c.Symbols, symbols...)
+	case len(scSprites) > 0:
+		c.Symbols = append(c.Symbols, scSprites[0].Symbols()...)
+		link, symbols, err = linkSingleColorSpritesAnimation(scSprites)
+		if err != nil {
+			return n, fmt.Errorf("linkSingleColorSpritesAnimation failed: %w", err)
 		}
+		c.Symbols = append(c.Symbols, symbols...)
 	default:
 		return n, fmt.Errorf("animation displayers do not support %q", imgs[0].graphicsType)
 	}
 
-	tsc, err := TSCrunch.New(TSCOptions, buf)
-	if err != nil {
-		return n, fmt.Errorf("tscrunch.New failed: %w", err)
+	if c.opt.NoCrunch {
+		return c.writeLinkTo(w, link)
 	}
+	c.link = link
 	t1 := time.Now()
-	m, err := tsc.WriteTo(w)
+	wt, err := injectCrunch(link, c.opt)
+	if err != nil {
+		return n, fmt.Errorf("injectCrunch failed: %w", err)
+	}
+	m, err := wt.WriteTo(w)
 	n += m
 	if err != nil {
-		return n, fmt.Errorf("tsc.WriteTo failed: %w", err)
+		return n, fmt.Errorf("WriteTo failed: %w", err)
 	}
 	if !c.opt.Quiet {
-		fmt.Printf("TSCrunched in %s\n", time.Since(t1))
+		fmt.Printf("crunched in %s\n", time.Since(t1))
 	}
 	return n, nil
 }
 
+// Animation playback modes.
+const (
+	PlaybackLoop     = "loop"
+	PlaybackPingPong = "pingpong"
+	PlaybackOnce     = "once"
+)
+
+// A transition is the change from frame from to frame to, stored as a single animation frame.
+type transition struct {
+	from, to int
+}
+
+// animationSequence returns the order in which the n frames are shown, according to opt.FrameOrder and opt.Playback.
+func animationSequence(opt Options, n int) ([]int, error) {
+	seq := make([]int, 0, n)
+	if opt.FrameOrder == "" {
+		for i := 0; i < n; i++ {
+			seq = append(seq, i)
+		}
+	} else {
+		for _, v := range strings.Split(opt.FrameOrder, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("strconv.Atoi conversion of %q to integers failed: %w", opt.FrameOrder, err)
+			}
+			if i < 0 || i >= n {
+				return nil, fmt.Errorf("incorrect frame %d in frame order, only 0-%d are allowed", i, n-1)
+			}
+			seq = append(seq, i)
+		}
+	}
+	if len(seq) < 2 {
+		return nil, fmt.Errorf("insufficient number of frames %d < 2", len(seq))
+	}
+	switch opt.Playback {
+	case "", PlaybackLoop, PlaybackOnce:
+	case PlaybackPingPong:
+		for i := len(seq) - 2; i > 0; i-- {
+			seq = append(seq, seq[i])
+		}
+	default:
+		return nil, fmt.Errorf("unknown playback mode %q, use %s, %s or %s", opt.Playback, PlaybackLoop, PlaybackPingPong, PlaybackOnce)
+	}
+	return seq, nil
+}
+
+// animationTransitions returns the transitions to play the sequence of frames according to opt, starting with the first frame shown.
+// Unless the playback mode is once, the last transition returns to the first frame.
+func animationTransitions(opt Options, n int) ([]transition, error) {
+	seq, err := animationSequence(opt, n)
+	if err != nil {
+		return nil, err
+	}
+	tt := make([]transition, 0, len(seq))
+	for i := 1; i < len(seq); i++ {
+		tt = append(tt, transition{seq[i-1], seq[i]})
+	}
+	if opt.Playback != PlaybackOnce {
+		tt = append(tt, transition{seq[len(seq)-1], seq[0]})
+	}
+	return tt, nil
+}
+
+// animationEnd returns the end of all frames marker, followed by the address the displayer continues at.
+// When looping, this is the first frame at start. When playing once, an empty frame holding the last frame is
+// added at start+length, hold is prepended to it for the charset animations with a background and border color per frame.
+func animationEnd(opt Options, start Word, length int, hold ...byte) []byte {
+	if opt.Playback != PlaybackOnce {
+		return []byte{0xff, start.Low(), start.High()}
+	}
+	addr := start + Word(length)
+	return append(hold, 0x00, 0x00, 0xff, addr.Low(), addr.High())
+}
+
+// isKeyframe returns true if the frame of transition j contains all chars, instead of only the differences
+// with the previous frame. This allows a player to seek to that frame.
+func isKeyframe(opt Options, j int) bool {
+	return opt.Keyframes > 0 && j%opt.Keyframes == 0
+}
+
+// frameOffsets returns the offsets of the frames, relative to the start of the first frame.
+func frameOffsets(frames [][]byte) []int {
+	offsets := make([]int, 0, len(frames))
+	offset := 0
+	for _, f := range frames {
+		offsets = append(offsets, offset)
+		offset += len(f)
+	}
+	return offsets
+}
+
+// frameTable returns the frame offset table of the frames at offsets from start.
+// The table contains the low bytes of all frame addresses, followed by the high bytes.
+func frameTable(start Word, offsets []int) []byte {
+	table := make([]byte, 2*len(offsets))
+	for i, offset := range offsets {
+		addr := start + Word(offset)
+		table[i] = addr.Low()
+		table[len(offsets)+i] = addr.High()
+	}
+	return table
+}
+
+// frameTableSymbols returns the symbols of the frame offset table at addr, with the frames at offsets from start.
+func frameTableSymbols(opt Options, addr, start Word, offsets []int) []c64Symbol {
+	symbols := []c64Symbol{
+		{"frames", len(offsets)},
+		{"keyframes", opt.Keyframes},
+		{"frametable_lo", int(addr)},
+		{"frametable_hi", int(addr) + len(offsets)},
+	}
+	for i, offset := range offsets {
+		symbols = append(symbols, c64Symbol{fmt.Sprintf("frame%d", i), int(start) + offset})
+	}
+	return symbols
+}
+
+// appendFrameTable appends the frame offset table to end, which follows the frames at start, if opt.Keyframes is set.
+// It also returns the symbols of the frame table.
+func appendFrameTable(opt Options, start Word, frames [][]byte, end []byte) ([]byte, []c64Symbol) {
+	if opt.Keyframes <= 0 {
+		return end, nil
+	}
+	offsets := frameOffsets(frames)
+	addr := start + Word(framesLength(frames)+len(end))
+	return append(end, frameTable(start, offsets)...), frameTableSymbols(opt, addr, start, offsets)
+}
+
 type Char interface {
 	Index() int
 	Bytes() []byte
@@ -567,8 +853,9 @@ func (c SingleColorChar) Bytes() (buf []byte) {
 }
 
 // processFramesOfChars creates a slice of byteslices, where each byteslice contains a frame of chunks in animation format.
+// Each frame ends with the frame delay from delays.
 // See readme for details on format.
-func processFramesOfChars(opt Options, frames [][]Char) ([][]byte, error) {
+func processFramesOfChars(opt Options, frames [][]Char, delays []byte) ([][]byte, error) {
 	if len(frames) < 2 {
 		return nil, fmt.Errorf("insufficient number of images %d < 2", len(frames))
 	}
@@ -584,7 +871,7 @@ func processFramesOfChars(opt Options, frames [][]Char) ([][]byte, error) {
 		prg := []byte{}
 		for _, char := range frame {
 			switch {
-			case curChar == char.Index()-1:
+			case curChar == char.Index()-1 && curChunk.charCount < maxChunkChars:
 				// next char of current chunk
 				curChunk.append(char)
 			default:
@@ -608,102 +895,196 @@ func processFramesOfChars(opt Options, frames [][]Char) ([][]byte, error) {
 			prg = append(prg, curChunk.export()...)
 		}
 
-		// end of chunk marker
-		prg = append(prg, 0x00)
+		// end of chunk marker and frame delay
+		prg = append(prg, 0x00, delays[i])
 		prgs = append(prgs, prg)
 	}
 	return prgs, nil
 }
 
-// processAnimation extracts the differences between the various imgs per char (single or multicolor).
+// processAnimation extracts the differences between the various imgs per char (single or multicolor), for each transition in tt.
+// Keyframes contain all chars, see isKeyframe.
 // returns the converted animation in slices of byteslices, where each byteslice contains a frame of chunks in animation format.
+// delays contains the number of frames to show each img, 0 uses the default frame delay of the displayer.
 // See readme for details on format.
-func processAnimation(opt Options, imgs []Charer) ([][]byte, error) {
+func processAnimation(opt Options, imgs []Charer, tt []transition, delays []byte) ([][]byte, error) {
 	if len(imgs) < 2 {
 		return nil, fmt.Errorf("insufficient number of frames %d < 2", len(imgs))
 	}
 	if opt.Verbose {
-		log.Printf("total number of frames: %d", len(imgs))
+		log.Printf("total number of frames: %d, transitions: %d", len(imgs), len(tt))
 	}
 
-	charFrames := make([][]Char, len(imgs))
-	for i := 0; i < len(imgs)-1; i++ {
-		charFrames[i] = []Char{}
+	charFrames := make([][]Char, len(tt))
+	frameDelays := make([]byte, len(tt))
+	for j, t := range tt {
+		charFrames[j] = []Char{}
+		frameDelays[j] = delays[t.to]
+		key := isKeyframe(opt, j)
+		for i := 0; i < 1000; i++ {
+			prevChar := imgs[t.from].Char(i)
+			frameChar := imgs[t.to].Char(i)
+			if key || prevChar != frameChar {
+				charFrames[j] = append(charFrames[j], frameChar)
+			}
+		}
+	}
+	prgs, err := processFramesOfChars(opt, charFrames, frameDelays)
+	if err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < 1000; i++ {
-		for j := 0; j < len(imgs); j++ {
-			k := len(imgs) - 1
-			if j > 0 {
-				k = j - 1
+	// use the masked encoding for frames where it is smaller, keyframes always contain whole chars.
+	for j, t := range tt {
+		if isKeyframe(opt, j) {
+			continue
+		}
+		masked := maskedFrame(imgs[t.from], imgs[t.to])
+		if len(masked)+2 < len(prgs[j]) {
+			if opt.Verbose {
+				log.Printf("frame %d: masked encoding %d bytes instead of %d", j, len(masked)+2, len(prgs[j]))
 			}
-			prevChar := imgs[k].Char(i)
-			frameChar := imgs[j].Char(i)
-			if prevChar != frameChar {
-				charFrames[j] = append(charFrames[j], frameChar)
+			prgs[j] = append(masked, 0x00, frameDelays[j])
+		}
+	}
+	return prgs, nil
+}
+
+const (
+	// maskedFrameMarker marks a frame in the masked encoding, instead of the first chunk.
+	maskedFrameMarker = 0xfe
+
+	maskedScreen   = 0x80
+	maskedColorRAM = 0x40
+	maskedRows     = 0x20
+	maskedMaxSkip  = 0x1f
+)
+
+// maskedFrame returns the changes from prev to cur in the masked encoding, without the end of frame and frame delay.
+// For each changed char a flags byte contains the number of unchanged chars skipped since the previous char
+// and which of the screenram, colorram and pixel rows changed, followed by the changed values only.
+// A flags byte of $1f skips 32 chars without changes.
+// See readme for details on format.
+func maskedFrame(prev, cur Charer) []byte {
+	buf := []byte{maskedFrameMarker}
+	skip := 0
+	for i := 0; i < FullScreenChars; i++ {
+		p, c := prev.Char(i).Bytes(), cur.Char(i).Bytes()
+		flags, rows := byte(0), byte(0)
+		data := []byte{}
+		if p[8] != c[8] {
+			flags |= maskedScreen
+			data = append(data, c[8])
+		}
+		if len(c) > 9 && p[9] != c[9] {
+			flags |= maskedColorRAM
+			data = append(data, c[9])
+		}
+		for row := 0; row < 8; row++ {
+			if p[row] != c[row] {
+				rows |= 0x80 >> row
 			}
 		}
+		if rows != 0 {
+			flags |= maskedRows
+			data = append(data, rows)
+			for row := 0; row < 8; row++ {
+				if p[row] != c[row] {
+					data = append(data, c[row])
+				}
+			}
+		}
+		if flags == 0 {
+			skip++
+			continue
+		}
+		for ; skip > maskedMaxSkip; skip -= maskedMaxSkip + 1 {
+			buf = append(buf, maskedMaxSkip)
+		}
+		buf = append(buf, flags|byte(skip))
+		buf = append(buf, data...)
+		skip = 0
 	}
-	return processFramesOfChars(opt, charFrames)
+	return buf
 }
 
 // WriteKoalaDisplayAnimTo processes kk and writes the converted animation and displayer to w.
 func WriteKoalaDisplayAnimTo(w io.Writer, kk []Koala) (n int64, err error) {
-	bgBorder := kk[0].BackgroundColor | kk[0].BorderColor<<4
-	opt := kk[0].opt
-
-	frames := makeCharer(kk)
-	framePrgs, err := processAnimation(opt, frames)
+	link, _, err := linkKoalaDisplayAnim(kk)
 	if err != nil {
 		return n, err
 	}
+	return link.WriteTo(w)
+}
+
+// linkKoalaDisplayAnim returns the Linker written by WriteKoalaDisplayAnimTo, and the symbols of the frame table.
+func linkKoalaDisplayAnim(kk []Koala) (link *Linker, symbols []c64Symbol, err error) {
+	opt := kk[0].opt
+	tt, err := animationTransitions(opt, len(kk))
+	if err != nil {
+		return nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+	}
+	k := kk[tt[0].from]
+	bgBorder := k.BackgroundColor | k.BorderColor<<4
+	picture := LinkMap{
+		BitmapAddress:                k.Bitmap[:],
+		BitmapScreenRAMAddress:       k.ScreenColor[:],
+		BitmapColorRAMAddress:        k.D800Color[:],
+		BitmapColorRAMAddress + 1000: {bgBorder},
+	}
 
 	displayer := koalaDisplayAnim
 	if opt.AlternativeFade {
 		displayer = koalaDisplayAnimAlternative
 	}
-	link := NewLinker(0, opt.VeryVerbose)
-	if _, err = link.WritePrg(displayer); err != nil {
-		return n, err
+	budget := animationBudget{displayer: displayer, picture: picture, start: koalaAnimationStart, fadeStart: koalaFadePassStart}
+	opt, framePrgs, err := fitAnimation(opt, budget, tt, func(tt []transition) ([][]byte, error) {
+		return processAnimation(opt, makeCharer(kk), tt, koalaFrameDelays(kk))
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	link.SetByte(DisplayerSettingsStart+7, byte(opt.FrameDelay), byte(opt.WaitSeconds), opt.NoFadeByte())
+
+	link = NewLinker(0, opt.VeryVerbose)
+	if _, err = link.WriteNamedPrg(displayer, "displayer"); err != nil {
+		return nil, nil, err
+	}
+	link.SetByte(DisplayerSettingsStart+9, byte(opt.FrameDelay), byte(opt.WaitSeconds), opt.NoFadeByte())
 	if !opt.NoFade {
 		link.Block(koalaFadePassStart, 0xd000)
+		link.Name(koalaFadePassStart, 0xd000, "fade")
 	}
 	if !opt.Quiet {
 		fmt.Printf("memory usage for displayer code: %s - %s\n", link.StartAddress(), link.EndAddress())
 	}
-	if _, err = link.WriteMap(LinkMap{
-		BitmapAddress:                kk[0].Bitmap[:],
-		BitmapScreenRAMAddress:       kk[0].ScreenColor[:],
-		BitmapColorRAMAddress:        kk[0].D800Color[:],
-		BitmapColorRAMAddress + 1000: {bgBorder},
-	}); err != nil {
-		return n, fmt.Errorf("link.WriteMap error: %w", err)
+	if _, err = link.WriteNamedMap(picture, defaultBitmapLayout.names(picture, "bitmap")); err != nil {
+		return nil, nil, fmt.Errorf("link.WriteMap error: %w", err)
 	}
 	if !opt.Quiet {
 		fmt.Printf("memory usage for picture: 0x%04x - %s\n", BitmapAddress, link.EndAddress())
 	}
 
 	link.SetCursor(koalaAnimationStart)
-	framePrgs = append(framePrgs, []byte{0xff})
+	end, symbols := appendFrameTable(opt, koalaAnimationStart, framePrgs, animationEnd(opt, koalaAnimationStart, framesLength(framePrgs)))
+	framePrgs = append(framePrgs, end)
 	for _, bin := range framePrgs {
 		if _, err = link.Write(bin); err != nil {
-			return n, fmt.Errorf("link.Write error: %w", err)
+			return nil, nil, fmt.Errorf("link.Write error: %w", err)
 		}
 	}
+	link.Name(koalaAnimationStart, link.Cursor(), "animation frames")
 
 	if !opt.Quiet {
 		fmt.Printf("memory usage for animations: %s - %s\n", Word(koalaAnimationStart), link.EndAddress())
 		fmt.Printf("memory usage for generated fadecode: %s - %s\n", Word(koalaFadePassStart), Word(0xcfff))
 	}
 
-	if err = injectSID(link, opt.IncludeSID, opt.Quiet); err != nil {
-		return n, fmt.Errorf("injectSID failed: %w", err)
+	link.Block(animationZeroPageStart, animationZeroPageEnd)
+	link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+	if err = injectSID(link, opt); err != nil {
+		return nil, nil, fmt.Errorf("injectSID failed: %w", err)
 	}
-	m, err := link.WriteTo(w)
-	n += int64(m)
-	return n, err
+	return link, symbols, nil
 }
 
 // exportAnims format:
@@ -717,32 +1098,54 @@ func WriteKoalaDisplayAnimTo(w io.Writer, kk []Koala) (n int64, err error) {
 
 // WriteHiresDisplayAnimTo processes hh and writes the converted animation and displayer to w.
 func WriteHiresDisplayAnimTo(w io.Writer, hh []Hires) (n int64, err error) {
+	link, _, err := linkHiresDisplayAnim(hh)
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// linkHiresDisplayAnim returns the Linker written by WriteHiresDisplayAnimTo, and the symbols of the frame table.
+func linkHiresDisplayAnim(hh []Hires) (link *Linker, symbols []c64Symbol, err error) {
 	opt := hh[0].opt
-	frames := makeCharer(hh)
-	framePrgs, err := processAnimation(opt, frames)
+	tt, err := animationTransitions(opt, len(hh))
 	if err != nil {
-		return n, fmt.Errorf("processAnimation error: %w", err)
+		return nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+	}
+	h := hh[tt[0].from]
+	budget := animationBudget{
+		displayer: hiresDisplayAnim,
+		picture:   LinkMap{BitmapAddress: h.Bitmap[:], BitmapScreenRAMAddress: h.ScreenColor[:], BitmapColorRAMAddress: {h.BorderColor}},
+		start:     hiresAnimationStart,
+		fadeStart: hiresFadePassStart,
+	}
+	opt, framePrgs, err := fitAnimation(opt, budget, tt, func(tt []transition) ([][]byte, error) {
+		return processAnimation(opt, makeCharer(hh), tt, hiresFrameDelays(hh))
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	link := NewLinker(0, opt.VeryVerbose)
-	if _, err = link.WritePrg(hiresDisplayAnim); err != nil {
-		return n, fmt.Errorf("link.WritePrg error: %w", err)
+	link = NewLinker(0, opt.VeryVerbose)
+	if _, err = link.WriteNamedPrg(hiresDisplayAnim, "displayer"); err != nil {
+		return nil, nil, fmt.Errorf("link.WritePrg error: %w", err)
 	}
 	if !opt.NoFade {
 		link.Block(hiresFadePassStart, 0xd000)
+		link.Name(hiresFadePassStart, 0xd000, "fade")
 	}
-	link.SetByte(DisplayerSettingsStart+7, byte(opt.FrameDelay), byte(opt.WaitSeconds), opt.NoFadeByte())
+	link.SetByte(DisplayerSettingsStart+9, byte(opt.FrameDelay), byte(opt.WaitSeconds), opt.NoFadeByte())
 	if !opt.Quiet {
 		fmt.Printf("memory usage for displayer code: %s - %s\n", link.StartAddress(), link.EndAddress())
 	}
 
 	link.SetCursor(BitmapAddress)
-	h := hh[0]
 	for _, b := range [][]byte{h.Bitmap[:], h.ScreenColor[:], {h.BorderColor}} {
 		if _, err = link.Write(b); err != nil {
-			return n, fmt.Errorf("link.Write error: %w", err)
+			return nil, nil, fmt.Errorf("link.Write error: %w", err)
 		}
 	}
+	link.NameMap(budget.picture, defaultBitmapLayout.names(budget.picture, "bitmap"))
 	if !opt.Quiet {
 		fmt.Printf("memory usage for picture: %#04x - %s\n", BitmapAddress, link.EndAddress())
 	}
@@ -750,26 +1153,32 @@ func WriteHiresDisplayAnimTo(w io.Writer, hh []Hires) (n int64, err error) {
 	link.SetCursor(hiresAnimationStart)
 	for _, bin := range framePrgs {
 		if _, err = link.Write(bin); err != nil {
-			return n, fmt.Errorf("link.Write error: %w", err)
+			return nil, nil, fmt.Errorf("link.Write error: %w", err)
 		}
 	}
-	if _, err = link.Write([]byte{0xff}); err != nil {
-		return n, fmt.Errorf("link.Write error: %w", err)
+	end, symbols := appendFrameTable(opt, hiresAnimationStart, framePrgs, animationEnd(opt, hiresAnimationStart, framesLength(framePrgs)))
+	if _, err = link.Write(end); err != nil {
+		return nil, nil, fmt.Errorf("link.Write error: %w", err)
 	}
+	link.Name(hiresAnimationStart, link.Cursor(), "animation frames")
 	if !opt.Quiet {
 		fmt.Printf("memory usage for animations: %#04x - %s\n", hiresAnimationStart, link.EndAddress())
 		fmt.Printf("memory usage for generated fadecode: %#04x - %#04x\n", hiresFadePassStart, 0xcfff)
 	}
 
-	if err = injectSID(link, opt.IncludeSID, opt.Quiet); err != nil {
-		return n, fmt.Errorf("injectSID failed: %w", err)
+	link.Block(animationZeroPageStart, animationZeroPageEnd)
+	link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+	if err = injectSID(link, opt); err != nil {
+		return nil, nil, fmt.Errorf("injectSID failed: %w", err)
 	}
 
-	m, err := link.WriteTo(w)
-	n += int64(m)
-	return n, err
+	return link, symbols, nil
 }
 
+// maxChunkChars is the maximum number of chars in a chunk, as a chunk count of $ff marks the end of all frames
+// and $fe a frame in the masked encoding.
+const maxChunkChars = 0xfd
+
 type chunk struct {
 	charIndex int
 	charCount byte
@@ -831,41 +1240,106 @@ func (h Hires) Char(charIndex int) Char {
 	return c
 }
 
+// keepUnusedColors copies the colors of the bitpairs not used by a char from prev.
+// Unchanged chars stay equal to the previous animation frame, also when the bitpair colors of the frames differ.
+func (k *Koala) keepUnusedColors(prev Koala) {
+	for char := 0; char < FullScreenChars; char++ {
+		used := [4]bool{}
+		for _, b := range k.Bitmap[char*8 : char*8+8] {
+			for shift := 0; shift < 8; shift += 2 {
+				used[(b>>shift)&3] = true
+			}
+		}
+		if !used[1] {
+			k.ScreenColor[char] = k.ScreenColor[char]&0x0f | prev.ScreenColor[char]&0xf0
+		}
+		if !used[2] {
+			k.ScreenColor[char] = k.ScreenColor[char]&0xf0 | prev.ScreenColor[char]&0x0f
+		}
+		if !used[3] {
+			k.D800Color[char] = prev.D800Color[char]
+		}
+	}
+}
+
+// keepUnusedColors copies the colors of the bitpairs not used by a char from prev.
+// Unchanged chars stay equal to the previous animation frame, also when the bitpair colors of the frames differ.
+func (h *Hires) keepUnusedColors(prev Hires) {
+	for char := 0; char < FullScreenChars; char++ {
+		or, and := byte(0), byte(0xff)
+		for _, b := range h.Bitmap[char*8 : char*8+8] {
+			or |= b
+			and &= b
+		}
+		if or == 0 {
+			h.ScreenColor[char] = h.ScreenColor[char]&0x0f | prev.ScreenColor[char]&0xf0
+		}
+		if and == 0xff {
+			h.ScreenColor[char] = h.ScreenColor[char]&0xf0 | prev.ScreenColor[char]&0x0f
+		}
+	}
+}
+
 // WriteMultiColorCharsetAnimationTo writes the MultiColorCharsets to w, optionally with displayer code.
 func WriteMultiColorCharsetAnimationTo(w io.Writer, cc []MultiColorCharset) (n int64, err error) {
+	link, _, err := linkMultiColorCharsetAnimation(cc)
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// charsetAnimationNames names the data blocks of charset animations in the memory map.
+var charsetAnimationNames = map[Word]string{
+	0x2000: "charset",
+	0x2800: "screen",
+	0x2c00: "colorram",
+	0x2fe8: "colors",
+	0x3c00: "colorram",
+	0x3fe8: "colors",
+	0x4000: "charset",
+}
+
+// linkMultiColorCharsetAnimation returns the Linker written by WriteMultiColorCharsetAnimationTo, and the symbols of the frame table.
+func linkMultiColorCharsetAnimation(cc []MultiColorCharset) (link *Linker, symbols []c64Symbol, err error) {
 	if len(cc) < 2 {
-		return n, fmt.Errorf("not enough images %d < 2", len(cc))
+		return nil, nil, fmt.Errorf("not enough images %d < 2", len(cc))
 	}
 	opt := cc[0].opt
-	var link *Linker
 	displayer := mcCharsetDisplayMulti
 	if opt.NoAnimation {
 		link = NewLinker(0x3c00, opt.VeryVerbose)
-		_, err = link.WriteMap(LinkMap{
+		_, err = link.WriteNamedMap(LinkMap{
 			0x3c00: cc[0].D800Color[:],
 			0x3fe8: []byte{cc[0].BorderColor, cc[0].BackgroundColor, cc[0].D022Color, cc[0].D023Color, byte(len(cc)) & 0xff},
 			0x4000: cc[len(cc)-1].Bitmap[:],
-		})
+		}, charsetAnimationNames)
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 		for i := 0; i < len(cc); i++ {
 			_, err = link.WriteMap(LinkMap{0x4800 + Word(i)*0x400: cc[i].Screen[:]})
 			if err != nil {
-				return n, fmt.Errorf("link.WriteMap failed: %w", err)
+				return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 			}
 		}
+		link.Name(0x4800, link.Cursor(), "frames")
 	} else {
 		displayer = mcCharsetDisplayAnim
+		tt, err := animationTransitions(opt, len(cc))
+		if err != nil {
+			return nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+		}
+		first := cc[tt[0].from]
 		link = NewLinker(0x2000, opt.VeryVerbose)
-		_, err = link.WriteMap(LinkMap{
+		_, err = link.WriteNamedMap(LinkMap{
 			0x2000: cc[len(cc)-1].Bitmap[:],
-			0x2800: cc[0].Screen[:],
-			0x2c00: cc[0].D800Color[:],
-			0x2fe8: []byte{cc[0].BorderColor, cc[0].BackgroundColor, cc[0].D022Color, cc[0].D023Color},
-		})
+			0x2800: first.Screen[:],
+			0x2c00: first.D800Color[:],
+			0x2fe8: []byte{first.BorderColor, first.BackgroundColor, first.D022Color, first.D023Color},
+		}, charsetAnimationNames)
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 		buf := []byte{}
 		curChunk := charChunk{charIndex: -10}
@@ -883,25 +1357,30 @@ func WriteMultiColorCharsetAnimationTo(w io.Writer, cc []MultiColorCharset) (n i
 				curChunk = charChunk{charIndex: -10}
 			}
 		}
-		cc = append(cc, cc[0]) // for clean loop
-		for i := 1; i < len(cc); i++ {
+		offsets := make([]int, 0, len(tt))
+		for j, t := range tt {
+			offsets = append(offsets, len(buf))
+			key := isKeyframe(opt, j)
+			prev, cur := cc[t.from], cc[t.to]
 			for char := 0; char < FullScreenChars; char++ {
-				if cc[i].Screen[char] != cc[i-1].Screen[char] || cc[i].D800Color[char] != cc[i-1].D800Color[char] {
+				if key || cur.Screen[char] != prev.Screen[char] || cur.D800Color[char] != prev.D800Color[char] {
 					if opt.VeryVerbose {
-						log.Printf("%d %d: cc.Screen[char] = %d | prevscreen[char] = %d", i, char, cc[i].Screen[char], cc[i-1].Screen[char])
-						log.Printf("%d %d: cc.D800Color[char] = %d | prevcolram[char] = %d", i, char, cc[i].D800Color[char], cc[i-1].D800Color[char])
+						log.Printf("%d %d: cc.Screen[char] = %d | prevscreen[char] = %d", t.to, char, cur.Screen[char], prev.Screen[char])
+						log.Printf("%d %d: cc.D800Color[char] = %d | prevcolram[char] = %d", t.to, char, cur.D800Color[char], prev.D800Color[char])
 					}
 					if curChunk.charCount == 0 {
 						curChunk = charChunk{
 							charIndex: char,
 							charCount: 1,
-							bytes:     []byte{cc[i].Screen[char], cc[i].D800Color[char]},
+							bytes:     []byte{cur.Screen[char], cur.D800Color[char]},
 						}
 					} else {
-						curChunk.bytes = append(curChunk.bytes, cc[i].Screen[char], cc[i].D800Color[char])
+						curChunk.bytes = append(curChunk.bytes, cur.Screen[char], cur.D800Color[char])
 						curChunk.charCount++
-						if curChunk.charCount > 254 {
-							log.Printf("large chunck detected (%d chars), flushing...", curChunk.charCount)
+						if curChunk.charCount == maxChunkChars {
+							if opt.Verbose {
+								log.Printf("large chunk detected (%d chars), flushing...", curChunk.charCount)
+							}
 							flushChunk()
 						}
 					}
@@ -910,12 +1389,16 @@ func WriteMultiColorCharsetAnimationTo(w io.Writer, cc []MultiColorCharset) (n i
 				flushChunk()
 			}
 			flushChunk()
-			buf = append(buf, 0x00) // end of chunks and frame
+			buf = append(buf, 0x00, byte(cur.opt.FrameDelay)) // end of chunks and frame, frame delay
+		}
+		buf = append(buf, animationEnd(opt, 0x3000, len(buf))...) // end of frames
+		if opt.Keyframes > 0 {
+			symbols = frameTableSymbols(opt, 0x3000+Word(len(buf)), 0x3000, offsets)
+			buf = append(buf, frameTable(0x3000, offsets)...)
 		}
-		buf = append(buf, 0xff) // end of frames
-		_, err = link.WriteMap(LinkMap{0x3000: buf})
+		_, err = link.WriteNamedMap(LinkMap{0x3000: buf}, map[Word]string{0x3000: "animation frames"})
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 		if opt.Verbose {
 			log.Printf("flushed %d chunks, %d chars", flushedtotal, flushedchartotal)
@@ -923,15 +1406,17 @@ func WriteMultiColorCharsetAnimationTo(w io.Writer, cc []MultiColorCharset) (n i
 	}
 
 	if opt.Display {
-		if _, err = link.WritePrg(displayer); err != nil {
-			return n, fmt.Errorf("link.WritePrg failed: %w", err)
+		if _, err = link.WriteNamedPrg(displayer, "displayer"); err != nil {
+			return nil, nil, fmt.Errorf("link.WritePrg failed: %w", err)
 		}
-		link.SetByte(DisplayerSettingsStart+7, byte(cc[0].opt.FrameDelay), byte(cc[0].opt.WaitSeconds))
-		if err = injectSID(link, opt.IncludeSID, opt.Quiet); err != nil {
-			return n, fmt.Errorf("injectSID failed: %w", err)
+		link.SetByte(DisplayerSettingsStart+9, byte(cc[0].opt.FrameDelay), byte(cc[0].opt.WaitSeconds))
+		link.Block(animationZeroPageStart, animationZeroPageEnd)
+		link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+		if err = injectSID(link, opt); err != nil {
+			return nil, nil, fmt.Errorf("injectSID failed: %w", err)
 		}
 	}
-	return link.WriteTo(w)
+	return link, symbols, nil
 }
 
 type charChunk struct {
@@ -957,20 +1442,28 @@ func newCharChunk(charIndex int) charChunk {
 
 // WriteSingleColorCharsetAnimationTo writes the SingleColorCharset to w, optionally with displayer code.
 func WriteSingleColorCharsetAnimationTo(w io.Writer, cc []SingleColorCharset) (n int64, err error) {
+	link, _, err := linkSingleColorCharsetAnimation(cc)
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// linkSingleColorCharsetAnimation returns the Linker written by WriteSingleColorCharsetAnimationTo, and the symbols of the frame table.
+func linkSingleColorCharsetAnimation(cc []SingleColorCharset) (link *Linker, symbols []c64Symbol, err error) {
 	if len(cc) < 2 {
-		return n, fmt.Errorf("not enough images %d < 2", len(cc))
+		return nil, nil, fmt.Errorf("not enough images %d < 2", len(cc))
 	}
 	opt := cc[0].opt
-	var link *Linker
 	displayer := scCharsetDisplayMulti
 	if opt.NoAnimation {
 		link = NewLinker(0x3fe8, opt.VeryVerbose)
-		_, err = link.WriteMap(LinkMap{
+		_, err = link.WriteNamedMap(LinkMap{
 			0x3fe8: []byte{cc[0].BorderColor, cc[0].BackgroundColor, byte(len(cc)) & 0xff},
 			0x4000: cc[len(cc)-1].Bitmap[:],
-		})
+		}, charsetAnimationNames)
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 		for i := 0; i < len(cc); i++ {
 			_, err = link.WriteMap(LinkMap{
@@ -979,23 +1472,28 @@ func WriteSingleColorCharsetAnimationTo(w io.Writer, cc []SingleColorCharset) (n
 				0x4c00 + Word(i)*0x800: cc[i].D800Color[:],
 			})
 			if err != nil {
-				return n, fmt.Errorf("link.WriteMap failed: %w", err)
+				return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 			}
 		}
+		link.Name(0x4800, link.Cursor(), "frames")
 	} else {
 		displayer = scCharsetDisplayAnim
+		tt, err := animationTransitions(opt, len(cc))
+		if err != nil {
+			return nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+		}
+		first := cc[tt[0].from]
 		link = NewLinker(0x2000, cc[0].opt.VeryVerbose)
-		_, err = link.WriteMap(LinkMap{
+		_, err = link.WriteNamedMap(LinkMap{
 			0x2000: cc[len(cc)-1].Bitmap[:],
-			0x2800: cc[0].Screen[:],
-			0x2c00: cc[0].D800Color[:],
-			0x2fe8: []byte{cc[0].BorderColor, cc[0].BackgroundColor},
-		})
+			0x2800: first.Screen[:],
+			0x2c00: first.D800Color[:],
+			0x2fe8: []byte{first.BorderColor, first.BackgroundColor},
+		}, charsetAnimationNames)
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 
-		cc = append(cc, cc[0]) // for clean loop
 		buf := []byte{}
 		curChunk := charChunk{charIndex: -10}
 		flushedtotal := 0
@@ -1012,25 +1510,31 @@ func WriteSingleColorCharsetAnimationTo(w io.Writer, cc []SingleColorCharset) (n
 				curChunk = charChunk{charIndex: -10}
 			}
 		}
-		for i := 1; i < len(cc); i++ {
-			buf = append(buf, cc[i].BackgroundColor|cc[i].BorderColor<<4) // bgBorder
+		offsets := make([]int, 0, len(tt))
+		for j, t := range tt {
+			offsets = append(offsets, len(buf))
+			key := isKeyframe(opt, j)
+			prev, cur := cc[t.from], cc[t.to]
+			buf = append(buf, cur.BackgroundColor|cur.BorderColor<<4) // bgBorder
 			for char := 0; char < FullScreenChars; char++ {
-				if cc[i].Screen[char] != cc[i-1].Screen[char] || cc[i].D800Color[char] != cc[i-1].D800Color[char] {
+				if key || cur.Screen[char] != prev.Screen[char] || cur.D800Color[char] != prev.D800Color[char] {
 					if cc[0].opt.VeryVerbose {
-						log.Printf("%d %d: cc.Screen[char] = %d | prevscreen[char] = %d", i, char, cc[i].Screen[char], cc[i-1].Screen[char])
-						log.Printf("%d %d: cc.D800Color[char] = %d | prevcolram[char] = %d", i, char, cc[i].D800Color[char], cc[i-1].D800Color[char])
+						log.Printf("%d %d: cc.Screen[char] = %d | prevscreen[char] = %d", t.to, char, cur.Screen[char], prev.Screen[char])
+						log.Printf("%d %d: cc.D800Color[char] = %d | prevcolram[char] = %d", t.to, char, cur.D800Color[char], prev.D800Color[char])
 					}
 					if curChunk.charCount == 0 {
 						curChunk = charChunk{
 							charIndex: char,
 							charCount: 1,
-							bytes:     []byte{cc[i].Screen[char], cc[i].D800Color[char]},
+							bytes:     []byte{cur.Screen[char], cur.D800Color[char]},
 						}
 					} else {
-						curChunk.bytes = append(curChunk.bytes, cc[i].Screen[char], cc[i].D800Color[char])
+						curChunk.bytes = append(curChunk.bytes, cur.Screen[char], cur.D800Color[char])
 						curChunk.charCount++
-						if curChunk.charCount > 254 {
-							log.Printf("large chunck detected (%d chars), flushing...", curChunk.charCount)
+						if curChunk.charCount == maxChunkChars {
+							if opt.Verbose {
+								log.Printf("large chunk detected (%d chars), flushing...", curChunk.charCount)
+							}
 							flushChunk()
 						}
 					}
@@ -1039,12 +1543,17 @@ func WriteSingleColorCharsetAnimationTo(w io.Writer, cc []SingleColorCharset) (n
 				flushChunk()
 			}
 			flushChunk()
-			buf = append(buf, 0x00) // end of chunks and frame
+			buf = append(buf, 0x00, byte(cur.opt.FrameDelay)) // end of chunks and frame, frame delay
+		}
+		last := cc[tt[len(tt)-1].to]
+		buf = append(buf, animationEnd(opt, 0x3000, len(buf), last.BackgroundColor|last.BorderColor<<4)...) // end of frames
+		if opt.Keyframes > 0 {
+			symbols = frameTableSymbols(opt, 0x3000+Word(len(buf)), 0x3000, offsets)
+			buf = append(buf, frameTable(0x3000, offsets)...)
 		}
-		buf = append(buf, 0xff) // end of frames
-		_, err = link.WriteMap(LinkMap{0x3000: buf})
+		_, err = link.WriteNamedMap(LinkMap{0x3000: buf}, map[Word]string{0x3000: "animation frames"})
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 		if cc[0].opt.Verbose {
 			log.Printf("flushed %d chunks, %d chars", flushedtotal, flushedchartotal)
@@ -1052,36 +1561,52 @@ func WriteSingleColorCharsetAnimationTo(w io.Writer, cc []SingleColorCharset) (n
 	}
 
 	if cc[0].opt.Display {
-		if _, err = link.WritePrg(displayer); err != nil {
-			return n, fmt.Errorf("link.WritePrg failed: %w", err)
+		if _, err = link.WriteNamedPrg(displayer, "displayer"); err != nil {
+			return nil, nil, fmt.Errorf("link.WritePrg failed: %w", err)
 		}
-		link.SetByte(DisplayerSettingsStart+7, byte(cc[0].opt.FrameDelay), byte(cc[0].opt.WaitSeconds), byte(cc[0].opt.NoFadeByte()))
+		link.SetByte(DisplayerSettingsStart+9, byte(cc[0].opt.FrameDelay), byte(cc[0].opt.WaitSeconds), byte(cc[0].opt.NoFadeByte()))
 		if !opt.NoFade {
 			link.Block(hiresFadePassStart, 0xcfff)
+			link.Name(hiresFadePassStart, 0xcfff, "fade")
 		}
-		if err = injectSID(link, cc[0].opt.IncludeSID, cc[0].opt.Quiet); err != nil {
-			return n, fmt.Errorf("injectSID failed: %w", err)
+		link.Block(animationZeroPageStart, animationZeroPageEnd)
+		link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+		if err = injectSID(link, cc[0].opt); err != nil {
+			return nil, nil, fmt.Errorf("injectSID failed: %w", err)
 		}
 	}
-	return link.WriteTo(w)
+	return link, symbols, nil
 }
 
 // WritePETSCIICharsetAnimationTo writes the PETSCIICharset to w, optionally with displayer code.
 func WritePETSCIICharsetAnimationTo(w io.Writer, cc []PETSCIICharset) (n int64, err error) {
+	link, _, err := linkPETSCIICharsetAnimation(cc)
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// linkPETSCIICharsetAnimation returns the Linker written by WritePETSCIICharsetAnimationTo, and the symbols of the frame table.
+func linkPETSCIICharsetAnimation(cc []PETSCIICharset) (link *Linker, symbols []c64Symbol, err error) {
 	if len(cc) < 2 {
-		return n, fmt.Errorf("not enough images %d < 2", len(cc))
+		return nil, nil, fmt.Errorf("not enough images %d < 2", len(cc))
 	}
-	link := NewLinker(0x2000, cc[0].opt.VeryVerbose)
-	_, err = link.WriteMap(LinkMap{
-		0x2800: cc[0].Screen[:],
-		0x2c00: cc[0].D800Color[:],
-		0x2fe8: []byte{cc[0].BorderColor, cc[0].BackgroundColor},
-	})
+	tt, err := animationTransitions(cc[0].opt, len(cc))
+	if err != nil {
+		return nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+	}
+	first := cc[tt[0].from]
+	link = NewLinker(0x2000, cc[0].opt.VeryVerbose)
+	_, err = link.WriteNamedMap(LinkMap{
+		0x2800: first.Screen[:],
+		0x2c00: first.D800Color[:],
+		0x2fe8: []byte{first.BorderColor, first.BackgroundColor},
+	}, charsetAnimationNames)
 	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+		return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 	}
 
-	cc = append(cc, cc[0]) // for clean loop
 	pos := Word(0x3000)
 	buf := []byte{}
 	curChunk := charChunk{charIndex: -10}
@@ -1099,25 +1624,31 @@ func WritePETSCIICharsetAnimationTo(w io.Writer, cc []PETSCIICharset) (n int64,
 			curChunk = charChunk{charIndex: -10}
 		}
 	}
-	for i := 1; i < len(cc); i++ {
-		buf = append(buf, cc[i].BackgroundColor|cc[i].BorderColor<<4) // bgBorder
+	offsets := make([]int, 0, len(tt))
+	for j, t := range tt {
+		offsets = append(offsets, len(buf))
+		key := isKeyframe(cc[0].opt, j)
+		prev, cur := cc[t.from], cc[t.to]
+		buf = append(buf, cur.BackgroundColor|cur.BorderColor<<4) // bgBorder
 		for char := 0; char < FullScreenChars; char++ {
-			if cc[i].Screen[char] != cc[i-1].Screen[char] || cc[i].D800Color[char] != cc[i-1].D800Color[char] {
+			if key || cur.Screen[char] != prev.Screen[char] || cur.D800Color[char] != prev.D800Color[char] {
 				if cc[0].opt.VeryVerbose {
-					log.Printf("%d %d: cc.Screen[char] = %d | prevscreen[char] = %d", i, char, cc[i].Screen[char], cc[i-1].Screen[char])
-					log.Printf("%d %d: cc.D800Color[char] = %d | prevcolram[char] = %d", i, char, cc[i].D800Color[char], cc[i-1].D800Color[char])
+					log.Printf("%d %d: cc.Screen[char] = %d | prevscreen[char] = %d", t.to, char, cur.Screen[char], prev.Screen[char])
+					log.Printf("%d %d: cc.D800Color[char] = %d | prevcolram[char] = %d", t.to, char, cur.D800Color[char], prev.D800Color[char])
 				}
 				if curChunk.charCount == 0 {
 					curChunk = charChunk{
 						charIndex: char,
 						charCount: 1,
-						bytes:     []byte{cc[i].Screen[char], cc[i].D800Color[char]},
+						bytes:     []byte{cur.Screen[char], cur.D800Color[char]},
 					}
 				} else {
-					curChunk.bytes = append(curChunk.bytes, cc[i].Screen[char], cc[i].D800Color[char])
+					curChunk.bytes = append(curChunk.bytes, cur.Screen[char], cur.D800Color[char])
 					curChunk.charCount++
-					if curChunk.charCount > 254 {
-						log.Printf("large chunck detected (%d chars), flushing...", curChunk.charCount)
+					if curChunk.charCount == maxChunkChars {
+						if cc[0].opt.Verbose {
+							log.Printf("large chunk detected (%d chars), flushing...", curChunk.charCount)
+						}
 						flushChunk()
 					}
 				}
@@ -1126,48 +1657,64 @@ func WritePETSCIICharsetAnimationTo(w io.Writer, cc []PETSCIICharset) (n int64,
 			flushChunk()
 		}
 		flushChunk()
-		buf = append(buf, 0x00) // end of chunks and frame
+		buf = append(buf, 0x00, byte(cur.opt.FrameDelay)) // end of chunks and frame, frame delay
+	}
+	last := cc[tt[len(tt)-1].to]
+	buf = append(buf, animationEnd(cc[0].opt, 0x3000, len(buf), last.BackgroundColor|last.BorderColor<<4)...) // end of frames
+	if cc[0].opt.Keyframes > 0 {
+		symbols = frameTableSymbols(cc[0].opt, 0x3000+Word(len(buf)), 0x3000, offsets)
+		buf = append(buf, frameTable(0x3000, offsets)...)
 	}
-	buf = append(buf, 0xff) // end of frames
-	_, err = link.WriteMap(LinkMap{pos: buf})
+	_, err = link.WriteNamedMap(LinkMap{pos: buf}, map[Word]string{pos: "animation frames"})
 	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+		return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 	}
 	if cc[0].opt.Verbose {
 		log.Printf("flushed %d chunks, %d chars", flushedtotal, flushedchartotal)
 	}
 
 	if cc[0].opt.Display {
-		if _, err = link.WritePrg(petsciiCharsetDisplayAnim); err != nil {
-			return n, fmt.Errorf("link.WritePrg failed: %w", err)
+		if _, err = link.WriteNamedPrg(petsciiCharsetDisplayAnim, "displayer"); err != nil {
+			return nil, nil, fmt.Errorf("link.WritePrg failed: %w", err)
 		}
-		link.SetByte(DisplayerSettingsStart+7, byte(cc[0].Lowercase), byte(cc[0].opt.FrameDelay), byte(cc[0].opt.WaitSeconds), cc[0].opt.NoFadeByte())
+		link.SetByte(DisplayerSettingsStart+9, byte(cc[0].Lowercase), byte(cc[0].opt.FrameDelay), byte(cc[0].opt.WaitSeconds), cc[0].opt.NoFadeByte())
 		if !cc[0].opt.NoFade {
 			link.Block(hiresFadePassStart, 0xcfff)
+			link.Name(hiresFadePassStart, 0xcfff, "fade")
 		}
-		if err = injectSID(link, cc[0].opt.IncludeSID, cc[0].opt.Quiet); err != nil {
-			return n, fmt.Errorf("injectSID failed: %w", err)
+		link.Block(animationZeroPageStart, animationZeroPageEnd)
+		link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+		if err = injectSID(link, cc[0].opt); err != nil {
+			return nil, nil, fmt.Errorf("injectSID failed: %w", err)
 		}
 	}
-	return link.WriteTo(w)
+	return link, symbols, nil
 }
 
 // WriteMixedCharsetAnimationTo writes the MixedCharset to w, optionally with displayer code.
 func WriteMixedCharsetAnimationTo(w io.Writer, cc []MixedCharset) (n int64, err error) {
+	link, _, err := linkMixedCharsetAnimation(cc)
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// linkMixedCharsetAnimation returns the Linker written by WriteMixedCharsetAnimationTo, and the symbols of the frame table.
+func linkMixedCharsetAnimation(cc []MixedCharset) (link *Linker, symbols []c64Symbol, err error) {
 	if len(cc) < 2 {
-		return n, fmt.Errorf("not enough images %d < 2", len(cc))
+		return nil, nil, fmt.Errorf("not enough images %d < 2", len(cc))
 	}
 	opt := cc[0].opt
-	var link *Linker
 	displayer := mcCharsetDisplayMulti
 	if opt.NoAnimation {
 		link = NewLinker(0x3fe8, opt.VeryVerbose)
-		_, err = link.WriteMap(LinkMap{
+		_, err = link.WriteNamedMap(LinkMap{
 			0x3fe8: []byte{cc[0].BorderColor, cc[0].BackgroundColor, byte(len(cc)) & 0xff},
 			0x4000: cc[len(cc)-1].Bitmap[:],
-		})
+		}, charsetAnimationNames)
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 		for i := 0; i < len(cc); i++ {
 			_, err = link.WriteMap(LinkMap{
@@ -1175,23 +1722,28 @@ func WriteMixedCharsetAnimationTo(w io.Writer, cc []MixedCharset) (n int64, err
 				0x4c00 + Word(i)*0x800: cc[i].D800Color[:],
 			})
 			if err != nil {
-				return n, fmt.Errorf("link.WriteMap failed: %w", err)
+				return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 			}
 		}
+		link.Name(0x4800, link.Cursor(), "frames")
 	} else {
 		displayer = mcCharsetDisplayAnim
+		tt, err := animationTransitions(opt, len(cc))
+		if err != nil {
+			return nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+		}
+		first := cc[tt[0].from]
 		link = NewLinker(0x2000, cc[0].opt.VeryVerbose)
-		_, err = link.WriteMap(LinkMap{
+		_, err = link.WriteNamedMap(LinkMap{
 			0x2000: cc[len(cc)-1].Bitmap[:],
-			0x2800: cc[0].Screen[:],
-			0x2c00: cc[0].D800Color[:],
-			0x2fe8: []byte{cc[0].BorderColor, cc[0].BackgroundColor},
-		})
+			0x2800: first.Screen[:],
+			0x2c00: first.D800Color[:],
+			0x2fe8: []byte{first.BorderColor, first.BackgroundColor},
+		}, charsetAnimationNames)
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 
-		cc = append(cc, cc[0]) // for clean loop
 		buf := []byte{}
 		curChunk := charChunk{charIndex: -10}
 		flushedtotal := 0
@@ -1208,24 +1760,30 @@ func WriteMixedCharsetAnimationTo(w io.Writer, cc []MixedCharset) (n int64, err
 				curChunk = charChunk{charIndex: -10}
 			}
 		}
-		for i := 1; i < len(cc); i++ {
+		offsets := make([]int, 0, len(tt))
+		for j, t := range tt {
+			offsets = append(offsets, len(buf))
+			key := isKeyframe(opt, j)
+			prev, cur := cc[t.from], cc[t.to]
 			for char := 0; char < FullScreenChars; char++ {
-				if cc[i].Screen[char] != cc[i-1].Screen[char] || cc[i].D800Color[char] != cc[i-1].D800Color[char] {
+				if key || cur.Screen[char] != prev.Screen[char] || cur.D800Color[char] != prev.D800Color[char] {
 					if cc[0].opt.VeryVerbose {
-						log.Printf("%d %d: cc.Screen[char] = %d | prevscreen[char] = %d", i, char, cc[i].Screen[char], cc[i-1].Screen[char])
-						log.Printf("%d %d: cc.D800Color[char] = %d | prevcolram[char] = %d", i, char, cc[i].D800Color[char], cc[i-1].D800Color[char])
+						log.Printf("%d %d: cc.Screen[char] = %d | prevscreen[char] = %d", t.to, char, cur.Screen[char], prev.Screen[char])
+						log.Printf("%d %d: cc.D800Color[char] = %d | prevcolram[char] = %d", t.to, char, cur.D800Color[char], prev.D800Color[char])
 					}
 					if curChunk.charCount == 0 {
 						curChunk = charChunk{
 							charIndex: char,
 							charCount: 1,
-							bytes:     []byte{cc[i].Screen[char], cc[i].D800Color[char]},
+							bytes:     []byte{cur.Screen[char], cur.D800Color[char]},
 						}
 					} else {
-						curChunk.bytes = append(curChunk.bytes, cc[i].Screen[char], cc[i].D800Color[char])
+						curChunk.bytes = append(curChunk.bytes, cur.Screen[char], cur.D800Color[char])
 						curChunk.charCount++
-						if curChunk.charCount > 254 {
-							log.Printf("large chunck detected (%d chars), flushing...", curChunk.charCount)
+						if curChunk.charCount == maxChunkChars {
+							if opt.Verbose {
+								log.Printf("large chunk detected (%d chars), flushing...", curChunk.charCount)
+							}
 							flushChunk()
 						}
 					}
@@ -1234,12 +1792,16 @@ func WriteMixedCharsetAnimationTo(w io.Writer, cc []MixedCharset) (n int64, err
 				flushChunk()
 			}
 			flushChunk()
-			buf = append(buf, 0x00) // end of chunks and frame
+			buf = append(buf, 0x00, byte(cur.opt.FrameDelay)) // end of chunks and frame, frame delay
+		}
+		buf = append(buf, animationEnd(opt, 0x3000, len(buf))...) // end of frames
+		if opt.Keyframes > 0 {
+			symbols = frameTableSymbols(opt, 0x3000+Word(len(buf)), 0x3000, offsets)
+			buf = append(buf, frameTable(0x3000, offsets)...)
 		}
-		buf = append(buf, 0xff) // end of frames
-		_, err = link.WriteMap(LinkMap{0x3000: buf})
+		_, err = link.WriteNamedMap(LinkMap{0x3000: buf}, map[Word]string{0x3000: "animation frames"})
 		if err != nil {
-			return n, fmt.Errorf("link.WriteMap failed: %w", err)
+			return nil, nil, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
 		if cc[0].opt.Verbose {
 			log.Printf("flushed %d chunks, %d chars", flushedtotal, flushedchartotal)
@@ -1247,16 +1809,19 @@ func WriteMixedCharsetAnimationTo(w io.Writer, cc []MixedCharset) (n int64, err
 	}
 
 	if cc[0].opt.Display {
-		if _, err = link.WritePrg(displayer); err != nil {
-			return n, fmt.Errorf("link.WritePrg failed: %w", err)
+		if _, err = link.WriteNamedPrg(displayer, "displayer"); err != nil {
+			return nil, nil, fmt.Errorf("link.WritePrg failed: %w", err)
 		}
-		link.SetByte(DisplayerSettingsStart+7, byte(cc[0].opt.FrameDelay), byte(cc[0].opt.WaitSeconds))
+		link.SetByte(DisplayerSettingsStart+9, byte(cc[0].opt.FrameDelay), byte(cc[0].opt.WaitSeconds))
 		link.Block(hiresFadePassStart, 0xcfff)
-		if err = injectSID(link, cc[0].opt.IncludeSID, cc[0].opt.Quiet); err != nil {
-			return n, fmt.Errorf("injectSID failed: %w", err)
+		link.Name(hiresFadePassStart, 0xcfff, "fade")
+		link.Block(animationZeroPageStart, animationZeroPageEnd)
+		link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+		if err = injectSID(link, cc[0].opt); err != nil {
+			return nil, nil, fmt.Errorf("injectSID failed: %w", err)
 		}
 	}
-	return link.WriteTo(w)
+	return link, symbols, nil
 }
 
 func makeCharer[S []E, E Koala | Hires](s S) []Charer {
@@ -1266,3 +1831,36 @@ func makeCharer[S []E, E Koala | Hires](s S) []Charer {
 	}
 	return frames
 }
+
+// framesLength returns the total length in bytes of all frames.
+func framesLength(frames [][]byte) (length int) {
+	for _, f := range frames {
+		length += len(f)
+	}
+	return length
+}
+
+// writeAnimationFrames writes the picture in buf, followed by the frames and the end marker to w.
+func writeAnimationFrames(w io.Writer, buf *bytes.Buffer, frames [][]byte, end []byte) (n int64, err error) {
+	for _, f := range frames {
+		buf.Write(f)
+	}
+	buf.Write(end)
+	return buf.WriteTo(w)
+}
+
+func koalaFrameDelays(kk []Koala) []byte {
+	delays := make([]byte, len(kk))
+	for i := range kk {
+		delays[i] = byte(kk[i].opt.FrameDelay)
+	}
+	return delays
+}
+
+func hiresFrameDelays(hh []Hires) []byte {
+	delays := make([]byte, len(hh))
+	for i := range hh {
+		delays[i] = byte(hh[i].opt.FrameDelay)
+	}
+	return delays
+}
@@ -0,0 +1,263 @@
+package png2prg
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnimationTransitions(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		playback   string
+		frameOrder string
+		n          int
+		want       []transition
+	}{
+		{"", "", 3, []transition{{0, 1}, {1, 2}, {2, 0}}},
+		{PlaybackLoop, "", 2, []transition{{0, 1}, {1, 0}}},
+		{PlaybackPingPong, "", 4, []transition{{0, 1}, {1, 2}, {2, 3}, {3, 2}, {2, 1}, {1, 0}}},
+		{PlaybackOnce, "", 3, []transition{{0, 1}, {1, 2}}},
+		{"", "0,1,2,1,0,3", 4, []transition{{0, 1}, {1, 2}, {2, 1}, {1, 0}, {0, 3}, {3, 0}}},
+		{PlaybackOnce, "2, 0", 3, []transition{{2, 0}}},
+	}
+	for _, c := range cases {
+		opt := Options{Playback: c.playback, FrameOrder: c.frameOrder}
+		got, err := animationTransitions(opt, c.n)
+		require.NoError(t, err, "playback %q frame order %q", c.playback, c.frameOrder)
+		assert.Equal(t, c.want, got, "playback %q frame order %q", c.playback, c.frameOrder)
+	}
+
+	for _, opt := range []Options{{Playback: "backwards"}, {FrameOrder: "0,3"}, {FrameOrder: "0,-1"}, {FrameOrder: "1"}, {FrameOrder: "0,a"}} {
+		_, err := animationTransitions(opt, 3)
+		assert.Error(t, err, "playback %q frame order %q", opt.Playback, opt.FrameOrder)
+	}
+}
+
+func TestAnimationEnd(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []byte{0xff, 0x00, 0x30}, animationEnd(Options{}, 0x3000, 0x123))
+	assert.Equal(t, []byte{0x00, 0x00, 0xff, 0x23, 0x31}, animationEnd(Options{Playback: PlaybackOnce}, 0x3000, 0x123))
+	assert.Equal(t, []byte{0x6e, 0x00, 0x00, 0xff, 0x23, 0x31}, animationEnd(Options{Playback: PlaybackOnce}, 0x3000, 0x123, 0x6e))
+}
+
+func TestProcessAnimationKeyframes(t *testing.T) {
+	t.Parallel()
+	kk := make([]Koala, 3)
+	for i := range kk {
+		kk[i].ScreenColor[500] = byte(i)
+	}
+	opt := Options{Keyframes: 2}
+	tt, err := animationTransitions(opt, len(kk))
+	require.NoError(t, err)
+	prgs, err := processAnimation(opt, makeCharer(kk), tt, koalaFrameDelays(kk))
+	require.NoError(t, err)
+	require.Len(t, prgs, 3)
+
+	counts := func(prg []byte) (cc []byte) {
+		for i := 0; prg[i] != 0; i += 5 + int(prg[i])*10 {
+			cc = append(cc, prg[i])
+		}
+		return cc
+	}
+	assert.Equal(t, []byte{253, 253, 253, 241}, counts(prgs[0]), "keyframe")
+	assert.Equal(t, []byte{1}, counts(prgs[1]), "delta frame")
+	assert.Equal(t, []byte{253, 253, 253, 241}, counts(prgs[2]), "keyframe")
+}
+
+func TestFrameTable(t *testing.T) {
+	t.Parallel()
+	frames := [][]byte{make([]byte, 0x10), make([]byte, 0x100), make([]byte, 2)}
+	end, symbols := appendFrameTable(Options{}, 0x3000, frames, []byte{0xff, 0x00, 0x30})
+	assert.Equal(t, []byte{0xff, 0x00, 0x30}, end)
+	assert.Empty(t, symbols)
+
+	end, symbols = appendFrameTable(Options{Keyframes: 2}, 0x3000, frames, []byte{0xff, 0x00, 0x30})
+	assert.Equal(t, []byte{0xff, 0x00, 0x30, 0x00, 0x10, 0x10, 0x30, 0x30, 0x31}, end)
+	assert.Equal(t, []c64Symbol{
+		{"frames", 3},
+		{"keyframes", 2},
+		{"frametable_lo", 0x3115},
+		{"frametable_hi", 0x3118},
+		{"frame0", 0x3000},
+		{"frame1", 0x3010},
+		{"frame2", 0x3110},
+	}, symbols)
+}
+
+func TestMaskedFrame(t *testing.T) {
+	t.Parallel()
+	prev, cur := Koala{}, Koala{}
+	cur.Bitmap[2] = 0x55
+	cur.Bitmap[7] = 0xaa
+	cur.D800Color[1] = 0x05
+	cur.ScreenColor[40] = 0x12
+	cur.D800Color[40] = 0x03
+	cur.Bitmap[999*8] = 0xff
+	want := []byte{maskedFrameMarker,
+		maskedRows | 0, 0x21, 0x55, 0xaa,
+		maskedColorRAM | 0, 0x05,
+		maskedMaxSkip, maskedScreen | maskedColorRAM | 6, 0x12, 0x03,
+	}
+	for i := 0; i < 29; i++ {
+		want = append(want, maskedMaxSkip)
+	}
+	want = append(want, maskedRows|30, 0x80, 0xff)
+	assert.Equal(t, want, maskedFrame(prev, cur))
+
+	h := Hires{}
+	h.ScreenColor[0] = 0x10
+	assert.Equal(t, []byte{maskedFrameMarker, maskedScreen, 0x10}, maskedFrame(Hires{}, h))
+
+	kk := make([]Koala, 2)
+	for i := 0; i < 100; i++ {
+		kk[1].Bitmap[i*8] = 0x11
+	}
+	tt, err := animationTransitions(Options{}, len(kk))
+	require.NoError(t, err)
+	prgs, err := processAnimation(Options{}, makeCharer(kk), tt, koalaFrameDelays(kk))
+	require.NoError(t, err)
+	for _, prg := range prgs {
+		assert.Equal(t, byte(maskedFrameMarker), prg[0], "smaller encoding")
+		assert.Len(t, prg, 1+100*3+2)
+	}
+}
+
+// fillChar fills char of img with a multicolor pattern of the colors cc.
+func fillChar(img *image.RGBA, char int, cc ...C64Color) {
+	pal := paletteSources[0].Colors
+	x0, y0 := xyFromChar(char)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x0+x, y0+y, pal[cc[(x/2+y)%len(cc)]])
+		}
+	}
+}
+
+func TestAnimationBitpairColors(t *testing.T) {
+	t.Parallel()
+	frame := func(extra bool) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 320, 200))
+		for char := 0; char < FullScreenChars; char++ {
+			fillChar(img, char, 0)
+		}
+		for char := 0; char < 80; char++ {
+			fillChar(img, char, 0, 1, 2)
+		}
+		if extra {
+			// new colors in the next frame, only possible in a koala with black as background color.
+			for char := 400; char < 480; char++ {
+				fillChar(img, char, 0, 5, 6, 3)
+				fillChar(img, char+80, 0, 4, 2, 7)
+			}
+		}
+		return img
+	}
+
+	opt := Options{Quiet: true, NoCrunch: true}
+	imgs := []sourceImage{}
+	for i, f := range []image.Image{frame(false), frame(true), frame(false)} {
+		img, err := NewSourceImage(opt, i, f)
+		require.NoError(t, err)
+		imgs = append(imgs, img)
+	}
+	frames := analyzeAnimationFrames(imgs)
+	require.Len(t, frames, 3)
+	assert.Equal(t, multiColorCharset, frames[0].graphicsType)
+	assert.Equal(t, multiColorBitmap, frames[1].graphicsType)
+	gfxtype, err := animationGraphicsType(frames)
+	require.NoError(t, err)
+	assert.Equal(t, multiColorBitmap, gfxtype)
+
+	c := &Converter{opt: opt, images: imgs}
+	_, err = c.WriteTo(&bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, multiColorBitmap, c.FinalGraphicsType)
+	frames = analyzeAnimationFrames(c.images)
+	bpc, err := animationBitpairColors(frames)
+	require.NoError(t, err)
+	require.Len(t, bpc, 4)
+	assert.Equal(t, C64Color(0), bpc[0].C64Color)
+	assert.Equal(t, BPCString(bpc), c.images[0].BPCString())
+}
+
+func TestAnimationChangingBitpairColors(t *testing.T) {
+	t.Parallel()
+	frame := func(extra bool) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 320, 200))
+		for char := 0; char < FullScreenChars; char++ {
+			fillChar(img, char, 0, 1, 2)
+		}
+		if extra {
+			// the preferred bitpair colors of frame 0 do not fit these chars.
+			for char := 400; char < 440; char++ {
+				fillChar(img, char, 0, 4, 5, 6)
+			}
+		}
+		return img
+	}
+
+	opt := Options{Quiet: true, Display: true, NoCrunch: true, BitpairColorsString: "0", GraphicsMode: "koala", CurrentGraphicsType: multiColorBitmap}
+	imgs := []sourceImage{}
+	for i, f := range []image.Image{frame(false), frame(true), frame(false)} {
+		img, err := NewSourceImage(opt, i, f)
+		require.NoError(t, err)
+		imgs = append(imgs, img)
+	}
+	c := &Converter{opt: opt, images: imgs}
+	_, err := c.WriteTo(&bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, multiColorBitmap, c.FinalGraphicsType)
+	m, ok := c.MemoryMap()
+	require.True(t, ok)
+	size := 0
+	for _, s := range m.Segments {
+		if s.Name == "animation frames" {
+			size += s.Length
+		}
+	}
+	// only the 40 changed chars are stored in frame 1 and restored in frame 2, a full screen per frame would be 10000 bytes.
+	assert.Greater(t, size, 2*40*(8+2))
+	assert.Less(t, size, 1000)
+}
+
+func TestAnimationCharsetBitpairColors(t *testing.T) {
+	t.Parallel()
+	frame := func(extra bool) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 320, 200))
+		for char := 0; char < FullScreenChars; char++ {
+			fillChar(img, char, 0, 1, 2)
+		}
+		if extra {
+			// a new color in the next frame, it needs the bitpair left free by the first frame.
+			for char := 400; char < 440; char++ {
+				fillChar(img, char, 0, 1, 2, 5)
+			}
+		}
+		return img
+	}
+
+	for _, bpc := range []string{"", "0", "0,1"} {
+		opt := Options{Quiet: true, Display: true, NoCrunch: true, BitpairColorsString: bpc}
+		imgs := []sourceImage{}
+		for i, f := range []image.Image{frame(false), frame(true), frame(false)} {
+			img, err := NewSourceImage(opt, i, f)
+			require.NoError(t, err)
+			imgs = append(imgs, img)
+		}
+		c := &Converter{opt: opt, images: imgs}
+		_, err := c.WriteTo(&bytes.Buffer{})
+		require.NoError(t, err, "bitpair colors %q", bpc)
+		assert.Equal(t, multiColorCharset, c.FinalGraphicsType)
+
+		frames := analyzeAnimationFrames(imgs)
+		got, err := animationCharsetBitpairColors(imgs, frames, bpc)
+		require.NoError(t, err)
+		require.Len(t, got, 4)
+		assert.Equal(t, C64Color(0), got[0].C64Color, "bitpair colors %q", bpc)
+		assert.LessOrEqual(t, got[3].C64Color, C64Color(7), "bitpair colors %q", bpc)
+	}
+}
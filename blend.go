@@ -0,0 +1,416 @@
+package png2prg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Images drawn in blended colors are shown by alternating 2 c64 colors every frame.
+// Each color of the image is matched to the average rgb color of a pair of c64 colors of a palette.
+
+// maxBlendDistance is the maximum rgb distance between a color in the image and the blend it is matched to,
+// to absorb the rounding of paint programs.
+const maxBlendDistance = 6
+
+// A blend is a pair of c64 colors, one for each frame, with a <= b.
+// Pure colors are blends of a color with itself.
+type blend struct {
+	a, b C64Color
+}
+
+func (bl blend) String() string {
+	if bl.a == bl.b {
+		return fmt.Sprintf("%d", bl.a)
+	}
+	return fmt.Sprintf("%d+%d", bl.a, bl.b)
+}
+
+// oriented returns the colors of bl for frame 0 and frame 1, swapped if flip is true.
+func (bl blend) oriented(flip bool) (C64Color, C64Color) {
+	if flip {
+		return bl.b, bl.a
+	}
+	return bl.a, bl.b
+}
+
+// A blendColor is a blend with its averaged rgb color.
+type blendColor struct {
+	blend
+	rgb color.RGBA
+}
+
+// blendColors returns the 136 blends of src, 16 pure colors followed by the 120 mixed pairs.
+func blendColors(src paletteSource) []blendColor {
+	bcs := make([]blendColor, 0, MaxColors*(MaxColors+1)/2)
+	for _, col := range src.Colors {
+		bcs = append(bcs, blendColor{blend: blend{col.C64Color, col.C64Color}, rgb: rgba(col)})
+	}
+	for a := 0; a < MaxColors; a++ {
+		for b := a + 1; b < MaxColors; b++ {
+			ca, cb := rgba(src.Colors[a]), rgba(src.Colors[b])
+			bcs = append(bcs, blendColor{
+				blend: blend{C64Color(a), C64Color(b)},
+				rgb: color.RGBA{
+					R: byte((int(ca.R) + int(cb.R) + 1) / 2),
+					G: byte((int(ca.G) + int(cb.G) + 1) / 2),
+					B: byte((int(ca.B) + int(cb.B) + 1) / 2),
+					A: 0xff,
+				},
+			})
+		}
+	}
+	return bcs
+}
+
+// blendTableCell is the size in pixels of each color in the blend table.
+const blendTableCell = 16
+
+// WriteBlendTable writes a png of the 136 blended colors of the named palette to w, for artists to draw with.
+// The cell at column a and row b shows the blend of c64 colors a and b, the diagonal shows the pure colors.
+func WriteBlendTable(w io.Writer, palette string) error {
+	names := []string{}
+	for _, src := range paletteSources {
+		if src.Name != palette {
+			names = append(names, src.Name)
+			continue
+		}
+		img := image.NewRGBA(image.Rect(0, 0, MaxColors*blendTableCell, MaxColors*blendTableCell))
+		for _, bc := range blendColors(src) {
+			for _, cell := range [][2]C64Color{{bc.a, bc.b}, {bc.b, bc.a}} {
+				for y := 0; y < blendTableCell; y++ {
+					for x := 0; x < blendTableCell; x++ {
+						img.Set(int(cell[0])*blendTableCell+x, int(cell[1])*blendTableCell+y, bc.rgb)
+					}
+				}
+			}
+		}
+		if err := png.Encode(w, img); err != nil {
+			return fmt.Errorf("png.Encode failed: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown palette %q, use one of: %s", palette, strings.Join(names, ", "))
+}
+
+func rgba(col color.Color) color.RGBA {
+	r, g, b, _ := col.RGBA()
+	return color.RGBA{byte(r), byte(g), byte(b), 0xff}
+}
+
+// A blendPalette maps the colors of an image drawn in blended colors to their blends.
+type blendPalette struct {
+	Name      string
+	colors    []Color
+	rgb2blend map[colorKey]blend
+}
+
+// newBlendPalette matches all colors of img to the blends of each palette source and returns the closest blendPalette.
+// Returns an error if a color is not close to any blend.
+func newBlendPalette(img image.Image) (bp blendPalette, err error) {
+	cols, _ := imageColors(img)
+	minDistance := -1
+	for _, src := range paletteSources {
+		bcs := blendColors(src)
+		p := blendPalette{Name: src.Name, colors: src.Colors, rgb2blend: make(map[colorKey]blend, len(cols))}
+		totalDistance := 0
+		for _, c := range cols {
+			distance, found := int(9e8), blend{}
+			for _, bc := range bcs {
+				if d := (Color{Color: bc.rgb}).Distance(c); d < distance {
+					distance, found = d, bc.blend
+				}
+			}
+			if distance > maxBlendDistance {
+				totalDistance = -1
+				break
+			}
+			p.rgb2blend[ColorKey(c)] = found
+			totalDistance += distance
+		}
+		if totalDistance >= 0 && (minDistance < 0 || totalDistance < minDistance) {
+			bp, minDistance = p, totalDistance
+		}
+	}
+	if minDistance < 0 {
+		return bp, fmt.Errorf("the %d colors are not blends of 2 c64 colors of any palette", len(cols))
+	}
+	return bp, nil
+}
+
+// NumBlends returns the number of mixed colors of bp, pure colors excluded.
+func (bp blendPalette) NumBlends() (n int) {
+	for _, bl := range bp.rgb2blend {
+		if bl.a != bl.b {
+			n++
+		}
+	}
+	return n
+}
+
+// palette returns the Palette of the pure c64 colors used by the blends of bp.
+func (bp blendPalette) palette() Palette {
+	p := BlankPalette(bp.Name, false)
+	for _, bl := range bp.rgb2blend {
+		p.Add(bp.colors[bl.a], bp.colors[bl.b])
+	}
+	return p
+}
+
+// blendAt returns the blend of the pixel at x, y of img.
+func (img *sourceImage) blendAt(x, y int) blend {
+	return img.blend.rgb2blend[ColorKey(img.At(x, y))]
+}
+
+// setPalette sets img.p, or img.blend and img.p for images drawn in blended colors.
+// Images with more than 16 colors are matched to blends, as are all images when a blended graphics mode is forced.
+func (img *sourceImage) setPalette() (err error) {
+	var perr error
+	img.p, img.hiresPixels, perr = NewPalette(img.image, false, img.opt.Verbose)
+	if perr == nil && !img.opt.CurrentGraphicsType.blended() {
+		return nil
+	}
+	bp, err := newBlendPalette(img.image)
+	if err != nil {
+		if perr != nil {
+			return fmt.Errorf("NewPalette failed: %w", perr)
+		}
+		return fmt.Errorf("newBlendPalette failed: %w", err)
+	}
+	img.blend = &bp
+	img.p = bp.palette()
+	if img.opt.Verbose {
+		log.Printf("blended palette %q: %d pure colors and %d blends", bp.Name, img.p.NumColors(), bp.NumBlends())
+	}
+	return nil
+}
+
+// analyzeBlend sets img.graphicsType for an image drawn in blended colors.
+func (img *sourceImage) analyzeBlend() error {
+	switch {
+	case img.hiresPixels:
+		img.graphicsType = singleColorInterlaceBitmap
+	case img.opt.GraphicsMode != "" && (img.opt.CurrentGraphicsType == interlaceFLIBitmap || img.opt.CurrentGraphicsType == multiColorInterlaceBitmap):
+		img.graphicsType = img.opt.CurrentGraphicsType
+	default:
+		// prefer multicolor interlace, ifli takes twice the memory
+		img.graphicsType = interlaceFLIBitmap
+		if _, _, _, err := img.SplitBlendMulticolor(); err == nil {
+			img.graphicsType = multiColorInterlaceBitmap
+		}
+	}
+	if !img.opt.Quiet {
+		fmt.Printf("file %q has graphics mode: %s with %d blended colors\n", img.sourceFilename, img.graphicsType, img.blend.NumBlends())
+	}
+	if img.opt.GraphicsMode != "" && img.graphicsType != img.opt.CurrentGraphicsType {
+		if !img.opt.CurrentGraphicsType.blended() && img.opt.CurrentGraphicsType != multiColorInterlaceBitmap {
+			return fmt.Errorf("graphics mode %s cannot show blended colors, use %s, %s or %s", img.opt.CurrentGraphicsType, multiColorInterlaceBitmap, singleColorInterlaceBitmap, interlaceFLIBitmap)
+		}
+		img.graphicsType = img.opt.CurrentGraphicsType
+		if !img.opt.Quiet {
+			fmt.Printf("graphics mode forced: %s\n", img.graphicsType)
+		}
+	}
+	if err := img.findBorderColor(); err != nil {
+		if img.opt.Verbose {
+			log.Printf("skipping: findBorderColor failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// SplitBlendHires splits the img drawn in blended colors into 2 hires images, to be shown by color flicker.
+// Each char of each frame has 2 colors, so each char can mix 4 colors at most.
+func (img *sourceImage) SplitBlendHires() (*image.RGBA, *image.RGBA, error) {
+	new0 := image.NewRGBA(image.Rect(0, 0, FullScreenWidth, FullScreenHeight))
+	new1 := image.NewRGBA(image.Rect(0, 0, FullScreenWidth, FullScreenHeight))
+	for char := 0; char < FullScreenChars; char++ {
+		blends := img.charBlends(char, 1)
+		flips, ok := orientBlends(blends, nil, 2)
+		if !ok {
+			x, y := xyFromChar(char)
+			return nil, nil, fmt.Errorf("char %d at x %d y %d has blends %v, which do not fit 2 colors per frame", char, x, y, blends)
+		}
+		img.setBlendChar(new0, new1, char, flips)
+	}
+	return new0, new1, nil
+}
+
+// SplitBlendMulticolor splits the img drawn in blended colors into 2 multicolor images, to be shown by color flicker.
+// Both frames share the background color and the colorram color of each char, each char of each frame has 2 screen colors.
+// Returns the background color, forced with the first bitpair color, otherwise all candidates are tried, most used first.
+func (img *sourceImage) SplitBlendMulticolor() (new0, new1 *image.RGBA, bg C64Color, err error) {
+	if img.hiresPixels {
+		return nil, nil, bg, fmt.Errorf("%s requires multicolor pixels", multiColorInterlaceBitmap)
+	}
+	for _, bg = range img.blendBgCandidates() {
+		if new0, new1, err = img.splitBlendMulticolor(bg); err == nil {
+			return new0, new1, bg, nil
+		}
+		if img.opt.Verbose {
+			log.Printf("%s background color %d failed: %v", multiColorInterlaceBitmap, bg, err)
+		}
+	}
+	return nil, nil, bg, err
+}
+
+func (img *sourceImage) splitBlendMulticolor(bg C64Color) (*image.RGBA, *image.RGBA, error) {
+	new0 := image.NewRGBA(image.Rect(0, 0, FullScreenWidth, FullScreenHeight))
+	new1 := image.NewRGBA(image.Rect(0, 0, FullScreenWidth, FullScreenHeight))
+	for char := 0; char < FullScreenChars; char++ {
+		blends := img.charBlends(char, 2)
+		var flips map[blend]bool
+		ok := false
+		for _, d800 := range img.blendD800Candidates(char, bg) {
+			if flips, ok = orientBlends(blends, []C64Color{bg, d800}, 2); ok {
+				break
+			}
+		}
+		if !ok {
+			x, y := xyFromChar(char)
+			return nil, nil, fmt.Errorf("char %d at x %d y %d has blends %v, which do not fit 2 screen colors per frame", char, x, y, blends)
+		}
+		img.setBlendChar(new0, new1, char, flips)
+	}
+	return new0, new1, nil
+}
+
+// charBlends returns the distinct blends of the char, reading every step pixels.
+func (img *sourceImage) charBlends(char, step int) []blend {
+	x, y := xyFromChar(char)
+	blends := []blend{}
+	for dy := 0; dy < 8; dy++ {
+		for dx := 0; dx < 8; dx += step {
+			if bl := img.blendAt(x+dx, y+dy); !In(blends, bl) {
+				blends = append(blends, bl)
+			}
+		}
+	}
+	return blends
+}
+
+// setBlendChar draws the pure colors of the blends of the char in new0 and new1, flipping the blends in flips.
+func (img *sourceImage) setBlendChar(new0, new1 *image.RGBA, char int, flips map[blend]bool) {
+	x, y := xyFromChar(char)
+	for dy := 0; dy < 8; dy++ {
+		for dx := 0; dx < 8; dx++ {
+			bl := img.blendAt(x+dx, y+dy)
+			c0, c1 := bl.oriented(flips[bl])
+			new0.Set(x+dx, y+dy, img.blend.colors[c0])
+			new1.Set(x+dx, y+dy, img.blend.colors[c1])
+		}
+	}
+}
+
+// blendSums returns the number of multicolor pixels using each c64 color in the area, counting both colors of each blend.
+func (img *sourceImage) blendSums(x0, y0, width, height int) (sum [MaxColors]int) {
+	for y := y0; y < y0+height; y++ {
+		for x := x0; x < x0+width; x += 2 {
+			bl := img.blendAt(x, y)
+			sum[bl.a]++
+			sum[bl.b]++
+		}
+	}
+	return sum
+}
+
+// blendBgCandidates returns the background color candidates of the img, most used first.
+// Only the first bitpair color is returned if it is forced.
+func (img *sourceImage) blendBgCandidates() []C64Color {
+	if len(img.bpc) > 0 && img.bpc[0] != nil {
+		return []C64Color{img.bpc[0].C64Color}
+	}
+	return sortedColors(img.blendSums(0, 0, FullScreenWidth, FullScreenHeight), nil)
+}
+
+// blendD800Candidates returns the colorram color candidates of the char, most used first, with bg as last resort.
+func (img *sourceImage) blendD800Candidates(char int, bg C64Color) []C64Color {
+	x, y := xyFromChar(char)
+	return append(sortedColors(img.blendSums(x, y, 8, 8), []C64Color{bg}), bg)
+}
+
+// sortedColors returns the colors used in sum, most used first, with exclude left out.
+func sortedColors(sum [MaxColors]int, exclude []C64Color) []C64Color {
+	cols := []C64Color{}
+	for c, n := range sum {
+		if n > 0 && !In(exclude, C64Color(c)) {
+			cols = append(cols, C64Color(c))
+		}
+	}
+	sort.SliceStable(cols, func(i, j int) bool {
+		return sum[cols[i]] > sum[cols[j]]
+	})
+	return cols
+}
+
+// orientBlends finds the frame of the colors of each blend, so that each frame uses at most max colors besides the free colors.
+// Returns the blends to flip, the search prefers the lower color of each blend in frame 0.
+// Mixing the orientation of a blend never helps, as both colors would have to fit in both frames.
+func orientBlends(blends []blend, free []C64Color, max int) (map[blend]bool, bool) {
+	var used [2][MaxColors]int
+	var n [2]int
+	add := func(frame int, c C64Color) {
+		if In(free, c) {
+			return
+		}
+		if used[frame][c] == 0 {
+			n[frame]++
+		}
+		used[frame][c]++
+	}
+	remove := func(frame int, c C64Color) {
+		if In(free, c) {
+			return
+		}
+		used[frame][c]--
+		if used[frame][c] == 0 {
+			n[frame]--
+		}
+	}
+	mixed := []blend{}
+	for _, bl := range blends {
+		if bl.a == bl.b {
+			add(0, bl.a)
+			add(1, bl.a)
+			continue
+		}
+		mixed = append(mixed, bl)
+	}
+	if n[0] > max || n[1] > max {
+		return nil, false
+	}
+	sort.Slice(mixed, func(i, j int) bool {
+		if mixed[i].a != mixed[j].a {
+			return mixed[i].a < mixed[j].a
+		}
+		return mixed[i].b < mixed[j].b
+	})
+	flips := make(map[blend]bool, len(mixed))
+	var search func(i int) bool
+	search = func(i int) bool {
+		if i == len(mixed) {
+			return true
+		}
+		for _, flip := range []bool{false, true} {
+			c0, c1 := mixed[i].oriented(flip)
+			add(0, c0)
+			add(1, c1)
+			if n[0] <= max && n[1] <= max && search(i+1) {
+				flips[mixed[i]] = flip
+				return true
+			}
+			remove(0, c0)
+			remove(1, c1)
+		}
+		return false
+	}
+	if !search(0) {
+		return nil, false
+	}
+	return flips, true
+}
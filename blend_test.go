@@ -0,0 +1,254 @@
+package png2prg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blendedImage returns a 320x200 image drawn in the blends of the first palette, with blendAt returning the blend
+// for each pixel of the given width.
+func blendedImage(pixelWidth int, blendAt func(x, y int) blend) *image.RGBA {
+	rgb := map[blend]color.RGBA{}
+	for _, bc := range blendColors(paletteSources[0]) {
+		rgb[bc.blend] = bc.rgb
+	}
+	img := image.NewRGBA(image.Rect(0, 0, FullScreenWidth, FullScreenHeight))
+	for y := 0; y < FullScreenHeight; y++ {
+		for x := 0; x < FullScreenWidth; x++ {
+			bl := blendAt(x/pixelWidth, y)
+			if bl.a > bl.b {
+				bl.a, bl.b = bl.b, bl.a
+			}
+			img.Set(x, y, rgb[bl])
+		}
+	}
+	return img
+}
+
+// blendedConverter returns a Converter of the single src image.
+func blendedConverter(t *testing.T, opt Options, src image.Image) *Converter {
+	img, err := NewSourceImage(opt, 0, src)
+	require.NoError(t, err)
+	return &Converter{opt: opt, images: []sourceImage{img}}
+}
+
+// hiresBlendAt mixes 2 blends per char, varying per row.
+func hiresBlendAt(x, y int) blend {
+	c := C64Color(y/8) % 12
+	if (x+y)%3 == 0 {
+		return blend{c, c + 1}
+	}
+	return blend{c + 2, c + 4}
+}
+
+// mciBlendAt mixes the background, a pure colorram color per char and 2 blends varying per row.
+func mciBlendAt(x, y int) blend {
+	bl := ifliBlendAt(x, y/8)
+	if x%4 == 1 {
+		// the colorram color mixed with the background color
+		bl.a = 0
+	}
+	return bl
+}
+
+// ifliBlendAt mixes the background, a pure colorram color per char and 2 blends varying per line.
+// The last 4 lines share the screen colors of line 196.
+func ifliBlendAt(x, y int) blend {
+	if y > 196 {
+		y = 196
+	}
+	c := C64Color(y) % 12
+	switch x % 4 {
+	case 0:
+		return blend{0, 0}
+	case 1:
+		d := C64Color(x/4)%15 + 1
+		return blend{d, d}
+	case 2:
+		return blend{c, c + 1}
+	}
+	return blend{c + 2, c + 4}
+}
+
+func TestOrientBlends(t *testing.T) {
+	t.Parallel()
+	flips, ok := orientBlends([]blend{{1, 2}, {3, 4}, {1, 1}}, nil, 2)
+	assert.False(t, ok)
+	flips, ok = orientBlends([]blend{{1, 2}, {1, 3}, {2, 4}}, nil, 2)
+	require.True(t, ok)
+	assert.False(t, flips[blend{1, 2}])
+	assert.False(t, flips[blend{1, 3}])
+	assert.True(t, flips[blend{2, 4}])
+	_, ok = orientBlends([]blend{{0, 5}, {1, 2}, {4, 6}, {6, 6}}, []C64Color{0, 6}, 2)
+	assert.True(t, ok)
+	_, ok = orientBlends([]blend{{1, 2}, {3, 4}, {5, 6}}, nil, 2)
+	assert.False(t, ok)
+}
+
+func TestNewBlendPalette(t *testing.T) {
+	t.Parallel()
+	bp, err := newBlendPalette(blendedImage(1, hiresBlendAt))
+	require.NoError(t, err)
+	assert.Equal(t, paletteSources[0].Name, bp.Name)
+	assert.Greater(t, bp.NumBlends(), MaxColors)
+	for k, bl := range bp.rgb2blend {
+		assert.LessOrEqual(t, bl.a, bl.b, "%v", k)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, FullScreenWidth, FullScreenHeight))
+	for x := 0; x < 20; x++ {
+		img.Set(x, 0, color.RGBA{byte(x * 13), 0x80, byte(x * 7), 0xff})
+	}
+	_, err = newBlendPalette(img)
+	assert.Error(t, err)
+}
+
+func TestBlendedHiresInterlace(t *testing.T) {
+	t.Parallel()
+	for _, display := range []bool{false, true} {
+		c := blendedConverter(t, Options{Quiet: true, Display: display, NoCrunch: true, D016Offset: 1}, blendedImage(1, hiresBlendAt))
+		buf := &bytes.Buffer{}
+		_, err := c.WriteTo(buf)
+		require.NoError(t, err)
+		assert.Equal(t, singleColorInterlaceBitmap, c.FinalGraphicsType)
+		if !display {
+			require.Equal(t, Word(0x5c00), NewWord(buf.Bytes()[0], buf.Bytes()[1]))
+			assert.Equal(t, byte(0), buf.Bytes()[2+0x7f42-0x5c00], "d016offset of color flicker")
+			continue
+		}
+		assert.Equal(t, Word(0x0801), NewWord(buf.Bytes()[0], buf.Bytes()[1]))
+	}
+}
+
+func TestIFLI(t *testing.T) {
+	t.Parallel()
+	src := blendedImage(2, ifliBlendAt)
+	c := blendedConverter(t, Options{Quiet: true}, src)
+	buf := &bytes.Buffer{}
+	_, err := c.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, interlaceFLIBitmap, c.FinalGraphicsType)
+	require.Equal(t, Word(ifliScreens0), NewWord(buf.Bytes()[0], buf.Bytes()[1]))
+	assert.Equal(t, 2+ifliColors+3-ifliScreens0, buf.Len())
+
+	img, err := NewSourceImage(Options{Quiet: true}, 0, src)
+	require.NoError(t, err)
+	require.NoError(t, img.analyze())
+	f, err := img.IFLI()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), f.BackgroundColor)
+	// char 1, line 3: the pure colorram color 2, blends 3+4 and 5+7 in the screen colors
+	char, line := 1, 3
+	d := C64Color(char)%15 + 1
+	assert.Equal(t, byte(d), f.D800Color[char])
+	var cols [4][2]byte
+	for frame := 0; frame < 2; frame++ {
+		bits := f.Bitmap[frame][char*8+line]
+		screen := f.Screens[frame][line][char]
+		for px := range cols {
+			switch bits >> (6 - px*2) & 3 {
+			case 0:
+				cols[px][frame] = f.BackgroundColor
+			case 1:
+				cols[px][frame] = screen >> 4
+			case 2:
+				cols[px][frame] = screen & 0xf
+			case 3:
+				cols[px][frame] = f.D800Color[char]
+			}
+		}
+	}
+	for px, c := range cols {
+		bl := ifliBlendAt(char*4+px, line)
+		got := blend{C64Color(c[0]), C64Color(c[1])}
+		assert.True(t, got == bl || got == blend{bl.b, bl.a}, "pixel %d: frames show %s, not blend %s", px, got, bl)
+	}
+
+	c = blendedConverter(t, Options{Quiet: true, Display: true, NoCrunch: true}, src)
+	buf.Reset()
+	_, err = c.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, Word(0x0801), NewWord(buf.Bytes()[0], buf.Bytes()[1]))
+
+	c = blendedConverter(t, Options{Quiet: true, InterlaceFormat: InterlaceFormatGunpaint}, src)
+	buf.Reset()
+	_, err = c.WriteTo(buf)
+	require.NoError(t, err)
+	b := buf.Bytes()
+	require.Equal(t, 33603, len(b))
+	require.Equal(t, Word(gunpaintScreens0), NewWord(b[0], b[1]))
+	assert.Equal(t, gunpaintID, string(b[2+gunpaintSignature-gunpaintScreens0:][:len(gunpaintID)]))
+	assert.Equal(t, f.Screens[0][line][:], b[2+line*0x400:][:1000])
+	assert.Equal(t, f.Bitmap[0][:], b[2+gunpaintBitmap0-gunpaintScreens0:][:8000])
+	assert.Equal(t, f.D800Color[:], b[2+gunpaintColorRAM-gunpaintScreens0:][:1000])
+	assert.Equal(t, f.Screens[1][line][:], b[2+gunpaintScreens1-gunpaintScreens0+line*0x400:][:1000])
+	assert.Equal(t, f.Bitmap[1][:], b[2+gunpaintBitmap1-gunpaintScreens0:][:8000])
+	assert.Equal(t, f.BackgroundColor, b[2+gunpaintD021Colors-gunpaintScreens0])
+
+	c = blendedConverter(t, Options{Quiet: true, Display: true, InterlaceFormat: InterlaceFormatGunpaint}, src)
+	_, err = c.WriteTo(buf)
+	assert.Error(t, err)
+}
+
+func TestBlendedMulticolorInterlace(t *testing.T) {
+	t.Parallel()
+	src := blendedImage(2, mciBlendAt)
+	img, err := NewSourceImage(Options{Quiet: true}, 0, src)
+	require.NoError(t, err)
+	require.NoError(t, img.analyze())
+	assert.Equal(t, multiColorInterlaceBitmap, img.graphicsType)
+	new0, new1, bg, err := img.SplitBlendMulticolor()
+	require.NoError(t, err)
+	assert.Equal(t, C64Color(0), bg)
+	for y := 0; y < FullScreenHeight; y++ {
+		for x := 0; x < FullScreenWidth; x++ {
+			bl := img.blendAt(x, y)
+			got := blend{img.p.FromColorNoErr(new0.At(x, y)).C64Color, img.p.FromColorNoErr(new1.At(x, y)).C64Color}
+			require.True(t, got == bl || got == blend{bl.b, bl.a}, "x %d y %d: frames show %s, not blend %s", x, y, got, bl)
+		}
+	}
+
+	for _, display := range []bool{false, true} {
+		c := blendedConverter(t, Options{Quiet: true, Display: display, NoCrunch: true, D016Offset: 1}, src)
+		buf := &bytes.Buffer{}
+		_, err = c.WriteTo(buf)
+		require.NoError(t, err, "display %v", display)
+		assert.Equal(t, multiColorInterlaceBitmap, c.FinalGraphicsType)
+		if display {
+			assert.Equal(t, Word(0x0801), NewWord(buf.Bytes()[0], buf.Bytes()[1]))
+			continue
+		}
+		// true paint .mci format, the d016 offset of color flicker is 0
+		require.Equal(t, Word(0x9c00), NewWord(buf.Bytes()[0], buf.Bytes()[1]))
+		assert.Equal(t, byte(0), buf.Bytes()[2+0x9fe8-0x9c00]&0xf, "d021 color")
+		assert.Equal(t, byte(0), buf.Bytes()[2+0x9fe9-0x9c00], "d016offset")
+	}
+
+	c := blendedConverter(t, Options{Quiet: true, GraphicsMode: "koala", CurrentGraphicsType: multiColorBitmap}, src)
+	_, err = c.WriteTo(&bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestWriteBlendTable(t *testing.T) {
+	t.Parallel()
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteBlendTable(buf, paletteSources[0].Name))
+	img, err := png.Decode(buf)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, MaxColors*blendTableCell, MaxColors*blendTableCell), img.Bounds())
+	bp, err := newBlendPalette(img)
+	require.NoError(t, err)
+	// the blend of dark grey and light grey is medium grey in this palette, the pure color wins
+	assert.Equal(t, MaxColors*(MaxColors-1)/2-1, bp.NumBlends())
+	assert.Equal(t, blend{12, 12}, bp.rgb2blend[ColorKey(img.At(11*blendTableCell, 15*blendTableCell))])
+	assert.Equal(t, blend{1, 2}, bp.rgb2blend[ColorKey(img.At(2*blendTableCell, blendTableCell))])
+	assert.Equal(t, img.At(2*blendTableCell, blendTableCell), img.At(blendTableCell, 2*blendTableCell))
+
+	assert.Error(t, WriteBlendTable(buf, "no such palette"))
+}
@@ -0,0 +1,133 @@
+package png2prg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// bruteCacheEntry is the brute-force winner as stored in the cache directory.
+type bruteCacheEntry struct {
+	Version            string `json:"version"`
+	Mode               string `json:"mode"`
+	Winner             string `json:"winner"`
+	BitpairColors      string `json:"bpc"`
+	NoPrevCharColors   bool   `json:"npcc,omitempty"`
+	NoBitpairCounters  bool   `json:"nbc,omitempty"`
+	NoPackEmptyChar    bool   `json:"npe,omitempty"`
+	ForcePackEmptyChar bool   `json:"fpe,omitempty"`
+	Score              int    `json:"score"`
+	Crunched           int    `json:"crunched,omitempty"`
+	Raw                int    `json:"raw"`
+	Chars              int    `json:"chars,omitempty"`
+	Delta              int    `json:"delta,omitempty"`
+}
+
+// bruteCacheKey returns the hex encoded sha256 of the decoded pixels of all images, the target graphics type, the
+// png2prg version, the objective and all options that change the conversion.
+func (c *Converter) bruteCacheKey(gfxtype GraphicsType, maxColors int, cruncher Cruncher, strategy string, objective bruteObjective) (string, error) {
+	opt, err := json.Marshal(c.opt.conversionOptions())
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "png2prg %s\n%s %d\n%s %s %q\n%s\n", Version, gfxtype, maxColors, cruncher, strategy, objective, opt)
+	for _, img := range c.images {
+		hashPixels(h, img.image)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashPixels writes the bounds and the 8 bit rgb values of all pixels of img to h.
+func hashPixels(h io.Writer, img image.Image) {
+	b := img.Bounds()
+	fmt.Fprintf(h, "%d %d %d %d\n", b.Min.X, b.Min.Y, b.Max.X, b.Max.Y)
+	row := make([]byte, 0, b.Dx()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = row[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			row = append(row, byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+		h.Write(row)
+	}
+}
+
+// loadBruteCache returns the cached winner of key in dir, ok is false if it is not cached.
+func loadBruteCache(dir, key string) (r bruteResult, ok bool, err error) {
+	buf, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, false, nil
+	}
+	if err != nil {
+		return r, false, fmt.Errorf("os.ReadFile failed: %w", err)
+	}
+	e := bruteCacheEntry{}
+	if err = json.Unmarshal(buf, &e); err != nil {
+		return r, false, fmt.Errorf("json.Unmarshal %q failed: %w", key, err)
+	}
+	r = bruteResult{
+		bpc: e.BitpairColors,
+		flags: bruteFlags{
+			noPrevCharColors:   e.NoPrevCharColors,
+			noBitpairCounters:  e.NoBitpairCounters,
+			noPackEmptyChar:    e.NoPackEmptyChar,
+			forcePackEmptyChar: e.ForcePackEmptyChar,
+		},
+		metrics: bruteMetrics{crunched: e.Crunched, raw: e.Raw, chars: e.Chars, delta: e.Delta},
+		score:   e.Score,
+	}
+	return r, true, nil
+}
+
+// storeBruteCache stores the winner r of key for gfxtype in dir, which is created if it does not exist.
+func storeBruteCache(dir, key string, gfxtype GraphicsType, r bruteResult) error {
+	e := bruteCacheEntry{
+		Version:            Version,
+		Mode:               gfxtype.String(),
+		Winner:             r.String(),
+		BitpairColors:      r.bpc,
+		NoPrevCharColors:   r.flags.noPrevCharColors,
+		NoBitpairCounters:  r.flags.noBitpairCounters,
+		NoPackEmptyChar:    r.flags.noPackEmptyChar,
+		ForcePackEmptyChar: r.flags.forcePackEmptyChar,
+		Score:              r.score,
+		Crunched:           r.metrics.crunched,
+		Raw:                r.metrics.raw,
+		Chars:              r.metrics.chars,
+		Delta:              r.metrics.delta,
+	}
+	buf, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent failed: %w", err)
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("os.MkdirAll failed: %w", err)
+	}
+	// write to a temporary file first, so parallel builds never read a partial entry.
+	f, err := os.CreateTemp(dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp failed: %w", err)
+	}
+	if _, err = f.Write(append(buf, '\n')); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("f.Write failed: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("f.Close failed: %w", err)
+	}
+	if err = os.Rename(f.Name(), filepath.Join(dir, key+".json")); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("os.Rename failed: %w", err)
+	}
+	return nil
+}
@@ -5,202 +5,685 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/rand"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
-
-	"github.com/staD020/TSCrunch"
+	"time"
 )
 
 type bruteResult struct {
-	bpc               string
-	noprevcharcols    bool
-	nobitpaircounters bool
-	length            int
+	bpc     string
+	flags   bruteFlags
+	metrics bruteMetrics
+	score   int
+	err     error
 }
 
-// BruteForceBitpairColors bruteforces all possible bitpair color combinations.
-// Sets img.bpc to the best result.
-func (c *Converter) BruteForceBitpairColors(gfxtype GraphicsType, maxColors int) error {
-	if maxColors > 4 {
-		return fmt.Errorf("maxColors has a max of 4, but it is %d", maxColors)
+// String returns the flags to reproduce the result.
+func (r bruteResult) String() string {
+	s := "-bpc " + r.bpc
+	if f := r.flags.String(); f != "" {
+		s += " " + f
 	}
-	if gfxtype == unknownGraphicsType {
-		return fmt.Errorf("BruteForceBitpairColors failed: unknownGraphicsType")
+	return s
+}
+
+// Heuristics that can be permuted in brute-force mode, besides the bitpair colors.
+const (
+	HeuristicNoPrevCharColors   = "npcc"
+	HeuristicNoBitpairCounters  = "nbc"
+	HeuristicNoPackEmptyChar    = "npe"
+	HeuristicForcePackEmptyChar = "fpe"
+	HeuristicAll                = "all"
+)
+
+// bruteFlags are the heuristic flags of a brute-force attempt.
+type bruteFlags struct {
+	noPrevCharColors   bool
+	noBitpairCounters  bool
+	noPackEmptyChar    bool
+	forcePackEmptyChar bool
+}
+
+func newBruteFlags(opt Options) bruteFlags {
+	return bruteFlags{
+		noPrevCharColors:   opt.NoPrevCharColors,
+		noBitpairCounters:  opt.NoBitpairCounters,
+		noPackEmptyChar:    opt.NoPackEmptyChar,
+		forcePackEmptyChar: opt.ForcePackEmptyChar,
 	}
-	origOpt := c.opt
+}
+
+// apply sets the flags in opt.
+func (f bruteFlags) apply(opt *Options) {
+	opt.NoPrevCharColors = f.noPrevCharColors
+	opt.NoBitpairCounters = f.noBitpairCounters
+	opt.NoPackEmptyChar = f.noPackEmptyChar
+	opt.ForcePackEmptyChar = f.forcePackEmptyChar
+}
+
+// String returns the command line flags that are set, like "-npcc -nbc".
+func (f bruteFlags) String() string {
+	ss := []string{}
+	for _, v := range []struct {
+		set  bool
+		flag string
+	}{
+		{f.noPrevCharColors, HeuristicNoPrevCharColors},
+		{f.noBitpairCounters, HeuristicNoBitpairCounters},
+		{f.noPackEmptyChar, HeuristicNoPackEmptyChar},
+		{f.forcePackEmptyChar, HeuristicForcePackEmptyChar},
+	} {
+		if v.set {
+			ss = append(ss, "-"+v.flag)
+		}
+	}
+	return strings.Join(ss, " ")
+}
+
+// bruteForceVariants returns the combinations of the heuristics in the comma separated list heuristics that apply to
+// gfxtype, starting from the flags in opt.
+func bruteForceVariants(opt Options, heuristics string, gfxtype GraphicsType) ([]bruteFlags, error) {
+	variants := []bruteFlags{newBruteFlags(opt)}
+	if heuristics == "" {
+		return variants, nil
+	}
+	names := strings.Split(heuristics, ",")
+	if heuristics == HeuristicAll {
+		names = []string{HeuristicNoPrevCharColors, HeuristicNoBitpairCounters, HeuristicNoPackEmptyChar, HeuristicForcePackEmptyChar}
+	}
+	done := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		var toggle func(f *bruteFlags)
+		switch name {
+		case HeuristicNoPrevCharColors:
+			toggle = func(f *bruteFlags) { f.noPrevCharColors = !f.noPrevCharColors }
+		case HeuristicNoBitpairCounters:
+			toggle = func(f *bruteFlags) { f.noBitpairCounters = !f.noBitpairCounters }
+		case HeuristicNoPackEmptyChar:
+			if gfxtype != multiColorCharset && gfxtype != mixedCharset && gfxtype != ecmCharset {
+				continue
+			}
+			toggle = func(f *bruteFlags) { f.noPackEmptyChar = !f.noPackEmptyChar }
+		case HeuristicForcePackEmptyChar:
+			if gfxtype != singleColorCharset {
+				continue
+			}
+			toggle = func(f *bruteFlags) { f.forcePackEmptyChar = !f.forcePackEmptyChar }
+		default:
+			return nil, fmt.Errorf("unknown brute-force heuristic %q, use %s, %s, %s, %s or %s", name, HeuristicNoPrevCharColors, HeuristicNoBitpairCounters, HeuristicNoPackEmptyChar, HeuristicForcePackEmptyChar, HeuristicAll)
+		}
+		if done[name] {
+			continue
+		}
+		done[name] = true
+		for _, f := range variants {
+			toggle(&f)
+			variants = append(variants, f)
+		}
+	}
+	return variants, nil
+}
+
+// Brute-force search strategies.
+const (
+	StrategyPermute = "permute"
+	StrategyClimb   = "climb"
+
+	// defaultClimbBudget is the number of attempts of the climb strategy when no budget is set.
+	defaultClimbBudget = 500
+	// permuteDepth is the number of most used colors permuted by the permute strategy.
+	permuteDepth = 8
+)
+
+// bruteCandidate is a combination of bitpair colors and heuristic flags to try.
+type bruteCandidate struct {
+	colors []C64Color
+	flags  bruteFlags
+}
+
+func (cand bruteCandidate) bpc() string {
+	ss := make([]string, len(cand.colors))
+	for i, col := range cand.colors {
+		ss[i] = strconv.Itoa(int(col))
+	}
+	return strings.Join(ss, ",")
+}
+
+// String returns the flags to reproduce the candidate, like bruteResult.String.
+func (cand bruteCandidate) String() string {
+	return bruteResult{bpc: cand.bpc(), flags: cand.flags}.String()
+}
+
+// bruteStats contains the statistics of a brute-force search.
+type bruteStats struct {
+	attempts     int // crunched conversions
+	failed       int // conversions that failed
+	skipped      int // impossible candidates, not converted
+	cached       int // candidates tried before
+	improvements int
+	restarts     int
+	elapsed      time.Duration
+}
+
+func (s bruteStats) String() string {
+	perSecond := float64(s.attempts) / s.elapsed.Seconds()
+	return fmt.Sprintf("%d attempts (%d failed), %d skipped, %d cached, %d improvements, %d restarts in %s (%.1f attempts/s)",
+		s.attempts, s.failed, s.skipped, s.cached, s.improvements, s.restarts, s.elapsed.Round(time.Millisecond), perSecond)
+}
+
+// bruteJob contains the prefilled sourceImages of a brute-force attempt.
+type bruteJob struct {
+	// gfxtype is multiColorInterlaceBitmap for both images of an interlace picture, otherwise imgs contains a
+	// single image or all frames of an animation.
+	gfxtype GraphicsType
+	imgs    []*sourceImage
+	// crunch is false if the objective does not need the crunched size.
+	crunch bool
+}
+
+// bruteSearch evaluates candidates with a pool of workers and caches the results.
+type bruteSearch struct {
+	c         *Converter
+	gfxtype   GraphicsType
+	maxColors int
+	frames    int // number of images converted per attempt
+	objective bruteObjective
+	jobs      chan bruteJob
+	results   chan bruteResult
+	wg        *sync.WaitGroup
+	workers   int // number of workers started, at least 1
+	cache     map[string]bruteResult
+	stats     bruteStats
+}
+
+// newBruteSearch starts c.opt.NumWorkers workers, crunching with cruncher. Call close when done.
+// Each attempt converts the first frames images of c.images and is scored by objective.
+func newBruteSearch(c *Converter, gfxtype GraphicsType, maxColors, frames int, objective bruteObjective, cruncher Cruncher) *bruteSearch {
 	num := c.opt.NumWorkers
-	jobs := make(chan *sourceImage, num)
-	result := make(chan bruteResult, num)
-	wg := &sync.WaitGroup{}
-	wg.Add(num)
+	if num < 1 {
+		num = 1
+	}
+	s := &bruteSearch{
+		c:         c,
+		gfxtype:   gfxtype,
+		maxColors: maxColors,
+		frames:    frames,
+		objective: objective,
+		jobs:      make(chan bruteJob, num),
+		results:   make(chan bruteResult, num),
+		wg:        &sync.WaitGroup{},
+		workers:   num,
+		cache:     map[string]bruteResult{},
+	}
+	s.wg.Add(num)
 	for i := 1; i <= num; i++ {
-		go c.bruteWorker(i, wg, jobs, result)
+		go c.bruteWorker(i, s.wg, cruncher, s.jobs, s.results)
 	}
-	out := []bruteResult{}
+	return s
+}
+
+func (s *bruteSearch) close() {
+	close(s.jobs)
+	s.wg.Wait()
+}
+
+// needsBgCandidate returns true if the first bitpair color must be one of the background candidates.
+func (s *bruteSearch) needsBgCandidate() bool {
+	switch s.gfxtype {
+	case multiColorBitmap, multiColorInterlaceBitmap, singleColorCharset, multiColorCharset, mixedCharset:
+		return true
+	}
+	return false
+}
+
+// job returns the job with the prefilled sourceImages to convert cand, or an error if cand is impossible.
+func (s *bruteSearch) job(cand bruteCandidate) (bruteJob, error) {
+	job := bruteJob{gfxtype: s.gfxtype, crunch: s.objective.uses(ObjectiveCrunched)}
+	c := s.c
+	if len(cand.colors) != s.maxColors {
+		return job, fmt.Errorf("%d colors instead of %d", len(cand.colors), s.maxColors)
+	}
+	if s.needsBgCandidate() {
+		bgok := false
+		for _, col := range c.images[0].bgCandidates {
+			if col.C64Color == cand.colors[0] {
+				bgok = true
+			}
+		}
+		if !bgok {
+			return job, fmt.Errorf("impossible bgcolor %d", cand.colors[0])
+		}
+	}
+	if s.gfxtype == multiColorCharset || s.gfxtype == mixedCharset {
+		if cand.colors[3] > 7 {
+			return job, fmt.Errorf("impossible d800 color %d", cand.colors[3])
+		}
+	}
+
+	gfxtype := s.gfxtype
+	if gfxtype == multiColorInterlaceBitmap {
+		// both halves are converted as koala
+		gfxtype = multiColorBitmap
+	}
+	opt := c.opt
+	opt.GraphicsMode = gfxtype.String()
+	opt.CurrentGraphicsType = gfxtype
+	opt.BitpairColorsString = cand.bpc()
+	opt.BruteForce = false
+	opt.Display = false
+	opt.NoCrunch = true
+	opt.Verbose = false
+	opt.VeryVerbose = false
+	opt.Quiet = true
+	cand.flags.apply(&opt)
+	job.imgs = make([]*sourceImage, s.frames)
+	for i := range job.imgs {
+		// prefilled NewSourceImage, no need to redo the same work
+		job.imgs[i] = &sourceImage{
+			sourceFilename: fmt.Sprintf("png2prg_%02d_%d", len(s.cache), i),
+			opt:            opt,
+			image:          c.images[i].image,
+			p:              c.images[i].p,
+			hiresPixels:    c.images[i].hiresPixels,
+			graphicsType:   c.images[i].graphicsType,
+			charColors:     c.images[i].charColors,
+			sumColors:      c.images[i].sumColors,
+		}
+		if err := job.imgs[i].checkBounds(); err != nil {
+			return job, fmt.Errorf("img.checkBounds failed: %w", err)
+		}
+		if err := job.imgs[i].setPreferredBitpairColors(opt.BitpairColorsString); err != nil {
+			return job, fmt.Errorf("setPreferredBitpairColors failed: %w", err)
+		}
+	}
+	return job, nil
+}
+
+// evaluate converts and crunches the candidates in parallel and returns their results in the same order.
+// Candidates tried before are returned from the cache, impossible candidates return a result with an error.
+func (s *bruteSearch) evaluate(cands []bruteCandidate) []bruteResult {
+	results := make([]bruteResult, len(cands))
+	pending := map[string][]int{}
+	jobs := []bruteJob{}
+	for i, cand := range cands {
+		key := cand.String()
+		if r, ok := s.cache[key]; ok {
+			results[i] = r
+			s.stats.cached++
+			continue
+		}
+		if _, ok := pending[key]; ok {
+			pending[key] = append(pending[key], i)
+			continue
+		}
+		job, err := s.job(cand)
+		if err != nil {
+			if s.c.opt.VeryVerbose {
+				log.Printf("skipping %s: %v", cand, err)
+			}
+			r := bruteResult{bpc: cand.bpc(), flags: cand.flags, err: err}
+			s.cache[key] = r
+			results[i] = r
+			s.stats.skipped++
+			continue
+		}
+		pending[key] = []int{i}
+		jobs = append(jobs, job)
+	}
+
 	go func() {
-		for v := range result {
-			out = append(out, v)
+		for _, job := range jobs {
+			s.jobs <- job
 		}
-		wg.Done()
 	}()
-	if !c.opt.Quiet {
-		fmt.Printf("started %d brute-force workers\n", num)
+	for range jobs {
+		r := <-s.results
+		s.stats.attempts++
+		if r.err != nil {
+			s.stats.failed++
+			if s.c.opt.Verbose {
+				log.Printf("skipping %s: %v", r, r.err)
+			}
+		}
+		r.score = s.objective.score(r.metrics)
+		key := r.String()
+		s.cache[key] = r
+		for _, i := range pending[key] {
+			results[i] = r
+		}
+		if !s.c.opt.Quiet && s.stats.attempts%10 == 0 {
+			fmt.Print(".")
+		}
+	}
+	return results
+}
+
+// out returns the successful results, best score first.
+func (s *bruteSearch) out() []bruteResult {
+	out := []bruteResult{}
+	for _, r := range s.cache {
+		if r.err == nil {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].score == out[j].score {
+			return out[i].String() < out[j].String()
+		}
+		return out[i].score < out[j].score
+	})
+	return out
+}
+
+// colors returns the colors used in the image, most used first.
+// Interlace adds the colors only used in the second image, sprites use the palette.
+func (s *bruteSearch) colors() []C64Color {
+	cc := []C64Color{}
+	add := func(colors []Color) {
+		for _, col := range colors {
+			if !slices.Contains(cc, col.C64Color) {
+				cc = append(cc, col.C64Color)
+			}
+		}
 	}
+	switch s.gfxtype {
+	case singleColorSprites, multiColorSprites:
+		add(s.c.images[0].p.SortColors())
+	case multiColorInterlaceBitmap:
+		add(s.c.images[0].SortedColors())
+		add(s.c.images[1].SortedColors())
+	default:
+		add(s.c.images[0].SortedColors())
+	}
+	return cc
+}
 
-	colors := c.images[0].SortedColors()
-	const permuteDepth = 8
+// permute tries all permutations of the permuteDepth most used colors, combined with the variants.
+// When budget is positive, at most budget attempts are made.
+func (s *bruteSearch) permute(variants []bruteFlags, budget int) {
+	colors := s.colors()
 	if len(colors) > permuteDepth {
 		colors = colors[0:permuteDepth]
 	}
-	if c.opt.Verbose {
+	if s.c.opt.Verbose {
 		log.Printf("bruteforce colors: %v", colors)
 	}
 
-	count := 0
-	total := 0
+	cands := []bruteCandidate{}
 	done := map[[4]C64Color]bool{}
 	for p := make([]int, len(colors)); p[0] < len(p); PermuteNext(p) {
-		count++
-		s := Permutation(colors, p)
-		if len(s) > maxColors {
-			s = s[:maxColors]
-		}
-		if len(s) < maxColors {
-			if c.opt.Verbose {
-				log.Printf("skipping permutation %v as it does not contain %d colors", s, maxColors)
+		perm := Permutation(colors, p)
+		if len(perm) > s.maxColors {
+			perm = perm[:s.maxColors]
+		}
+		if len(perm) < s.maxColors {
+			if s.c.opt.Verbose {
+				log.Printf("skipping permutation %v as it does not contain %d colors", perm, s.maxColors)
 			}
 			continue
 		}
 		tmp := [4]C64Color{}
-		for i := range tmp {
-			if i < maxColors {
-				tmp[i] = s[i].C64Color
-			}
-		}
-		if _, ok := done[tmp]; ok {
+		copy(tmp[:], perm)
+		if done[tmp] {
 			continue
 		}
 		done[tmp] = true
+		for _, flags := range variants {
+			cands = append(cands, bruteCandidate{colors: append([]C64Color{}, perm...), flags: flags})
+		}
+	}
+	if budget <= 0 {
+		s.evaluate(cands)
+		return
+	}
+	for len(cands) > 0 && s.stats.attempts < budget {
+		n := budget - s.stats.attempts
+		if n > len(cands) {
+			n = len(cands)
+		}
+		s.evaluate(cands[:n])
+		cands = cands[n:]
+	}
+}
 
-		if gfxtype == multiColorBitmap || gfxtype == singleColorCharset || gfxtype == multiColorCharset || gfxtype == mixedCharset {
-			// skip impossible bgcolors
-			bgok := false
-			for _, col := range c.images[0].bgCandidates {
-				if col.C64Color == s[0].C64Color {
-					bgok = true
-				}
-			}
-			if !bgok {
-				continue
-			}
+// climb hill-climbs from the guessed bitpair colors over all colors of the image. Each step evaluates all neighbors
+// of the current candidate in parallel: each bitpair color replaced by another color, two bitpair colors swapped or
+// other heuristic flags. When no neighbor scores better, it restarts from a random candidate, until budget attempts
+// are spent.
+func (s *bruteSearch) climb(variants []bruteFlags, budget int) {
+	if budget <= 0 {
+		budget = defaultClimbBudget
+	}
+	r := rand.New(rand.NewSource(1))
+	colors := s.colors()
+	cur := s.guess(colors, variants[0])
+	score := func(cand bruteCandidate) int {
+		res := s.evaluate([]bruteCandidate{cand})[0]
+		if res.err != nil {
+			return math.MaxInt
 		}
-		if gfxtype == multiColorCharset || gfxtype == mixedCharset {
-			// skip impossible d800 colors
-			if s[3].C64Color > 7 {
-				continue
+		return res.score
+	}
+	curScore := score(cur)
+	for steps := 0; s.stats.attempts < budget && steps < budget; steps++ {
+		nbs := s.neighbors(cur, colors, variants)
+		r.Shuffle(len(nbs), func(i, j int) { nbs[i], nbs[j] = nbs[j], nbs[i] })
+		if left := budget - s.stats.attempts; len(nbs) > left {
+			nbs = nbs[:left]
+		}
+		improved := false
+		for i, res := range s.evaluate(nbs) {
+			if res.err == nil && res.score < curScore {
+				cur, curScore = nbs[i], res.score
+				improved = true
 			}
 		}
+		if improved {
+			s.stats.improvements++
+			continue
+		}
+		if s.stats.attempts >= budget {
+			break
+		}
+		s.stats.restarts++
+		cur = s.random(r, colors, variants)
+		curScore = score(cur)
+	}
+}
 
-		bitpaircols := ""
-		for i, col := range s {
-			bitpaircols += strconv.Itoa(int(col.C64Color))
-			if i < len(s)-1 {
-				bitpaircols += ","
-			}
+// guess returns the candidate of the bitpair colors guessed by analyze, filled up with the most used colors.
+func (s *bruteSearch) guess(colors []C64Color, flags bruteFlags) bruteCandidate {
+	cand := bruteCandidate{flags: flags}
+	used := map[C64Color]bool{}
+	for _, col := range s.c.images[0].bpc {
+		if col != nil && !used[col.C64Color] && len(cand.colors) < s.maxColors {
+			cand.colors = append(cand.colors, col.C64Color)
+			used[col.C64Color] = true
 		}
+	}
+	for _, col := range colors {
+		if !used[col] && len(cand.colors) < s.maxColors {
+			cand.colors = append(cand.colors, col)
+			used[col] = true
+		}
+	}
+	return cand
+}
 
-		opt := c.opt
-		opt.GraphicsMode = gfxtype.String()
-		opt.CurrentGraphicsType = gfxtype
-		opt.BitpairColorsString = bitpaircols
-		opt.Display = false
-		opt.NoCrunch = true
-		opt.Verbose = false
-		opt.VeryVerbose = false
-		opt.Quiet = true
-		// prefilled NewSourceImage, no need to redo the same work
-		img := &sourceImage{
-			sourceFilename: fmt.Sprintf("png2prg_%02d", count),
-			opt:            opt,
-			image:          c.images[0].image,
-			p:              c.images[0].p,
-			hiresPixels:    c.images[0].hiresPixels,
-			graphicsType:   c.images[0].graphicsType,
-			charColors:     c.images[0].charColors,
-			sumColors:      c.images[0].sumColors,
-		}
-		if err := img.checkBounds(); err != nil {
-			if c.opt.Verbose {
-				log.Printf("skipping permutation %q because img.checkBounds failed: %v", bitpaircols, err)
+// random returns a random candidate, with one of the background candidates as first color.
+func (s *bruteSearch) random(r *rand.Rand, colors []C64Color, variants []bruteFlags) bruteCandidate {
+	cand := bruteCandidate{flags: variants[r.Intn(len(variants))]}
+	if bg := s.c.images[0].bgCandidates; len(bg) > 0 && s.needsBgCandidate() {
+		cand.colors = append(cand.colors, bg[r.Intn(len(bg))].C64Color)
+	}
+	for _, i := range r.Perm(len(colors)) {
+		if len(cand.colors) < s.maxColors && !slices.Contains(cand.colors, colors[i]) {
+			cand.colors = append(cand.colors, colors[i])
+		}
+	}
+	return cand
+}
+
+// neighbors returns the candidates that differ from cand by a single replaced color, two swapped colors or the
+// heuristic flags.
+func (s *bruteSearch) neighbors(cand bruteCandidate, colors []C64Color, variants []bruteFlags) []bruteCandidate {
+	nbs := []bruteCandidate{}
+	for i := range cand.colors {
+		for _, col := range colors {
+			if !slices.Contains(cand.colors, col) {
+				n := bruteCandidate{colors: slices.Clone(cand.colors), flags: cand.flags}
+				n.colors[i] = col
+				nbs = append(nbs, n)
 			}
-			continue
 		}
-		if err := img.setPreferredBitpairColors(opt.BitpairColorsString); err != nil {
-			if c.opt.Verbose {
-				log.Printf("skipping permutation %q because setPreferredBitpairColors failed: %v", bitpaircols, err)
+		for j := i + 1; j < len(cand.colors); j++ {
+			n := bruteCandidate{colors: slices.Clone(cand.colors), flags: cand.flags}
+			n.colors[i], n.colors[j] = n.colors[j], n.colors[i]
+			nbs = append(nbs, n)
+		}
+	}
+	for _, flags := range variants {
+		if flags != cand.flags {
+			nbs = append(nbs, bruteCandidate{colors: cand.colors, flags: flags})
+		}
+	}
+	return nbs
+}
+
+// BruteForceBitpairColors searches the bitpair color combinations, combined with the heuristics in
+// opt.BruteForceHeuristics, with the search strategy in opt.BruteForceStrategy, minimizing opt.BruteForceObjective.
+// Animations of koala and hires frames are scored over all frames.
+// Sets img.bpc and the heuristic flags to the best result.
+// If opt.BruteForceCache is set, the winner is stored in and reused from the cache directory.
+func (c *Converter) BruteForceBitpairColors(gfxtype GraphicsType, maxColors int) error {
+	if maxColors > 4 {
+		return fmt.Errorf("maxColors has a max of 4, but it is %d", maxColors)
+	}
+	if gfxtype == unknownGraphicsType {
+		return fmt.Errorf("BruteForceBitpairColors failed: unknownGraphicsType")
+	}
+	if gfxtype == multiColorInterlaceBitmap && len(c.images) < 2 {
+		return fmt.Errorf("BruteForceBitpairColors failed: %s requires 2 images, not %d", gfxtype, len(c.images))
+	}
+	cruncher, err := NewCruncher(c.opt.Cruncher)
+	if err != nil {
+		return err
+	}
+	variants, err := bruteForceVariants(c.opt, c.opt.BruteForceHeuristics, gfxtype)
+	if err != nil {
+		return err
+	}
+	strategy := c.opt.BruteForceStrategy
+	if strategy == "" {
+		strategy = StrategyPermute
+	}
+	if err = validateBruteForceStrategy(strategy); err != nil {
+		return err
+	}
+	objective, err := parseBruteObjective(c.opt.BruteForceObjective)
+	if err != nil {
+		return err
+	}
+	frames, animation := 1, false
+	switch {
+	case gfxtype == multiColorInterlaceBitmap:
+		frames = 2
+	case (gfxtype == multiColorBitmap || gfxtype == singleColorBitmap) && len(c.images) > 1:
+		frames, animation = len(c.images), true
+	}
+	if err = objective.validate(gfxtype, animation); err != nil {
+		return err
+	}
+
+	key := ""
+	if c.opt.BruteForceCache != "" {
+		if key, err = c.bruteCacheKey(gfxtype, maxColors, cruncher, strategy, objective); err != nil {
+			return fmt.Errorf("bruteCacheKey failed: %w", err)
+		}
+		r, ok, err := loadBruteCache(c.opt.BruteForceCache, key)
+		if err != nil {
+			return fmt.Errorf("loadBruteCache failed: %w", err)
+		}
+		if ok {
+			if !c.opt.Quiet {
+				fmt.Printf("brute-force cached winner %q %s (%s)\n", c.opt.OutFile, r, objective.describe(r.metrics))
 			}
-			continue
+			return c.applyBruteResult(r)
 		}
-		jobs <- img
-		total++
-		if !origOpt.Quiet && total%10 == 0 {
-			fmt.Print(".")
+	}
+
+	t0 := time.Now()
+	s := newBruteSearch(c, gfxtype, maxColors, frames, objective, cruncher)
+	if !c.opt.Quiet {
+		fmt.Printf("started %d brute-force workers, scoring %s with %s, using the %s strategy\n", s.workers, objective, cruncher, strategy)
+		if animation {
+			fmt.Printf("scoring all %d frames of the animation\n", frames)
 		}
 	}
-	close(jobs)
-	wg.Wait()
-	wg.Add(1)
-	close(result)
-	wg.Wait()
-	c.opt = origOpt
+	switch strategy {
+	case StrategyPermute:
+		s.permute(variants, c.opt.BruteForceBudget)
+	case StrategyClimb:
+		s.climb(variants, c.opt.BruteForceBudget)
+	}
+	s.close()
+	s.stats.elapsed = time.Since(t0)
 	if !c.opt.Quiet {
 		fmt.Println()
+		fmt.Printf("brute-force %s: %s\n", strategy, s.stats)
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].length < out[j].length })
+
+	out := s.out()
 	if !c.opt.Quiet && len(out) > 5 {
-		threshold := out[0].length + 5
+		threshold := out[0].score + 5
 		d := 0
 		for i := range out {
-			if i > 0 && out[i].length < threshold && d < 10 {
+			if i > 0 && out[i].score < threshold && d < 10 {
 				d++
-				fmt.Printf("you may want to manually try -bpc %s (%d bytes)\n", out[i].bpc, out[i].length)
+				fmt.Printf("you may want to manually try %s (%s)\n", out[i], objective.describe(out[i].metrics))
 			}
 		}
 	}
 	if c.opt.Verbose {
 		for i := range out {
-			extra := ""
-			if out[i].noprevcharcols {
-				extra = "-npcc"
-			}
-			if out[i].nobitpaircounters {
-				if extra != "" {
-					extra += " "
-				}
-				extra += "-nbc"
-			}
-
-			log.Printf("%d: -bpc %s %s (length: %d)", i, out[i].bpc, extra, out[i].length)
+			log.Printf("%d: %s (%s)", i, out[i], objective.describe(out[i].metrics))
 			if !c.opt.VeryVerbose && i == 9 {
 				break
 			}
 		}
-		log.Printf("-brute-force mode tried %d permutations, %d attempts and got %d results, use -vv to display all", count, total, len(out))
+		log.Printf("-brute-force mode tried %d heuristic variants, %d attempts and got %d results, use -vv to display all", len(variants), s.stats.attempts, len(out))
 	}
 	if len(out) == 0 {
 		return fmt.Errorf("no color options found to brute-force")
 	}
 	if !c.opt.Quiet {
-		fmt.Printf("brute-force winner %q -bpc %v (%d bytes)\n", c.opt.OutFile, out[0].bpc, out[0].length)
-	}
-	c.opt.BitpairColorsString = out[0].bpc
-	c.opt.NoPrevCharColors = out[0].noprevcharcols
-	c.opt.NoBitpairCounters = out[0].nobitpaircounters
-	c.images[0].opt.BitpairColorsString = out[0].bpc
-	var bpc []*Color
-	var err error
-	if bpc, err = c.images[0].p.ParseBPC(c.opt.BitpairColorsString); err != nil {
+		fmt.Printf("brute-force winner %q %s (%s)\n", c.opt.OutFile, out[0], objective.describe(out[0].metrics))
+	}
+	if c.opt.BruteForceCache != "" {
+		if err = storeBruteCache(c.opt.BruteForceCache, key, gfxtype, out[0]); err != nil {
+			return fmt.Errorf("storeBruteCache failed: %w", err)
+		}
+	}
+	return c.applyBruteResult(out[0])
+}
+
+// applyBruteResult sets the bitpair colors and heuristic flags of the brute-force winner r.
+func (c *Converter) applyBruteResult(r bruteResult) error {
+	c.BruteForceWinner = r.String()
+	c.opt.BitpairColorsString = r.bpc
+	r.flags.apply(&c.opt)
+	for i := range c.images {
+		r.flags.apply(&c.images[i].opt)
+	}
+	c.images[0].opt.BitpairColorsString = r.bpc
+	bpc, err := c.images[0].p.ParseBPC(c.opt.BitpairColorsString)
+	if err != nil {
 		return fmt.Errorf("p.ParseBPC failed: %w", err)
 	}
 	if len(bpc) > 0 {
@@ -211,79 +694,178 @@ func (c *Converter) BruteForceBitpairColors(gfxtype GraphicsType, maxColors int)
 	return nil
 }
 
-// bruteWorker is launched to receive sourceImages, process and crunch them and deliver results to the result channel.
-func (c *Converter) bruteWorker(i int, wg *sync.WaitGroup, jobs <-chan *sourceImage, result chan bruteResult) {
+// validateBruteForceStrategy returns an error if strategy is unknown.
+func validateBruteForceStrategy(strategy string) error {
+	switch strategy {
+	case "", StrategyPermute, StrategyClimb:
+		return nil
+	}
+	return fmt.Errorf("unknown brute-force strategy %q, use %s or %s", strategy, StrategyPermute, StrategyClimb)
+}
+
+// bruteWorker is launched to receive jobs, process and measure them with cruncher and deliver results to the result channel.
+// A result is delivered for every job, failed conversions and crunches have the error set and are skipped by evaluate.
+func (c *Converter) bruteWorker(i int, wg *sync.WaitGroup, cruncher Cruncher, jobs <-chan bruteJob, result chan bruteResult) {
 	defer wg.Done()
-NEXTJOB:
-	for img := range jobs {
-		err := img.analyze()
+	for job := range jobs {
+		img := job.imgs[0]
+		r := bruteResult{bpc: img.opt.BitpairColorsString, flags: newBruteFlags(img.opt)}
+		r.metrics, r.err = bruteMeasure(job, cruncher)
+		result <- r
+	}
+}
+
+// bruteMeasure converts the images of job and returns the metrics of the result.
+// Interlace pictures are measured with both koalas, animations with all frames.
+func bruteMeasure(job bruteJob, cruncher Cruncher) (m bruteMetrics, err error) {
+	for _, img := range job.imgs {
+		if err = img.analyze(); err != nil && img.opt.VeryVerbose {
+			log.Printf("img.analyze %q failed: %v", img.sourceFilename, err)
+		}
+	}
+	img := job.imgs[0]
+	buf := &bytes.Buffer{}
+	var wt io.WriterTo
+	switch {
+	case job.gfxtype == multiColorInterlaceBitmap:
+		k0, k1, _, err := interlaceKoalaPair(job.imgs[0], job.imgs[1])
 		if err != nil {
-			if img.opt.VeryVerbose {
-				log.Printf("img.analyze %q failed: %v", img.sourceFilename, err)
-				continue NEXTJOB
-			}
+			return m, fmt.Errorf("interlaceKoalaPair %q failed: %w", img.sourceFilename, err)
 		}
-		var wt io.WriterTo
-		switch img.graphicsType {
-		case multiColorBitmap:
-			if wt, err = img.Koala(); err != nil {
-				if img.opt.VeryVerbose {
-					log.Printf("img.Koala %q failed: %v", img.sourceFilename, err)
-				}
-				continue NEXTJOB
-			}
-		case singleColorBitmap:
-			if wt, err = img.Hires(); err != nil {
-				if img.opt.VeryVerbose {
-					log.Printf("img.Hires %q failed: %v", img.sourceFilename, err)
-				}
-				continue NEXTJOB
-			}
-		case multiColorCharset:
-			if wt, err = img.MultiColorCharset(nil); err != nil {
-				if img.opt.VeryVerbose {
-					log.Printf("img.MultiColorCharset %q failed: %v", img.sourceFilename, err)
-				}
-				continue NEXTJOB
-			}
-		case mixedCharset:
-			if len(img.bpc) > 3 {
-				if img.bpc[3].C64Color > 7 {
-					continue NEXTJOB
-				}
-			}
-			if wt, err = img.MixedCharset(nil); err != nil {
-				if img.opt.VeryVerbose {
-					log.Printf("img.MixedCharset %q failed: %v", img.sourceFilename, err)
-				}
-				continue NEXTJOB
+		if _, err = k0.WriteTo(buf); err != nil {
+			return m, fmt.Errorf("k0.WriteTo failed: %w", err)
+		}
+		wt = k1
+	case len(job.imgs) > 1:
+		var frames [][]byte
+		if frames, err = bruteAnimation(job.imgs, buf); err != nil {
+			return m, fmt.Errorf("bruteAnimation %q failed: %w", img.sourceFilename, err)
+		}
+		m.delta = framesLength(frames)
+		for _, frame := range frames {
+			buf.Write(frame)
+		}
+	case img.graphicsType == multiColorBitmap:
+		if wt, err = img.Koala(); err != nil {
+			return m, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
+		}
+	case img.graphicsType == singleColorBitmap:
+		if wt, err = img.Hires(); err != nil {
+			return m, fmt.Errorf("img.Hires %q failed: %w", img.sourceFilename, err)
+		}
+	case img.graphicsType == singleColorCharset:
+		ch, err := img.SingleColorCharset(nil)
+		if err != nil {
+			return m, fmt.Errorf("img.SingleColorCharset %q failed: %w", img.sourceFilename, err)
+		}
+		wt, m.chars = ch, uniqueChars(ch.Screen[:], 0xff)
+	case img.graphicsType == multiColorCharset:
+		ch, err := img.MultiColorCharset(nil)
+		if err != nil {
+			return m, fmt.Errorf("img.MultiColorCharset %q failed: %w", img.sourceFilename, err)
+		}
+		wt, m.chars = ch, uniqueChars(ch.Screen[:], 0xff)
+	case img.graphicsType == mixedCharset:
+		if len(img.bpc) > 3 {
+			if img.bpc[3].C64Color > 7 {
+				return m, fmt.Errorf("impossible d800 color %d", img.bpc[3].C64Color)
 			}
-		default:
-			log.Printf("skip unsupported bruteforce graphicsType: %s", img.graphicsType)
-			continue NEXTJOB
 		}
-		buf := bytes.Buffer{}
-		if _, err = wt.WriteTo(&buf); err != nil {
-			panic(err)
+		ch, err := img.MixedCharset(nil)
+		if err != nil {
+			return m, fmt.Errorf("img.MixedCharset %q failed: %w", img.sourceFilename, err)
+		}
+		wt, m.chars = ch, uniqueChars(ch.Screen[:], 0xff)
+	case img.graphicsType == ecmCharset:
+		ch, err := img.ECMCharset(nil)
+		if err != nil {
+			return m, fmt.Errorf("img.ECMCharset %q failed: %w", img.sourceFilename, err)
+		}
+		// the upper 2 bits of the screen codes select the background color
+		wt, m.chars = ch, uniqueChars(ch.Screen[:], 0x3f)
+	case img.graphicsType == singleColorSprites:
+		if wt, err = img.SingleColorSprites(); err != nil {
+			return m, fmt.Errorf("img.SingleColorSprites %q failed: %w", img.sourceFilename, err)
 		}
+	case img.graphicsType == multiColorSprites:
+		if wt, err = img.MultiColorSprites(); err != nil {
+			return m, fmt.Errorf("img.MultiColorSprites %q failed: %w", img.sourceFilename, err)
+		}
+	default:
+		log.Printf("skip unsupported bruteforce graphicsType: %s", img.graphicsType)
+		return m, fmt.Errorf("unsupported bruteforce graphicsType: %s", img.graphicsType)
+	}
+	if wt != nil {
+		if _, err = wt.WriteTo(buf); err != nil {
+			return m, fmt.Errorf("WriteTo %q failed: %w", img.sourceFilename, err)
+		}
+	}
+	m.raw = buf.Len()
+	if !job.crunch {
+		return m, nil
+	}
+	crunched, err := cruncher.Crunch(buf.Bytes())
+	if err != nil {
+		return m, fmt.Errorf("%s crunch %q failed: %w", cruncher, img.sourceFilename, err)
+	}
+	m.crunched = len(crunched)
+	return m, nil
+}
 
-		tscopt := TSCrunch.Options{PRG: true, QUIET: true, Fast: true}
-		tsc, err := TSCrunch.New(tscopt, &buf)
+// bruteAnimation converts the analyzed koala or hires frames like WriteAnimationTo, writes the first frame to buf
+// and returns the animation frames produced by processAnimation.
+func bruteAnimation(imgs []*sourceImage, buf *bytes.Buffer) ([][]byte, error) {
+	opt := imgs[0].opt
+	var prevBpcCache *[FullScreenChars]map[C64Color]byte
+	switch imgs[0].graphicsType {
+	case multiColorBitmap:
+		kk := make([]Koala, len(imgs))
+		for i, img := range imgs {
+			img.prevBpcCache = prevBpcCache
+			k, err := img.Koala()
+			if err != nil {
+				return nil, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
+			}
+			if i > 0 {
+				k.keepUnusedColors(kk[i-1])
+			}
+			kk[i] = k
+			bpcCache := img.bpcCache
+			prevBpcCache = &bpcCache
+		}
+		tt, err := animationTransitions(opt, len(kk))
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("animationTransitions failed: %w", err)
+		}
+		if _, err = kk[tt[0].from].WriteTo(buf); err != nil {
+			return nil, fmt.Errorf("WriteTo failed: %w", err)
+		}
+		return processAnimation(opt, makeCharer(kk), tt, koalaFrameDelays(kk))
+	case singleColorBitmap:
+		hh := make([]Hires, len(imgs))
+		for i, img := range imgs {
+			img.prevBpcCache = prevBpcCache
+			h, err := img.Hires()
+			if err != nil {
+				return nil, fmt.Errorf("img.Hires %q failed: %w", img.sourceFilename, err)
+			}
+			if i > 0 {
+				h.keepUnusedColors(hh[i-1])
+			}
+			hh[i] = h
+			bpcCache := img.bpcCache
+			prevBpcCache = &bpcCache
 		}
-		compressed := bytes.Buffer{}
-		_, err = tsc.WriteTo(&compressed)
+		tt, err := animationTransitions(opt, len(hh))
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("animationTransitions failed: %w", err)
 		}
-		result <- bruteResult{
-			bpc:               img.opt.BitpairColorsString,
-			noprevcharcols:    img.opt.NoPrevCharColors,
-			nobitpaircounters: img.opt.NoBitpairCounters,
-			length:            compressed.Len(),
+		if _, err = hh[tt[0].from].WriteTo(buf); err != nil {
+			return nil, fmt.Errorf("WriteTo failed: %w", err)
 		}
+		return processAnimation(opt, makeCharer(hh), tt, hiresFrameDelays(hh))
 	}
+	return nil, fmt.Errorf("unsupported brute-force animation graphicsType: %s", imgs[0].graphicsType)
 }
 
 // SortedColors returns Colors sorted by number of chars each Color is used in.
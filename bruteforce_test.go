@@ -0,0 +1,225 @@
+package png2prg
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBruteForceVariants(t *testing.T) {
+	t.Parallel()
+	variants, err := bruteForceVariants(Options{}, "", multiColorBitmap)
+	require.NoError(t, err)
+	assert.Equal(t, []bruteFlags{{}}, variants)
+
+	variants, err = bruteForceVariants(Options{NoBitpairCounters: true}, "npcc,nbc,npcc", multiColorBitmap)
+	require.NoError(t, err)
+	require.Len(t, variants, 4)
+	assert.Equal(t, "-nbc", variants[0].String())
+	assert.Equal(t, "-npcc -nbc", variants[1].String())
+	assert.Equal(t, "", variants[2].String())
+	assert.Equal(t, "-npcc", variants[3].String())
+
+	// npe only applies to mc, mixed and ecm charsets, fpe to sc charsets.
+	variants, err = bruteForceVariants(Options{}, HeuristicAll, multiColorCharset)
+	require.NoError(t, err)
+	assert.Len(t, variants, 8)
+	variants, err = bruteForceVariants(Options{}, HeuristicAll, singleColorCharset)
+	require.NoError(t, err)
+	assert.Len(t, variants, 8)
+	assert.Contains(t, variants, bruteFlags{forcePackEmptyChar: true})
+
+	_, err = bruteForceVariants(Options{}, "npcc,dali", multiColorBitmap)
+	assert.Error(t, err)
+
+	opt := Options{}
+	bruteResult{flags: variants[7]}.flags.apply(&opt)
+	assert.Equal(t, variants[7], newBruteFlags(opt))
+}
+
+func TestBruteForceClimb(t *testing.T) {
+	t.Parallel()
+	opt := Options{Quiet: true, BruteForce: true, BruteForceStrategy: StrategyClimb, BruteForceBudget: 40, NumWorkers: 2}
+	c, err := NewFromPath(opt, "testdata/mixedcharset/avatar.png")
+	require.NoError(t, err)
+	_, err = c.WriteTo(io.Discard)
+	require.NoError(t, err)
+	require.NotEmpty(t, c.BruteForceWinner)
+
+	s := &bruteSearch{cache: map[string]bruteResult{}}
+	cand := bruteCandidate{colors: []C64Color{0, 1, 2, 3}, flags: bruteFlags{noBitpairCounters: true}}
+	assert.Equal(t, "-bpc 0,1,2,3 -nbc", cand.String())
+	nbs := s.neighbors(cand, []C64Color{0, 1, 2, 3, 4, 5}, []bruteFlags{{}, {noBitpairCounters: true}})
+	// 4 positions * 2 unused colors, 6 swaps and 1 other variant
+	assert.Len(t, nbs, 15)
+	assert.Equal(t, "-bpc 0,1,2,3 -nbc", cand.String(), "neighbors must not modify cand")
+
+	s = newBruteSearch(&Converter{opt: Options{NumWorkers: 0}}, multiColorBitmap, 4, 1, bruteObjective{}, failingCruncher{})
+	assert.Equal(t, 1, s.workers)
+	s.close()
+
+	opt.BruteForceStrategy = "anneal"
+	_, err = NewFromPath(opt, "testdata/mixedcharset/avatar.png")
+	assert.Error(t, err)
+}
+
+func TestBruteForceCache(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	opt := Options{Quiet: true, BruteForce: true, BruteForceBudget: 20, BruteForceCache: dir, Cruncher: CruncherLZSA1, NumWorkers: 1}
+	convert := func(opt Options) (*Converter, []byte) {
+		c, err := NewFromPath(opt, "testdata/mixedcharset/avatar.png")
+		require.NoError(t, err)
+		buf := &bytes.Buffer{}
+		_, err = c.WriteTo(buf)
+		require.NoError(t, err)
+		return c, buf.Bytes()
+	}
+	c, want := convert(opt)
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	r, ok, err := loadBruteCache(dir, strings.TrimSuffix(filepath.Base(files[0]), ".json"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, c.BruteForceWinner, r.String())
+	c2, got := convert(opt)
+	assert.Equal(t, c.BruteForceWinner, c2.BruteForceWinner)
+	assert.Equal(t, want, got)
+
+	opt.BruteForceBudget = 10
+	convert(opt)
+	files, err = filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2, "other brute-force options must not reuse the cached winner")
+
+	keyOf := func(opt Options) string {
+		key, err := (&Converter{opt: opt, images: c.images}).bruteCacheKey(multiColorBitmap, 4, tsCruncher{}, StrategyPermute, bruteObjective{})
+		require.NoError(t, err)
+		return key
+	}
+	key := keyOf(opt)
+	for _, o := range []Options{
+		{Playback: "pingpong"}, {FrameOrder: "0,1,0"}, {Keyframes: 4}, {NoPackChars: true}, {Trd: true}, {NoGuess: true},
+		{ForceBorderColor: 1}, {InterlaceFormat: "truepaint"}, {BitpairColorsString: "0,1,2,3"},
+	} {
+		o.Quiet, o.BruteForce, o.BruteForceBudget, o.BruteForceCache, o.Cruncher = opt.Quiet, opt.BruteForce, opt.BruteForceBudget, opt.BruteForceCache, opt.Cruncher
+		assert.NotEqual(t, key, keyOf(o), "%+v", o)
+	}
+	o := opt
+	o.Quiet, o.Verbose, o.NumWorkers, o.OutFile, o.BruteForceCache = false, true, 8, "out.prg", ""
+	assert.Equal(t, key, keyOf(o), "options that do not change the conversion must reuse the cached winner")
+}
+
+func TestBruteForceModes(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		files     []string
+		interlace bool
+	}{
+		{files: []string{"testdata/ecm/orion.png"}},
+		{files: []string{"testdata/hirescharset/arkos.png"}},
+		{files: []string{"testdata/sprites_tank_singlecolor.png"}},
+		{files: []string{"testdata/sprites_tank_multicolor.png"}},
+		{files: []string{"testdata/mcinterlace/flower0.png", "testdata/mcinterlace/flower1.png"}, interlace: true},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(filepath.Base(tc.files[0]), func(t *testing.T) {
+			t.Parallel()
+			opt := Options{Quiet: true, BruteForce: true, BruteForceStrategy: StrategyClimb, BruteForceBudget: 12, Cruncher: CruncherLZSA1, NumWorkers: 1, Interlace: tc.interlace}
+			c, err := NewFromPath(opt, tc.files...)
+			require.NoError(t, err)
+			_, err = c.WriteTo(io.Discard)
+			require.NoError(t, err)
+			assert.NotEmpty(t, c.BruteForceWinner)
+		})
+	}
+}
+
+func TestBruteObjective(t *testing.T) {
+	t.Parallel()
+	o, err := parseBruteObjective("")
+	require.NoError(t, err)
+	assert.Equal(t, "crunched", o.String())
+	m := bruteMetrics{crunched: 1000, raw: 4000, chars: 100, delta: 500}
+	assert.Equal(t, 1000, o.score(m))
+	assert.Equal(t, "1000 bytes", o.describe(m))
+
+	o, err = parseBruteObjective("crunched,chars:2.5")
+	require.NoError(t, err)
+	assert.Equal(t, "crunched,chars:2.5", o.String())
+	assert.Equal(t, 1250, o.score(m))
+	assert.Equal(t, "score 1250: 1000 bytes, 100 chars", o.describe(m))
+	assert.Error(t, o.validate(multiColorBitmap, false))
+	assert.NoError(t, o.validate(ecmCharset, false))
+
+	o, err = parseBruteObjective("delta")
+	require.NoError(t, err)
+	assert.Error(t, o.validate(multiColorBitmap, false))
+	assert.NoError(t, o.validate(multiColorBitmap, true))
+
+	for _, s := range []string{"size", "chars:0", "raw:x", "crunched,"} {
+		_, err = parseBruteObjective(s)
+		assert.Error(t, err, s)
+	}
+
+	assert.Equal(t, 2, uniqueChars([]byte{0x01, 0x41, 0x81, 0x02}, 0x3f))
+	assert.Equal(t, 4, uniqueChars([]byte{0x01, 0x41, 0x81, 0x02}, 0xff))
+}
+
+func TestBruteForceAnimationDelta(t *testing.T) {
+	t.Parallel()
+	files, err := filepath.Glob("testdata/evoluer/PIC0[1-4].png")
+	require.NoError(t, err)
+	opt := Options{Quiet: true, BruteForce: true, BruteForceStrategy: StrategyClimb, BruteForceBudget: 6, BruteForceObjective: ObjectiveDelta, NumWorkers: 1}
+	c, err := NewFromPath(opt, files...)
+	require.NoError(t, err)
+	_, err = c.WriteTo(io.Discard)
+	require.NoError(t, err)
+	assert.NotEmpty(t, c.BruteForceWinner)
+}
+
+// failingCruncher fails every crunch.
+type failingCruncher struct{}
+
+func (failingCruncher) Crunch(prg []byte) ([]byte, error) { return nil, errors.New("crunch failed") }
+func (failingCruncher) CrunchSFX(prg []byte, jumpTo Word) ([]byte, error) {
+	return nil, errors.New("crunch failed")
+}
+func (failingCruncher) String() string { return "failing" }
+
+func TestBruteWorkerError(t *testing.T) {
+	t.Parallel()
+	f, err := os.Open(inFile)
+	require.NoError(t, err)
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	require.NoError(t, err)
+	opt := Options{Quiet: true}
+	img, err := NewSourceImage(opt, 0, src)
+	require.NoError(t, err)
+
+	c := &Converter{opt: opt}
+	jobs, results := make(chan bruteJob, 1), make(chan bruteResult, 1)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go c.bruteWorker(1, wg, failingCruncher{}, jobs, results)
+	jobs <- bruteJob{gfxtype: multiColorBitmap, imgs: []*sourceImage{&img}, crunch: true}
+	close(jobs)
+	r := <-results
+	wg.Wait()
+	assert.ErrorContains(t, r.err, "crunch failed")
+	assert.NotZero(t, r.metrics.raw)
+}
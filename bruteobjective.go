@@ -0,0 +1,157 @@
+package png2prg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Objectives to minimize in brute-force mode, see Options.BruteForceObjective.
+const (
+	ObjectiveCrunched = "crunched" // crunched size in bytes
+	ObjectiveRaw      = "raw"      // uncrunched size in bytes
+	ObjectiveChars    = "chars"    // number of unique chars, for charsets only
+	ObjectiveDelta    = "delta"    // total size of the animation frames in bytes, for koala and hires animations only
+)
+
+// bruteMetrics are the measurements of a brute-force attempt, scored by a bruteObjective.
+// Animations are measured over all frames: the first frame followed by the frames produced by processAnimation.
+type bruteMetrics struct {
+	crunched int
+	raw      int
+	chars    int
+	delta    int
+}
+
+func (m bruteMetrics) get(name string) int {
+	switch name {
+	case ObjectiveCrunched:
+		return m.crunched
+	case ObjectiveRaw:
+		return m.raw
+	case ObjectiveChars:
+		return m.chars
+	case ObjectiveDelta:
+		return m.delta
+	}
+	return 0
+}
+
+type objectiveTerm struct {
+	name   string
+	weight float64
+}
+
+// bruteObjective is the weighted sum of metrics to minimize.
+type bruteObjective []objectiveTerm
+
+// parseBruteObjective parses a comma separated list of objectives with optional weights, like "crunched,chars:16".
+// An empty string returns the crunched objective.
+func parseBruteObjective(s string) (bruteObjective, error) {
+	if s == "" {
+		return bruteObjective{{ObjectiveCrunched, 1}}, nil
+	}
+	o := bruteObjective{}
+	for _, v := range strings.Split(s, ",") {
+		t := objectiveTerm{name: strings.TrimSpace(v), weight: 1}
+		if name, weight, ok := strings.Cut(t.name, ":"); ok {
+			w, err := strconv.ParseFloat(weight, 64)
+			if err != nil {
+				return nil, fmt.Errorf("strconv.ParseFloat weight of objective %q failed: %w", v, err)
+			}
+			if w <= 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+				return nil, fmt.Errorf("weight of objective %q must be positive", v)
+			}
+			t.name, t.weight = name, w
+		}
+		switch t.name {
+		case ObjectiveCrunched, ObjectiveRaw, ObjectiveChars, ObjectiveDelta:
+		default:
+			return nil, fmt.Errorf("unknown brute-force objective %q, use %s, %s, %s or %s", t.name, ObjectiveCrunched, ObjectiveRaw, ObjectiveChars, ObjectiveDelta)
+		}
+		o = append(o, t)
+	}
+	return o, nil
+}
+
+// validate returns an error if o cannot be measured for gfxtype.
+func (o bruteObjective) validate(gfxtype GraphicsType, animation bool) error {
+	for _, t := range o {
+		switch t.name {
+		case ObjectiveChars:
+			switch gfxtype {
+			case singleColorCharset, multiColorCharset, mixedCharset, ecmCharset:
+			default:
+				return fmt.Errorf("objective %s requires a charset graphics mode, not %s", t.name, gfxtype)
+			}
+		case ObjectiveDelta:
+			if !animation {
+				return fmt.Errorf("objective %s requires a koala or hires animation", t.name)
+			}
+		}
+	}
+	return nil
+}
+
+// uses returns true if o contains the objective name.
+func (o bruteObjective) uses(name string) bool {
+	for _, t := range o {
+		if t.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// score returns the weighted sum of m, lower is better.
+func (o bruteObjective) score(m bruteMetrics) int {
+	sum := 0.0
+	for _, t := range o {
+		sum += t.weight * float64(m.get(t.name))
+	}
+	return int(math.Round(sum))
+}
+
+// describe returns the metrics of m used by o, in human readable form.
+func (o bruteObjective) describe(m bruteMetrics) string {
+	units := map[string]string{
+		ObjectiveCrunched: "bytes",
+		ObjectiveRaw:      "raw bytes",
+		ObjectiveChars:    "chars",
+		ObjectiveDelta:    "delta bytes",
+	}
+	ss := []string{}
+	for _, t := range o {
+		ss = append(ss, fmt.Sprintf("%d %s", m.get(t.name), units[t.name]))
+	}
+	if len(o) == 1 && o[0].weight == 1 {
+		return ss[0]
+	}
+	return fmt.Sprintf("score %d: %s", o.score(m), strings.Join(ss, ", "))
+}
+
+func (o bruteObjective) String() string {
+	ss := []string{}
+	for _, t := range o {
+		if t.weight == 1 {
+			ss = append(ss, t.name)
+			continue
+		}
+		ss = append(ss, t.name+":"+strconv.FormatFloat(t.weight, 'g', -1, 64))
+	}
+	return strings.Join(ss, ",")
+}
+
+// uniqueChars returns the number of different chars used in screen, mask selects the char bits of a screen code.
+func uniqueChars(screen []byte, mask byte) int {
+	used := [256]bool{}
+	n := 0
+	for _, v := range screen {
+		if !used[v&mask] {
+			used[v&mask] = true
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,182 @@
+package png2prg
+
+import (
+	"fmt"
+
+	"github.com/staD020/sid"
+)
+
+// ioStart and ioEnd mark the io area, the animation displayers cannot read frames from underneath it.
+const (
+	ioStart = 0xd000
+	ioEnd   = 0xe000
+)
+
+// An animationBudget contains the memory layout of a koala or hires animation displayer,
+// to plan the memory usage of the frames before linking.
+type animationBudget struct {
+	displayer []byte
+	picture   LinkMap
+	start     Word // address of the first frame
+	fadeStart Word // start of the generated fade code up to the io area, unless opt.NoFade is set
+}
+
+// A memoryPlan describes how the frames of an animation fit in the free memory from start to end.
+type memoryPlan struct {
+	start, end Word
+	sizes      []int
+	// needed is the number of bytes needed by all frames, including the end of all frames marker and frame table.
+	needed int
+	// overflow is the index of the first frame that does not fit, -1 if all frames fit.
+	overflow int
+	// sidLoad and sidLength describe the sid, sidMove is the address it is moved to if it overlaps the frames.
+	sidLoad   Word
+	sidLength int
+	sidMove   Word
+	sidFits   bool
+}
+
+// fits returns true if all frames and the sid fit in memory.
+func (p memoryPlan) fits() bool {
+	return p.overflow < 0 && p.sidFits
+}
+
+// String returns a description of the overflow, for use in error messages.
+func (p memoryPlan) String() string {
+	free := int(p.end) - int(p.start)
+	if p.overflow >= 0 {
+		return fmt.Sprintf("frame %d of %d (%d bytes) does not fit, the frames need %d bytes, but only %d bytes are free from %s to %s",
+			p.overflow, len(p.sizes), p.sizes[p.overflow], p.needed, free, p.start, p.end)
+	}
+	if !p.sidFits {
+		return fmt.Sprintf("the frames use %s - %s, no free memory is left for the sid of %d bytes", p.start, p.start+Word(p.needed), p.sidLength)
+	}
+	return fmt.Sprintf("the frames use %s - %s, %d of %d bytes free", p.start, p.start+Word(p.needed), free-p.needed, free)
+}
+
+// plan returns the memory plan for frames, with the displayer, fade code and sid according to opt.
+// A sid overlapping the frames is planned to be relocated, unless opt.NoSIDRelocation is set.
+func (b animationBudget) plan(opt Options, frames [][]byte) (memoryPlan, error) {
+	l := NewLinker(0, false)
+	if _, err := l.WritePrg(b.displayer); err != nil {
+		return memoryPlan{}, fmt.Errorf("link.WritePrg failed: %w", err)
+	}
+	if _, err := l.WriteMap(b.picture); err != nil {
+		return memoryPlan{}, fmt.Errorf("link.WriteMap failed: %w", err)
+	}
+	if !opt.NoFade {
+		l.Block(b.fadeStart, ioStart)
+	}
+	l.Block(ioStart, ioEnd)
+	p := memoryPlan{start: b.start, overflow: -1, sidFits: true}
+	if opt.IncludeSID != "" {
+		s, err := sid.LoadSID(opt.IncludeSID)
+		if err != nil {
+			return memoryPlan{}, fmt.Errorf("sid.LoadSID failed: %w", err)
+		}
+		p.sidLoad, p.sidLength = Word(s.LoadAddress()), len(s.RawBytes())
+		if opt.NoSIDRelocation {
+			l.Block(p.sidLoad, p.sidLoad+Word(p.sidLength))
+		}
+	}
+
+	end := int(b.start)
+	for end <= MaxMemory && !l.Overlaps(Word(end), 1) {
+		end++
+	}
+	p.end = Word(end)
+	free := end - int(b.start)
+
+	tail := len(endOfFrames(opt, b.start, frames))
+	p.sizes = make([]int, len(frames))
+	for i, f := range frames {
+		p.sizes[i] = len(f)
+		p.needed += len(f)
+		if p.overflow < 0 && p.needed+tail > free {
+			p.overflow = i
+		}
+	}
+	p.needed += tail
+
+	if p.sidLength > 0 && !opt.NoSIDRelocation && p.overflow < 0 {
+		l.Block(b.start, b.start+Word(p.needed))
+		if l.Overlaps(p.sidLoad, p.sidLength) {
+			addr, err := findSIDArea(l, p.sidLoad, p.sidLength)
+			p.sidMove, p.sidFits = addr, err == nil
+		}
+	}
+	return p, nil
+}
+
+// endOfFrames returns the end of all frames marker and optional frame table, following frames at start.
+func endOfFrames(opt Options, start Word, frames [][]byte) []byte {
+	end, _ := appendFrameTable(opt, start, frames, animationEnd(opt, start, framesLength(frames)))
+	return end
+}
+
+// truncateTransitions returns the first n transitions of tt. Unless playing once, a transition back to
+// the first frame is appended to keep the animation looping.
+func truncateTransitions(opt Options, tt []transition, n int) []transition {
+	if n >= len(tt) {
+		return tt
+	}
+	t := append([]transition{}, tt[:n]...)
+	if opt.Playback != PlaybackOnce && t[n-1].to != tt[0].from {
+		t = append(t, transition{from: t[n-1].to, to: tt[0].from})
+	}
+	return t
+}
+
+// fitAnimation processes the transitions tt with process and plans the memory usage of the resulting frames.
+// If the frames do not fit and opt.FitMemory is set, the fade is dropped first, then the animation is truncated
+// until it fits. It returns opt with the decisions applied and the frames.
+func fitAnimation(opt Options, b animationBudget, tt []transition, process func(tt []transition) ([][]byte, error)) (Options, [][]byte, error) {
+	frames, err := process(tt)
+	if err != nil {
+		return opt, nil, fmt.Errorf("processing frames failed: %w", err)
+	}
+	p, err := b.plan(opt, frames)
+	if err != nil {
+		return opt, nil, err
+	}
+	if !p.fits() && opt.FitMemory && !opt.NoFade {
+		opt.NoFade = true
+		if p, err = b.plan(opt, frames); err != nil {
+			return opt, nil, err
+		}
+		if !opt.Quiet {
+			fmt.Printf("memory plan: dropped the fade code to free %s - %s\n", b.fadeStart, Word(ioStart))
+		}
+	}
+	if !p.fits() && opt.FitMemory {
+		total := len(tt)
+		n := total - 1
+		if p.overflow > 0 && p.overflow < n {
+			n = p.overflow
+		}
+		for ; n > 0 && !p.fits(); n-- {
+			if frames, err = process(truncateTransitions(opt, tt, n)); err != nil {
+				return opt, nil, fmt.Errorf("processing frames failed: %w", err)
+			}
+			if p, err = b.plan(opt, frames); err != nil {
+				return opt, nil, err
+			}
+		}
+		if p.fits() && !opt.Quiet {
+			fmt.Printf("memory plan: truncated the animation from %d to %d frames\n", total, len(frames))
+		}
+	}
+	if !p.fits() {
+		if opt.FitMemory {
+			return opt, nil, fmt.Errorf("animation does not fit in memory: %s", p)
+		}
+		return opt, nil, fmt.Errorf("animation does not fit in memory: %s, use -fit to drop the fade or truncate the animation", p)
+	}
+	if p.sidMove != 0 && !opt.Quiet {
+		fmt.Printf("memory plan: the sid at %s overlaps the frames and is moved to %s\n", p.sidLoad, p.sidMove)
+	}
+	if opt.Verbose {
+		fmt.Printf("memory plan: %s\n", p)
+	}
+	return opt, frames, nil
+}
@@ -0,0 +1,35 @@
+package png2prg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateTransitions(t *testing.T) {
+	t.Parallel()
+	tt := []transition{{0, 1}, {1, 2}, {2, 3}, {3, 0}}
+	assert.Equal(t, tt, truncateTransitions(Options{}, tt, 4))
+	assert.Equal(t, []transition{{0, 1}, {1, 2}, {2, 0}}, truncateTransitions(Options{}, tt, 2))
+	assert.Equal(t, []transition{{0, 1}, {1, 2}}, truncateTransitions(Options{Playback: PlaybackOnce}, tt, 2))
+}
+
+func TestAnimationBudgetPlan(t *testing.T) {
+	t.Parallel()
+	b := animationBudget{displayer: koalaDisplayAnim, start: koalaAnimationStart, fadeStart: koalaFadePassStart}
+	frames := [][]byte{make([]byte, 0x2000), make([]byte, 0x2000), make([]byte, 0x2000)}
+
+	p, err := b.plan(Options{}, frames)
+	require.NoError(t, err)
+	assert.Equal(t, Word(koalaFadePassStart), p.end)
+	assert.Equal(t, 2, p.overflow)
+	assert.Equal(t, 3*0x2000+3, p.needed)
+	assert.False(t, p.fits())
+
+	p, err = b.plan(Options{NoFade: true}, frames)
+	require.NoError(t, err)
+	assert.Equal(t, Word(ioStart), p.end)
+	assert.Equal(t, -1, p.overflow)
+	assert.True(t, p.fits())
+}
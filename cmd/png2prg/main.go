@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,11 +19,15 @@ import (
 )
 
 var (
-	memProfile string
-	cpuProfile string
-	help       bool
-	parallel   bool
-	altOffset  bool
+	memProfile  string
+	cpuProfile  string
+	help        bool
+	parallel    bool
+	altOffset   bool
+	blendTable  string
+	memoryMap   bool
+	memoryChart string
+	vicBank     int
 )
 
 func main() {
@@ -48,11 +53,20 @@ func main() {
 		png2prg.PrintHelp()
 		return
 	}
+	if blendTable != "" {
+		if err := writeBlendTable(blendTable, opt); err != nil {
+			log.Fatalf("writeBlendTable failed: %v", err)
+		}
+		return
+	}
+	if memoryChart != "" && memoryChart != png2prg.MemoryChartPNG && memoryChart != png2prg.MemoryChartSVG {
+		log.Fatalf("unknown -memory-chart format %q, use %s or %s", memoryChart, png2prg.MemoryChartPNG, png2prg.MemoryChartSVG)
+	}
 	if len(filenames) == 0 {
 		png2prg.PrintUsage()
 		return
 	}
-	if opt.IncludeSID != "" && !opt.Display {
+	if opt.IncludeSID != "" && !opt.Display && !opt.Slideshow {
 		log.Printf("ignoring sid %q, it makes no sense without the -display flag set.\n", opt.IncludeSID)
 	}
 
@@ -80,6 +94,28 @@ func main() {
 	}
 }
 
+// writeBlendTable writes the blend table png of the palette to opt.OutFile or blend_table.png.
+func writeBlendTable(palette string, opt png2prg.Options) error {
+	filename := opt.OutFile
+	if filename == "" {
+		filename = "blend_table.png"
+	}
+	if opt.TargetDir != "" {
+		filename = filepath.Join(opt.TargetDir, filepath.Base(filename))
+	}
+	buf := bytes.Buffer{}
+	if err := png2prg.WriteBlendTable(&buf, palette); err != nil {
+		return fmt.Errorf("png2prg.WriteBlendTable failed: %w", err)
+	}
+	if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("os.WriteFile failed: %w", err)
+	}
+	if !opt.Quiet {
+		fmt.Printf("write blend table of palette %q to %q\n", palette, filename)
+	}
+	return nil
+}
+
 // processAsOne converts the filenames as single entity, this may be 2 images for interlace and 2 or more for animations.
 // It reads the files(s) from filesystem and stores the resulting .prg.
 // returns error on failure.
@@ -115,6 +151,16 @@ func processAsOne(opt *png2prg.Options, filenames ...string) error {
 	if !opt.Quiet {
 		fmt.Printf("write %d bytes to %q in %q format.\n", n, opt.OutFile, p.FinalGraphicsType)
 	}
+	if len(p.Segments) > 0 {
+		if err = writeSegments(p, opt.OutFile, opt.Quiet); err != nil {
+			return fmt.Errorf("writeSegments failed: %w", err)
+		}
+	}
+	if memoryMap || memoryChart != "" {
+		if err = writeMemoryMap(p, opt.OutFile, opt.Quiet); err != nil {
+			return fmt.Errorf("writeMemoryMap failed: %w", err)
+		}
+	}
 	if opt.Symbols && len(p.Symbols) > 0 {
 		fn := strings.TrimSuffix(opt.OutFile, ".prg") + ".sym"
 		wsym, err := os.Create(fn)
@@ -135,6 +181,80 @@ func processAsOne(opt *png2prg.Options, filenames ...string) error {
 	return nil
 }
 
+// writeSegments writes the segments of a streamed animation to separate files next to outFile, followed by the .json index.
+func writeSegments(p *png2prg.Converter, outFile string, quiet bool) error {
+	dir := filepath.Dir(outFile)
+	for _, s := range p.Segments {
+		fn := filepath.Join(dir, s.File)
+		w, err := os.Create(fn)
+		if err != nil {
+			return fmt.Errorf("os.Create failed: %w", err)
+		}
+		n, err := s.WriteTo(w)
+		w.Close()
+		if err != nil {
+			return fmt.Errorf("s.WriteTo failed: %w", err)
+		}
+		if !quiet {
+			fmt.Printf("write %d bytes to %q as %q on disk.\n", n, fn, s.Name)
+		}
+	}
+	fn := strings.TrimSuffix(outFile, ".prg") + ".json"
+	w, err := os.Create(fn)
+	if err != nil {
+		return fmt.Errorf("os.Create failed: %w", err)
+	}
+	defer w.Close()
+	if _, err = p.WriteStreamIndexTo(w); err != nil {
+		return fmt.Errorf("p.WriteStreamIndexTo failed: %w", err)
+	}
+	if !quiet {
+		fmt.Printf("write %q\n", fn)
+	}
+	return nil
+}
+
+// writeMemoryMap writes the memory map of p next to outFile as .map.json with -memory-map,
+// and as .map.png or .map.svg chart with -memory-chart.
+func writeMemoryMap(p *png2prg.Converter, outFile string, quiet bool) error {
+	m, ok := p.MemoryMap()
+	if !ok {
+		if !quiet {
+			fmt.Printf("no memory map for %q, it is not linked\n", outFile)
+		}
+		return nil
+	}
+	base := strings.TrimSuffix(outFile, ".prg") + ".map"
+	buf := &bytes.Buffer{}
+	if memoryChart != "" {
+		if err := m.WriteChartTo(buf, memoryChart); err != nil {
+			return fmt.Errorf("m.WriteChartTo failed: %w", err)
+		}
+		fn := base + "." + memoryChart
+		if err := os.WriteFile(fn, buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("os.WriteFile failed: %w", err)
+		}
+		if !quiet {
+			fmt.Printf("write %q\n", fn)
+		}
+	}
+	if !memoryMap {
+		return nil
+	}
+	buf.Reset()
+	if _, err := m.WriteTo(buf); err != nil {
+		return fmt.Errorf("m.WriteTo failed: %w", err)
+	}
+	fn := base + ".json"
+	if err := os.WriteFile(fn, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("os.WriteFile failed: %w", err)
+	}
+	if !quiet {
+		fmt.Printf("write %q\n", fn)
+	}
+	return nil
+}
+
 // processInParallel processes all filenames in parallel.
 // It starts the workers and feeds filenames to them for processing.
 // The function returns when all jobs are finished.
@@ -254,9 +374,11 @@ func initAndParseFlags() (opt png2prg.Options) {
 	flag.StringVar(&opt.TargetDir, "td", "", "targetdir")
 	flag.StringVar(&opt.TargetDir, "targetdir", "", "specify targetdir")
 	flag.StringVar(&opt.GraphicsMode, "m", "", "mode")
-	flag.StringVar(&opt.GraphicsMode, "mode", "", "force graphics mode to koala, hires, mixedcharset, sccharset, mccharset (4col), scsprites or mcsprites")
+	flag.StringVar(&opt.GraphicsMode, "mode", "", "force graphics mode to koala, hires, mixedcharset, sccharset, mccharset (4col), scsprites, mcsprites, mcibitmap, hiresinterlace or ifli (blended colors)")
 	flag.BoolVar(&opt.Interlace, "i", false, "interlace")
-	flag.BoolVar(&opt.Interlace, "interlace", false, "when you supply 2 frames, specify -interlace to treat the images as such")
+	flag.BoolVar(&opt.Interlace, "interlace", false, "when you supply 2 frames, specify -interlace to treat the images as such, or pairs of frames for an interlace animation")
+	flag.StringVar(&opt.InterlaceFormat, "if", "", "interlace-format")
+	flag.StringVar(&opt.InterlaceFormat, "interlace-format", "", "interlace output format without -display: "+png2prg.InterlaceFormatDrazlace+", "+png2prg.InterlaceFormatTruePaint+", "+png2prg.InterlaceFormatHires+" or "+png2prg.InterlaceFormatGunpaint+" (default automatic)")
 	flag.IntVar(&opt.D016Offset, "d016", 1, "d016offset")
 	flag.IntVar(&opt.D016Offset, "d016offset", 1, "number of pixels to shift with d016 when using interlace")
 	flag.StringVar(&opt.BitpairColorsString, "bpc", "", "bitpair-colors")
@@ -265,6 +387,16 @@ func initAndParseFlags() (opt png2prg.Options) {
 
 	flag.BoolVar(&opt.BruteForce, "bf", false, "brute-force")
 	flag.BoolVar(&opt.BruteForce, "brute-force", false, "brute force bitpair-colors")
+	flag.StringVar(&opt.BruteForceHeuristics, "bfh", "", "brute-force-heuristics")
+	flag.StringVar(&opt.BruteForceHeuristics, "brute-force-heuristics", "", "comma separated heuristics to permute in -brute-force mode: npcc, nbc, npe, fpe or all")
+	flag.StringVar(&opt.BruteForceStrategy, "bfs", png2prg.StrategyPermute, "brute-force-strategy")
+	flag.StringVar(&opt.BruteForceStrategy, "brute-force-strategy", png2prg.StrategyPermute, "brute-force search strategy: "+png2prg.StrategyPermute+" (8 most used colors) or "+png2prg.StrategyClimb+" (hill-climbing over all colors)")
+	flag.IntVar(&opt.BruteForceBudget, "bfb", 0, "brute-force-budget")
+	flag.IntVar(&opt.BruteForceBudget, "brute-force-budget", 0, "maximum number of brute-force attempts, 0 means all permutations or 500 climbing")
+	flag.StringVar(&opt.BruteForceCache, "bfc", "", "brute-force-cache")
+	flag.StringVar(&opt.BruteForceCache, "brute-force-cache", "", "directory to cache brute-force winners in, unchanged images reuse the cached winner")
+	flag.StringVar(&opt.BruteForceObjective, "bfo", "", "brute-force-objective")
+	flag.StringVar(&opt.BruteForceObjective, "brute-force-objective", "", "comma separated objectives to minimize in -brute-force mode, with optional :weight: crunched (default), raw, chars or delta (animations)")
 	flag.BoolVar(&opt.NoGuess, "ng", false, "no-guess")
 	flag.BoolVar(&opt.NoGuess, "no-guess", false, "do not guess preferred bitpair-colors")
 	flag.BoolVar(&opt.NoPackChars, "np", false, "no-pack")
@@ -278,18 +410,39 @@ func initAndParseFlags() (opt png2prg.Options) {
 	flag.BoolVar(&opt.NoBitpairCounters, "nbc", false, "no-bitpair-counters")
 	flag.BoolVar(&opt.NoBitpairCounters, "no-bitpair-counters", false, "do not use c64color bitpar counters optimization")
 	flag.BoolVar(&opt.NoCrunch, "nc", false, "no-crunch")
-	flag.BoolVar(&opt.NoCrunch, "no-crunch", false, "do not TSCrunch displayer")
+	flag.BoolVar(&opt.NoCrunch, "no-crunch", false, "do not crunch displayer")
+	flag.StringVar(&opt.Cruncher, "cruncher", png2prg.CruncherTSCrunch, "cruncher to pack the displayer and score -brute-force results: "+png2prg.CruncherTSCrunch+" or "+png2prg.CruncherLZSA1)
 	flag.BoolVar(&opt.Symbols, "sym", false, "symbols")
 	flag.BoolVar(&opt.Symbols, "symbols", false, "export symbols to .sym")
+	flag.BoolVar(&memoryMap, "mm", false, "memory-map")
+	flag.BoolVar(&memoryMap, "memory-map", false, "export the memory map with named segments (displayer, bitmap, sid, ...) to .map.json")
+	flag.StringVar(&memoryChart, "memory-chart", "", "export the memory map as chart in `format` png or svg to .map.png or .map.svg")
 
 	// flag.BoolVar(&opt.AlternativeFade, "alt-fade", false, "use alternative (less memory hungry) fade for animation displayers.")
 	flag.BoolVar(&opt.NoFade, "nf", false, "no-fade")
 	flag.BoolVar(&opt.NoFade, "no-fade", false, "do not use fade in/out for animation displayers and free up a lot of memory")
 	flag.StringVar(&opt.IncludeSID, "sid", "", "include .sid in displayer (see -help for free memory locations)")
+	flag.IntVar(&opt.SubTune, "subtune", 0, "select the subtune (1-n) of the sid, by default the start song from the sid header is used")
+	flag.BoolVar(&opt.NoSIDRelocation, "nsr", false, "no-sid-reloc")
+	flag.BoolVar(&opt.NoSIDRelocation, "no-sid-reloc", false, "do not relocate sids that overlap the displayer, picture or zeropage addresses in use")
+	flag.IntVar(&vicBank, "bank", -1, "force vic bank 0-3 for the picture in koala and hires displayers, by default bank 0 is used unless the sid overlaps")
 	flag.BoolVar(&opt.NoAnimation, "na", false, "no-anim")
 	flag.BoolVar(&opt.NoAnimation, "no-anim", false, "disable charset animations and store frames as separate screens")
 	flag.IntVar(&opt.FrameDelay, "frame-delay", 6, "frames to wait before displaying next animation frame")
 	flag.IntVar(&opt.WaitSeconds, "wait-seconds", 0, "seconds to wait before animation starts")
+	flag.BoolVar(&opt.NTSC, "ntsc", false, "convert the frame delays of animated gifs to NTSC (60 Hz) frames instead of PAL")
+	flag.StringVar(&opt.Playback, "playback", "loop", "animation playback mode: loop, pingpong or once (play once and hold the last frame)")
+	flag.StringVar(&opt.FrameOrder, "frame-order", "", "play the animation frames in this order, e.g. 0,1,2,1,0,3")
+	flag.IntVar(&opt.Keyframes, "keyframes", 0, "insert a full-screen keyframe every n animation frames and add a frame offset table, to allow seeking")
+	flag.BoolVar(&opt.FitMemory, "fit", false, "drop the fade and truncate koala and hires animations that do not fit in memory, instead of failing")
+	flag.BoolVar(&opt.SpriteSheet, "sprite-sheet", false, "animate each row of a single sprite sheet as an object, using the sprites in the row as frames (requires -display)")
+	flag.BoolVar(&opt.Stream, "stream", false, "split koala and hires animations into separately crunched segment files plus a .json index, loaded from disk by the displayer (requires -display)")
+	flag.BoolVar(&opt.Player, "player", false, "write koala and hires animations with a relocatable player routine for your own code, instead of the displayer")
+	flag.Var(address{&opt.PlayerAddress}, "player-address", "page aligned address of the -player routine, eg $c000, by default the first page after the frames")
+	flag.Var(address{&opt.PlayerZeroPage}, "player-zp", "first of 8 zeropage addresses used by the -player routine, default $08")
+	flag.BoolVar(&opt.Slideshow, "ss", false, "slideshow")
+	flag.BoolVar(&opt.Slideshow, "slideshow", false, "treat multiple images as independent pictures in a slideshow displayer instead of animation frames")
+	flag.IntVar(&opt.SlideDelay, "slide-delay", 5, "seconds to show each picture in a slideshow, 0 waits for space")
 	w := int(runtime.NumCPU() / 2)
 	if w < 1 {
 		w = 1
@@ -298,14 +451,52 @@ func initAndParseFlags() (opt png2prg.Options) {
 	flag.IntVar(&opt.NumWorkers, "workers", w, "number of concurrent workers in -parallel or -brute-force mode")
 	flag.BoolVar(&parallel, "p", false, "parallel")
 	flag.BoolVar(&parallel, "parallel", false, "run number of workers in parallel for fast conversion, treat each image as a standalone, not to be used for animations")
+	flag.StringVar(&blendTable, "bt", "", "blend-table")
+	flag.StringVar(&blendTable, "blend-table", "", "write the 136 blended colors of `palette` (eg vice or pepto) as png to -o or blend_table.png, to draw interlace pictures with")
 	flag.BoolVar(&altOffset, "ao", false, "alt-offset")
 	flag.BoolVar(&altOffset, "alt-offset", false, "use alternate screenshot offset with x,y = 32,36")
 
+	flag.Var(address{&opt.BitmapAddress}, "ba", "bitmap-address")
+	flag.Var(address{&opt.BitmapAddress}, "bitmap-address", "custom bitmap address, eg $6000 (8K aligned, not in $1000-$1fff or $9000-$9fff)")
+	flag.Var(address{&opt.CharsetAddress}, "ca", "charset-address")
+	flag.Var(address{&opt.CharsetAddress}, "charset-address", "custom charset address, eg $4800 (2K aligned, not in $1000-$1fff or $9000-$9fff)")
+	flag.Var(address{&opt.ScreenRAMAddress}, "sa", "screenram-address")
+	flag.Var(address{&opt.ScreenRAMAddress}, "screenram-address", "custom screenram address, eg $4400 (1K aligned, in the same vic bank as bitmap or charset)")
+	flag.Var(address{&opt.ColorRAMAddress}, "cra", "colorram-address")
+	flag.Var(address{&opt.ColorRAMAddress}, "colorram-address", "custom colorram address, eg $5000, d02x colors are stored directly after colorram")
+
 	flag.BoolVar(&opt.Trd, "trd", false, "has side effect of enforcing screenram bitpair colors in level area")
 
 	flag.Parse()
 	if opt.VeryVerbose {
 		opt.Verbose = true
 	}
+	if vicBank >= 0 {
+		opt.DisplayerBank, opt.ForceDisplayerBank = vicBank, true
+	}
 	return opt
 }
+
+// address implements the flag.Value interface for memory addresses in $c000, 0xc000 or decimal notation.
+type address struct {
+	v *int
+}
+
+func (a address) String() string {
+	if a.v == nil || *a.v == 0 {
+		return ""
+	}
+	return fmt.Sprintf("$%04x", *a.v)
+}
+
+func (a address) Set(s string) error {
+	if strings.HasPrefix(s, "$") {
+		s = "0x" + s[1:]
+	}
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", s, err)
+	}
+	*a.v = int(v)
+	return nil
+}
@@ -172,6 +172,22 @@ func (img *sourceImage) newBitpairs(char int, cc []Color, forcePreferred bool) (
 		return bp, nil
 	}
 
+	// prefer the bitpairs of the same char in the previous animation frame, to keep the changes between frames small.
+	// This goes before the preferred bitpair colors, so unchanged chars keep their bitmap when those differ per frame.
+	if img.prevBpcCache != nil {
+		for _, col := range cc {
+			if _, ok := bp.bitpair(col); ok {
+				continue
+			}
+			if prevbp, ok := img.prevBpcCache[char][col.C64Color]; ok && In(bp.bitpairs, prevbp) {
+				bp.add(prevbp, col)
+				if img.opt.VeryVerbose {
+					log.Printf("char %d: previous frame hit for col %s with bitpair %d", char, col, prevbp)
+				}
+			}
+		}
+	}
+
 	// prefill preferred and used colors
 	if len(img.bpc) > 0 {
 		for preferBitpair, preferColor := range img.bpc {
@@ -179,9 +195,15 @@ func (img *sourceImage) newBitpairs(char int, cc []Color, forcePreferred bool) (
 				continue
 			}
 			for _, col := range cc {
-				if preferColor.C64Color == col.C64Color {
-					bp.add(byte(preferBitpair), col)
+				if preferColor.C64Color != col.C64Color {
+					continue
+				}
+				if img.prevBpcCache != nil {
+					if _, ok := bp.bitpair(col); ok || !In(bp.bitpairs, byte(preferBitpair)) {
+						continue
+					}
 				}
+				bp.add(byte(preferBitpair), col)
 			}
 		}
 	}
@@ -0,0 +1,812 @@
+package png2prg
+
+import (
+	"fmt"
+)
+
+// addrMode is the addressing mode of a 6502 instruction.
+type addrMode byte
+
+const (
+	modeImp addrMode = iota
+	modeAcc
+	modeImm
+	modeZp
+	modeZpx
+	modeZpy
+	modeAbs
+	modeAbx
+	modeAby
+	modeInd
+	modeIzx
+	modeIzy
+	modeRel
+)
+
+// size returns the instruction length in bytes, including the opcode.
+func (m addrMode) size() uint16 {
+	switch m {
+	case modeImp, modeAcc:
+		return 1
+	case modeAbs, modeAbx, modeAby, modeInd:
+		return 3
+	}
+	return 2
+}
+
+// absolute returns true if the instruction has a 16 bit operand.
+func (m addrMode) absolute() bool {
+	return m.size() == 3
+}
+
+type mnemonic byte
+
+const (
+	opInvalid mnemonic = iota
+	opADC
+	opAND
+	opASL
+	opBCC
+	opBCS
+	opBEQ
+	opBIT
+	opBMI
+	opBNE
+	opBPL
+	opBRK
+	opBVC
+	opBVS
+	opCLC
+	opCLD
+	opCLI
+	opCLV
+	opCMP
+	opCPX
+	opCPY
+	opDEC
+	opDEX
+	opDEY
+	opEOR
+	opINC
+	opINX
+	opINY
+	opJMP
+	opJSR
+	opLDA
+	opLDX
+	opLDY
+	opLSR
+	opNOP
+	opORA
+	opPHA
+	opPHP
+	opPLA
+	opPLP
+	opROL
+	opROR
+	opRTI
+	opRTS
+	opSBC
+	opSEC
+	opSED
+	opSEI
+	opSTA
+	opSTX
+	opSTY
+	opTAX
+	opTAY
+	opTSX
+	opTXA
+	opTXS
+	opTYA
+	// stable undocumented opcodes, used by some sid players.
+	opALR
+	opANC
+	opARR
+	opDCP
+	opISC
+	opLAX
+	opRLA
+	opRRA
+	opSAX
+	opSBX
+	opSLO
+	opSRE
+)
+
+var mnemonicNames = [...]string{
+	"???", "adc", "and", "asl", "bcc", "bcs", "beq", "bit", "bmi", "bne", "bpl", "brk", "bvc", "bvs", "clc", "cld", "cli", "clv",
+	"cmp", "cpx", "cpy", "dec", "dex", "dey", "eor", "inc", "inx", "iny", "jmp", "jsr", "lda", "ldx", "ldy", "lsr", "nop", "ora",
+	"pha", "php", "pla", "plp", "rol", "ror", "rti", "rts", "sbc", "sec", "sed", "sei", "sta", "stx", "sty", "tax", "tay", "tsx",
+	"txa", "txs", "tya", "alr", "anc", "arr", "dcp", "isc", "lax", "rla", "rra", "sax", "sbx", "slo", "sre",
+}
+
+func (m mnemonic) String() string {
+	return mnemonicNames[m]
+}
+
+type opcode struct {
+	op   mnemonic
+	mode addrMode
+}
+
+// opcodes contains all documented and stable undocumented 6502 opcodes.
+// Unstable opcodes and jams are opInvalid.
+var opcodes = [256]opcode{
+	0x00: {opBRK, modeImp}, 0x01: {opORA, modeIzx}, 0x03: {opSLO, modeIzx}, 0x04: {opNOP, modeZp},
+	0x05: {opORA, modeZp}, 0x06: {opASL, modeZp}, 0x07: {opSLO, modeZp}, 0x08: {opPHP, modeImp},
+	0x09: {opORA, modeImm}, 0x0a: {opASL, modeAcc}, 0x0b: {opANC, modeImm}, 0x0c: {opNOP, modeAbs},
+	0x0d: {opORA, modeAbs}, 0x0e: {opASL, modeAbs}, 0x0f: {opSLO, modeAbs},
+	0x10: {opBPL, modeRel}, 0x11: {opORA, modeIzy}, 0x13: {opSLO, modeIzy}, 0x14: {opNOP, modeZpx},
+	0x15: {opORA, modeZpx}, 0x16: {opASL, modeZpx}, 0x17: {opSLO, modeZpx}, 0x18: {opCLC, modeImp},
+	0x19: {opORA, modeAby}, 0x1a: {opNOP, modeImp}, 0x1b: {opSLO, modeAby}, 0x1c: {opNOP, modeAbx},
+	0x1d: {opORA, modeAbx}, 0x1e: {opASL, modeAbx}, 0x1f: {opSLO, modeAbx},
+	0x20: {opJSR, modeAbs}, 0x21: {opAND, modeIzx}, 0x23: {opRLA, modeIzx}, 0x24: {opBIT, modeZp},
+	0x25: {opAND, modeZp}, 0x26: {opROL, modeZp}, 0x27: {opRLA, modeZp}, 0x28: {opPLP, modeImp},
+	0x29: {opAND, modeImm}, 0x2a: {opROL, modeAcc}, 0x2b: {opANC, modeImm}, 0x2c: {opBIT, modeAbs},
+	0x2d: {opAND, modeAbs}, 0x2e: {opROL, modeAbs}, 0x2f: {opRLA, modeAbs},
+	0x30: {opBMI, modeRel}, 0x31: {opAND, modeIzy}, 0x33: {opRLA, modeIzy}, 0x34: {opNOP, modeZpx},
+	0x35: {opAND, modeZpx}, 0x36: {opROL, modeZpx}, 0x37: {opRLA, modeZpx}, 0x38: {opSEC, modeImp},
+	0x39: {opAND, modeAby}, 0x3a: {opNOP, modeImp}, 0x3b: {opRLA, modeAby}, 0x3c: {opNOP, modeAbx},
+	0x3d: {opAND, modeAbx}, 0x3e: {opROL, modeAbx}, 0x3f: {opRLA, modeAbx},
+	0x40: {opRTI, modeImp}, 0x41: {opEOR, modeIzx}, 0x43: {opSRE, modeIzx}, 0x44: {opNOP, modeZp},
+	0x45: {opEOR, modeZp}, 0x46: {opLSR, modeZp}, 0x47: {opSRE, modeZp}, 0x48: {opPHA, modeImp},
+	0x49: {opEOR, modeImm}, 0x4a: {opLSR, modeAcc}, 0x4b: {opALR, modeImm}, 0x4c: {opJMP, modeAbs},
+	0x4d: {opEOR, modeAbs}, 0x4e: {opLSR, modeAbs}, 0x4f: {opSRE, modeAbs},
+	0x50: {opBVC, modeRel}, 0x51: {opEOR, modeIzy}, 0x53: {opSRE, modeIzy}, 0x54: {opNOP, modeZpx},
+	0x55: {opEOR, modeZpx}, 0x56: {opLSR, modeZpx}, 0x57: {opSRE, modeZpx}, 0x58: {opCLI, modeImp},
+	0x59: {opEOR, modeAby}, 0x5a: {opNOP, modeImp}, 0x5b: {opSRE, modeAby}, 0x5c: {opNOP, modeAbx},
+	0x5d: {opEOR, modeAbx}, 0x5e: {opLSR, modeAbx}, 0x5f: {opSRE, modeAbx},
+	0x60: {opRTS, modeImp}, 0x61: {opADC, modeIzx}, 0x63: {opRRA, modeIzx}, 0x64: {opNOP, modeZp},
+	0x65: {opADC, modeZp}, 0x66: {opROR, modeZp}, 0x67: {opRRA, modeZp}, 0x68: {opPLA, modeImp},
+	0x69: {opADC, modeImm}, 0x6a: {opROR, modeAcc}, 0x6b: {opARR, modeImm}, 0x6c: {opJMP, modeInd},
+	0x6d: {opADC, modeAbs}, 0x6e: {opROR, modeAbs}, 0x6f: {opRRA, modeAbs},
+	0x70: {opBVS, modeRel}, 0x71: {opADC, modeIzy}, 0x73: {opRRA, modeIzy}, 0x74: {opNOP, modeZpx},
+	0x75: {opADC, modeZpx}, 0x76: {opROR, modeZpx}, 0x77: {opRRA, modeZpx}, 0x78: {opSEI, modeImp},
+	0x79: {opADC, modeAby}, 0x7a: {opNOP, modeImp}, 0x7b: {opRRA, modeAby}, 0x7c: {opNOP, modeAbx},
+	0x7d: {opADC, modeAbx}, 0x7e: {opROR, modeAbx}, 0x7f: {opRRA, modeAbx},
+	0x80: {opNOP, modeImm}, 0x81: {opSTA, modeIzx}, 0x82: {opNOP, modeImm}, 0x83: {opSAX, modeIzx},
+	0x84: {opSTY, modeZp}, 0x85: {opSTA, modeZp}, 0x86: {opSTX, modeZp}, 0x87: {opSAX, modeZp},
+	0x88: {opDEY, modeImp}, 0x89: {opNOP, modeImm}, 0x8a: {opTXA, modeImp}, 0x8c: {opSTY, modeAbs},
+	0x8d: {opSTA, modeAbs}, 0x8e: {opSTX, modeAbs}, 0x8f: {opSAX, modeAbs},
+	0x90: {opBCC, modeRel}, 0x91: {opSTA, modeIzy}, 0x94: {opSTY, modeZpx}, 0x95: {opSTA, modeZpx},
+	0x96: {opSTX, modeZpy}, 0x97: {opSAX, modeZpy}, 0x98: {opTYA, modeImp}, 0x99: {opSTA, modeAby},
+	0x9a: {opTXS, modeImp}, 0x9d: {opSTA, modeAbx},
+	0xa0: {opLDY, modeImm}, 0xa1: {opLDA, modeIzx}, 0xa2: {opLDX, modeImm}, 0xa3: {opLAX, modeIzx},
+	0xa4: {opLDY, modeZp}, 0xa5: {opLDA, modeZp}, 0xa6: {opLDX, modeZp}, 0xa7: {opLAX, modeZp},
+	0xa8: {opTAY, modeImp}, 0xa9: {opLDA, modeImm}, 0xaa: {opTAX, modeImp}, 0xac: {opLDY, modeAbs},
+	0xad: {opLDA, modeAbs}, 0xae: {opLDX, modeAbs}, 0xaf: {opLAX, modeAbs},
+	0xb0: {opBCS, modeRel}, 0xb1: {opLDA, modeIzy}, 0xb3: {opLAX, modeIzy}, 0xb4: {opLDY, modeZpx},
+	0xb5: {opLDA, modeZpx}, 0xb6: {opLDX, modeZpy}, 0xb7: {opLAX, modeZpy}, 0xb8: {opCLV, modeImp},
+	0xb9: {opLDA, modeAby}, 0xba: {opTSX, modeImp}, 0xbc: {opLDY, modeAbx}, 0xbd: {opLDA, modeAbx},
+	0xbe: {opLDX, modeAby}, 0xbf: {opLAX, modeAby},
+	0xc0: {opCPY, modeImm}, 0xc1: {opCMP, modeIzx}, 0xc2: {opNOP, modeImm}, 0xc3: {opDCP, modeIzx},
+	0xc4: {opCPY, modeZp}, 0xc5: {opCMP, modeZp}, 0xc6: {opDEC, modeZp}, 0xc7: {opDCP, modeZp},
+	0xc8: {opINY, modeImp}, 0xc9: {opCMP, modeImm}, 0xca: {opDEX, modeImp}, 0xcb: {opSBX, modeImm},
+	0xcc: {opCPY, modeAbs}, 0xcd: {opCMP, modeAbs}, 0xce: {opDEC, modeAbs}, 0xcf: {opDCP, modeAbs},
+	0xd0: {opBNE, modeRel}, 0xd1: {opCMP, modeIzy}, 0xd3: {opDCP, modeIzy}, 0xd4: {opNOP, modeZpx},
+	0xd5: {opCMP, modeZpx}, 0xd6: {opDEC, modeZpx}, 0xd7: {opDCP, modeZpx}, 0xd8: {opCLD, modeImp},
+	0xd9: {opCMP, modeAby}, 0xda: {opNOP, modeImp}, 0xdb: {opDCP, modeAby}, 0xdc: {opNOP, modeAbx},
+	0xdd: {opCMP, modeAbx}, 0xde: {opDEC, modeAbx}, 0xdf: {opDCP, modeAbx},
+	0xe0: {opCPX, modeImm}, 0xe1: {opSBC, modeIzx}, 0xe2: {opNOP, modeImm}, 0xe3: {opISC, modeIzx},
+	0xe4: {opCPX, modeZp}, 0xe5: {opSBC, modeZp}, 0xe6: {opINC, modeZp}, 0xe7: {opISC, modeZp},
+	0xe8: {opINX, modeImp}, 0xe9: {opSBC, modeImm}, 0xea: {opNOP, modeImp}, 0xeb: {opSBC, modeImm},
+	0xec: {opCPX, modeAbs}, 0xed: {opSBC, modeAbs}, 0xee: {opINC, modeAbs}, 0xef: {opISC, modeAbs},
+	0xf0: {opBEQ, modeRel}, 0xf1: {opSBC, modeIzy}, 0xf3: {opISC, modeIzy}, 0xf4: {opNOP, modeZpx},
+	0xf5: {opSBC, modeZpx}, 0xf6: {opINC, modeZpx}, 0xf7: {opISC, modeZpx}, 0xf8: {opSED, modeImp},
+	0xf9: {opSBC, modeAby}, 0xfa: {opNOP, modeImp}, 0xfb: {opISC, modeAby}, 0xfc: {opNOP, modeAbx},
+	0xfd: {opSBC, modeAbx}, 0xfe: {opINC, modeAbx}, 0xff: {opISC, modeAbx},
+}
+
+// disassemble returns the instruction at addr in mem as text, e.g. "lda $1000,x".
+func disassemble(mem []byte, addr uint16) string {
+	o := opcodes[mem[addr]]
+	lo, hi := mem[addr+1], mem[addr+2]
+	w := uint16(hi)<<8 | uint16(lo)
+	switch o.mode {
+	case modeAcc:
+		return o.op.String() + " a"
+	case modeImm:
+		return fmt.Sprintf("%s #$%02x", o.op, lo)
+	case modeZp:
+		return fmt.Sprintf("%s $%02x", o.op, lo)
+	case modeZpx:
+		return fmt.Sprintf("%s $%02x,x", o.op, lo)
+	case modeZpy:
+		return fmt.Sprintf("%s $%02x,y", o.op, lo)
+	case modeAbs:
+		return fmt.Sprintf("%s $%04x", o.op, w)
+	case modeAbx:
+		return fmt.Sprintf("%s $%04x,x", o.op, w)
+	case modeAby:
+		return fmt.Sprintf("%s $%04x,y", o.op, w)
+	case modeInd:
+		return fmt.Sprintf("%s ($%04x)", o.op, w)
+	case modeIzx:
+		return fmt.Sprintf("%s ($%02x,x)", o.op, lo)
+	case modeIzy:
+		return fmt.Sprintf("%s ($%02x),y", o.op, lo)
+	case modeRel:
+		return fmt.Sprintf("%s $%04x", o.op, addr+2+uint16(int8(lo)))
+	}
+	return o.op.String()
+}
+
+const (
+	flagC byte = 1 << iota
+	flagZ
+	flagI
+	flagD
+	flagB
+	flagU
+	flagV
+	flagN
+)
+
+// noOrigin marks a register or memory cell whose value does not originate from traced memory.
+const noOrigin = -1
+
+// A cpu6502 is a minimal 6502 emulator without cycle timing, used to analyze and verify sid players.
+//
+// Every register and memory cell keeps track of its origin: the memory address its value was originally loaded from.
+// When tracing, the origins of bytes used as the high byte of an address or as a zeropage address are marked,
+// so the relocator knows which bytes need to be patched, even if they pass through registers, the stack or
+// self-modifying code first.
+type cpu6502 struct {
+	a, x, y, sp, p byte
+	pc             uint16
+	ao, xo, yo     int32
+
+	mem    [MaxMemory + 1]byte
+	origin [MaxMemory + 1]int32
+
+	trace    bool
+	executed [MaxMemory + 1]bool // opcode addresses
+	accessed [MaxMemory + 1]bool // data read or written
+	hiUse    [MaxMemory + 1]bool // indexed by origin
+	zpUse    [MaxMemory + 1]bool // indexed by origin
+	zpUsed   [0x100]bool
+
+	// sidWrites contains all writes to $d400-$d7ff and $de00-$dfff as address<<8 | value.
+	sidWrites []uint32
+	ioCounter byte
+}
+
+// newCPU6502 returns a cpu with all memory set to 0 and the memory configuration set to $35, like the displayers.
+func newCPU6502() *cpu6502 {
+	c := &cpu6502{sp: 0xff, p: flagU | flagI, ao: noOrigin, xo: noOrigin, yo: noOrigin}
+	for i := range c.origin {
+		c.origin[i] = noOrigin
+	}
+	c.mem[0] = 0x2f
+	c.mem[1] = 0x35
+	return c
+}
+
+// load copies buf to addr. When origins is true, each byte is its own origin.
+func (c *cpu6502) load(addr uint16, buf []byte, origins bool) {
+	for i, b := range buf {
+		a := addr + uint16(i)
+		c.mem[a] = b
+		c.origin[a] = noOrigin
+		if origins {
+			c.origin[a] = int32(a)
+		}
+	}
+}
+
+func (c *cpu6502) ioVisible() bool {
+	return c.mem[1]&3 != 0 && c.mem[1]&4 != 0
+}
+
+// inROM returns true if addr is in basic or kernal rom in the current memory configuration.
+func (c *cpu6502) inROM(addr uint16) bool {
+	switch {
+	case addr >= 0xe000:
+		return c.mem[1]&2 != 0
+	case addr >= 0xa000 && addr < 0xc000:
+		return c.mem[1]&3 == 3
+	}
+	return false
+}
+
+func (c *cpu6502) read(addr uint16) (byte, int32) {
+	if addr >= 0xd000 && addr < 0xe000 && c.ioVisible() {
+		// return changing values for raster and random polls, so wait loops terminate.
+		c.ioCounter++
+		switch addr {
+		case 0xd011:
+			return c.ioCounter & 0x80, noOrigin
+		case 0xd012, 0xd41b, 0xd41c:
+			return c.ioCounter, noOrigin
+		}
+		return 0, noOrigin
+	}
+	if c.trace {
+		c.accessed[addr] = true
+	}
+	return c.mem[addr], c.origin[addr]
+}
+
+func (c *cpu6502) write(addr uint16, v byte, o int32) {
+	if addr >= 0xd000 && addr < 0xe000 && c.ioVisible() {
+		if addr >= 0xd400 && addr < 0xd800 || addr >= 0xde00 {
+			c.sidWrites = append(c.sidWrites, uint32(addr)<<8|uint32(v))
+		}
+		return
+	}
+	if c.trace {
+		c.accessed[addr] = true
+	}
+	c.mem[addr] = v
+	c.origin[addr] = o
+}
+
+func (c *cpu6502) push(v byte, o int32) {
+	c.mem[0x100+uint16(c.sp)] = v
+	c.origin[0x100+uint16(c.sp)] = o
+	c.sp--
+}
+
+func (c *cpu6502) pull() (byte, int32) {
+	c.sp++
+	return c.mem[0x100+uint16(c.sp)], c.origin[0x100+uint16(c.sp)]
+}
+
+// markHigh marks the origin of the byte at addr as the high byte of an address.
+func (c *cpu6502) markHigh(o int32) {
+	if c.trace && o >= 0 {
+		c.hiUse[o] = true
+	}
+}
+
+// markZeroPage marks the origin of the zeropage address zp and the zp itself as used.
+func (c *cpu6502) markZeroPage(o int32, zp ...byte) {
+	if !c.trace {
+		return
+	}
+	if o >= 0 {
+		c.zpUse[o] = true
+	}
+	for _, v := range zp {
+		c.zpUsed[v] = true
+	}
+}
+
+func (c *cpu6502) setNZ(v byte) {
+	c.p &^= flagN | flagZ
+	c.p |= v & flagN
+	if v == 0 {
+		c.p |= flagZ
+	}
+}
+
+func (c *cpu6502) setFlag(f byte, on bool) {
+	if on {
+		c.p |= f
+		return
+	}
+	c.p &^= f
+}
+
+func (c *cpu6502) adc(v byte) {
+	carry := uint16(c.p & flagC)
+	if c.p&flagD != 0 {
+		lo := uint16(c.a&0x0f) + uint16(v&0x0f) + carry
+		hi := uint16(c.a>>4) + uint16(v>>4)
+		if lo > 9 {
+			lo += 6
+			hi++
+		}
+		c.setFlag(flagZ, byte(uint16(c.a)+uint16(v)+carry) == 0)
+		c.setFlag(flagN, hi&8 != 0)
+		c.setFlag(flagV, (c.a^v)&0x80 == 0 && (uint16(c.a)^(hi<<4))&0x80 != 0)
+		if hi > 9 {
+			hi += 6
+		}
+		c.setFlag(flagC, hi > 15)
+		c.a = byte(hi<<4 | lo&0x0f)
+		return
+	}
+	sum := uint16(c.a) + uint16(v) + carry
+	c.setFlag(flagC, sum > 0xff)
+	c.setFlag(flagV, (c.a^v)&0x80 == 0 && (uint16(c.a)^sum)&0x80 != 0)
+	c.a = byte(sum)
+	c.setNZ(c.a)
+}
+
+func (c *cpu6502) sbc(v byte) {
+	if c.p&flagD != 0 {
+		borrow := int(1 - c.p&flagC)
+		diff := uint16(c.a) - uint16(v) - uint16(borrow)
+		lo := int(c.a&0x0f) - int(v&0x0f) - borrow
+		hi := int(c.a>>4) - int(v>>4)
+		if lo < 0 {
+			lo -= 6
+			hi--
+		}
+		if hi < 0 {
+			hi -= 6
+		}
+		c.setFlag(flagC, diff < 0x100)
+		c.setFlag(flagV, (c.a^v)&0x80 != 0 && (uint16(c.a)^diff)&0x80 != 0)
+		c.setNZ(byte(diff))
+		c.a = byte(hi<<4 | lo&0x0f)
+		return
+	}
+	c.adc(^v)
+}
+
+func (c *cpu6502) compare(r, v byte) {
+	c.setFlag(flagC, r >= v)
+	c.setNZ(r - v)
+}
+
+func (c *cpu6502) asl(v byte) byte {
+	c.setFlag(flagC, v&0x80 != 0)
+	v <<= 1
+	c.setNZ(v)
+	return v
+}
+
+func (c *cpu6502) lsr(v byte) byte {
+	c.setFlag(flagC, v&1 != 0)
+	v >>= 1
+	c.setNZ(v)
+	return v
+}
+
+func (c *cpu6502) rol(v byte) byte {
+	r := v<<1 | c.p&flagC
+	c.setFlag(flagC, v&0x80 != 0)
+	c.setNZ(r)
+	return r
+}
+
+func (c *cpu6502) ror(v byte) byte {
+	r := v>>1 | (c.p&flagC)<<7
+	c.setFlag(flagC, v&1 != 0)
+	c.setNZ(r)
+	return r
+}
+
+// rts returns from a subroutine, the high byte of the return address is marked as used.
+func (c *cpu6502) rts() {
+	lo, _ := c.pull()
+	hi, o := c.pull()
+	c.markHigh(o)
+	c.pc = uint16(hi)<<8 | uint16(lo) + 1
+}
+
+// effectiveAddress returns the effective address of the operand of the instruction at pc and marks
+// the origins of the address bytes.
+func (c *cpu6502) effectiveAddress(mode addrMode, pc uint16) uint16 {
+	lo := c.mem[pc+1]
+	hi := c.mem[pc+2]
+	w := uint16(hi)<<8 | uint16(lo)
+	switch mode {
+	case modeImm:
+		return pc + 1
+	case modeZp:
+		c.markZeroPage(c.origin[pc+1], lo)
+		return uint16(lo)
+	case modeZpx:
+		c.markZeroPage(c.origin[pc+1], lo+c.x)
+		return uint16(lo + c.x)
+	case modeZpy:
+		c.markZeroPage(c.origin[pc+1], lo+c.y)
+		return uint16(lo + c.y)
+	case modeAbs, modeAbx, modeAby:
+		c.markHigh(c.origin[pc+2])
+		if hi == 0 {
+			c.markZeroPage(c.origin[pc+1], lo)
+		}
+		switch mode {
+		case modeAbx:
+			return w + uint16(c.x)
+		case modeAby:
+			return w + uint16(c.y)
+		}
+		return w
+	case modeInd:
+		c.markHigh(c.origin[pc+2])
+		hiAddr := w&0xff00 | (w+1)&0x00ff
+		c.markHigh(c.origin[hiAddr])
+		return uint16(c.mem[hiAddr])<<8 | uint16(c.mem[w])
+	case modeIzx:
+		zp := lo + c.x
+		c.markZeroPage(c.origin[pc+1], zp, zp+1)
+		c.markHigh(c.origin[zp+1])
+		return uint16(c.mem[zp+1])<<8 | uint16(c.mem[zp])
+	case modeIzy:
+		c.markZeroPage(c.origin[pc+1], lo, lo+1)
+		c.markHigh(c.origin[lo+1])
+		return (uint16(c.mem[lo+1])<<8 | uint16(c.mem[lo])) + uint16(c.y)
+	case modeRel:
+		return pc + 2 + uint16(int8(lo))
+	}
+	return 0
+}
+
+// step executes a single instruction.
+func (c *cpu6502) step() error {
+	if c.inROM(c.pc) {
+		// kernal and basic calls are not emulated, return to the caller.
+		c.rts()
+		return nil
+	}
+	pc := c.pc
+	o := opcodes[c.mem[pc]]
+	if o.op == opInvalid || o.op == opBRK {
+		return fmt.Errorf("unsupported opcode $%02x at %s", c.mem[pc], Word(pc))
+	}
+	if c.trace {
+		c.executed[pc] = true
+	}
+	c.pc += o.mode.size()
+	var ea uint16
+	if o.mode != modeImp && o.mode != modeAcc {
+		ea = c.effectiveAddress(o.mode, pc)
+	}
+	load := func() (byte, int32) {
+		if o.mode == modeImm {
+			return c.mem[ea], c.origin[ea]
+		}
+		return c.read(ea)
+	}
+	// modify applies f to the accumulator or the operand in memory, the result loses its origin.
+	modify := func(f func(byte) byte) byte {
+		if o.mode == modeAcc {
+			c.a = f(c.a)
+			c.ao = noOrigin
+			return c.a
+		}
+		v, _ := c.read(ea)
+		v = f(v)
+		c.write(ea, v, noOrigin)
+		return v
+	}
+	// combine keeps the origin of the accumulator, unless it has none.
+	combine := func(o int32) {
+		if c.ao == noOrigin {
+			c.ao = o
+		}
+	}
+	branch := func(cond bool) {
+		if cond {
+			c.pc = ea
+		}
+	}
+
+	switch o.op {
+	case opLDA:
+		c.a, c.ao = load()
+		c.setNZ(c.a)
+	case opLDX:
+		c.x, c.xo = load()
+		c.setNZ(c.x)
+	case opLDY:
+		c.y, c.yo = load()
+		c.setNZ(c.y)
+	case opLAX:
+		c.a, c.ao = load()
+		c.x, c.xo = c.a, c.ao
+		c.setNZ(c.a)
+	case opSTA:
+		c.write(ea, c.a, c.ao)
+	case opSTX:
+		c.write(ea, c.x, c.xo)
+	case opSTY:
+		c.write(ea, c.y, c.yo)
+	case opSAX:
+		c.write(ea, c.a&c.x, noOrigin)
+	case opADC:
+		v, vo := load()
+		c.adc(v)
+		combine(vo)
+	case opSBC:
+		v, vo := load()
+		c.sbc(v)
+		combine(vo)
+	case opAND:
+		v, vo := load()
+		c.a &= v
+		c.setNZ(c.a)
+		combine(vo)
+	case opORA:
+		v, vo := load()
+		c.a |= v
+		c.setNZ(c.a)
+		combine(vo)
+	case opEOR:
+		v, vo := load()
+		c.a ^= v
+		c.setNZ(c.a)
+		combine(vo)
+	case opCMP:
+		v, _ := load()
+		c.compare(c.a, v)
+	case opCPX:
+		v, _ := load()
+		c.compare(c.x, v)
+	case opCPY:
+		v, _ := load()
+		c.compare(c.y, v)
+	case opBIT:
+		v, _ := load()
+		c.setFlag(flagZ, c.a&v == 0)
+		c.setFlag(flagN, v&flagN != 0)
+		c.setFlag(flagV, v&flagV != 0)
+	case opASL:
+		modify(c.asl)
+	case opLSR:
+		modify(c.lsr)
+	case opROL:
+		modify(c.rol)
+	case opROR:
+		modify(c.ror)
+	case opINC, opDEC:
+		v, vo := c.read(ea)
+		if o.op == opINC {
+			v++
+		} else {
+			v--
+		}
+		c.write(ea, v, vo)
+		c.setNZ(v)
+	case opSLO:
+		v := modify(c.asl)
+		c.a |= v
+		c.ao = noOrigin
+		c.setNZ(c.a)
+	case opRLA:
+		v := modify(c.rol)
+		c.a &= v
+		c.ao = noOrigin
+		c.setNZ(c.a)
+	case opSRE:
+		v := modify(c.lsr)
+		c.a ^= v
+		c.ao = noOrigin
+		c.setNZ(c.a)
+	case opRRA:
+		v := modify(c.ror)
+		c.adc(v)
+		c.ao = noOrigin
+	case opDCP:
+		v, vo := c.read(ea)
+		v--
+		c.write(ea, v, vo)
+		c.compare(c.a, v)
+	case opISC:
+		v, vo := c.read(ea)
+		v++
+		c.write(ea, v, vo)
+		c.sbc(v)
+		c.ao = noOrigin
+	case opANC:
+		c.a &= c.mem[ea]
+		c.setNZ(c.a)
+		c.setFlag(flagC, c.a&0x80 != 0)
+	case opALR:
+		c.a = c.lsr(c.a & c.mem[ea])
+		c.ao = noOrigin
+	case opARR:
+		c.a = c.ror(c.a & c.mem[ea])
+		c.ao = noOrigin
+		c.setFlag(flagC, c.a&0x40 != 0)
+		c.setFlag(flagV, (c.a>>6^c.a>>5)&1 != 0)
+	case opSBX:
+		v := c.a & c.x
+		c.setFlag(flagC, v >= c.mem[ea])
+		c.x = v - c.mem[ea]
+		c.xo = noOrigin
+		c.setNZ(c.x)
+	case opINX:
+		c.x++
+		c.setNZ(c.x)
+	case opINY:
+		c.y++
+		c.setNZ(c.y)
+	case opDEX:
+		c.x--
+		c.setNZ(c.x)
+	case opDEY:
+		c.y--
+		c.setNZ(c.y)
+	case opTAX:
+		c.x, c.xo = c.a, c.ao
+		c.setNZ(c.x)
+	case opTAY:
+		c.y, c.yo = c.a, c.ao
+		c.setNZ(c.y)
+	case opTXA:
+		c.a, c.ao = c.x, c.xo
+		c.setNZ(c.a)
+	case opTYA:
+		c.a, c.ao = c.y, c.yo
+		c.setNZ(c.a)
+	case opTSX:
+		c.x, c.xo = c.sp, noOrigin
+		c.setNZ(c.x)
+	case opTXS:
+		c.sp = c.x
+	case opPHA:
+		c.push(c.a, c.ao)
+	case opPLA:
+		c.a, c.ao = c.pull()
+		c.setNZ(c.a)
+	case opPHP:
+		c.push(c.p|flagB|flagU, noOrigin)
+	case opPLP:
+		c.p, _ = c.pull()
+	case opJSR:
+		ret := pc + 2
+		c.push(byte(ret>>8), noOrigin)
+		c.push(byte(ret), noOrigin)
+		c.pc = ea
+	case opRTS:
+		c.rts()
+	case opRTI:
+		c.p, _ = c.pull()
+		c.rts()
+		c.pc--
+	case opJMP:
+		c.pc = ea
+	case opBCC:
+		branch(c.p&flagC == 0)
+	case opBCS:
+		branch(c.p&flagC != 0)
+	case opBNE:
+		branch(c.p&flagZ == 0)
+	case opBEQ:
+		branch(c.p&flagZ != 0)
+	case opBPL:
+		branch(c.p&flagN == 0)
+	case opBMI:
+		branch(c.p&flagN != 0)
+	case opBVC:
+		branch(c.p&flagV == 0)
+	case opBVS:
+		branch(c.p&flagV != 0)
+	case opCLC:
+		c.p &^= flagC
+	case opSEC:
+		c.p |= flagC
+	case opCLD:
+		c.p &^= flagD
+	case opSED:
+		c.p |= flagD
+	case opCLI:
+		c.p &^= flagI
+	case opSEI:
+		c.p |= flagI
+	case opCLV:
+		c.p &^= flagV
+	case opNOP:
+	}
+	return nil
+}
+
+// call executes a jsr to addr with the accumulator set to a and returns when the subroutine returns.
+// An error is returned if the subroutine does not return within maxSteps instructions.
+func (c *cpu6502) call(addr uint16, a byte, maxSteps int) error {
+	// the return address is pushed minus 1, so the sentinel return address $0000 is pushed as $ffff.
+	const ret = 0x0000
+	c.sp = 0xff
+	c.push(0xff, noOrigin)
+	c.push(0xff, noOrigin)
+	c.pc = addr
+	c.a, c.ao = a, noOrigin
+	c.x, c.xo = 0, noOrigin
+	c.y, c.yo = 0, noOrigin
+	for i := 0; i < maxSteps; i++ {
+		if c.pc == ret {
+			return nil
+		}
+		if err := c.step(); err != nil {
+			return fmt.Errorf("call %s failed: %w", Word(addr), err)
+		}
+	}
+	return fmt.Errorf("call %s did not return within %d instructions", Word(addr), maxSteps)
+}
@@ -0,0 +1,64 @@
+package png2prg
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/staD020/TSCrunch"
+)
+
+// Supported crunchers for Options.Cruncher.
+const (
+	CruncherTSCrunch = "tscrunch"
+	CruncherLZSA1    = "lzsa1"
+)
+
+// A Cruncher crunches .prg files, it is used to score brute-force results and to pack the displayers.
+type Cruncher interface {
+	// Crunch returns the crunched data of prg.
+	Crunch(prg []byte) ([]byte, error)
+	// CrunchSFX returns a self-extracting .prg, starting at $0801, which decrunches prg and jumps to jumpTo.
+	CrunchSFX(prg []byte, jumpTo Word) ([]byte, error)
+	// String returns the name of the cruncher.
+	String() string
+}
+
+// NewCruncher returns the Cruncher named name, TSCrunch is returned for an empty name.
+func NewCruncher(name string) (Cruncher, error) {
+	switch name {
+	case "", CruncherTSCrunch:
+		return tsCruncher{}, nil
+	case CruncherLZSA1:
+		return lzCruncher{}, nil
+	}
+	return nil, fmt.Errorf("unknown cruncher %q, use %s or %s", name, CruncherTSCrunch, CruncherLZSA1)
+}
+
+// tsCruncher crunches with TSCrunch in fast mode.
+type tsCruncher struct{}
+
+func (tsCruncher) String() string {
+	return CruncherTSCrunch
+}
+
+func (tsCruncher) Crunch(prg []byte) ([]byte, error) {
+	return tsCrunch(TSCrunch.Options{PRG: true, QUIET: true, Fast: true}, prg)
+}
+
+func (tsCruncher) CrunchSFX(prg []byte, jumpTo Word) ([]byte, error) {
+	opt := TSCOptions
+	opt.JumpTo = jumpTo.String()
+	return tsCrunch(opt, prg)
+}
+
+func tsCrunch(opt TSCrunch.Options, prg []byte) ([]byte, error) {
+	t, err := TSCrunch.New(opt, bytes.NewReader(prg))
+	if err != nil {
+		return nil, fmt.Errorf("tscrunch.New failed: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = t.WriteTo(buf); err != nil {
+		return nil, fmt.Errorf("tsc.WriteTo failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,165 @@
+.importonce
+
+// NMOS 6502 decompressor for data stored in TSCrunch format, without the inplace option.
+// Based on decrunch.asm from TSCrunch, Copyright Antonio Savona 2022, Apache License 2.0.
+// Labels are prefixed with ts_ to avoid collisions with the displayer code.
+
+.const tsget  = $f8	// 2 bytes
+.const tstemp = $fa
+.const tsput  = $fb	// 2 bytes
+.const lzput  = $fd	// 2 bytes
+
+// ts_decrunch decrunches the crunched data at tsget to tsput.
+// Usage: jsr to a label in front of the macro call.
+.macro ts_decrunch() {
+		ldy #0
+		lda (tsget),y
+		sta ts_optrun + 1
+
+		inc tsget
+		bne ts_entry2
+		inc tsget + 1
+ts_entry2:
+		lax (tsget),y
+		bmi ts_rleorlz
+		cmp #$20
+		bcs ts_lz2
+
+		// literal
+		tay
+ts_delit_loop:
+		lda (tsget),y
+		dey
+		sta (tsput),y
+		bne ts_delit_loop
+
+		txa
+		inx
+ts_updatezp_noclc:
+		adc tsput
+		sta tsput
+		bcs ts_updateput_hi
+ts_putnoof:
+		txa
+ts_update_getonly:
+		adc tsget
+		sta tsget
+		bcc ts_entry2
+		inc tsget + 1
+		bcs ts_entry2
+ts_updateput_hi:
+		inc tsput + 1
+		clc
+		bcc ts_putnoof
+
+ts_rleorlz:
+		alr #$7f
+		bcc ts_delz
+
+		// rle
+		beq ts_optrun
+		ldx #2
+		iny
+		sta tstemp		// number of bytes to de-rle
+		lda (tsget),y	// fetch rle byte
+		ldy tstemp
+ts_runstart:
+		sta (tsput),y
+ts_derle_loop:
+		dey
+		sta (tsput),y
+		bne ts_derle_loop
+
+		// update zero page with a = runlen, x = 2 , y = 0
+		lda tstemp
+		bcs ts_updatezp_noclc
+ts_done:
+		rts
+
+		// lz2
+ts_lz2:
+		beq ts_done
+		ora #$80
+		adc tsput
+		sta lzput
+		lda tsput + 1
+		sbc #$00
+		sta lzput + 1
+
+		// y already zero
+		lda (lzput),y
+		sta (tsput),y
+		iny
+		lda (lzput),y
+		sta (tsput),y
+
+		tya
+		dey
+		adc tsput
+		sta tsput
+		bcs ts_lz2_put_hi
+ts_skp:
+		inc tsget
+		bne ts_entry2
+		inc tsget + 1
+		bne ts_entry2
+ts_lz2_put_hi:
+		inc tsput + 1
+		bcs ts_skp
+
+		// lz
+ts_delz:
+		lsr
+		sta ts_lzto + 1
+		iny
+		lda tsput
+		bcc ts_long
+
+		sbc (tsget),y
+		sta lzput
+		lda tsput + 1
+		sbc #$00
+		ldx #2
+		// lz must decrunch forward
+ts_lz_put:
+		sta lzput + 1
+		ldy #0
+		lda (lzput),y
+		sta (tsput),y
+		iny
+		lda (lzput),y
+		sta (tsput),y
+ts_delz_loop:
+		iny
+		lda (lzput),y
+		sta (tsput),y
+ts_lzto:
+		cpy #0
+		bne ts_delz_loop
+
+		tya
+		// update zero page with a = runlen, x = 2, y = 0
+		ldy #0
+		// clc not needed as we have len - 1 in a (from the encoder) and c = 1
+		jmp ts_updatezp_noclc
+
+ts_optrun:
+		ldy #255
+		sty tstemp
+		ldx #1
+		// a is zero
+		bne ts_runstart
+
+ts_long:
+		// carry is clear and compensated for from the encoder
+		adc (tsget),y
+		sta lzput
+		iny
+		lax (tsget),y
+		ora #$80
+		adc tsput + 1
+		cpx #$80
+		rol ts_lzto + 1
+		ldx #3
+		bne ts_lz_put
+}
@@ -4,10 +4,34 @@
 .const LOOP = false
 .const fade_speed = 2
 .const steps = 16
+
+// the default displayer copies screenram from koala-like format at $2000.
+// the BANK1-3 variants load bitmap and screenram to the vic bank directly, to free up low memory for sids.
+#if BANK1
+.const KOALA_FORMAT = false
+.const bitmap     = $6000
+.const screenram  = $5c00
+.const fade_pass_address = $8000
+.const src_screenram = $5800
+#elif BANK2
+.const KOALA_FORMAT = false
+.const bitmap     = $a000
+.const screenram  = $8c00
+.const fade_pass_address = $6400
+.const src_screenram = $8800
+#elif BANK3
+.const KOALA_FORMAT = false
+.const bitmap     = $e000
+.const screenram  = $cc00
+.const fade_pass_address = $a000
+.const src_screenram = $c800
+#else
+.const KOALA_FORMAT = true
 .const bitmap     = $2000
 .const screenram  = $0400
 .const fade_pass_address = $4800
 .const src_screenram = $4000
+#endif
 
 .const zp_start = $0334		// displaycode will be shorter if this is <$f9, but we prefer zeropage-less code to allow most sids to play.
 .const zp_screen_lo = zp_start + 0
@@ -32,6 +56,9 @@ music_init:
 .pc = * "music_play"
 music_play:
 		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
 .pc = * "frame_delay"
 frame_delay:
 		.byte 0
@@ -102,6 +129,7 @@ start:
 		inx
 		bne !-
 
+	.if (KOALA_FORMAT) {
 		ldy #4
 		ldx #$e8
 	!:
@@ -116,6 +144,7 @@ smc_src_col:
 		dec smc_src_col+2
 		dey
 		bne !-
+	}
 
 		jsr vblank
 		:setBank(bitmap)
@@ -179,7 +208,7 @@ smc_yval:	ldy #steps-1
 		jsr vblank
 		lda #0
 		sta $d011
-		sta $d418
+		:mute_sids()
 		jsr $e544
 		jmp $fce2
 	}
@@ -0,0 +1,3 @@
+// streaming displayer for hires animations.
+#define HIRES
+#import "display_koala_anim_stream.asm"
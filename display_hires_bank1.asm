@@ -0,0 +1,3 @@
+// hires displayer with the picture in vic bank 1.
+#define BANK1
+#import "display_hires.asm"
@@ -0,0 +1,3 @@
+// hires displayer with the picture in vic bank 2.
+#define BANK2
+#import "display_hires.asm"
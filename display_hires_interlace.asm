@@ -0,0 +1,190 @@
+.const MUSICDEBUG = false
+.const LOOP = false
+.const bitmap     = $6000
+.const screenram  = $5c00
+.const bitmap2    = $a000
+.const screenram2 = $9c00
+.const d020color  = $7f40
+.const d016offset = $7f42
+
+// hires interlace alternates 2 hires bitmaps every frame.
+// the d016offset is 0 for color flicker and usually 1 for a pixel shift.
+
+.import source "lib.asm"
+
+.pc = $0801 "basic upstart"
+		.byte <basicend, >basicend, <year(), >year(), $9e
+		.text toIntString(start)
+		.text " PNG2PRG " + versionString()
+basicend:
+		.byte 0, 0, 0
+.pc = settings_start() "music_startsong"
+music_startsong:
+		.byte 0
+.pc = * "music_init"
+music_init:
+		jmp rrts
+.pc = * "music_play"
+music_play:
+		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
+.pc = * "frame_delay"
+frame_delay:
+		.byte 0
+.pc = * "wait_seconds"
+wait_seconds:
+		.byte 0
+
+.pc = basicsys() "start"
+start:
+		sei
+		jsr $e544
+		lda #$35
+		sta $01
+
+		ldy #7
+!loop:
+		ldx #2
+	!:	jsr vblank
+		dex
+		bne !-
+
+		lda $d020
+		and #$0f
+		tax
+		lda t_easyfade,x
+		sta $d020
+		lda $d021
+		and #$0f
+		tax
+		lda t_easyfade,x
+		sta $d021
+		dey
+		bne !loop-
+		sta $d011
+
+		// default pal 50 hz: $4cc7
+		lda #$c7
+		sta $dc04
+		lda #$4c
+		sta $dc05
+
+		lax music_startsong
+		tay
+		jsr music_init
+		lda #<irq
+		sta $fffe
+		lda #>irq
+		sta $ffff
+
+		lda #$80
+	!:	cmp $d012
+		bne !-
+	.if (MUSICDEBUG) {
+		ldx #5
+	!:	dex
+		bne !-
+	}
+		lda #%00010001
+		sta $dc0e
+		cli
+
+		jsr vblank
+		lda d020color
+		sta $d020
+		:setBank(bitmap)
+		lda #toD018(screenram, bitmap)
+		sta $d018
+		lda #$c8
+		sta $d016
+		lda #$3b
+		sta $d011
+
+.pc = * "interlaceloop"
+interlaceloop:
+		lda $dc01
+		cmp #$ef
+		beq !done+
+
+		jsr vblank
+		:setBank(bitmap2)
+		lda #toD018(screenram2, bitmap2)
+		sta $d018
+		lda $d016
+		eor d016offset
+		sta $d016
+
+		jsr vblank
+		:setBank(bitmap)
+		lda #toD018(screenram, bitmap)
+		sta $d018
+		lda $d016
+		eor d016offset
+		sta $d016
+		jmp interlaceloop
+!done:
+	.if (LOOP) {
+	!:	lda $dc01
+		cmp #$ef
+		beq !-
+		jmp interlaceloop
+	} else {
+		sei
+		lda #$37
+		sta $01
+		jsr vblank
+		lda #0
+		sta $d011
+		:mute_sids()
+		jsr $e544
+		jmp $fce2
+	}
+.pc = * "vblank"
+vblank:
+		:vblank()
+rrts:	rts
+// --------------------------------
+.pc = * "irq"
+irq:
+		pha
+		txa
+		pha
+		tya
+		pha
+		.if (MUSICDEBUG) dec $d020
+		jsr music_play
+		.if (MUSICDEBUG) inc $d020
+		lda $dc0d
+		pla
+		tay
+		pla
+		tax
+		pla
+		rti
+// ------------------------------
+.pc = * "t_easyfade"
+t_easyfade:
+		.byte $00,$0d,$09,$0c,$02,$08,$00,$0f
+		.byte $02,$00,$08,$09,$04,$03,$04,$05
+// ------------------------------
+.pc = screenram "screenram_source" virtual
+screenram_source:
+.fill 1000, 0
+// ------------------------------
+.pc = bitmap "bitmap_source" virtual
+bitmap_source:
+.fill $1f40, 0
+// ------------------------------
+.pc = d020color "settings" virtual
+settings:
+.fill 3, 0
+// ------------------------------
+.pc = screenram2 "screenram2_source" virtual
+screenram2_source:
+.fill 1000, 0
+// ------------------------------
+.pc = bitmap2 "bitmap2_source" virtual
+bitmap2_source:
+.fill $1f40, 0
@@ -0,0 +1,290 @@
+.const MUSICDEBUG = false
+.const LOOP = false
+.const screens    = $4000
+.const bitmap     = $6000
+.const screens2   = $c000
+.const bitmap2    = $e000
+.const src_frame2 = $8000
+.const src_colorram = $c000
+.const src_settings = $c3e8	// d021, d020, d016offset
+.const irq_line   = $2d
+
+// ifli alternates 2 fli pictures every frame, each with 8 screens and a bitmap.
+// the second frame is loaded at $8000 and copied to $c000, the colorram at $c000 is shared.
+//
+// fli timing, pal only:
+// irq1 at line $2d starts a double irq, irq2 is stable at cycle 4 of line $2f.
+// line $33 is a normal badline, the cpu stalls on the jmp after inc fli_dummy until cycle 55.
+// each next line is forced to be a badline by writing d011 in cycle 14, the fli loop takes exactly 23 cycles.
+// the late badline keeps the row counter running and causes the fli bug in the first 3 chars of each line.
+// lines $f8-$fa cannot be badlines and keep the screen colors of line $f7.
+
+.import source "lib.asm"
+
+.pc = $0801 "basic upstart"
+		.byte <basicend, >basicend, <year(), >year(), $9e
+		.text toIntString(start)
+		.text " PNG2PRG " + versionString()
+basicend:
+		.byte 0, 0, 0
+.pc = settings_start() "music_startsong"
+music_startsong:
+		.byte 0
+.pc = * "music_init"
+music_init:
+		jmp rrts
+.pc = * "music_play"
+music_play:
+		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
+.pc = * "frame_delay"
+frame_delay:
+		.byte 0
+.pc = * "wait_seconds"
+wait_seconds:
+		.byte 0
+
+.pc = basicsys() "start"
+start:
+		sei
+		jsr $e544
+		lda #$35
+		sta $01
+
+		ldy #7
+!loop:
+		ldx #2
+	!:	jsr vblank
+		dex
+		bne !-
+
+		lda $d020
+		and #$0f
+		tax
+		lda t_easyfade,x
+		sta $d020
+		lda $d021
+		and #$0f
+		tax
+		lda t_easyfade,x
+		sta $d021
+		dey
+		bne !loop-
+		sta $d011
+
+		ldx #0
+	!:
+	.for (var i=0; i<4; i++) {
+		lda src_colorram+(i*$100),x
+		sta $d800+(i*$100),x
+	}
+		inx
+		bne !-
+		lda src_settings
+		sta $d021
+		lda src_settings+1
+		sta $d020
+		lda src_settings+2
+		sta d016offset
+
+		lda #$34
+		sta $01
+		ldy #>(bitmap2+$1f40-screens2)
+		ldx #0
+!loop:
+smc_src:
+		lda src_frame2,x
+smc_dest:
+		sta screens2,x
+		inx
+		bne !loop-
+		inc smc_src+2
+		inc smc_dest+2
+		dey
+		bne !loop-
+	!:	lda src_frame2+(bitmap2+$1f00-screens2),x
+		sta bitmap2+$1f00,x
+		inx
+		cpx #$40
+		bne !-
+		lda #$35
+		sta $01
+
+		lda #<nmi
+		sta $fffa
+		lda #>nmi
+		sta $fffb
+		lda #<irq1
+		sta $fffe
+		lda #>irq1
+		sta $ffff
+		lda #$7f
+		sta $dc0d
+		lda $dc0d
+
+		lax music_startsong
+		tay
+		jsr music_init
+
+		jsr vblank
+		lda t_dd00
+		sta $dd00
+		lda #$d8
+		sta $d016
+		lda #irq_line
+		sta $d012
+		lda #$0b
+		sta $d011
+		lda #1
+		sta $d01a
+		asl $d019
+		cli
+
+.pc = * "mainloop"
+mainloop:
+		lda $dc01
+		cmp #$ef
+		bne mainloop
+	.if (LOOP) {
+	!:	lda $dc01
+		cmp #$ef
+		beq !-
+		jmp mainloop
+	} else {
+		sei
+		lda #$37
+		sta $01
+		lda #0
+		sta $d01a
+		jsr vblank
+		lda #0
+		sta $d011
+		:mute_sids()
+		jsr $e544
+		jmp $fce2
+	}
+.pc = * "vblank"
+vblank:
+		:vblank()
+rrts:	rts
+nmi:	rti
+// --------------------------------
+.pc = * "irq1"
+irq1:
+		sta irq_a+1
+		stx irq_x+1
+		sty irq_y+1
+		lda #<irq2
+		sta $fffe
+		lda #>irq2
+		sta $ffff
+		inc $d012
+		asl $d019
+		tsx
+		cli
+		.fill 16, $ea	// nop slide, irq2 hits here
+irq2:
+		// irq2 starts in cycle 10 or 11 of line irq_line+1
+		txs
+		ldx #8
+	!:	dex
+		bne !-
+		bit $00
+		lda $d012
+		cmp $d012
+		beq *+2
+		// stable in cycle 4 of line irq_line+2
+		lda #toD018(screens, bitmap)
+		sta $d018
+		ldx #$38
+		ldy #48
+	!:	dey
+		bne !-
+		nop
+		nop
+		nop
+		// line $33 cycle 7: the writes of inc run into the badline, the cpu stalls on the jmp
+		inc fli_dummy
+		jmp fli
+fli:
+		inx
+		beq fli_done
+		lda t_d018,x
+		sta $d018
+		lda t_d011,x
+		sta $d011
+		jmp fli
+fli_done:
+		lda #$3b
+		sta $d011
+		// lines $f8-$fa do not stall the loop, wait for the border before flipping frames
+		lda #$fb
+	!:	cmp $d012
+		bne !-
+		lda frame
+		eor #1
+		sta frame
+		tax
+		lda t_dd00,x
+		sta $dd00
+		lda $d016
+		eor d016offset
+		sta $d016
+
+		.if (MUSICDEBUG) dec $d020
+		jsr music_play
+		.if (MUSICDEBUG) inc $d020
+
+		lda #<irq1
+		sta $fffe
+		lda #>irq1
+		sta $ffff
+		lda #irq_line
+		sta $d012
+		asl $d019
+irq_a:	lda #0
+irq_x:	ldx #0
+irq_y:	ldy #0
+		rti
+// ------------------------------
+.pc = * "variables"
+fli_dummy:
+		.byte 0
+frame:
+		.byte 0
+d016offset:
+		.byte 0
+t_dd00:
+		.byte toDD00(screens), toDD00(screens2)
+// ------------------------------
+.pc = * "t_easyfade"
+t_easyfade:
+		.byte $00,$0d,$09,$0c,$02,$08,$00,$0f
+		.byte $02,$00,$08,$09,$04,$03,$04,$05
+// ------------------------------
+// indexed by x = $38 + y for picture line y, at raster line $33 + y
+.align $100
+.pc = * "t_d018"
+t_d018:
+		.fill $100, i < $38 ? 0 : toD018(screens + ((i - $38) & 7) * $400, bitmap)
+.pc = * "t_d011"
+t_d011:
+		.fill $100, i < $38 ? 0 : $38 | (($33 + i - $38) & 7)
+// ------------------------------
+.pc = screens "screens_source" virtual
+screens_source:
+.fill $2000, 0
+// ------------------------------
+.pc = bitmap "bitmap_source" virtual
+bitmap_source:
+.fill $1f40, 0
+// ------------------------------
+.pc = src_frame2 "frame2_source" virtual
+frame2_source:
+.fill $3f40, 0
+// ------------------------------
+.pc = src_colorram "colorram_source" virtual
+colorram_source:
+.fill $3eb, 0
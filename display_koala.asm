@@ -6,12 +6,39 @@
 .const PERFRAME = false
 .const fade_speed = 1
 .const steps = 16
+
+// the default displayer copies screen and colorram from koala format at $2000.
+// the BANK1-3 variants load bitmap, screen and colorram to the vic bank directly, to free up low memory for sids.
+#if BANK1
+.const KOALA_FORMAT = false
+.const bitmap     = $6000
+.const screenram  = $5c00
+.const fade_pass_address = $8000
+.const src_screenram = $5400
+.const src_colorram = $5800
+#elif BANK2
+.const KOALA_FORMAT = false
+.const bitmap     = $a000
+.const screenram  = $8c00
+.const fade_pass_address = $3daf	// 18001 bytes of fade code, up to src_screenram
+.const src_screenram = $8400
+.const src_colorram = $8800
+#elif BANK3
+.const KOALA_FORMAT = false
+.const bitmap     = $e000
+.const screenram  = $cc00
+.const fade_pass_address = $7c00
+.const src_screenram = $c400
+.const src_colorram = $c800
+#else
+.const KOALA_FORMAT = true
 .const bitmap     = $2000
 .const screenram  = $0400
-.const colorram   = $d800
 .const fade_pass_address = $4800
 .const src_screenram = $4000
 .const src_colorram = $4400
+#endif
+.const colorram   = $d800
 
 .const zp_start = $0334		// displaycode will be shorter if this is <$f9, but we prefer zeropage-less code to allow most sids to play.
 .const zp_screen_lo = zp_start + 0
@@ -40,6 +67,9 @@ music_init:
 .pc = * "music_play"
 music_play:
 		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
 .pc = * "frame_delay"
 frame_delay:
 		.byte 0
@@ -111,6 +141,7 @@ start:
 		inx
 		bne !-
 
+	.if (KOALA_FORMAT) {
 		ldy #4
 		ldx #$e8
 	!:
@@ -140,6 +171,7 @@ smc_src_screen:
 		dec smc_src_screen+2
 		dey
 		bne !-
+	}
 
 		jsr vblank
 
@@ -224,7 +256,7 @@ smc_yval:	ldy #steps-1
 		jsr vblank
 		lda #0
 		sta $d011
-		sta $d418
+		:mute_sids()
 		jsr $e544
 		jmp $fce2
 	}
@@ -406,7 +438,7 @@ t_color_fade:
 // ------------------------------
 .pc = bitmap "koala_source" virtual
 koala_source:
-.fill $2711, 0
+.if (KOALA_FORMAT) .fill $2711, 0
 // ------------------------------
 .pc = fade_pass_address "fade_pass" virtual
 fade_pass:
@@ -49,6 +49,9 @@ music_init:
 .pc = * "music_play"
 music_play:
 		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
 .pc = * "frame_delay"
 frame_delay:
 		.byte 0
@@ -247,7 +250,10 @@ smc_yval:	ldy #steps-1
 		bne !waitloop-
 
 loop_anim:
+		ldx cur_delay
+		bne !+
 		ldx frame_delay
+	!:
 !loop:	//jsr vblank
 		lda #$e0
 	!:	cmp $d012
@@ -288,7 +294,7 @@ loop_anim:
 		jsr vblank
 		lda #0
 		sta $d011
-		sta $d418
+		:mute_sids()
 		jsr $e544
 		jmp $fce2
 	}
@@ -448,19 +454,38 @@ smc_totpercol:
 		inc smc_fadepercol2 + 1
 		rts
 // ------------------------------
+.pc = * "cur_delay"
+cur_delay:
+		.byte 0
+// ------------------------------
 .pc = * "anim_play"
 anim_play:
 next_chunk:
 		ldy #0
 		lax (zp_anim_lo),y      //  a = x = number of chars in chunk
 		bne plot_chunk          // #$00 = end of frame
-		inc zp_anim_lo
-		bne !+
+		iny
+		lda (zp_anim_lo),y      // frame delay, 0 = use frame_delay
+		sta cur_delay
+		dey
+		lda zp_anim_lo
+		clc
+		adc #2
+		sta zp_anim_lo
+		bcc !+
 		inc zp_anim_hi
 	!:
 		lda (zp_anim_lo),y
-		cmp #$ff				// #$ff = end of all frames
+		cmp #$ff				// #$ff = end of all frames, followed by the address of the next frame
 		bne !skip+
+		iny
+		lda (zp_anim_lo),y
+		tax
+		iny
+		lda (zp_anim_lo),y
+		sta zp_anim_hi
+		stx zp_anim_lo
+!skip:	rts
 
 .pc = * "anim_init"
 anim_init:
@@ -468,9 +493,13 @@ anim_init:
 		sta zp_anim_lo
 		lda #>anim_frames
 		sta zp_anim_hi
-!skip:	rts
+		rts
 
 plot_chunk:
+		cpx #$fe                // #$fe = masked frame
+		bne !+
+		jmp plot_masked
+	!:
 		//tax                     // x = number of chars in chunk
 		iny
 		lda (zp_anim_lo),y
@@ -544,6 +573,90 @@ smc_keep_screencol:
 		bne plot_next_char
 		jmp next_chunk
 
+// masked frame, see readme for details on format.
+plot_masked:
+		lda #<(bitmap - 8)      // start at char -1
+		sta zp_bitmap_lo
+		lda #>(bitmap - 8)
+		sta zp_bitmap_hi
+		lda #<(screenram - 1)
+		sta zp_char_lo
+		sta zp_d800_lo
+		lda #>(screenram - 1)
+		sta zp_char_hi
+		lda #>(colorram - 1)
+		sta zp_d800_hi
+		ldx #1                  // skip #$fe
+next_masked:
+		txa                     // x = length of the previous char
+		clc
+		adc zp_anim_lo
+		sta zp_anim_lo
+		sta smc_masked_data + 1
+		bcc !+
+		inc zp_anim_hi
+	!:	lda zp_anim_hi
+		sta smc_masked_data + 2
+		ldy #0
+		lda (zp_anim_lo),y
+		bne !+
+		jmp next_chunk          // #$00 = end of frame
+	!:	sta masked_flags
+		and #$1f                // number of chars to skip
+		tax
+		inx
+		txa
+		clc
+		adc zp_char_lo
+		sta zp_char_lo
+		sta zp_d800_lo
+		bcc !+
+		inc zp_char_hi
+		inc zp_d800_hi
+	!:	txa
+		asl
+		asl
+		asl
+		bcc !+
+		inc zp_bitmap_hi
+		clc
+	!:	adc zp_bitmap_lo
+		sta zp_bitmap_lo
+		bcc !+
+		inc zp_bitmap_hi
+	!:
+		ldx #1
+		asl masked_flags        // bit 7 = screenram
+		bcc !+
+		jsr masked_data
+		sta (zp_char_lo),y
+	!:	asl masked_flags        // bit 6 = colorram
+		bcc !+
+		jsr masked_data
+		sta (zp_d800_lo),y
+	!:	asl masked_flags        // bit 5 = pixel rows, followed by the mask of changed rows
+		bcc next_masked
+		jsr masked_data
+		sta masked_rows
+	!:	asl masked_rows
+		bcc !+
+		jsr masked_data
+		sta (zp_bitmap_lo),y
+	!:	iny
+		cpy #8
+		bne !--
+		beq next_masked
+
+masked_data:
+smc_masked_data:
+		lda $ffff,x
+		inx
+		rts
+masked_flags:
+		.byte 0
+masked_rows:
+		.byte 0
+
 // ------------------------------
 .pc = * "t_col2index"
 t_col2index:
@@ -0,0 +1,580 @@
+
+.const DEBUG = false
+.const MUSICDEBUG = false
+
+// the streaming displayer plays the animation in a raster irq from two segment buffers,
+// while the main loop loads and decrunches the next segment into the free buffer.
+// the HIRES variant plays hires animations, there is no fade in or out.
+.const bitmap       = $2000
+.const src_screen   = $3f40
+.const src_colorram = $4328
+.const screenram    = $0400
+.const colorram     = $d800
+.const buffer0      = $4800
+.const buffer1      = $7800
+.const load_address = $a800	// crunched segments are loaded here, up to $cfff
+.const raster_line  = $f8
+
+.const zp_anim_start  = $08
+.const zp_anim_lo     = zp_anim_start + 0
+.const zp_anim_hi     = zp_anim_start + 1
+.const zp_bitmap_lo   = zp_anim_start + 2
+.const zp_bitmap_hi   = zp_anim_start + 3
+.const zp_char_lo     = zp_anim_start + 4
+.const zp_char_hi     = zp_anim_start + 5
+.const zp_d800_lo     = zp_anim_start + 6
+.const zp_d800_hi     = zp_anim_start + 7
+
+.import source "lib.asm"
+.import source "decrunch.asm"
+
+.pc = $0801 "basic upstart"
+		.byte <basicend, >basicend, <year(), >year(), $9e
+		.text toIntString(start)
+		.text " PNG2PRG " + versionString()
+basicend:
+		.byte 0, 0, 0
+.pc = settings_start() "music_startsong"
+music_startsong:
+		.byte 0
+.pc = * "music_init"
+music_init:
+		jmp rrts
+.pc = * "music_play"
+music_play:
+		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
+.pc = * "frame_delay"
+frame_delay:
+		.byte 0
+.pc = * "wait_seconds"
+wait_seconds:
+		.byte 0
+.pc = * "segments"
+segments:
+		.byte 1
+.pc = * "loop_segments"
+loop_segments:
+		.byte 1	// 0 = stop after the last segment, holding the last frame
+
+.pc = basicsys() "start"
+start:
+		jmp init
+// the loader vector and segment filename follow the start, as the settings area is full.
+.pc = * "loader"
+loader:
+		jmp kernal_load	// a = length of the filename, x/y = lo/hi of the filename, carry set on error
+.pc = * "segment_name"
+segment_name_length:
+		.byte 2
+segment_name:
+		.fill 16, $30	// petscii, the last 2 chars are replaced with the segment number in hex
+
+.pc = * "init"
+init:
+		sei
+		jsr $e544
+		lda #$35
+		sta $01
+		lda #0
+		sta $d011
+		sta $d015
+		jsr vblank
+
+		// default pal 50 hz: $4cc7
+		lda #$c7
+		sta $dc04
+		lda #$4c
+		sta $dc05
+
+		lax music_startsong
+		tay
+		jsr music_init
+		lda #<irq
+		sta $fffe
+		lda #>irq
+		sta $ffff
+		lda #<irq_kernal
+		sta $0314
+		lda #>irq_kernal
+		sta $0315
+
+		ldx #0
+	!:
+	.for (var i=0; i<4; i++) {
+		lda src_screen+(i*$fa),x
+		sta screenram+(i*$fa),x
+#if !HIRES
+		lda src_colorram+(i*$fa),x
+		sta colorram+(i*$fa),x
+#endif
+	}
+		inx
+		cpx #$fa
+		bne !-
+
+#if HIRES
+		lda src_colorram
+		sta $d020
+#else
+		lda src_colorram+1000
+		sta $d021
+		lsr
+		lsr
+		lsr
+		lsr
+		sta $d020
+#endif
+		jsr vblank
+		:setBank(bitmap)
+		lda #toD018(screenram, bitmap)
+		sta $d018
+#if HIRES
+		lda #$c8
+#else
+		lda #$d8
+#endif
+		sta $d016
+		lda #$3b
+		sta $d011
+
+		lda #<buffer0
+		sta zp_anim_lo
+		lda #>buffer0
+		sta zp_anim_hi
+
+		lda #raster_line
+		sta $d012
+		lda #1
+		sta $d01a
+		lda #%00010001
+		sta $dc0e
+		cli
+
+		// optional wait before anim start
+		ldy wait_seconds
+		beq !start+
+!waitloop:
+		ldx #50
+	!:	jsr vblank
+		dex
+		bne !-
+		dey
+		bne !waitloop-
+!start:
+		lda #1
+		sta delay_counter
+		sta playing
+
+main_loop:
+		lda $dc01
+		cmp #$ef
+		beq !done+
+		lda cur_buffer
+		eor #1
+		tax
+		lda buffer_ready,x
+		bne main_loop
+		lda next_segment
+		cmp segments
+		bcc !+
+		lda loop_segments
+		beq main_loop
+		lda #0
+		sta next_segment
+	!:
+		jsr load_segment
+		jmp main_loop
+!done:
+		sei
+		lda #$37
+		sta $01
+		jsr vblank
+		lda #0
+		sta $d01a
+		sta $d011
+		:mute_sids()
+		jsr $e544
+		jmp $fce2
+
+// load_segment loads segment next_segment and decrunches it to buffer x.
+.pc = * "load_segment"
+load_segment:
+		stx load_buffer
+		lda next_segment
+		lsr
+		lsr
+		lsr
+		lsr
+		tax
+		lda t_hex,x
+		ldy segment_name_length
+		sta segment_name-2,y
+		lda next_segment
+		and #$0f
+		tax
+		lda t_hex,x
+		sta segment_name-1,y
+		tya
+		ldx #<segment_name
+		ldy #>segment_name
+		jsr loader
+		bcc !+
+		inc $d020	// load error, try again
+		rts
+	!:
+		lda #<load_address
+		sta tsget
+		lda #>load_address
+		sta tsget+1
+		ldx load_buffer
+		lda t_buffer_lo,x
+		sta tsput
+		lda t_buffer_hi,x
+		sta tsput+1
+		jsr ts_decrunch
+		inc next_segment
+		ldx load_buffer
+		lda #1
+		sta buffer_ready,x
+		rts
+
+// kernal_load loads a file from the last used drive to the load address in the file, the default loader.
+.pc = * "kernal_load"
+kernal_load:
+		pha
+		lda #$36
+		sta $01
+		pla
+		jsr $ffbd	// setnam
+		lda #0
+		jsr $ff90	// setmsg: no messages
+		lda #1
+		ldx $ba
+		bne !+
+		ldx #8
+	!:	ldy #1
+		jsr $ffba	// setlfs
+		lda #0
+		jsr $ffd5	// load
+		lda #$35
+		sta $01
+		rts
+
+.pc = * "ts_decrunch"
+ts_decrunch:
+		:ts_decrunch()
+
+.pc = * "vblank"
+vblank:
+		:vblank()
+rrts:	rts
+// --------------------------------
+.pc = * "irq"
+irq:
+		pha
+		txa
+		pha
+		tya
+		pha
+		jsr irq_body
+		pla
+		tay
+		pla
+		tax
+		pla
+		rti
+irq_kernal:
+		jsr irq_body
+		jmp $ea81
+
+irq_body:
+		lda $01
+		pha
+		lda #$35
+		sta $01
+		lda $dc0d
+		and #1
+		beq !+
+		.if (MUSICDEBUG) dec $d020
+		jsr music_play
+		.if (MUSICDEBUG) inc $d020
+	!:	lda $d019
+		and #1
+		beq !done+
+		sta $d019
+		lda playing
+		beq !done+
+		lda busy
+		bne !done+
+		dec delay_counter
+		bne !done+
+		inc busy
+		cli	// allow music irqs while plotting large frames
+		.if (DEBUG) inc $d020
+		jsr anim_play
+		.if (DEBUG) dec $d020
+		ldx cur_delay
+		bne !+
+		ldx frame_delay
+	!:	stx delay_counter
+		dec busy
+!done:
+		pla
+		sta $01
+		rts
+
+playing:
+		.byte 0
+busy:
+		.byte 0
+delay_counter:
+		.byte 0
+cur_delay:
+		.byte 0
+cur_buffer:
+		.byte 0
+buffer_ready:
+		.byte 1, 0
+next_segment:
+		.byte 1
+load_buffer:
+		.byte 0
+t_buffer_lo:
+		.byte <buffer0, <buffer1
+t_buffer_hi:
+		.byte >buffer0, >buffer1
+t_hex:
+		.fill 10, $30+i	// petscii 0-9
+		.fill 6, $41+i	// petscii a-f
+
+// switch_buffer continues with the start of the other buffer, if its segment is loaded.
+// returns carry set if the segment is not loaded yet.
+.pc = * "switch_buffer"
+switch_buffer:
+		lda cur_buffer
+		eor #1
+		tax
+		lda buffer_ready,x
+		bne !+
+		sec
+		rts
+	!:	ldy cur_buffer
+		lda #0
+		sta buffer_ready,y
+		stx cur_buffer
+		lda t_buffer_lo,x
+		sta zp_anim_lo
+		lda t_buffer_hi,x
+		sta zp_anim_hi
+		clc
+		rts
+
+// --------------------------------
+.pc = * "anim_play"
+anim_play:
+		ldy #0
+		lda (zp_anim_lo),y
+		cmp #$ff				// #$ff = end of segment, continue with the next segment
+		bne next_chunk
+		jsr switch_buffer
+		bcc next_chunk
+		rts
+next_chunk:
+		ldy #0
+		lax (zp_anim_lo),y      //  a = x = number of chars in chunk
+		bne plot_chunk          // #$00 = end of frame
+		iny
+		lda (zp_anim_lo),y      // frame delay, 0 = use frame_delay
+		sta cur_delay
+		lda zp_anim_lo
+		clc
+		adc #2
+		sta zp_anim_lo
+		bcc !+
+		inc zp_anim_hi
+	!:
+		ldy #0
+		lda (zp_anim_lo),y
+		cmp #$ff
+		bne !+
+		jsr switch_buffer
+	!:	rts
+
+plot_chunk:
+		cpx #$fe                // #$fe = masked frame
+		bne !+
+		jmp plot_masked
+	!:
+		iny
+		lda (zp_anim_lo),y
+		sta zp_bitmap_lo
+		iny
+		lda (zp_anim_lo),y
+		clc
+		adc #>bitmap
+		sta zp_bitmap_hi
+		iny
+		lda (zp_anim_lo),y
+		sta zp_char_lo
+		sta zp_d800_lo
+		iny
+		lda (zp_anim_lo),y
+		adc #>screenram
+		sta zp_char_hi
+		adc #>(colorram - screenram)
+		sta zp_d800_hi
+
+		lda zp_anim_lo
+		adc #5
+		sta zp_anim_lo
+		bcc !+
+		inc zp_anim_hi
+		clc
+	!:
+
+plot_next_char:
+		ldy #0
+	.for (var i = 0; i < 8; i++) {
+		lda (zp_anim_lo),y
+		sta (zp_bitmap_lo),y
+		iny
+	}
+#if HIRES
+		lda (zp_anim_lo),y
+		ldy #0
+		sta (zp_char_lo),y
+		lda #9
+#else
+		lda (zp_anim_lo),y
+		sta smc_keep_screencol+1
+		iny
+		lda (zp_anim_lo),y
+		ldy #0
+		sta (zp_d800_lo),y
+smc_keep_screencol:
+		lda #0
+		sta (zp_char_lo),y
+		lda #10
+#endif
+		clc
+		adc zp_anim_lo
+		sta zp_anim_lo
+		bcc !+
+		clc
+		inc zp_anim_hi
+	!:
+		lda zp_bitmap_lo
+		adc #8
+		sta zp_bitmap_lo
+		bcc !+
+		clc
+		inc zp_bitmap_hi
+	!:
+		inc zp_char_lo
+		inc zp_d800_lo
+		bne !+
+		inc zp_char_hi
+		inc zp_d800_hi
+	!:
+		dex
+		bne plot_next_char
+		jmp next_chunk
+
+// masked frame, see readme for details on format.
+plot_masked:
+		lda #<(bitmap - 8)      // start at char -1
+		sta zp_bitmap_lo
+		lda #>(bitmap - 8)
+		sta zp_bitmap_hi
+		lda #<(screenram - 1)
+		sta zp_char_lo
+		sta zp_d800_lo
+		lda #>(screenram - 1)
+		sta zp_char_hi
+		lda #>(colorram - 1)
+		sta zp_d800_hi
+		ldx #1                  // skip #$fe
+next_masked:
+		txa                     // x = length of the previous char
+		clc
+		adc zp_anim_lo
+		sta zp_anim_lo
+		sta smc_masked_data + 1
+		bcc !+
+		inc zp_anim_hi
+	!:	lda zp_anim_hi
+		sta smc_masked_data + 2
+		ldy #0
+		lda (zp_anim_lo),y
+		bne !+
+		jmp next_chunk          // #$00 = end of frame
+	!:	sta masked_flags
+		and #$1f                // number of chars to skip
+		tax
+		inx
+		txa
+		clc
+		adc zp_char_lo
+		sta zp_char_lo
+		sta zp_d800_lo
+		bcc !+
+		inc zp_char_hi
+		inc zp_d800_hi
+	!:	txa
+		asl
+		asl
+		asl
+		bcc !+
+		inc zp_bitmap_hi
+		clc
+	!:	adc zp_bitmap_lo
+		sta zp_bitmap_lo
+		bcc !+
+		inc zp_bitmap_hi
+	!:
+		ldx #1
+		asl masked_flags        // bit 7 = screenram
+		bcc !+
+		jsr masked_data
+		sta (zp_char_lo),y
+	!:	asl masked_flags        // bit 6 = colorram
+		bcc !+
+		jsr masked_data
+		sta (zp_d800_lo),y
+	!:	asl masked_flags        // bit 5 = pixel rows, followed by the mask of changed rows
+		bcc next_masked
+		jsr masked_data
+		sta masked_rows
+	!:	asl masked_rows
+		bcc !+
+		jsr masked_data
+		sta (zp_bitmap_lo),y
+	!:	iny
+		cpy #8
+		bne !--
+		beq next_masked
+
+masked_data:
+smc_masked_data:
+		lda $ffff,x
+		inx
+		rts
+masked_flags:
+		.byte 0
+masked_rows:
+		.byte 0
+
+// ------------------------------
+.pc = bitmap "picture" virtual
+picture:
+		.fill $2711, 0
+// ------------------------------
+.pc = buffer0 "segment_buffer0" virtual
+		.fill $3000, 0
+.pc = buffer1 "segment_buffer1" virtual
+		.fill $3000, 0
+.pc = load_address "segment_load" virtual
+		.fill $2800, 0
@@ -0,0 +1,3 @@
+// koala displayer with the picture in vic bank 1.
+#define BANK1
+#import "display_koala.asm"
@@ -0,0 +1,3 @@
+// koala displayer with the picture in vic bank 2.
+#define BANK2
+#import "display_koala.asm"
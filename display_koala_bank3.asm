@@ -0,0 +1,3 @@
+// koala displayer with the picture in vic bank 3.
+#define BANK3
+#import "display_koala.asm"
@@ -32,6 +32,9 @@ music_init:
 .pc = * "music_play"
 music_play:
 		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
 .pc = * "charset_case"
 charset_case:
 		.byte 0 // 0 = uppercase, 1 = lowercase
@@ -189,7 +192,7 @@ smc_yval:	ldy #steps-1
 		jsr vblank
 		lda #0
 		sta $d011
-		sta $d418
+		:mute_sids()
 		jsr $e544
 		jmp $fce2
 	}
@@ -0,0 +1,456 @@
+.const DEBUG = false
+.const MUSICDEBUG = false
+.const bitmap     = $2000
+.const bitmap2    = $6000
+.const screenram  = $0400
+.const screenram2 = $5c00
+.const colorram   = $d800
+.const bg_border  = $7f40
+.const d016offset = $7f42
+.const animations = $8000
+
+.const src_screenram = $4000
+.const src_colorram = $4400
+
+// the interlace animation displayer shows both halves in turn, switching vic banks every frame.
+// each frame of the animation contains the changes of both halves, the first half is applied while the second half
+// is shown and vice versa. the colorram is shared by both halves.
+.const zp_anim_start  = $08
+.const zp_anim_lo     = zp_anim_start + 0
+.const zp_anim_hi     = zp_anim_start + 1
+.const zp_bitmap_lo   = zp_anim_start + 2
+.const zp_bitmap_hi   = zp_anim_start + 3
+.const zp_char_lo     = zp_anim_start + 4
+.const zp_char_hi     = zp_anim_start + 5
+.const zp_d800_lo     = zp_anim_start + 6
+.const zp_d800_hi     = zp_anim_start + 7
+
+.import source "lib.asm"
+
+.pc = $0801 "basic upstart"
+		.byte <basicend, >basicend, <year(), >year(), $9e
+		.text toIntString(start)
+		.text " PNG2PRG " + versionString()
+basicend:
+		.byte 0, 0, 0
+.pc = settings_start() "music_startsong"
+music_startsong:
+		.byte 0
+.pc = * "music_init"
+music_init:
+		jmp rrts
+.pc = * "music_play"
+music_play:
+		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
+.pc = * "frame_delay"
+frame_delay:
+		.byte 0
+.pc = * "wait_seconds"
+wait_seconds:
+		.byte 0
+
+.pc = basicsys() "start"
+start:
+		sei
+		jsr $e544
+		lda #$35
+		sta $01
+		jsr vblank
+		lda #0
+		sta $d011
+		sta $d020
+
+		// default pal 50 hz: $4cc7
+		lda #$c7
+		sta $dc04
+		lda #$4c
+		sta $dc05
+
+		lax music_startsong
+		tay
+		jsr music_init
+		lda #<irq
+		sta $fffe
+		lda #>irq
+		sta $ffff
+		lda #%00010001
+		sta $dc0e
+		cli
+
+		ldx #0
+	!:
+	.for (var i=0; i<4; i++) {
+		lda src_screenram+(i*$100),x
+		sta screenram+(i*$100),x
+		lda src_colorram+(i*$100),x
+		sta colorram+(i*$100),x
+	}
+		inx
+		bne !-
+
+		lda bg_border
+		sta $d021
+		lsr
+		lsr
+		lsr
+		lsr
+		sta $d020
+		jsr anim_init
+
+		jsr vblank
+		jsr show_half
+		lda #$3b
+		sta $d011
+
+		// optional wait before anim start
+		ldy wait_seconds
+		beq loop_anim
+!waitloop:
+		ldx #50
+	!:	jsr flip
+		dex
+		bne !-
+		dey
+		bne !waitloop-
+
+loop_anim:
+		jsr flip
+		lda next_half
+		bpl !apply+
+		dec delay_counter
+		bne !key+
+		lda #0
+		sta next_half
+!apply:
+		cmp shown_half          // only change the half that is not shown
+		beq !key+
+		tax
+		.if (DEBUG) inc $d020
+		jsr anim_play_half
+		.if (DEBUG) dec $d020
+		inc next_half
+		lda next_half
+		cmp #2
+		bne !key+
+		lda #$ff
+		sta next_half
+		ldx cur_delay
+		bne !+
+		ldx frame_delay
+		bne !+
+		inx
+	!:	stx delay_counter
+!key:
+		lda $dc01
+		cmp #$ef
+		bne loop_anim
+
+		sei
+		lda #$37
+		sta $01
+		jsr vblank
+		lda #0
+		sta $d011
+		:mute_sids()
+		jsr $e544
+		jmp $fce2
+
+.pc = * "vblank"
+vblank:
+		:vblank()
+rrts:	rts
+// --------------------------------
+.pc = * "irq"
+irq:
+		pha
+		txa
+		pha
+		tya
+		pha
+		.if (MUSICDEBUG) dec $d020
+		jsr music_play
+		.if (MUSICDEBUG) inc $d020
+		lda $dc0d
+		pla
+		tay
+		pla
+		tax
+		pla
+		rti
+// --------------------------------
+// flip waits for the vertical blank and shows the other half.
+.pc = * "flip"
+flip:
+		jsr vblank
+		lda shown_half
+		eor #1
+		sta shown_half
+show_half:
+		ldy shown_half
+		lda t_dd00,y
+		sta $dd00
+		lda t_d018,y
+		sta $d018
+		lda #$d8
+		cpy #0
+		beq !+
+		eor d016offset
+	!:	sta $d016
+		rts
+
+shown_half:
+		.byte 0
+next_half:
+		.byte $ff               // the half to change next, $ff while waiting for the next frame
+delay_counter:
+		.byte 1
+cur_delay:
+		.byte 0
+cur_bitmap_hi:
+		.byte >bitmap
+cur_screen_hi:
+		.byte >screenram
+
+t_dd00:
+		.byte toDD00(bitmap), toDD00(bitmap2)
+t_d018:
+		.byte toD018(screenram, bitmap), toD018(screenram2, bitmap2)
+t_bitmap_hi:
+		.byte >bitmap, >bitmap2
+t_screen_hi:
+		.byte >screenram, >screenram2
+// ------------------------------
+// anim_play_half applies the changes of the next frame to half x.
+.pc = * "anim_play_half"
+anim_play_half:
+		lda t_bitmap_hi,x
+		sta cur_bitmap_hi
+		lda t_screen_hi,x
+		sta cur_screen_hi
+anim_play:
+next_chunk:
+		ldy #0
+		lax (zp_anim_lo),y      //  a = x = number of chars in chunk
+		bne plot_chunk          // #$00 = end of frame
+		iny
+		lda (zp_anim_lo),y      // frame delay, 0 = use frame_delay
+		sta cur_delay
+		dey
+		lda zp_anim_lo
+		clc
+		adc #2
+		sta zp_anim_lo
+		bcc !+
+		inc zp_anim_hi
+	!:
+		lda (zp_anim_lo),y
+		cmp #$ff				// #$ff = end of all frames, followed by the address of the next frame
+		bne !skip+
+		iny
+		lda (zp_anim_lo),y
+		tax
+		iny
+		lda (zp_anim_lo),y
+		sta zp_anim_hi
+		stx zp_anim_lo
+!skip:	rts
+
+.pc = * "anim_init"
+anim_init:
+		lda #<anim_frames
+		sta zp_anim_lo
+		lda #>anim_frames
+		sta zp_anim_hi
+		rts
+
+plot_chunk:
+		cpx #$fe                // #$fe = masked frame
+		bne !+
+		jmp plot_masked
+	!:
+		iny
+		lda (zp_anim_lo),y
+		sta zp_bitmap_lo
+		iny
+		lda (zp_anim_lo),y
+		clc
+		adc cur_bitmap_hi
+		sta zp_bitmap_hi
+		iny
+		lda (zp_anim_lo),y
+		sta zp_char_lo
+		sta zp_d800_lo
+		iny
+		lda (zp_anim_lo),y
+		//clc
+		adc cur_screen_hi
+		sta zp_char_hi
+		lda (zp_anim_lo),y
+		//clc
+		adc #>colorram
+		sta zp_d800_hi
+
+		lda zp_anim_lo
+		//clc
+		adc #5
+		sta zp_anim_lo
+		bcc !+
+		inc zp_anim_hi
+		clc
+	!:
+
+plot_next_char:
+		ldy #0
+	.for (var i = 0; i < 8; i++) {
+		lda (zp_anim_lo),y
+		sta (zp_bitmap_lo),y
+		iny
+	}
+		lda (zp_anim_lo),y
+		sta smc_keep_screencol+1
+		iny
+		lda (zp_anim_lo),y
+
+		ldy #0
+		sta (zp_d800_lo),y
+smc_keep_screencol:
+		lda #0
+		sta (zp_char_lo),y
+
+		lda zp_anim_lo
+		//clc
+		adc #10
+		sta zp_anim_lo
+		bcc !+
+		clc
+		inc zp_anim_hi
+	!:
+		lda zp_bitmap_lo
+		adc #8
+		sta zp_bitmap_lo
+		bcc !+
+		clc
+		inc zp_bitmap_hi
+	!:
+		inc zp_char_lo
+		inc zp_d800_lo
+		bne !+
+		inc zp_char_hi
+		inc zp_d800_hi
+	!:
+		dex
+		bne plot_next_char
+		jmp next_chunk
+
+// masked frame, see readme for details on format.
+plot_masked:
+		lda #<(bitmap - 8)      // start at char -1, both halves are page aligned
+		sta zp_bitmap_lo
+		ldx cur_bitmap_hi
+		dex
+		stx zp_bitmap_hi
+		lda #<(screenram - 1)
+		sta zp_char_lo
+		sta zp_d800_lo
+		ldx cur_screen_hi
+		dex
+		stx zp_char_hi
+		lda #>(colorram - 1)
+		sta zp_d800_hi
+		ldx #1                  // skip #$fe
+next_masked:
+		txa                     // x = length of the previous char
+		clc
+		adc zp_anim_lo
+		sta zp_anim_lo
+		sta smc_masked_data + 1
+		bcc !+
+		inc zp_anim_hi
+	!:	lda zp_anim_hi
+		sta smc_masked_data + 2
+		ldy #0
+		lda (zp_anim_lo),y
+		bne !+
+		jmp next_chunk          // #$00 = end of frame
+	!:	sta masked_flags
+		and #$1f                // number of chars to skip
+		tax
+		inx
+		txa
+		clc
+		adc zp_char_lo
+		sta zp_char_lo
+		sta zp_d800_lo
+		bcc !+
+		inc zp_char_hi
+		inc zp_d800_hi
+	!:	txa
+		asl
+		asl
+		asl
+		bcc !+
+		inc zp_bitmap_hi
+		clc
+	!:	adc zp_bitmap_lo
+		sta zp_bitmap_lo
+		bcc !+
+		inc zp_bitmap_hi
+	!:
+		ldx #1
+		asl masked_flags        // bit 7 = screenram
+		bcc !+
+		jsr masked_data
+		sta (zp_char_lo),y
+	!:	asl masked_flags        // bit 6 = colorram
+		bcc !+
+		jsr masked_data
+		sta (zp_d800_lo),y
+	!:	asl masked_flags        // bit 5 = pixel rows, followed by the mask of changed rows
+		bcc next_masked
+		jsr masked_data
+		sta masked_rows
+	!:	asl masked_rows
+		bcc !+
+		jsr masked_data
+		sta (zp_bitmap_lo),y
+	!:	iny
+		cpy #8
+		bne !--
+		beq next_masked
+
+masked_data:
+smc_masked_data:
+		lda $ffff,x
+		inx
+		rts
+masked_flags:
+		.byte 0
+masked_rows:
+		.byte 0
+
+// ------------------------------
+.pc = bitmap "koala_source" virtual
+koala_source:
+		.fill $1f40, 0
+// ------------------------------
+.pc = src_screenram "src_screenram" virtual
+		.fill 1000, 0
+// ------------------------------
+.pc = src_colorram "src_colorram" virtual
+		.fill 1000, 0
+// ------------------------------
+.pc = screenram2 "screenram2" virtual
+		.fill 1000, 0
+// ------------------------------
+.pc = bitmap2 "koala2_source" virtual
+		.fill $1f40, 0
+// ------------------------------
+.pc = bg_border "bg_border" virtual
+		.byte 0, 0, 0
+// ------------------------------
+.pc = animations "anim_frames" virtual
+anim_frames:
+		.byte 0, 0, 0, 0, $ff
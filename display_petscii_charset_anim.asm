@@ -46,6 +46,9 @@ music_init:
 .pc = * "music_play"
 music_play:
 		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
 .pc = * "charset_case"
 charset_case:
 		.byte 0 // 0 = uppercase, 1 = lowercase
@@ -211,16 +214,13 @@ smc_yval:	ldy #steps-1
 		bne !waitloop-
 
 loop_anim:
+		ldx cur_delay
+		bne !+
 		ldx frame_delay
-!loop:
-	!:	bit $d011
-		bpl !-
-	!:	lda $d012
-		bne !-
-	!:	lda $d012
-		beq !-
+	!:
+	!:	jsr vblank
 		dex
-		bne !loop-
+		bne !-
 
 		.if (DEBUG) inc $d020
 		jsr anim_play
@@ -253,7 +253,7 @@ loop_anim:
 		jsr vblank
 		lda #0
 		sta $d011
-		sta $d418
+		:mute_sids()
 		jsr $e544
 		jmp $fce2
 	}
@@ -390,6 +390,10 @@ smc_totpercol:
 		inc smc_fadepercol2 + 1
 		rts
 // ------------------------------
+.pc = * "cur_delay"
+cur_delay:
+		.byte 0
+// ------------------------------
 .pc = * "anim_play"
 anim_play:
 		lda $d020
@@ -423,13 +427,28 @@ skip_grey_dot2:
 		ldy #0
 		lax (zp_anim_lo),y
 		bne plot_chunk          // #$00 = end of frame
-		inc zp_anim_lo
-		bne !+
+		iny
+		lda (zp_anim_lo),y      // frame delay, 0 = use frame_delay
+		sta cur_delay
+		dey
+		lda zp_anim_lo
+		clc
+		adc #2
+		sta zp_anim_lo
+		bcc !+
 		inc zp_anim_hi
 	!:
 		lda (zp_anim_lo),y
-		cmp #$ff				// #$ff = end of all frames
+		cmp #$ff				// #$ff = end of all frames, followed by the address of the next frame
 		bne !skip+
+		iny
+		lda (zp_anim_lo),y
+		tax
+		iny
+		lda (zp_anim_lo),y
+		sta zp_anim_hi
+		stx zp_anim_lo
+!skip:		rts
 
 .pc = * "anim_init"
 anim_init:
@@ -437,7 +456,7 @@ anim_init:
 		sta zp_anim_lo
 		lda #>anim_frames
 		sta zp_anim_hi
-!skip:		rts
+		rts
 
 plot_chunk:
 		//tax                     // x = number of chars in chunk
@@ -0,0 +1,409 @@
+
+.const DEBUG = false
+.const MUSICDEBUG = false
+.const fade_speed = 2
+.const fade_steps = 7
+
+// the pictures are decrunched to vic bank 1, the second bitmap and screenram of interlace pictures to vic bank 3.
+.const screenram  = $5c00
+.const screenram2 = $cc00
+.const colorram   = $d800
+.const t_fade     = $0400	// generated at start, vic bank 0 is not used
+
+// each slide has a 16 byte header, filled in by png2prg:
+.const slide_d011      = 0
+.const slide_d016      = 1
+.const slide_d018      = 2
+.const slide_dd00      = 3
+.const slide_colorram  = 4	// hi-byte of the colorram source, 0 if not used
+.const slide_fade      = 5	// bit 7: fade screenram, bit 6: fade colorram, bit 5: fade screenram2
+.const slide_colors    = 6	// d020-d024
+.const slide_interlace = 11	// d016 eor value for interlace
+.const slide_chunks    = 12	// lo/hi pointer to the chunk list
+.const slide_d018_2    = 14	// d018 and dd00 of the second interlace frame, d018 is 0 if not interlaced
+.const slide_dd00_2    = 15
+.const slide_length    = 16
+// the chunk list contains a 4 byte src and dst address per crunched chunk, terminated by a 0 src address.
+
+.import source "lib.asm"
+.import source "decrunch.asm"
+
+// fade_area fades the 1000 colors at addr one step to black, using both nibbles.
+.macro fade_area(addr) {
+		ldx #0
+	!:
+	.for (var i=0; i<4; i++) {
+		ldy addr+(i*$100),x
+		lda t_fade,y
+		sta addr+(i*$100),x
+	}
+		inx
+		bne !-
+}
+
+.pc = $0801 "basic upstart"
+		.byte <basicend, >basicend, <year(), >year(), $9e
+		.text toIntString(start)
+		.text " PNG2PRG " + versionString()
+basicend:
+		.byte 0, 0, 0
+.pc = settings_start() "music_startsong"
+music_startsong:
+		.byte 0
+.pc = * "music_init"
+music_init:
+		jmp rrts
+.pc = * "music_play"
+music_play:
+		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
+.pc = * "slide_delay"
+slide_delay:
+		.byte 5 // seconds, 0 waits for space
+.pc = * "slide_count"
+slide_count:
+		.byte 0
+.pc = * "no_fade"
+no_fade:
+		.byte 0
+
+.pc = basicsys() "start"
+start:
+		sei
+		jsr $e544
+		lda #$35
+		sta $01
+
+		ldy #7
+!loop:
+		ldx #fade_speed
+	!:	jsr vblank
+		dex
+		bne !-
+
+		lda $d020
+		and #$0f
+		tax
+		lda t_easyfade,x
+		sta $d020
+		lda $d021
+		and #$0f
+		tax
+		lda t_easyfade,x
+		sta $d021
+		dey
+		bne !loop-
+		sta $d011
+
+		// default pal 50 hz: $4cc7
+		lda #$c7
+		sta $dc04
+		lda #$4c
+		sta $dc05
+
+		lax music_startsong
+		tay
+		jsr music_init
+		lda #<irq
+		sta $fffe
+		lda #>irq
+		sta $ffff
+
+		lda #$80
+	!:	cmp $d012
+		bne !-
+	.if (MUSICDEBUG) {
+		ldx #5
+	!:	dex
+		bne !-
+	}
+		lda #%00010001
+		sta $dc0e
+		cli
+
+		jsr generate_fade_table
+
+.pc = * "slide_loop"
+slide_loop:
+		ldx #slide_length-1
+	!:
+smc_slide:
+		lda slides,x
+		sta cur,x
+		dex
+		bpl !-
+
+		.if (DEBUG) inc $d020
+		jsr decrunch_slide
+		.if (DEBUG) dec $d020
+		jsr show_slide
+		jsr wait_slide
+		jsr fade_out
+		jsr vblank
+		lda #0
+		sta $d011
+
+		lda smc_slide+1
+		clc
+		adc #slide_length
+		sta smc_slide+1
+		bcc !+
+		inc smc_slide+2
+	!:
+		inc slide_index
+		lda slide_index
+		cmp slide_count
+		bcc slide_loop
+
+		lda #0
+		sta slide_index
+		lda #<slides
+		sta smc_slide+1
+		lda #>slides
+		sta smc_slide+2
+		jmp slide_loop
+// --------------------------------
+.pc = * "decrunch_slide"
+decrunch_slide:
+		lda cur+slide_chunks
+		ldx cur+slide_chunks+1
+!loop:
+		sta smc_chunk+1
+		stx smc_chunk+2
+		ldx #3
+	!:
+smc_chunk:
+		lda $ffff,x
+		sta chunk,x
+		dex
+		bpl !-
+
+		lda chunk+1
+		beq !done+
+		sta tsget+1
+		lda chunk
+		sta tsget
+		lda chunk+2
+		sta tsput
+		lda chunk+3
+		sta tsput+1
+		jsr ts_decrunch
+
+		lda smc_chunk+1
+		ldx smc_chunk+2
+		clc
+		adc #4
+		bcc !loop-
+		inx
+		bcs !loop-
+!done:
+		rts
+// --------------------------------
+.pc = * "show_slide"
+show_slide:
+		lda cur+slide_colorram
+		beq !skip+
+		sta smc_src_col+2
+		lda #>colorram
+		sta smc_col+2
+		ldy #4
+		ldx #0
+	!:
+smc_src_col:
+		lda $ff00,x
+smc_col:
+		sta colorram,x
+		inx
+		bne !-
+		inc smc_src_col+2
+		inc smc_col+2
+		dey
+		bne !-
+!skip:
+		ldx #4
+	!:	lda cur+slide_colors,x
+		sta $d020,x
+		dex
+		bpl !-
+
+		jsr vblank
+		lda cur+slide_dd00
+		sta $dd00
+		lda cur+slide_d018
+		sta $d018
+		lda cur+slide_d016
+		sta $d016
+		lda cur+slide_d011
+		sta $d011
+		rts
+// --------------------------------
+.pc = * "wait_slide"
+wait_slide:
+		lda slide_delay
+		sta seconds_left
+!second:
+		lda #50
+		sta frames_left
+!frame:
+		jsr next_frame
+		lda $dc01
+		ldx last_key
+		sta last_key
+		cmp #$ef
+		bne !+
+		cpx #$ef
+		bne !done+
+	!:
+		lda slide_delay
+		beq !frame-
+		dec frames_left
+		bne !frame-
+		dec seconds_left
+		bne !second-
+!done:
+		rts
+// --------------------------------
+.pc = * "fade_out"
+fade_out:
+		lda no_fade
+		bne !done+
+		lda #fade_steps
+		sta steps_left
+!loop:
+		lda #fade_speed
+		sta frames_left
+	!:	jsr next_frame
+		dec frames_left
+		bne !-
+
+		jsr fade_step
+		dec steps_left
+		bne !loop-
+!done:
+		rts
+// --------------------------------
+.pc = * "fade_step"
+fade_step:
+		ldx #4
+	!:	lda $d020,x
+		and #$0f
+		tay
+		lda t_easyfade,y
+		sta $d020,x
+		dex
+		bpl !-
+
+		bit cur+slide_fade
+		bpl !+
+		:fade_area(screenram)
+	!:
+		bit cur+slide_fade
+		bvc !+
+		:fade_area(colorram)
+	!:
+		lda cur+slide_fade
+		and #%00100000
+		beq !+
+		:fade_area(screenram2)
+	!:	rts
+// --------------------------------
+// next_frame waits for the next frame and swaps vic bank, d018 and d016 for interlace slides.
+.pc = * "next_frame"
+next_frame:
+		jsr vblank
+		lda cur+slide_d018_2
+		beq !done+
+		ldx cur+slide_dd00_2
+		lda cur+slide_dd00
+		stx cur+slide_dd00
+		sta cur+slide_dd00_2
+		stx $dd00
+		ldx cur+slide_d018_2
+		lda cur+slide_d018
+		stx cur+slide_d018
+		sta cur+slide_d018_2
+		stx $d018
+		lda $d016
+		eor cur+slide_interlace
+		sta $d016
+!done:
+		rts
+// --------------------------------
+.pc = * "generate_fade_table"
+generate_fade_table:
+		ldx #0
+	!:
+		txa
+		and #$0f
+		tay
+		lda t_easyfade,y
+		sta smc_lo+1
+		txa
+		lsr
+		lsr
+		lsr
+		lsr
+		tay
+		lda t_easyfade,y
+		asl
+		asl
+		asl
+		asl
+smc_lo:
+		ora #0
+		sta t_fade,x
+		inx
+		bne !-
+		rts
+.pc = * "vblank"
+vblank:
+		:vblank()
+rrts:	rts
+// --------------------------------
+.pc = * "irq"
+irq:
+		pha
+		txa
+		pha
+		tya
+		pha
+		.if (MUSICDEBUG) dec $d020
+		jsr music_play
+		.if (MUSICDEBUG) inc $d020
+		lda $dc0d
+		pla
+		tay
+		pla
+		tax
+		pla
+		rti
+// --------------------------------
+.pc = * "ts_decrunch"
+ts_decrunch:
+		:ts_decrunch()
+// ------------------------------
+.pc = * "t_easyfade"
+t_easyfade:
+		.byte $00,$0d,$09,$0c,$02,$08,$00,$0f
+		.byte $02,$00,$08,$09,$04,$03,$04,$05
+// ------------------------------
+.pc = * "variables"
+slide_index:
+		.byte 0
+seconds_left:
+		.byte 0
+frames_left:
+		.byte 0
+steps_left:
+		.byte 0
+last_key:
+		.byte 0
+chunk:
+		.fill 4, 0
+cur:
+		.fill slide_length, 0
+// ------------------------------
+// png2prg links the slide headers and chunk lists here.
+slides:
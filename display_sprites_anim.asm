@@ -0,0 +1,261 @@
+.const DEBUG = false
+.const MUSICDEBUG = false
+
+// the sprite animation displayer animates up to 8 sprite objects, each with its own sequence of sprite pointers and delays.
+// the object table follows the code at anim_header, it is written by png2prg.
+.const screenram = $0400
+.const sprites   = $2000
+
+.const zp_anim_start = $08
+.const zp_ptrs_lo    = zp_anim_start + 0
+.const zp_ptrs_hi    = zp_anim_start + 1
+.const zp_delays_lo  = zp_anim_start + 2
+.const zp_delays_hi  = zp_anim_start + 3
+
+.import source "lib.asm"
+
+.pc = $0801 "basic upstart"
+		.byte <basicend, >basicend, <year(), >year(), $9e
+		.text toIntString(start)
+		.text " PNG2PRG " + versionString()
+basicend:
+		.byte 0, 0, 0
+.pc = settings_start() "music_startsong"
+music_startsong:
+		.byte 0
+.pc = * "music_init"
+music_init:
+		jmp rrts
+.pc = * "music_play"
+music_play:
+		jmp rrts
+.pc = * "music_sids"
+music_sids:
+		.byte 0, 0 // extra sids from the psid header, $42 = $d420
+.pc = * "frame_delay"
+frame_delay:
+		.byte 0
+.pc = * "wait_seconds"
+wait_seconds:
+		.byte 0
+
+.pc = basicsys() "start"
+start:
+		sei
+		jsr $e544
+		lda #$35
+		sta $01
+		jsr vblank
+		lda #0
+		sta $d011
+		sta $d015
+		sta $d020
+
+		// default pal 50 hz: $4cc7
+		lda #$c7
+		sta $dc04
+		lda #$4c
+		sta $dc05
+
+		lax music_startsong
+		tay
+		jsr music_init
+		lda #<irq
+		sta $fffe
+		lda #>irq
+		sta $ffff
+		lda #%00010001
+		sta $dc0e
+		cli
+
+		ldx #0
+		lda #$20
+	!:	sta screenram,x
+		sta screenram+$100,x
+		sta screenram+$200,x
+		sta screenram+$2e8,x
+		inx
+		bne !-
+
+		lda #0
+		sta $d010
+		sta $d017
+		sta $d01b
+		sta $d01d
+		lda sprite_d01c
+		sta $d01c
+		lda sprite_bgcol
+		sta $d021
+		lda sprite_d025
+		sta $d025
+		lda sprite_d026
+		sta $d026
+		jsr anim_init
+
+		jsr vblank
+		:setBank(screenram)
+		lda #toD018(screenram, $1000)
+		sta $d018
+		lda #$c8
+		sta $d016
+		lda #$1b
+		sta $d011
+		ldx objects
+		lda t_enable,x
+		sta $d015
+
+		// optional wait before anim start
+		ldy wait_seconds
+		beq loop_anim
+!waitloop:
+		ldx #50
+	!:	jsr vblank
+		dex
+		bne !-
+		dey
+		bne !waitloop-
+
+loop_anim:
+		jsr vblank
+		.if (DEBUG) inc $d020
+		jsr anim_play
+		.if (DEBUG) dec $d020
+
+		lda $dc01
+		cmp #$ef
+		bne loop_anim
+		sei
+		lda #$37
+		sta $01
+		jsr vblank
+		lda #0
+		sta $d011
+		sta $d015
+		:mute_sids()
+		jsr $e544
+		jmp $fce2
+
+.pc = * "vblank"
+vblank:
+		:vblank()
+rrts:	rts
+// --------------------------------
+.pc = * "irq"
+irq:
+		pha
+		txa
+		pha
+		tya
+		pha
+		.if (MUSICDEBUG) dec $d020
+		jsr music_play
+		.if (MUSICDEBUG) inc $d020
+		lda $dc0d
+		pla
+		tay
+		pla
+		tax
+		pla
+		rti
+// --------------------------------
+// anim_init positions and colors the sprites and shows the first step of each object.
+.pc = * "anim_init"
+anim_init:
+		ldx objects
+		dex
+		bmi !done+
+!loop:
+		txa
+		asl
+		tay
+		lda obj_x,x
+		sta $d000,y
+		lda obj_y,x
+		sta $d001,y
+		lda obj_color,x
+		sta $d027,x
+		lda #0
+		sta obj_index,x
+		jsr show_step
+		dex
+		bpl !loop-
+!done:
+		rts
+
+// anim_play advances each object when its delay counter runs out.
+// objects playing once stop at their last step, with their counter at 0.
+.pc = * "anim_play"
+anim_play:
+		ldx objects
+		dex
+		bmi !done+
+!loop:
+		lda obj_counter,x
+		beq !next+
+		dec obj_counter,x
+		bne !next+
+		inc obj_index,x
+		lda obj_index,x
+		cmp obj_steps,x
+		bcc !show+
+		lda sprite_loop
+		beq !hold+
+		lda #0
+		sta obj_index,x
+!show:
+		jsr show_step
+		jmp !next+
+!hold:
+		dec obj_index,x	// play once, hold the last step
+!next:
+		dex
+		bpl !loop-
+!done:
+		rts
+
+// show_step sets the sprite pointer and delay counter of step obj_index of object x.
+show_step:
+		lda obj_ptrs_lo,x
+		sta zp_ptrs_lo
+		lda obj_ptrs_hi,x
+		sta zp_ptrs_hi
+		lda obj_delays_lo,x
+		sta zp_delays_lo
+		lda obj_delays_hi,x
+		sta zp_delays_hi
+		ldy obj_index,x
+		lda (zp_ptrs_lo),y
+		sta screenram+$3f8,x
+		lda (zp_delays_lo),y
+		bne !+
+		lda frame_delay
+		bne !+
+		lda #1
+	!:	sta obj_counter,x
+		rts
+
+obj_index:
+		.fill 8, 0
+obj_counter:
+		.fill 8, 0
+t_enable:
+		.fill 9, (1 << i) - 1
+
+// --------------------------------
+.pc = * "anim_header"
+anim_header:
+sprite_d01c:	.byte 0	// $ff for multicolor sprites
+sprite_bgcol:	.byte 0
+sprite_d025:	.byte 0
+sprite_d026:	.byte 0
+sprite_loop:	.byte 1	// 0 = play once, hold the last step
+objects:		.byte 0
+obj_x:			.fill 8, 0
+obj_y:			.fill 8, 0
+obj_color:		.fill 8, 0
+obj_steps:		.fill 8, 0
+obj_ptrs_lo:	.fill 8, 0
+obj_ptrs_hi:	.fill 8, 0
+obj_delays_lo:	.fill 8, 0
+obj_delays_hi:	.fill 8, 0
+anim_header_end:
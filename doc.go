@@ -58,6 +58,8 @@ func PrintHelp() {
 	fmt.Println("    mcsprites:    multicolor sprites (max 4 colors)")
 	fmt.Println("    scsprites:    singlecolor sprites (max 2 colors)")
 	fmt.Println("    mcibitmap:    320x200 multicolor interlace bitmap (max 4 colors per char/frame)")
+	fmt.Println("    hiresinterlace: 2 hires bitmaps (blended colors, max 2 colors per char/frame)")
+	fmt.Println("    ifli:         2 multicolor fli bitmaps (blended colors, max 4 colors per line/frame)")
 	fmt.Println()
 	fmt.Println("Png2prg is mostly able to autodetect the correct graphics mode, but you can")
 	fmt.Println("also force a specific graphics mode with the -mode flag:")
@@ -83,6 +85,21 @@ func PrintHelp() {
 	fmt.Println()
 	fmt.Println("    ./png2prg -i testdata/madonna/frame_0.png testdata/madonna/frame_1.png")
 	fmt.Println()
+	fmt.Println("### Blended Colors (color mixing)")
+	fmt.Println()
+	fmt.Println("Or supply one multicolor image drawn in blended colors, each color the average")
+	fmt.Println("of 2 c64 colors shown by color flicker. Write the 136 blended colors of a")
+	fmt.Println("palette as a png to draw with:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -blend-table pepto -o pepto_blends.png")
+	fmt.Println()
+	fmt.Println("The cell at column a and row b is the blend of colors a and b. Png2prg splits")
+	fmt.Println("the image into 2 koala frames, sharing the D021 color and the D800 color of")
+	fmt.Println("each char, with 2 screen colors per char per frame. When the image does not")
+	fmt.Println("fit, it is converted to ifli. The -d016 offset is 0 for blended images.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d blended_koala.png")
+	fmt.Println()
 	fmt.Println("### Drazlace (shared screenram and colorram for both frames)")
 	fmt.Println()
 	fmt.Println("    ./png2prg testdata/madonna/cjam_pure_madonna.png")
@@ -108,6 +125,74 @@ func PrintHelp() {
 	fmt.Println("    Screen2: $e000 - $e3e7")
 	fmt.Println("    D800:    $e400 - $e7e7")
 	fmt.Println()
+	fmt.Println("### -interlace-format (-if)")
+	fmt.Println()
+	fmt.Println("By default drazlace is written when both frames share screenram and colorram,")
+	fmt.Println("the true paint .mci format otherwise. Use -interlace-format drazlace, truepaint")
+	fmt.Println("or hires to choose the format, png2prg fails when the chosen format cannot")
+	fmt.Println("represent the picture. The format only applies without -display.")
+	fmt.Println("Use -interlace-format gunpaint to write ifli images as Gunpaint files.")
+	fmt.Println("Interlace Studio files are not supported.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -if truepaint testdata/madonna/cjam_pure_madonna.png")
+	fmt.Println()
+	fmt.Println("### Hires Interlace (2 hires bitmaps)")
+	fmt.Println()
+	fmt.Println("Supply both frames as regular hires images (-interlace flag required).")
+	fmt.Println()
+	fmt.Println("    ./png2prg -i frame_0.png frame_1.png")
+	fmt.Println()
+	fmt.Println("    Screen1: $5c00 - $5fe7")
+	fmt.Println("    Bitmap1: $6000 - $7f3f")
+	fmt.Println("    D020:    $7f40")
+	fmt.Println("    D016Offset: $7f42")
+	fmt.Println("    Screen2: $9c00 - $9fe7")
+	fmt.Println("    Bitmap2: $a000 - $bf3f")
+	fmt.Println()
+	fmt.Println("This layout is specific to png2prg, it does not follow the file format of any")
+	fmt.Println("c64 paint program.")
+	fmt.Println()
+	fmt.Println("Or supply a single hires image drawn in blended colors, each color the average")
+	fmt.Println("of 2 c64 colors. The image is split into 2 frames shown by color flicker, each")
+	fmt.Println("char may mix 2 colors per frame. The -d016 offset is 0 for blended images.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d blended_hires.png")
+	fmt.Println()
+	fmt.Println("## IFLI (2 multicolor fli bitmaps)")
+	fmt.Println()
+	fmt.Println("Supply a single multicolor image drawn in blended colors, that does not fit")
+	fmt.Println("multicolor interlace. Images with more than 16 colors are matched to the blends")
+	fmt.Println("of the palettes, use -mode mcibitmap, hiresinterlace or ifli to match images")
+	fmt.Println("with less colors. Each line of a char may mix the background color, the")
+	fmt.Println("colorram color and 2 screen colors per frame.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -m ifli blended_koala.png")
+	fmt.Println()
+	fmt.Println("    Screens1: $4000 - $5fff (8 screens, 1 per line)")
+	fmt.Println("    Bitmap1:  $6000 - $7f3f")
+	fmt.Println("    Screens2: $8000 - $9fff")
+	fmt.Println("    Bitmap2:  $a000 - $bf3f")
+	fmt.Println("    D800:     $c000 - $c3e7")
+	fmt.Println("    D021:     $c3e8")
+	fmt.Println("    D020:     $c3e9")
+	fmt.Println("    D016Offset: $c3ea")
+	fmt.Println()
+	fmt.Println("The fli bug hides the first 3 chars of each line, use the background color")
+	fmt.Println("there. The last 3 lines of the picture repeat the screen colors of line 196.")
+	fmt.Println("The displayer copies the second frame to $c000 and is PAL only.")
+	fmt.Println()
+	fmt.Println("Without -display, -interlace-format gunpaint writes the Gunpaint layout,")
+	fmt.Println("loadable in Gunpaint. All raster lines use the background color.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -m ifli -if gunpaint blended_koala.png")
+	fmt.Println()
+	fmt.Println("    Screens1: $4000 - $5fff (signature at $43e8)")
+	fmt.Println("    Bitmap1:  $6000 - $7f3f")
+	fmt.Println("    D021:     $7f4f - $7fff (1 per raster line)")
+	fmt.Println("    D800:     $8000 - $83e7")
+	fmt.Println("    Screens2: $8400 - $a3ff")
+	fmt.Println("    Bitmap2:  $a400 - $c33f")
+	fmt.Println()
 	fmt.Println("## Singlecolor, PETSCII or ECM Charset (individual d800 colors)")
 	fmt.Println()
 	fmt.Println("By default charsets are packed, they only contain unique characters.")
@@ -169,6 +254,23 @@ func PrintHelp() {
 	fmt.Println("    Sprite 2: $2040-$207f")
 	fmt.Println("    ...")
 	fmt.Println()
+	fmt.Println("## Custom Memory Layout")
+	fmt.Println()
+	fmt.Println("Without -display, the bitmap, charset, screenram and colorram addresses can")
+	fmt.Println("be set to fit the memory layout of your own production.")
+	fmt.Println("Blocks without a custom address follow the previous block and must fit in memory.")
+	fmt.Println("The d02x colors are stored directly after the colorram.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -bitmap-address $6000 -screenram-address $5c00 image.png")
+	fmt.Println("    ./png2prg -m mccharset -charset-address $4800 -screenram-address $4400 image.png")
+	fmt.Println()
+	fmt.Println("Addresses are validated against the VIC bank rules: bitmaps are 8K aligned,")
+	fmt.Println("charsets 2K and screenram 1K. They must not overlap $1000-$1fff or")
+	fmt.Println("$9000-$9fff, where the VIC sees the character ROM in banks 0 and 2.")
+	fmt.Println("The screenram needs to be in the same VIC bank as the bitmap or charset.")
+	fmt.Println("Overlapping blocks are reported as an error.")
+	fmt.Println("Use -symbols to export the chosen addresses.")
+	fmt.Println()
 	fmt.Println("## Bitpair Colors")
 	fmt.Println()
 	fmt.Println("By default, png2prg guesses bitpair colors by itself. In most cases you")
@@ -192,10 +294,67 @@ func PrintHelp() {
 	fmt.Println("When multiple files are added, they are treated as animation frames.")
 	fmt.Println("You can also supply an animated .gif.")
 	fmt.Println()
+	fmt.Println("Frames of animated .gifs are composited according to their disposal method,")
+	fmt.Println("so frames only covering part of the image work as expected.")
+	fmt.Println("The delay of each .gif frame is converted from 1/100s to PAL frames and")
+	fmt.Println("overrides -frame-delay. Use -ntsc to convert the delays to NTSC frames.")
+	fmt.Println()
+	fmt.Println("By default the animation loops. Use -playback pingpong to play it forwards")
+	fmt.Println("and backwards, or -playback once to stop at the last frame. Use -frame-order")
+	fmt.Println("to play the frames in a specific order, frames can be repeated:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -frame-order 0,1,2,1,0,3 frame*.png")
+	fmt.Println()
+	fmt.Println("Only the changes for the transitions actually played are stored. The playback")
+	fmt.Println("mode and frame order are not supported with -no-anim.")
+	fmt.Println()
+	fmt.Println("The graphics mode and -bitpair-colors are guessed from all frames combined,")
+	fmt.Println("so frames may introduce new colors. A frame detected as a charset is converted")
+	fmt.Println("as a bitmap when other frames need one. Each char keeps the bitpairs of the")
+	fmt.Println("previous frame where possible, to keep the changes between frames small.")
+	fmt.Println("Bitmap frames that need other -bitpair-colors than the first frame only store")
+	fmt.Println("the chars that changed, but koala frames must share the background color.")
+	fmt.Println("Multicolor charset frames share all 4 colors, each color keeps its bitpair in")
+	fmt.Println("all frames and -bitpair-colors only fixes the given bitpairs.")
+	fmt.Println()
+	fmt.Println("### Keyframes")
+	fmt.Println()
+	fmt.Println("Each frame only contains the changes against the previous frame, so a player")
+	fmt.Println("cannot jump to any frame. Use -keyframes n to store every n-th frame as a")
+	fmt.Println("full-screen keyframe, starting with the first. A player can start playing at")
+	fmt.Println("any keyframe, regardless of the frame currently shown:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -keyframes 4 -sym frame*.png")
+	fmt.Println()
+	fmt.Println("With keyframes, a frame offset table follows the end of all frames. It holds")
+	fmt.Println("the low bytes of the addresses of all frames, followed by the high bytes.")
+	fmt.Println("The -sym file contains the number of frames, the keyframe interval, the")
+	fmt.Println("frametable_lo and frametable_hi addresses and the address of each frame.")
+	fmt.Println("Frames are numbered in playback order. Keyframes use a lot of memory, a")
+	fmt.Println("full-screen koala keyframe takes about 10 KB.")
+	fmt.Println()
 	fmt.Println("## Sprite Animation")
 	fmt.Println()
 	fmt.Println("Each frame will be concatenated in the output .prg.")
 	fmt.Println()
+	fmt.Println("With -d, the sprite animation displayer animates up to 8 objects, one for")
+	fmt.Println("each sprite on the sheet, laid out as on the sheet. All frames need the same")
+	fmt.Println("number of sprites. Identical sprites are only stored once, up to 128 unique")
+	fmt.Println("sprites fit at $2000. Frame delays, -playback and -frame-order are supported:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -sid music.sid -frame-delay 4 sprites*.png")
+	fmt.Println()
+	fmt.Println("Use -sprite-sheet to animate a single sprite sheet instead, each row is an")
+	fmt.Println("object using the sprites in the row as frames. Empty sprites at the end of a")
+	fmt.Println("row are skipped, so each object can have a different number of frames and")
+	fmt.Println("they animate independently:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -sprite-sheet -frame-delay 4 sheet.png")
+	fmt.Println()
+	fmt.Println("The -sym file contains the objects and the address of the object table")
+	fmt.Println("anim_header. Per object it holds the x and y position, color, number of steps")
+	fmt.Println("and pointers to the sprite pointer and delay of each step.")
+	fmt.Println()
 	fmt.Println("## Bitmap Animation (only koala and hires)")
 	fmt.Println()
 	fmt.Println("Note that png2prg uses a rather simple generic diff approach, where small")
@@ -203,7 +362,13 @@ func PrintHelp() {
 	fmt.Println("is not advised.")
 	fmt.Println("Use the -no-fade flag if you run out of memory.")
 	fmt.Println()
-	fmt.Println("The first image will be exported with all framedata appended.")
+	fmt.Println("Before linking, the size of each frame is checked against the free memory")
+	fmt.Println("for the displayer, fade code and sid. If the frames do not fit, the error")
+	fmt.Println("shows the first frame that overflows. Use -fit to drop the fade and then")
+	fmt.Println("truncate the animation until it fits, a summary of the decisions is shown.")
+	fmt.Println("A sid overlapping the frames is moved, unless -no-sid-reloc is set.")
+	fmt.Println()
+	fmt.Println("The first image of the frame order will be exported with all framedata appended.")
 	fmt.Println("Koala animation frames start at $4711, hires at $4329.")
 	fmt.Println()
 	fmt.Println("The frame files are following this format.")
@@ -224,8 +389,101 @@ func PrintHelp() {
 	fmt.Println()
 	fmt.Println("    ...          // next chunks")
 	fmt.Println("    .byte 0      // end of frame")
+	fmt.Println("    .byte 6      // frame delay, 0 uses the displayer's -frame-delay")
 	fmt.Println("    ...          // next frame(s)")
 	fmt.Println("    .byte $ff    // end of all frames")
+	fmt.Println("    .word frame  // address of the frame to continue with")
+	fmt.Println()
+	fmt.Println("Frames where only a few pixel rows or colors of each char change are stored")
+	fmt.Println("in the masked format instead, whichever is smaller. A masked frame starts")
+	fmt.Println("with $fe instead of the first chunk. For each changed char:")
+	fmt.Println()
+	fmt.Println("    .byte $a3    // bit 7: screenram changed")
+	fmt.Println("                 // bit 6: colorram changed (koala only)")
+	fmt.Println("                 // bit 5: pixel rows changed")
+	fmt.Println("                 // bit 0-4: number of unchanged chars skipped")
+	fmt.Println("                 // $1f skips 32 chars without changes")
+	fmt.Println("                 // $00 marks end of frame")
+	fmt.Println("    .byte $64    // screenram colors, if changed")
+	fmt.Println("    .byte $01    // colorram color, if changed")
+	fmt.Println("    .byte %10000001 // changed pixel rows, bit 7 is the top row, if changed")
+	fmt.Println("    .byte 0,128  // the changed pixel rows")
+	fmt.Println()
+	fmt.Println("    ...          // next char(s)")
+	fmt.Println("    .byte 0      // end of frame")
+	fmt.Println("    .byte 6      // frame delay")
+	fmt.Println()
+	fmt.Println("Skipping starts at the first char of the screen.")
+	fmt.Println()
+	fmt.Println("### Streaming")
+	fmt.Println()
+	fmt.Println("The frames must fit in memory between the picture and the fade code, which")
+	fmt.Println("limits the length of an animation. Use -stream to split the frames into")
+	fmt.Println("separately crunched segments of up to 12 KB, loaded from disk while playing:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -stream -o anim.prg anim.gif")
+	fmt.Println()
+	fmt.Println("Besides anim.prg, this writes the segment files anim00.prg, anim01.prg, ...")
+	fmt.Println("and the index anim.json, listing the filename on disk, number of frames,")
+	fmt.Println("frame offsets and (crunched) length of each segment. Copy the segments to")
+	fmt.Println("disk as ANIM00, ANIM01, ..., the name on disk is the uppercase name of the")
+	fmt.Println("output file, up to 14 chars, followed by the segment number in hex.")
+	fmt.Println()
+	fmt.Println("The displayer plays from two buffers at $4800 and $7800, the first segment")
+	fmt.Println("is included in anim.prg. While one buffer plays, the main loop loads the")
+	fmt.Println("next segment to $a800 and decrunches it into the other buffer. Each segment")
+	fmt.Println("ends with $ff, then the displayer continues at the start of the other buffer.")
+	fmt.Println("When looping, segment 00 is loaded again after the last one. With -playback")
+	fmt.Println("once the last frame is held. Streaming does not support fading.")
+	fmt.Println()
+	fmt.Println("By default segments are loaded with the kernal from the last used drive.")
+	fmt.Println("To use your own loader, patch the jmp at the loader address in the -sym")
+	fmt.Println("file. It is called with $01 set to $35, A holding the length of the")
+	fmt.Println("filename and X/Y pointing to the filename. It must load the file to its")
+	fmt.Println("load address and return with the carry set on error.")
+	fmt.Println()
+	fmt.Println("### Animation Player")
+	fmt.Println()
+	fmt.Println("To play a koala or hires animation from your own code, use -player instead")
+	fmt.Println("of -display. The output contains the first frame, the animation frames and")
+	fmt.Println("a small player routine, without displayer, fade or music:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -player -player-address $c000 -player-zp $f0 -o anim.prg anim.gif")
+	fmt.Println()
+	fmt.Println("The player is relocated to any page with -player-address, by default the")
+	fmt.Println("first page after the frames, and uses the 8 zeropage addresses starting at")
+	fmt.Println("-player-zp, default $08. The custom address flags like -bitmap-address and")
+	fmt.Println("-screenram-address are supported. Show the first frame yourself, then:")
+	fmt.Println()
+	fmt.Println("    jsr player+0 // init, once")
+	fmt.Println("    jsr player+3 // step, once every frame, honors the frame delays")
+	fmt.Println("    jsr player+6 // play, plays the next frame immediately")
+	fmt.Println()
+	fmt.Println("All entrypoints change A, X and Y. Use -sym to find the player_init,")
+	fmt.Println("player_step and player_play symbols.")
+	fmt.Println()
+	fmt.Println("### Interlace Animation")
+	fmt.Println()
+	fmt.Println("Supply multiple multicolor interlace images, or pairs of koala frames with")
+	fmt.Println("the -interlace flag, to animate interlaced pictures:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -i -frame-delay 4 a0.png a1.png b0.png b1.png c0.png c1.png")
+	fmt.Println()
+	fmt.Println("Each animation frame contains the changes of the first half, followed by")
+	fmt.Println("the changes of the second half, both in the regular frame format. The")
+	fmt.Println("displayer switches between both halves every frame and applies the changes")
+	fmt.Println("of each half while the other half is shown. The colorram is shared and the")
+	fmt.Println("background color must be the same in all frames. There is no fade.")
+	fmt.Println()
+	fmt.Println("    Bitmap1: $2000 - $3f3f")
+	fmt.Println("    Screen1: $4000 - $43e7 (copied to $0400)")
+	fmt.Println("    D800:    $4400 - $47e7")
+	fmt.Println("    Screen2: $5c00 - $5fe7")
+	fmt.Println("    Bitmap2: $6000 - $7f3f")
+	fmt.Println("    D021:    $7f40         (low-nibble)")
+	fmt.Println("    D020:    $7f40         (high-nibble)")
+	fmt.Println("    D016Offset: $7f42")
+	fmt.Println("    Frames:  $8000 - $cfff")
 	fmt.Println()
 	fmt.Println("## PETSCII and Charset Animation")
 	fmt.Println()
@@ -249,8 +507,10 @@ func PrintHelp() {
 	fmt.Println()
 	fmt.Println("    ...          // next chunks")
 	fmt.Println("    .byte 0      // end of frame")
+	fmt.Println("    .byte 6      // frame delay, 0 uses the displayer's -frame-delay")
 	fmt.Println("    ...          // next frame(s)")
 	fmt.Println("    .byte $ff    // end of all frames")
+	fmt.Println("    .word frame  // address of the frame to continue with")
 	fmt.Println()
 	fmt.Println("## Displayer")
 	fmt.Println()
@@ -258,19 +518,99 @@ func PrintHelp() {
 	fmt.Println("By default it will also crunch the resulting file with Antonio Savona's")
 	fmt.Println("[TSCrunch](https://github.com/tonysavon/TSCrunch/) with a couple of changes in my own [fork](https://github.com/staD020/TSCrunch/).")
 	fmt.Println()
-	fmt.Println("All displayers except for sprites support adding a .sid.")
+	fmt.Println("Use -cruncher lzsa1 to pack with the built in [LZSA1](https://github.com/emmanuel-marty/lzsa/)")
+	fmt.Println("cruncher instead, with an optimal parser. The crunched data is a raw LZSA1 block,")
+	fmt.Println("byte compatible with lzsa -r -f1. Its decruncher runs from zeropage and")
+	fmt.Println("decrunches in place, the crunched data is moved up just far enough in memory.")
+	fmt.Println("See lzsa_boot.asm for the format.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -cruncher lzsa1 image.png")
+	fmt.Println()
+	fmt.Println("Streamed animations and slideshows only support TSCrunch, as their displayers")
+	fmt.Println("decrunch the segments with the TSCrunch decruncher.")
+	fmt.Println()
+	fmt.Println("All displayers except for static sprites support adding a .sid.")
 	fmt.Println("Multispeed sids are supported as long as the .sid initializes the CIA timers")
 	fmt.Println("correctly.")
 	fmt.Println()
 	fmt.Println("You can use sids located from $0e00-$1fff or $e000+ in the displayers.")
+	fmt.Println("The koala animation displayer uses memory up to $0eff.")
 	fmt.Println("More areas may be free depending on graphics type.")
 	fmt.Println("A memory usage map is shown on error and in -vv (very verbose) mode.")
 	fmt.Println()
-	fmt.Println("If needed, you can relocate most sids using lft's [sidreloc](http://www.linusakesson.net/software/sidreloc/index.php).")
+	fmt.Println("The koala and hires displayers have variants with the picture in VIC bank")
+	fmt.Println("1, 2 or 3. When the sid overlaps the bank 0 displayer, the first bank that")
+	fmt.Println("fits is picked automatically. Use -bank 0-3 to force a VIC bank, a sid that")
+	fmt.Println("overlaps the forced bank is relocated.")
+	fmt.Println()
+	fmt.Println("    bank 0: sid at $0e00-$1fff or $8e50+ (koala), $0e00-$1fff or $6b29+ (hires)")
+	fmt.Println("    bank 1: sid at $0e00-$53ff or $c651+ (koala), $0e00-$57ff or $a329+ (hires)")
+	fmt.Println("    bank 2: sid at $0e00-$3dae, $8fe8-$9fff or $c000+ (koala)")
+	fmt.Println("            sid at $0e00-$63ff, $8fe8-$9fff or $c000+ (hires)")
+	fmt.Println("    bank 3: sid at $0e00-$7bff (koala), $0e00-$9fff (hires)")
+	fmt.Println()
+	fmt.Println("Use -subtune to select another song than the start song from the sid header:")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -sid music.sid -subtune 3 image.png")
+	fmt.Println()
+	fmt.Println("PSID v3 and v4 2SID and 3SID files are supported. The player writes to the")
+	fmt.Println("extra sids itself, the displayers also mute them on exit. Make sure your")
+	fmt.Println("emulator or hardware has the extra sids configured at the addresses from the")
+	fmt.Println("sid header, they are shown in the terminal output.")
 	fmt.Println()
 	fmt.Println("Zeropages $08-$0f are used in the animation displayers, while none are used")
 	fmt.Println("in hires/koala displayers, increasing sid compatibility.")
 	fmt.Println()
+	fmt.Println("When the sid overlaps the displayer, picture or zeropages in use, png2prg")
+	fmt.Println("relocates it to the first free memory area, keeping the low byte of the load")
+	fmt.Println("address. The player is emulated for 3 minutes to find all address high bytes")
+	fmt.Println("and zeropage addresses, also through self-modifying code and pointers.")
+	fmt.Println("Code that did not run is found by disassembling from the executed branches.")
+	fmt.Println("The relocated sid is emulated again and must write the exact same values to")
+	fmt.Println("the sid registers, otherwise png2prg stops with an error.")
+	fmt.Println("Sids without a play address are not relocated. Use -no-sid-reloc to disable")
+	fmt.Println("relocation, or relocate the sid yourself using lft's [sidreloc](http://www.linusakesson.net/software/sidreloc/index.php).")
+	fmt.Println()
+	fmt.Println("### Memory Map")
+	fmt.Println()
+	fmt.Println("Use -memory-map (-mm) to write the memory map of the linked .prg to .map.json,")
+	fmt.Println("with the named segments of all 64K memory in order of address, like the")
+	fmt.Println("displayer, bitmap, screen, colorram, animation frames, sid and the blocked")
+	fmt.Println("fade area. Each segment is used, blocked (kept free for the displayer at")
+	fmt.Println("runtime) or free. For crunched displayers it shows the memory after")
+	fmt.Println("decrunching. Use -memory-chart png or svg to inspect the layout visually,")
+	fmt.Println("the png shows a pixel for each byte, a row for each page.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -d -sid music.sid -mm -memory-chart svg image.png")
+	fmt.Println()
+	fmt.Println("      {")
+	fmt.Println("        \"name\": \"sid\",")
+	fmt.Println("        \"kind\": \"used\",")
+	fmt.Println("        \"start\": 4096,")
+	fmt.Println("        \"end\": 8172,")
+	fmt.Println("        \"length\": 4076")
+	fmt.Println("      },")
+	fmt.Println()
+	fmt.Println("Library users get the same map from Converter.MemoryMap after WriteTo.")
+	fmt.Println()
+	fmt.Println("## Slideshow")
+	fmt.Println()
+	fmt.Println("Multiple images are treated as animation frames by default. Use -slideshow")
+	fmt.Println("(-ss) to convert them as independent pictures instead, each in its own")
+	fmt.Println("graphics type. Koala, hires, charset, petscii, ecm and interlace pictures can")
+	fmt.Println("be mixed freely. Each picture is crunched separately and decrunched by the")
+	fmt.Println("displayer in turn, while a single sid keeps playing.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -ss -sid music.sid -slide-delay 8 pic1.png pic2.png pic3.png")
+	fmt.Println()
+	fmt.Println("Each picture is shown for -slide-delay seconds (default 5), or until space is")
+	fmt.Println("pressed. Use -slide-delay 0 to only switch on space. The pictures fade out")
+	fmt.Println("between slides, use -no-fade to switch immediately.")
+	fmt.Println()
+	fmt.Println("The pictures are decrunched to VIC bank 1, the second frame of interlace")
+	fmt.Println("pictures to bank 3. The crunched pictures share the remaining memory with the")
+	fmt.Println("sid, about 40 KB without interlace pictures.")
+	fmt.Println()
 	fmt.Println("## Brute Force Mode and Pack Optimization")
 	fmt.Println()
 	fmt.Println("By default png2prg 1.8 does a pretty good job at optimizing the resulting prg")
@@ -286,6 +626,17 @@ func PrintHelp() {
 	fmt.Println()
 	fmt.Println("    ./png2prg -bf image.png")
 	fmt.Println()
+	fmt.Println("Use -cruncher lzsa1 to score the permutations with the LZSA1 cruncher instead,")
+	fmt.Println("the same cruncher is used to pack the displayer.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -bf -d -cruncher lzsa1 image.png")
+	fmt.Println()
+	fmt.Println("All graphics modes except petscii are supported: the d021-d024 colors are")
+	fmt.Println("searched for ecm, the background, d025 and d026 colors for sprites. Both")
+	fmt.Println("bitmaps of an interlace picture are converted and scored together.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -bf -i image_0.png image_1.png")
+	fmt.Println()
 	fmt.Println("The -brute-force mode can be used in combination with additional flags.")
 	fmt.Println()
 	fmt.Println("### -no-bitpair-counters (-nbc)")
@@ -304,6 +655,60 @@ func PrintHelp() {
 	fmt.Println("results. This is also the reason for not including these options in the")
 	fmt.Println("brute force permutations automatically.")
 	fmt.Println()
+	fmt.Println("### -brute-force-heuristics (-bfh)")
+	fmt.Println()
+	fmt.Println("Add heuristics to the brute force permutations, each combination is tried")
+	fmt.Println("with every -bitpair-colors permutation: npcc, nbc, npe (no-pack-empty for")
+	fmt.Println("mc, mixed and ecm charsets), fpe (force-pack-empty for sc charsets) or all.")
+	fmt.Println("Each heuristic doubles the number of attempts, spread over the workers.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -bf -bfh npcc,nbc image.png")
+	fmt.Println()
+	fmt.Println("The winner is converted with its full set of flags, which are also shown to")
+	fmt.Println("reproduce the result without brute force:")
+	fmt.Println()
+	fmt.Println("    brute-force winner \"image.prg\" -bpc 0,5,11,6 -nbc (8036 bytes)")
+	fmt.Println()
+	fmt.Println("### -brute-force-strategy (-bfs)")
+	fmt.Println()
+	fmt.Println("By default all permutations of the 8 most used colors are tried. The climb")
+	fmt.Println("strategy explores all colors of the image instead: starting from the guessed")
+	fmt.Println("bitpair colors, it tries all neighbors (one color replaced, two colors")
+	fmt.Println("swapped or other heuristics) and moves to the shortest one, restarting at a")
+	fmt.Println("random combination when no neighbor is shorter.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -bf -bfs climb image.png")
+	fmt.Println()
+	fmt.Println("### -brute-force-budget (-bfb)")
+	fmt.Println()
+	fmt.Println("Limit the number of attempts, by default the permute strategy tries all")
+	fmt.Println("permutations and the climb strategy makes 500 attempts.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -bf -bfs climb -bfb 2000 -bfh all image.png")
+	fmt.Println()
+	fmt.Println("Search statistics are shown after brute forcing:")
+	fmt.Println()
+	fmt.Println("    brute-force climb: 301 attempts (0 failed), 138 skipped, 63 cached, 8 improvements, 4 restarts in 2.475s (121.6 attempts/s)")
+	fmt.Println()
+	fmt.Println("### -brute-force-cache (-bfc)")
+	fmt.Println()
+	fmt.Println("Store the brute-force winner in a cache directory, keyed by a hash of the")
+	fmt.Println("pixels, graphics mode, png2prg version and all flags that change the conversion.")
+	fmt.Println("Unchanged images reuse the cached winner instantly, which speeds up clean builds.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -bf -bfc .bfcache image.png")
+	fmt.Println()
+	fmt.Println("### -brute-force-objective (-bfo)")
+	fmt.Println()
+	fmt.Println("Select what to minimize, instead of the crunched size: raw (uncrunched size),")
+	fmt.Println("chars (unique chars of charsets) or delta (total size of the animation frames).")
+	fmt.Println("Koala and hires animations are always scored over all frames. Objectives can")
+	fmt.Println("be combined with a weight, the weighted sum is minimized.")
+	fmt.Println()
+	fmt.Println("    ./png2prg -bf -bfo chars charset.png")
+	fmt.Println("    ./png2prg -bf -bfo crunched,chars:8 charset.png")
+	fmt.Println("    ./png2prg -bf -bfo delta frame*.png")
+	fmt.Println()
 	fmt.Println("## Benchmark")
 	fmt.Println()
 	fmt.Println("The [koala otpimizing thread](https://csdb.dk/forums/?roomid=13&topicid=38311&showallposts=1) on csdb has gained some interest in the scene.")
@@ -424,6 +829,56 @@ func PrintHelp() {
 	fmt.Println("   (thanks Brush).")
 	fmt.Println(" - Typofix: fix simple install docs (thanks IcePic).")
 	fmt.Println(" - Added another weird palette (thanks Fungus).")
+	fmt.Println(" - Add -bitmap-address, -charset-address, -screenram-address and")
+	fmt.Println("   -colorram-address flags for a custom memory layout.")
+	fmt.Println(" - Add koala and hires displayers with the picture in VIC bank 1-3, picked")
+	fmt.Println("   automatically when the sid overlaps, or forced with the -bank flag.")
+	fmt.Println(" - Add automatic sid relocation and zeropage remapping when the sid overlaps")
+	fmt.Println("   the displayer, disable with the -no-sid-reloc flag.")
+	fmt.Println(" - Add -subtune flag to select the sid song.")
+	fmt.Println(" - Add support for PSID v3/v4 2SID and 3SID files.")
+	fmt.Println(" - Add -slideshow mode for multiple pictures of mixed graphics types.")
+	fmt.Println(" - Add per-frame delays and disposal methods of animated .gifs, each animation")
+	fmt.Println("   frame now ends with a frame delay byte. Add -ntsc flag for gif delays.")
+	fmt.Println(" - Add -playback loop, pingpong or once and -frame-order flags for animations,")
+	fmt.Println("   the end of all frames marker is now followed by the address to continue at.")
+	fmt.Println(" - Bugfix: -frame-delay counts full frames in sccharset and petscii animations.")
+	fmt.Println(" - Add -keyframes flag to insert full-screen keyframes in animations, with a")
+	fmt.Println("   frame offset table and frame symbols to allow seeking.")
+	fmt.Println(" - Bugfix: animation chunks of 255 chars were mistaken for the end of all")
+	fmt.Println("   frames, chunks now hold up to 253 chars.")
+	fmt.Println(" - Add masked frames to koala and hires animations, storing only the changed")
+	fmt.Println("   pixel rows and colors of each char, used when smaller than the chunks.")
+	fmt.Println(" - Add -stream flag to split koala and hires animations into segment files,")
+	fmt.Println("   loaded from disk while playing by a double buffering displayer.")
+	fmt.Println(" - Plan the memory usage of koala and hires animations before linking, add")
+	fmt.Println("   -fit flag to drop the fade or truncate animations that do not fit.")
+	fmt.Println(" - Add sprite animation displayer, with -sprite-sheet to animate each row of")
+	fmt.Println("   a sprite sheet as an independent object.")
+	fmt.Println(" - Add interlace animations of multicolor interlace images or pairs of koala")
+	fmt.Println("   frames, with a displayer applying the changes to both halves.")
+	fmt.Println(" - Guess the graphics mode and bitpair colors of animations from all frames,")
+	fmt.Println("   instead of failing when frames introduce new colors.")
+	fmt.Println(" - Add -player flag to write koala and hires animations with a relocatable")
+	fmt.Println("   player routine, to play them from your own code.")
+	fmt.Println(" - Add -cruncher flag to select the cruncher for the displayers and")
+	fmt.Println("   -brute-force scoring, with a built in LZSA1 cruncher besides TSCrunch.")
+	fmt.Println(" - Add -brute-force-heuristics flag to permute -npcc, -nbc, -npe and -fpe in")
+	fmt.Println("   -brute-force mode, the winner is converted with its full set of flags.")
+	fmt.Println(" - Add -brute-force-strategy climb to hill-climb over all colors of the image")
+	fmt.Println("   with a -brute-force-budget of attempts, and show search statistics.")
+	fmt.Println(" - Add -brute-force-cache flag to reuse the winners of unchanged images.")
+	fmt.Println(" - Support -brute-force for ecm, singlecolor charsets, sprites and interlace.")
+	fmt.Println(" - Add -brute-force-objective flag to minimize the raw size, unique chars or")
+	fmt.Println("   animation delta size, score koala and hires animations over all frames.")
+	fmt.Println(" - Add -interlace-format flag to choose drazlace, truepaint or the new hires")
+	fmt.Println("   interlace format of 2 hires bitmaps.")
+	fmt.Println(" - Add hiresinterlace and ifli modes with displayers, for single images drawn")
+	fmt.Println("   in blended colors.")
+	fmt.Println(" - Convert multicolor images drawn in blended colors to multicolor interlace,")
+	fmt.Println("   add -blend-table flag to write the 136 blended colors of a palette.")
+	fmt.Println(" - Add -memory-map flag to export the memory map with named segments as json,")
+	fmt.Println("   and -memory-chart to render it as png or svg.")
 	fmt.Println()
 	fmt.Println("## Changes for version 1.8")
 	fmt.Println()
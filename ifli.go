@@ -0,0 +1,308 @@
+package png2prg
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"slices"
+)
+
+// ifli examples:
+//
+// https://codebase64.org/doku.php?id=base:fli_graphics_mode
+// https://csdb.dk/release/?id=2119 funpaint 2
+// https://codebase64.org/doku.php?id=base:c64_grafix_files_specs_list_v0.03 gunpaint
+
+// IFLI memory layout, both with and without displayer.
+// The displayer copies the second frame from $8000 to $c000 at runtime.
+const (
+	ifliScreens0  = 0x4000 // 8 screens, one for each line of a char row
+	ifliBitmap0   = 0x6000
+	ifliScreens1  = 0x8000
+	ifliBitmap1   = 0xa000
+	ifliColorRAM  = 0xc000 // shared by both frames
+	ifliColors    = 0xc3e8 // d021, d020, d016offset
+	ifliFrameSize = 0x4000
+)
+
+// Gunpaint memory layout, written with InterlaceFormatGunpaint without displayer.
+// The screens of the second frame follow its colorram, the file ends with 1 padding byte.
+const (
+	gunpaintScreens0   = 0x4000
+	gunpaintSignature  = 0x43e8 // in the unused bytes of the first screen
+	gunpaintBitmap0    = 0x6000
+	gunpaintD021Colors = 0x7f4f // d021 color per raster line, 177 bytes
+	gunpaintColorRAM   = 0x8000
+	gunpaintScreens1   = 0x8400
+	gunpaintBitmap1    = 0xa400
+	gunpaintEnd        = 0xc340
+)
+
+// gunpaintID is the signature Gunpaint checks when loading a file.
+const gunpaintID = "GUNPAINT (JZ)   "
+
+// ifliBugChars is the number of chars on the left of each line that show the fli bug.
+const ifliBugChars = 3
+
+// IFLI contains 2 fli pictures with a shared colorram, shown by color flicker.
+type IFLI struct {
+	SourceFilename  string
+	Bitmap          [2][8000]byte
+	Screens         [2][8][1000]byte
+	D800Color       [1000]byte
+	BackgroundColor byte
+	BorderColor     byte
+	opt             Options
+}
+
+func (img IFLI) Symbols() []c64Symbol {
+	if img.gunpaint() {
+		return []c64Symbol{
+			{"screens1", gunpaintScreens0},
+			{"bitmap1", gunpaintBitmap0},
+			{"d021colors", gunpaintD021Colors},
+			{"colorram", gunpaintColorRAM},
+			{"screens2", gunpaintScreens1},
+			{"bitmap2", gunpaintBitmap1},
+			{"d020color", int(img.BorderColor)},
+			{"d021color", int(img.BackgroundColor)},
+		}
+	}
+	return []c64Symbol{
+		{"screens1", ifliScreens0},
+		{"bitmap1", ifliBitmap0},
+		{"screens2", ifliScreens1},
+		{"bitmap2", ifliBitmap1},
+		{"colorram", ifliColorRAM},
+		{"d021coloraddr", ifliColors},
+		{"d020coloraddr", ifliColors + 1},
+		{"d016offsetaddr", ifliColors + 2},
+		{"d020color", int(img.BorderColor)},
+		{"d021color", int(img.BackgroundColor)},
+	}
+}
+
+func (f IFLI) WriteTo(w io.Writer) (n int64, err error) {
+	return writeLinked(w, f)
+}
+
+// gunpaint returns true if f is written in the Gunpaint format.
+func (f IFLI) gunpaint() bool {
+	return !f.opt.Display && f.opt.InterlaceFormat == InterlaceFormatGunpaint
+}
+
+func (f IFLI) link() (link *Linker, err error) {
+	link = NewLinker(0, f.opt.VeryVerbose)
+	if f.gunpaint() {
+		return f.linkGunpaint(link)
+	}
+	if f.opt.Display {
+		// the second frame is copied to $c000-$ff3f
+		link.Block(ifliColors+3, ifliColorRAM+ifliFrameSize-0xc0)
+		if _, err = link.WriteNamedPrg(interlaceFLIBitmap.newHeader(), "displayer"); err != nil {
+			return nil, fmt.Errorf("link.WritePrg failed: %w", err)
+		}
+	}
+	m := LinkMap{
+		ifliColorRAM: f.D800Color[:],
+		ifliColors:   []byte{f.BackgroundColor, f.BorderColor, 0},
+	}
+	names := map[Word]string{ifliColorRAM: "colorram", ifliColors: "colors"}
+	for frame, screens := range [2]Word{ifliScreens0, ifliScreens1} {
+		for i := range f.Screens[frame] {
+			m[screens+Word(i)*0x400] = f.Screens[frame][i][:]
+			names[screens+Word(i)*0x400] = fmt.Sprintf("screens%d", frame+1)
+		}
+		m[screens+0x2000] = f.Bitmap[frame][:]
+		names[screens+0x2000] = fmt.Sprintf("bitmap%d", frame+1)
+	}
+	if _, err = link.WriteNamedMap(m, names); err != nil {
+		return nil, fmt.Errorf("link.WriteMap failed: %w", err)
+	}
+	if f.opt.Display {
+		if err = injectSID(link, f.opt); err != nil {
+			return nil, fmt.Errorf("injectSID failed: %w", err)
+		}
+	}
+	return link, nil
+}
+
+// linkGunpaint links f in the Gunpaint format, all raster lines use the background color.
+func (f IFLI) linkGunpaint(link *Linker) (*Linker, error) {
+	d021 := make([]byte, gunpaintColorRAM-gunpaintD021Colors)
+	for i := range d021 {
+		d021[i] = f.BackgroundColor
+	}
+	m := LinkMap{
+		gunpaintSignature:  []byte(gunpaintID),
+		gunpaintBitmap0:    f.Bitmap[0][:],
+		gunpaintD021Colors: d021,
+		gunpaintColorRAM:   f.D800Color[:],
+		gunpaintBitmap1:    f.Bitmap[1][:],
+		gunpaintEnd:        {0},
+	}
+	names := map[Word]string{
+		gunpaintSignature:  "signature",
+		gunpaintBitmap0:    "bitmap1",
+		gunpaintD021Colors: "d021colors",
+		gunpaintColorRAM:   "colorram",
+		gunpaintBitmap1:    "bitmap2",
+		gunpaintEnd:        "padding",
+	}
+	for frame, screens := range [2]Word{gunpaintScreens0, gunpaintScreens1} {
+		for i := range f.Screens[frame] {
+			m[screens+Word(i)*0x400] = f.Screens[frame][i][:]
+			names[screens+Word(i)*0x400] = fmt.Sprintf("screens%d", frame+1)
+		}
+	}
+	if _, err := link.WriteNamedMap(m, names); err != nil {
+		return nil, fmt.Errorf("link.WriteMap failed: %w", err)
+	}
+	return link, nil
+}
+
+// IFLI converts the img drawn in blended colors to IFLI and returns it.
+// The background color is forced with the first bitpair color, otherwise all candidates are tried, most used first.
+func (img *sourceImage) IFLI() (IFLI, error) {
+	f := IFLI{
+		SourceFilename: img.sourceFilename,
+		BorderColor:    byte(img.border.C64Color),
+		opt:            img.opt,
+	}
+	if img.blend == nil {
+		return f, fmt.Errorf("ifli requires an image drawn in blended colors")
+	}
+	if img.hiresPixels {
+		return f, fmt.Errorf("ifli requires multicolor pixels")
+	}
+	if img.opt.CustomLayout() {
+		return f, fmt.Errorf("custom addresses are not supported for ifli")
+	}
+	if format := img.opt.InterlaceFormat; format != "" && format != InterlaceFormatGunpaint {
+		return f, fmt.Errorf("interlace format %s cannot represent ifli, use %s", format, InterlaceFormatGunpaint)
+	}
+	if img.opt.InterlaceFormat != "" && img.opt.Display {
+		return f, fmt.Errorf("-interlace-format %s only applies without -display", img.opt.InterlaceFormat)
+	}
+
+	var err error
+	for _, bg := range img.blendBgCandidates() {
+		if err = img.ifliFrames(&f, bg); err == nil {
+			if !img.opt.Quiet {
+				fmt.Printf("ifli background color: %d\n", bg)
+			}
+			img.ifliBugWarning(bg)
+			return f, nil
+		}
+		if img.opt.Verbose {
+			log.Printf("ifli background color %d failed: %v", bg, err)
+		}
+	}
+	return f, err
+}
+
+// ifliLines returns the groups of lines of a char row that share a screen.
+// Raster lines $f8-$fa cannot be badlines, so the last 4 lines of the last row show screen 4.
+func ifliLines(row int) [][]int {
+	if row == FullScreenHeight/8-1 {
+		return [][]int{{0}, {1}, {2}, {3}, {4, 5, 6, 7}}
+	}
+	return [][]int{{0}, {1}, {2}, {3}, {4}, {5}, {6}, {7}}
+}
+
+// ifliFrames fills the bitmaps, screens and colorram of f, with bg as background color.
+func (img *sourceImage) ifliFrames(f *IFLI, bg C64Color) error {
+	f.BackgroundColor = byte(bg)
+	for char := 0; char < FullScreenChars; char++ {
+		x, y := xyFromChar(char)
+		done := false
+		for _, d800 := range img.blendD800Candidates(char, bg) {
+			if img.ifliChar(f, char, bg, d800) {
+				done = true
+				break
+			}
+		}
+		if !done {
+			return fmt.Errorf("char %d at x %d y %d does not fit 2 screen colors per line per frame", char, x, y)
+		}
+	}
+	return nil
+}
+
+// ifliChar converts the char with bg and d800 as colors for all lines and both frames.
+// Returns false if the char does not fit.
+func (img *sourceImage) ifliChar(f *IFLI, char int, bg, d800 C64Color) bool {
+	x, y := xyFromChar(char)
+	free := []C64Color{bg, d800}
+	for _, lines := range ifliLines(y / 8) {
+		blends := []blend{}
+		for _, line := range lines {
+			for dx := 0; dx < 8; dx += 2 {
+				if bl := img.blendAt(x+dx, y+line); !In(blends, bl) {
+					blends = append(blends, bl)
+				}
+			}
+		}
+		flips, ok := orientBlends(blends, free, 2)
+		if !ok {
+			return false
+		}
+		var screens [2][]C64Color
+		for _, line := range lines {
+			var bits [2]byte
+			for dx := 0; dx < 8; dx += 2 {
+				bl := img.blendAt(x+dx, y+line)
+				c0, c1 := bl.oriented(flips[bl])
+				for frame, c := range [2]C64Color{c0, c1} {
+					var bitpair byte
+					switch {
+					case c == bg:
+						bitpair = 0
+					case c == d800:
+						bitpair = 3
+					default:
+						i := slices.Index(screens[frame], c)
+						if i < 0 {
+							i = len(screens[frame])
+							screens[frame] = append(screens[frame], c)
+						}
+						bitpair = byte(i + 1)
+					}
+					bits[frame] = bits[frame]<<2 | bitpair
+				}
+			}
+			for frame := range bits {
+				f.Bitmap[frame][char*8+line] = bits[frame]
+			}
+		}
+		for frame := range screens {
+			var screen byte
+			if len(screens[frame]) > 0 {
+				screen = byte(screens[frame][0]) << 4
+			}
+			if len(screens[frame]) > 1 {
+				screen |= byte(screens[frame][1])
+			}
+			for _, line := range lines {
+				f.Screens[frame][line][char] = screen
+			}
+		}
+	}
+	f.D800Color[char] = byte(d800)
+	return true
+}
+
+// ifliBugWarning prints a warning if the img uses other colors than bg in the chars hidden by the fli bug.
+func (img *sourceImage) ifliBugWarning(bg C64Color) {
+	if img.opt.Quiet {
+		return
+	}
+	for y := 0; y < FullScreenHeight; y++ {
+		for x := 0; x < ifliBugChars*8; x += 2 {
+			if bl := img.blendAt(x, y); bl.a != bg || bl.b != bg {
+				fmt.Printf("warning: the fli bug hides the first %d chars of each line, use background color %d there\n", ifliBugChars, bg)
+				return
+			}
+		}
+	}
+}
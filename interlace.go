@@ -17,6 +17,23 @@ import (
 // http://unusedino.de/ec64/technical/aay/c64/gfxdrl0.htm
 // https://codebase64.org/doku.php?id=base:c64_grafix_files_specs_list_v0.03
 
+// Interlace output formats for Options.InterlaceFormat, without displayer.
+const (
+	InterlaceFormatDrazlace  = "drazlace"  // multicolor, shared screenram and colorram
+	InterlaceFormatTruePaint = "truepaint" // multicolor, shared colorram, true paint .mci format
+	InterlaceFormatHires     = "hires"     // 2 hires bitmaps with their own screenram
+	InterlaceFormatGunpaint  = "gunpaint"  // ifli only, gunpaint format
+)
+
+// validateInterlaceFormat returns an error if format is unknown, an empty format selects the format automatically.
+func validateInterlaceFormat(format string) error {
+	switch format {
+	case "", InterlaceFormatDrazlace, InterlaceFormatTruePaint, InterlaceFormatHires, InterlaceFormatGunpaint:
+		return nil
+	}
+	return fmt.Errorf("unknown interlace format %q, use %s, %s, %s or %s", format, InterlaceFormatDrazlace, InterlaceFormatTruePaint, InterlaceFormatHires, InterlaceFormatGunpaint)
+}
+
 // SplitInterlace splits the img by even and odd pixels into 2 multicolor images.
 func (img *sourceImage) SplitInterlace() (*image.RGBA, *image.RGBA) {
 	new0 := image.NewRGBA(image.Rect(0, 0, FullScreenWidth, FullScreenHeight))
@@ -39,64 +56,56 @@ func (c *Converter) WriteInterlaceTo(w io.Writer) (n int64, err error) {
 	if len(c.images) != 2 {
 		return n, fmt.Errorf("interlaces requires exactly 2 images at this stage, not %d", len(c.images))
 	}
-	img0 := &c.images[0]
-	img1 := &c.images[1]
-	if img0.p.NumColors() < MaxColors {
-		for _, v := range img1.p.Colors() {
-			img0.p.Add(v)
-		}
+	if c.opt.CustomLayout() {
+		return n, fmt.Errorf("custom addresses are not supported for interlace")
 	}
-	if img1.p.NumColors() < MaxColors {
-		for _, v := range img0.p.Colors() {
-			img1.p.Add(v)
-		}
+	if c.opt.Display && c.opt.DisplayerBank != 0 {
+		return n, fmt.Errorf("vic bank %d is not supported for interlace", c.opt.DisplayerBank)
 	}
-
-	if c.opt.BruteForce {
-		if err = c.BruteForceBitpairColors(multiColorBitmap, 4); err != nil {
-			return 0, fmt.Errorf("BruteForceBitpairColors %q failed: %w", img0.sourceFilename, err)
-		}
-		if err = img0.setPreferredBitpairColors(c.opt.BitpairColorsString); err != nil {
-			return 0, fmt.Errorf("img.setPreferredBitpairColors %q failed: %w", c.opt.BitpairColorsString, err)
-		}
-		if err = img1.setPreferredBitpairColors(c.opt.BitpairColorsString); err != nil {
-			return 0, fmt.Errorf("img.setPreferredBitpairColors %q failed: %w", c.opt.BitpairColorsString, err)
-		}
-	}
-
-	k0, k1, sharedcolors, err := img1.InterlaceKoala(*img0)
-	if err != nil {
-		return n, fmt.Errorf("img1.InterlaceKoala failed: %w", err)
+	format := c.opt.InterlaceFormat
+	if format != "" && c.opt.Display {
+		return n, fmt.Errorf("-interlace-format %s only applies without -display", format)
 	}
-	if sharedcolors {
-		k0.D800Color = k1.D800Color
-		k0.ScreenColor = k1.ScreenColor
+	if format == InterlaceFormatGunpaint {
+		return n, fmt.Errorf("interlace format %s requires a single ifli image, use -mode ifli", format)
 	}
-	if !sharedcolors {
-		k0, k1, _, err = img0.InterlaceKoala(*img1)
-		if err != nil {
-			return 0, fmt.Errorf("img0.InterlaceKoala %q failed: %w", img0.sourceFilename, err)
+	if c.images[0].graphicsType == singleColorBitmap && c.images[1].graphicsType == singleColorBitmap {
+		if format != "" && format != InterlaceFormatHires {
+			return n, fmt.Errorf("interlace format %s cannot represent 2 hires images, use %s", format, InterlaceFormatHires)
 		}
+		return c.writeHiresInterlaceTo(w)
 	}
-	sharedd800 := k0.D800Color == k1.D800Color
-	sharedscreen := k0.ScreenColor == k1.ScreenColor
-	sharedbitmap := k0.Bitmap == k1.Bitmap
-	if !c.opt.Quiet {
-		fmt.Printf("shared colorram: %v shared screenram: %v shared bitmap: %v\n", sharedd800, sharedscreen, sharedbitmap)
-		if !sharedd800 && c.opt.Verbose {
-			for i := range k0.D800Color {
-				if k0.D800Color[i] != k1.D800Color[i] {
-					fmt.Printf("char %d k0.D800Color %d k1.D800Color %d\n", i, k0.D800Color[i], k1.D800Color[i])
-				}
-			}
-		}
+	if format == InterlaceFormatHires {
+		return n, fmt.Errorf("interlace format %s requires 2 hires images, not %s and %s", format, c.images[0].graphicsType, c.images[1].graphicsType)
 	}
+	k0, k1, sharedcolors, err := c.interlaceKoalas()
+	if err != nil {
+		return n, err
+	}
+	img0 := &c.images[0]
 
 	bgBorder := k0.BackgroundColor | k0.BorderColor<<4
 	link := NewLinker(0, c.opt.VeryVerbose)
 	if !c.opt.Display {
-		if sharedcolors {
-			// drazlace
+		switch format {
+		case "":
+			format = InterlaceFormatTruePaint
+			if sharedcolors {
+				format = InterlaceFormatDrazlace
+			}
+		case InterlaceFormatDrazlace:
+			if !sharedcolors {
+				return n, fmt.Errorf("interlace format %s requires shared screenram and colorram, use %s", format, InterlaceFormatTruePaint)
+			}
+		case InterlaceFormatTruePaint:
+			if k0.D800Color != k1.D800Color {
+				return n, fmt.Errorf("interlace format %s requires shared colorram, but the frames differ", format)
+			}
+		}
+		if !c.opt.Quiet {
+			fmt.Printf("interlace format: %s\n", format)
+		}
+		if format == InterlaceFormatDrazlace {
 			if c.opt.Symbols {
 				c.Symbols = []c64Symbol{
 					{"colorram1", 0x5800},
@@ -110,17 +119,17 @@ func (c *Converter) WriteInterlaceTo(w io.Writer) (n int64, err error) {
 					{"d021color", int(img0.bg.C64Color)},
 				}
 			}
-			_, err = link.WriteMap(LinkMap{
+			_, err = link.WriteNamedMap(LinkMap{
 				0x5800: k1.D800Color[:],
 				0x5c00: k1.ScreenColor[:],
 				0x6000: k0.Bitmap[:],
 				0x7f40: []byte{bgBorder, 0, byte(c.opt.D016Offset)},
 				0x8000: k1.Bitmap[:],
-			})
+			}, map[Word]string{0x5800: "colorram", 0x5c00: "screen", 0x6000: "bitmap1", 0x7f40: "colors", 0x8000: "bitmap2"})
 			if err != nil {
 				return n, fmt.Errorf("link.WriteMap failed: %w", err)
 			}
-			return link.WriteTo(w)
+			return c.writeLinkTo(w, link)
 		}
 		// true paint .mci format
 		c.Symbols = []c64Symbol{
@@ -135,46 +144,47 @@ func (c *Converter) WriteInterlaceTo(w io.Writer) (n int64, err error) {
 			{"d020color", int(k0.BorderColor)},
 			{"d021color", int(k0.BackgroundColor)},
 		}
-		_, err = link.WriteMap(LinkMap{
+		_, err = link.WriteNamedMap(LinkMap{
 			0x9c00: k0.ScreenColor[:],
 			0x9fe8: []byte{bgBorder, byte(c.opt.D016Offset)},
 			0xa000: k0.Bitmap[:],
 			0xc000: k1.Bitmap[:],
 			0xe000: k1.ScreenColor[:],
 			0xe400: k1.D800Color[:],
-		})
+		}, map[Word]string{0x9c00: "screen1", 0x9fe8: "colors", 0xa000: "bitmap1", 0xc000: "bitmap2", 0xe000: "screen2", 0xe400: "colorram"})
 		if err != nil {
 			return n, fmt.Errorf("link.WriteMap failed: %w", err)
 		}
-		return link.WriteTo(w)
+		return c.writeLinkTo(w, link)
 	}
 
 	link.Block(0x7f50, 0xc5b0)
-	if _, err = link.WritePrg(multiColorInterlaceBitmap.newHeader()); err != nil {
+	if _, err = link.WriteNamedPrg(multiColorInterlaceBitmap.newHeader(), "displayer"); err != nil {
 		return n, fmt.Errorf("link.WritePrg failed: %w", err)
 	}
 
-	_, err = link.WriteMap(LinkMap{
+	_, err = link.WriteNamedMap(LinkMap{
 		BitmapAddress: k0.Bitmap[:],
 		0x4000:        k0.ScreenColor[:],
 		0x4400:        k1.D800Color[:],
 		0x5c00:        k1.ScreenColor[:],
 		0x6000:        k1.Bitmap[:],
 		0x7f40:        []byte{bgBorder, 0, byte(c.opt.D016Offset)},
-	})
+	}, map[Word]string{BitmapAddress: "bitmap1", 0x4000: "screen1", 0x4400: "colorram", 0x5c00: "screen2", 0x6000: "bitmap2", 0x7f40: "colors"})
 	if err != nil {
 		return n, fmt.Errorf("link.WriteMap failed: %w", err)
 	}
 
-	if err = injectSID(link, c.opt.IncludeSID, c.opt.Quiet); err != nil {
+	if err = injectSID(link, c.opt); err != nil {
 		return n, fmt.Errorf("injectSID failed: %w", err)
 	}
 	if c.opt.NoCrunch {
-		return link.WriteTo(w)
+		return c.writeLinkTo(w, link)
 	}
 
+	c.link = link
 	t1 := time.Now()
-	wt, err := injectCrunch(link, c.opt.Verbose)
+	wt, err := injectCrunch(link, c.opt)
 	if err != nil {
 		return n, fmt.Errorf("injectCrunch failed: %w", err)
 	}
@@ -183,12 +193,144 @@ func (c *Converter) WriteInterlaceTo(w io.Writer) (n int64, err error) {
 		return n, err
 	}
 	if !c.opt.Quiet && c.opt.Display && !c.opt.NoCrunch {
-		fmt.Printf("TSCrunched in %s\n", time.Since(t1))
+		fmt.Printf("crunched in %s\n", time.Since(t1))
 	}
 
 	return n, err
 }
 
+// writeHiresInterlaceTo converts the 2 analyzed hires images and writes them in the hires interlace format to w.
+func (c *Converter) writeHiresInterlaceTo(w io.Writer) (n int64, err error) {
+	h0, err := c.images[0].Hires()
+	if err != nil {
+		return n, fmt.Errorf("img.Hires %q failed: %w", c.images[0].sourceFilename, err)
+	}
+	h1, err := c.images[1].Hires()
+	if err != nil {
+		return n, fmt.Errorf("img.Hires %q failed: %w", c.images[1].sourceFilename, err)
+	}
+	if !c.opt.Quiet {
+		fmt.Printf("interlace format: %s\n", InterlaceFormatHires)
+	}
+	if c.opt.Symbols {
+		c.Symbols = []c64Symbol{
+			{"screenram1", 0x5c00},
+			{"bitmap1", 0x6000},
+			{"d020coloraddr", 0x7f40},
+			{"d016offsetaddr", 0x7f42},
+			{"screenram2", 0x9c00},
+			{"bitmap2", 0xa000},
+			{"d016offset", c.opt.D016Offset},
+			{"d020color", int(h0.BorderColor)},
+		}
+	}
+	link := NewLinker(0, c.opt.VeryVerbose)
+	if c.opt.Display {
+		if _, err = link.WriteNamedPrg(singleColorInterlaceBitmap.newHeader(), "displayer"); err != nil {
+			return n, fmt.Errorf("link.WritePrg failed: %w", err)
+		}
+	}
+	_, err = link.WriteNamedMap(LinkMap{
+		0x5c00: h0.ScreenColor[:],
+		0x6000: h0.Bitmap[:],
+		0x7f40: []byte{h0.BorderColor, 0, byte(c.opt.D016Offset)},
+		0x9c00: h1.ScreenColor[:],
+		0xa000: h1.Bitmap[:],
+	}, map[Word]string{0x5c00: "screen1", 0x6000: "bitmap1", 0x7f40: "colors", 0x9c00: "screen2", 0xa000: "bitmap2"})
+	if err != nil {
+		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+	}
+	if !c.opt.Display {
+		return c.writeLinkTo(w, link)
+	}
+
+	if err = injectSID(link, c.opt); err != nil {
+		return n, fmt.Errorf("injectSID failed: %w", err)
+	}
+	if c.opt.NoCrunch {
+		return c.writeLinkTo(w, link)
+	}
+	c.link = link
+	t1 := time.Now()
+	wt, err := injectCrunch(link, c.opt)
+	if err != nil {
+		return n, fmt.Errorf("injectCrunch failed: %w", err)
+	}
+	if n, err = wt.WriteTo(w); err != nil {
+		return n, err
+	}
+	if !c.opt.Quiet {
+		fmt.Printf("crunched in %s\n", time.Since(t1))
+	}
+	return n, nil
+}
+
+// interlaceKoalas converts the 2 analyzed images to 2 koalas for interlace.
+// If sharedcolors is true, both koalas use the same screenram and colorram.
+func (c *Converter) interlaceKoalas() (k0, k1 Koala, sharedcolors bool, err error) {
+	img0 := &c.images[0]
+	img1 := &c.images[1]
+	if img0.p.NumColors() < MaxColors {
+		for _, v := range img1.p.Colors() {
+			img0.p.Add(v)
+		}
+	}
+	if img1.p.NumColors() < MaxColors {
+		for _, v := range img0.p.Colors() {
+			img1.p.Add(v)
+		}
+	}
+
+	if c.opt.BruteForce {
+		if err = c.BruteForceBitpairColors(multiColorInterlaceBitmap, 4); err != nil {
+			return k0, k1, false, fmt.Errorf("BruteForceBitpairColors %q failed: %w", img0.sourceFilename, err)
+		}
+		if err = img0.setPreferredBitpairColors(c.opt.BitpairColorsString); err != nil {
+			return k0, k1, false, fmt.Errorf("img.setPreferredBitpairColors %q failed: %w", c.opt.BitpairColorsString, err)
+		}
+		if err = img1.setPreferredBitpairColors(c.opt.BitpairColorsString); err != nil {
+			return k0, k1, false, fmt.Errorf("img.setPreferredBitpairColors %q failed: %w", c.opt.BitpairColorsString, err)
+		}
+	}
+
+	if k0, k1, sharedcolors, err = interlaceKoalaPair(img0, img1); err != nil {
+		return k0, k1, false, err
+	}
+	sharedd800 := k0.D800Color == k1.D800Color
+	sharedscreen := k0.ScreenColor == k1.ScreenColor
+	sharedbitmap := k0.Bitmap == k1.Bitmap
+	if !c.opt.Quiet {
+		fmt.Printf("shared colorram: %v shared screenram: %v shared bitmap: %v\n", sharedd800, sharedscreen, sharedbitmap)
+		if !sharedd800 && c.opt.Verbose {
+			for i := range k0.D800Color {
+				if k0.D800Color[i] != k1.D800Color[i] {
+					fmt.Printf("char %d k0.D800Color %d k1.D800Color %d\n", i, k0.D800Color[i], k1.D800Color[i])
+				}
+			}
+		}
+	}
+	return k0, k1, sharedcolors, nil
+}
+
+// interlaceKoalaPair converts the 2 analyzed images with preferred bitpair colors to 2 koalas for interlace.
+// If sharedcolors is true, both koalas use the same screenram and colorram.
+func interlaceKoalaPair(img0, img1 *sourceImage) (k0, k1 Koala, sharedcolors bool, err error) {
+	k0, k1, sharedcolors, err = img1.InterlaceKoala(*img0)
+	if err != nil {
+		return k0, k1, false, fmt.Errorf("img1.InterlaceKoala failed: %w", err)
+	}
+	if sharedcolors {
+		k0.D800Color = k1.D800Color
+		k0.ScreenColor = k1.ScreenColor
+		return k0, k1, true, nil
+	}
+	k0, k1, _, err = img0.InterlaceKoala(*img1)
+	if err != nil {
+		return k0, k1, false, fmt.Errorf("img0.InterlaceKoala %q failed: %w", img0.sourceFilename, err)
+	}
+	return k0, k1, false, nil
+}
+
 // InterlaceKoala returns the secondary Koala, with as many bitpairs/colors the same as the first image.
 // it also merges possibly missing colors into k.ScreenColor and k.D800Color, use those.
 func (img1 *sourceImage) InterlaceKoala(img0 sourceImage) (k0, k1 Koala, sharedcolors bool, err error) {
@@ -0,0 +1,47 @@
+package png2prg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterlaceFormat(t *testing.T) {
+	t.Parallel()
+	mci := []string{"testdata/mcinterlace/flower0.png", "testdata/mcinterlace/flower1.png"}
+	drazlace := []string{"testdata/madonna/cjam_pure_madonna.png"}
+	hires := []string{"testdata/deev_desolate_hires.png", "testdata/lobo_biznisaur_hires.png"}
+	cases := []struct {
+		files  []string
+		format string
+		start  Word
+		err    bool
+	}{
+		{files: mci, start: 0x9c00},
+		{files: mci, format: InterlaceFormatTruePaint, start: 0x9c00},
+		{files: mci, format: InterlaceFormatDrazlace, err: true},
+		{files: mci, format: InterlaceFormatHires, err: true},
+		{files: drazlace, start: 0x5800},
+		{files: drazlace, format: InterlaceFormatTruePaint, start: 0x9c00},
+		{files: hires, start: 0x5c00},
+		{files: hires, format: InterlaceFormatDrazlace, err: true},
+		{files: mci, format: InterlaceFormatGunpaint, err: true},
+	}
+	for _, tc := range cases {
+		c, err := NewFromPath(Options{Quiet: true, Interlace: true, InterlaceFormat: tc.format}, tc.files...)
+		require.NoError(t, err)
+		buf := &bytes.Buffer{}
+		_, err = c.WriteTo(buf)
+		if tc.err {
+			assert.Error(t, err, "%v %s", tc.files, tc.format)
+			continue
+		}
+		require.NoError(t, err, "%v %s", tc.files, tc.format)
+		assert.Equal(t, tc.start, NewWord(buf.Bytes()[0], buf.Bytes()[1]), "%v %s", tc.files, tc.format)
+	}
+
+	_, err := NewFromPath(Options{Quiet: true, InterlaceFormat: "funpaint"}, mci...)
+	assert.Error(t, err)
+}
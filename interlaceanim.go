@@ -0,0 +1,221 @@
+package png2prg
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// The interlace animation displayer shows the first half from vic bank 0 and the second half from vic bank 1.
+// The screenram of the first half and the shared colorram are copied into place by the displayer.
+const (
+	mciAnimationStart    = 0x8000
+	mciScreenRAMSource   = 0x4000
+	mciColorRAMSource    = 0x4400
+	mciScreenRAM0        = 0x0400
+	mciScreenRAM1        = 0x5c00
+	mciBitmap1           = 0x6000
+	mciBackgroundBorder  = 0x7f40
+	mciD016OffsetAddress = 0x7f42
+)
+
+// WriteInterlaceAnimationTo converts the interlaced frames and writes the animation displayer .prg to w.
+// The frames are either pairs of images, or multicolor interlace images which are split.
+func (c *Converter) WriteInterlaceAnimationTo(w io.Writer) (n int64, err error) {
+	if !c.opt.Display {
+		return n, fmt.Errorf("interlace animations require the displayer")
+	}
+	if c.opt.Stream {
+		return n, fmt.Errorf("streaming is not supported for interlace animations")
+	}
+	if c.opt.CustomLayout() {
+		return n, fmt.Errorf("custom addresses are not supported for interlace")
+	}
+	if c.opt.DisplayerBank != 0 {
+		return n, fmt.Errorf("vic bank %d is not supported for interlace", c.opt.DisplayerBank)
+	}
+	kk0, kk1, err := c.interlaceAnimationKoalas()
+	if err != nil {
+		return n, err
+	}
+	c.FinalGraphicsType = multiColorInterlaceBitmap
+
+	link, symbols, err := linkInterlaceDisplayAnim(kk0, kk1)
+	if err != nil {
+		return n, fmt.Errorf("linkInterlaceDisplayAnim failed: %w", err)
+	}
+	c.Symbols = append(c.Symbols, symbols...)
+	if c.opt.NoCrunch {
+		return c.writeLinkTo(w, link)
+	}
+
+	c.link = link
+	t1 := time.Now()
+	wt, err := injectCrunch(link, c.opt)
+	if err != nil {
+		return n, fmt.Errorf("injectCrunch failed: %w", err)
+	}
+	if n, err = wt.WriteTo(w); err != nil {
+		return n, err
+	}
+	if !c.opt.Quiet {
+		fmt.Printf("crunched in %s\n", time.Since(t1))
+	}
+	return n, nil
+}
+
+// interlaceAnimationKoalas converts the frames to a koala per half.
+// The colorram of the first half is replaced by the shared colorram of the second half, as used by the displayer.
+func (c *Converter) interlaceAnimationKoalas() (kk0, kk1 []Koala, err error) {
+	pairs := [][]sourceImage{}
+	if c.images[0].graphicsType == multiColorInterlaceBitmap {
+		for i := range c.images {
+			pairs = append(pairs, c.images[i:i+1])
+		}
+	} else {
+		if len(c.images)%2 != 0 {
+			return nil, nil, fmt.Errorf("interlace animations require pairs of images, not %d images", len(c.images))
+		}
+		for i := 0; i < len(c.images); i += 2 {
+			pairs = append(pairs, c.images[i:i+2])
+		}
+	}
+
+	for i, pair := range pairs {
+		if !c.opt.Quiet {
+			fmt.Printf("processing interlace frame %d\n", i)
+		}
+		p := &Converter{opt: c.opt, images: append([]sourceImage{}, pair...)}
+		if err = p.images[0].analyze(); err != nil {
+			return nil, nil, fmt.Errorf("analyze %q failed: %w", p.images[0].sourceFilename, err)
+		}
+		if len(pair) == 1 && p.images[0].graphicsType != multiColorInterlaceBitmap {
+			return nil, nil, fmt.Errorf("mixed graphicsmodes detected %q != %q", p.images[0].graphicsType, multiColorInterlaceBitmap)
+		}
+		if err = p.splitInterlace(); err != nil {
+			return nil, nil, err
+		}
+		k0, k1, _, err := p.interlaceKoalas()
+		if err != nil {
+			return nil, nil, fmt.Errorf("interlaceKoalas of frame %d failed: %w", i, err)
+		}
+		if i > 0 && k0.BackgroundColor != kk0[0].BackgroundColor {
+			return nil, nil, fmt.Errorf("background color %d of frame %d differs from %d of the first frame", k0.BackgroundColor, i, kk0[0].BackgroundColor)
+		}
+		k0.D800Color = k1.D800Color
+		kk0 = append(kk0, k0)
+		kk1 = append(kk1, k1)
+	}
+	return kk0, kk1, nil
+}
+
+// processInterlaceAnimation processes the transitions tt of both halves kk0 and kk1.
+// Each frame contains the changes of the first half, followed by the changes of the second half.
+func processInterlaceAnimation(opt Options, kk0, kk1 []Koala, tt []transition, delays []byte) ([][]byte, error) {
+	frames0, err := processAnimation(opt, makeCharer(kk0), tt, delays)
+	if err != nil {
+		return nil, fmt.Errorf("processAnimation of the first half failed: %w", err)
+	}
+	frames1, err := processAnimation(opt, makeCharer(kk1), tt, delays)
+	if err != nil {
+		return nil, fmt.Errorf("processAnimation of the second half failed: %w", err)
+	}
+	frames := make([][]byte, len(tt))
+	for i := range tt {
+		frames[i] = append(frames0[i], frames1[i]...)
+	}
+	return frames, nil
+}
+
+// WriteInterlaceDisplayAnimTo processes the halves kk0 and kk1 and writes the converted animation and displayer to w.
+func WriteInterlaceDisplayAnimTo(w io.Writer, kk0, kk1 []Koala) (n int64, err error) {
+	link, _, err := linkInterlaceDisplayAnim(kk0, kk1)
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// linkInterlaceDisplayAnim returns the Linker written by WriteInterlaceDisplayAnimTo, and the symbols.
+func linkInterlaceDisplayAnim(kk0, kk1 []Koala) (link *Linker, symbols []c64Symbol, err error) {
+	if len(kk0) != len(kk1) {
+		return nil, nil, fmt.Errorf("the number of frames of both halves differ: %d != %d", len(kk0), len(kk1))
+	}
+	opt := kk0[0].opt
+	opt.NoFade = true
+	tt, err := animationTransitions(opt, len(kk0))
+	if err != nil {
+		return nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+	}
+	k0, k1 := kk0[tt[0].from], kk1[tt[0].from]
+	picture := LinkMap{
+		BitmapAddress:        k0.Bitmap[:],
+		mciScreenRAMSource:   k0.ScreenColor[:],
+		mciColorRAMSource:    k1.D800Color[:],
+		mciScreenRAM1:        k1.ScreenColor[:],
+		mciBitmap1:           k1.Bitmap[:],
+		mciBackgroundBorder:  {k0.BackgroundColor | k0.BorderColor<<4},
+		mciD016OffsetAddress: {byte(opt.D016Offset)},
+	}
+	budget := animationBudget{displayer: mciDisplayAnim, picture: picture, start: mciAnimationStart}
+	opt, framePrgs, err := fitAnimation(opt, budget, tt, func(tt []transition) ([][]byte, error) {
+		return processInterlaceAnimation(opt, kk0, kk1, tt, koalaFrameDelays(kk1))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	link = NewLinker(0, opt.VeryVerbose)
+	if _, err = link.WriteNamedPrg(mciDisplayAnim, "displayer"); err != nil {
+		return nil, nil, fmt.Errorf("link.WritePrg error: %w", err)
+	}
+	link.SetByte(DisplayerSettingsStart+9, byte(opt.FrameDelay), byte(opt.WaitSeconds))
+	if !opt.Quiet {
+		fmt.Printf("memory usage for displayer code: %s - %s\n", link.StartAddress(), link.EndAddress())
+	}
+	names := map[Word]string{
+		BitmapAddress:        "bitmap1",
+		mciScreenRAMSource:   "screen1",
+		mciColorRAMSource:    "colorram",
+		mciScreenRAM1:        "screen2",
+		mciBitmap1:           "bitmap2",
+		mciBackgroundBorder:  "colors",
+		mciD016OffsetAddress: "colors",
+	}
+	if _, err = link.WriteNamedMap(picture, names); err != nil {
+		return nil, nil, fmt.Errorf("link.WriteMap error: %w", err)
+	}
+	if !opt.Quiet {
+		fmt.Printf("memory usage for pictures: 0x%04x - %s\n", BitmapAddress, link.EndAddress())
+	}
+
+	// when playing once, the last frame is held by an empty frame for both halves.
+	end, tableSymbols := appendFrameTable(opt, mciAnimationStart, framePrgs, animationEnd(opt, mciAnimationStart, framesLength(framePrgs), 0x00, 0x00))
+	link.SetCursor(mciAnimationStart)
+	for _, bin := range append(framePrgs, end) {
+		if _, err = link.Write(bin); err != nil {
+			return nil, nil, fmt.Errorf("link.Write error: %w", err)
+		}
+	}
+	link.Name(mciAnimationStart, link.Cursor(), "animation frames")
+	if !opt.Quiet {
+		fmt.Printf("memory usage for animations: %s - %s\n", Word(mciAnimationStart), link.EndAddress())
+	}
+
+	link.Block(animationZeroPageStart, animationZeroPageEnd)
+	link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+	if err = injectSID(link, opt); err != nil {
+		return nil, nil, fmt.Errorf("injectSID failed: %w", err)
+	}
+	symbols = append([]c64Symbol{
+		{"bitmap1", BitmapAddress},
+		{"screenram1", mciScreenRAM0},
+		{"bitmap2", mciBitmap1},
+		{"screenram2", mciScreenRAM1},
+		{"d016offset", opt.D016Offset},
+		{"d020color", int(k0.BorderColor)},
+		{"d021color", int(k0.BackgroundColor)},
+		{"animation", mciAnimationStart},
+	}, tableSymbols...)
+	return link, symbols, nil
+}
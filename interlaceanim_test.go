@@ -0,0 +1,46 @@
+package png2prg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessInterlaceAnimation(t *testing.T) {
+	t.Parallel()
+	kk0, kk1 := make([]Koala, 2), make([]Koala, 2)
+	for i := range kk0 {
+		kk0[i].opt = Options{Quiet: true}
+		kk1[i].opt = kk0[i].opt
+	}
+	kk0[1].Bitmap[8] = 0x55
+	kk1[1].ScreenColor[999] = 0x12
+	tt, err := animationTransitions(kk0[0].opt, len(kk0))
+	require.NoError(t, err)
+	frames, err := processInterlaceAnimation(kk0[0].opt, kk0, kk1, tt, koalaFrameDelays(kk1))
+	require.NoError(t, err)
+	require.Len(t, frames, 2)
+
+	// the first half uses the masked encoding, the second half a chunk of char 999.
+	half0 := []byte{maskedFrameMarker, maskedRows | 1, 0x80, 0x55, 0x00, 0x00}
+	assert.Equal(t, half0, frames[0][:len(half0)])
+	half1 := frames[0][len(half0):]
+	assert.Equal(t, []byte{1, 0x38, 0x1f, 0xe7, 0x03}, half1[:5])
+	assert.Equal(t, []byte{0x12, 0x00, 0x00, 0x00}, half1[len(half1)-4:])
+
+	link, symbols, err := linkInterlaceDisplayAnim(kk0, kk1)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	_, err = link.WriteTo(buf)
+	require.NoError(t, err)
+	prg := buf.Bytes()
+	start := int(NewWord(prg[0], prg[1]))
+	require.Equal(t, 0x0801, start)
+	offset := mciAnimationStart - start + 2
+	length := framesLength(frames)
+	assert.Equal(t, append(frames[0], frames[1]...), prg[offset:offset+length])
+	assert.Equal(t, []byte{0xff, 0x00, 0x80}, prg[offset+length:offset+length+3])
+	assert.Contains(t, symbols, c64Symbol{"animation", mciAnimationStart})
+}
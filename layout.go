@@ -0,0 +1,279 @@
+package png2prg
+
+import (
+	"fmt"
+
+	"github.com/staD020/sid"
+)
+
+// A memoryLayout contains the addresses of the data blocks of a converted image.
+// Colors is the location of the d02x colors, stored directly after the ColorRAM block.
+type memoryLayout struct {
+	Bitmap   Word
+	Screen   Word
+	ColorRAM Word
+	Colors   Word
+}
+
+// defaultBitmapLayout and defaultCharsetLayout are used when no custom addresses are set in Options.
+var (
+	defaultBitmapLayout  = memoryLayout{BitmapAddress, BitmapScreenRAMAddress, BitmapColorRAMAddress, BitmapColorRAMAddress + FullScreenChars}
+	defaultCharsetLayout = memoryLayout{BitmapAddress, CharsetScreenRAMAddress, CharsetColorRAMAddress, CharsetColorRAMAddress + FullScreenChars}
+)
+
+// names returns the names of the data blocks of lm located at m, for the memory map.
+// The bitmap block is named bitmap, like "bitmap" or "charset". A colorram block shorter than a screen only holds colors.
+func (m memoryLayout) names(lm LinkMap, bitmap string) map[Word]string {
+	names := map[Word]string{
+		m.Bitmap:   bitmap,
+		m.Screen:   "screen",
+		m.ColorRAM: "colorram",
+		m.Colors:   "colors",
+	}
+	if len(lm[m.ColorRAM]) < FullScreenChars {
+		names[m.ColorRAM] = "colors"
+	}
+	return names
+}
+
+// CustomLayout returns true if any of the data block addresses are set.
+func (o Options) CustomLayout() bool {
+	return o.BitmapAddress != 0 || o.CharsetAddress != 0 || o.ScreenRAMAddress != 0 || o.ColorRAMAddress != 0
+}
+
+// bitmapLayout returns the memoryLayout for koala and hires bitmaps.
+// Blocks without a custom address follow the previous block, like the koala format.
+func (o Options) bitmapLayout() memoryLayout {
+	if !o.CustomLayout() {
+		return defaultBitmapLayout
+	}
+	a := o.customLayout(o.BitmapAddress, 8000, FullScreenChars)
+	return memoryLayout{Word(a[0]), Word(a[1]), Word(a[2]), Word(a[3])}
+}
+
+// charsetLayout returns the memoryLayout for all charset modes.
+// Blocks without a custom address follow the previous block: screen at charset+$800, colorram at screen+$400.
+func (o Options) charsetLayout() memoryLayout {
+	if !o.CustomLayout() {
+		return defaultCharsetLayout
+	}
+	a := o.customLayout(o.CharsetAddress, 0x800, 0x400)
+	return memoryLayout{Word(a[0]), Word(a[1]), Word(a[2]), Word(a[3])}
+}
+
+// customLayout returns the addresses of the bitmap, screen, colorram and colors blocks.
+// The addresses are computed in int, so validateLayout can detect blocks that do not fit in memory.
+func (o Options) customLayout(bitmap, bitmapSize, screenSize int) (a [4]int) {
+	a[0] = BitmapAddress
+	if bitmap != 0 {
+		a[0] = bitmap
+	}
+	a[1] = a[0] + bitmapSize
+	if o.ScreenRAMAddress != 0 {
+		a[1] = o.ScreenRAMAddress
+	}
+	a[2] = a[1] + screenSize
+	if o.ColorRAMAddress != 0 {
+		a[2] = o.ColorRAMAddress
+	}
+	a[3] = a[2] + FullScreenChars
+	return a
+}
+
+// vicBank returns the VIC bank (0-3) of addr.
+func vicBank(addr int) int {
+	return addr >> 14
+}
+
+// inCharROM returns true if the memory area from start to end overlaps the area where the VIC sees the character ROM.
+// This is $1000-$1fff in bank 0 and $9000-$9fff in bank 2.
+func inCharROM(start, end int) bool {
+	for _, rom := range []int{0x1000, 0x9000} {
+		if start < rom+0x1000 && end > rom {
+			return true
+		}
+	}
+	return false
+}
+
+// validateVICAddress returns an error if the data block called name at addr with length size
+// is not aligned or is located where the VIC cannot read it.
+func validateVICAddress(name string, addr, align, size int) error {
+	switch {
+	case addr < 0 || addr+size > MaxMemory+1:
+		return fmt.Errorf("%s address %s with length %#04x does not fit in memory", name, Word(addr), size)
+	case align > 0 && addr%align != 0:
+		return fmt.Errorf("%s address %s must be aligned to %#04x", name, Word(addr), align)
+	case align > 0 && inCharROM(addr, addr+size):
+		return fmt.Errorf("%s address %s overlaps character rom at $1000-$1fff or $9000-$9fff", name, Word(addr))
+	}
+	return nil
+}
+
+// validateLayout returns an error if the custom addresses in o do not follow the VIC bank rules.
+// Checks for overlapping blocks are left to the Linker.
+func (o Options) validateLayout() error {
+	if !o.CustomLayout() {
+		return nil
+	}
+	if o.Display {
+		return fmt.Errorf("custom addresses are not supported by the displayers")
+	}
+	if o.BitmapAddress != 0 {
+		if err := validateVICAddress("bitmap", o.BitmapAddress, 0x2000, 8000); err != nil {
+			return err
+		}
+	}
+	if o.CharsetAddress != 0 {
+		if err := validateVICAddress("charset", o.CharsetAddress, 0x800, 0x800); err != nil {
+			return err
+		}
+	}
+	if o.ScreenRAMAddress != 0 {
+		if err := validateVICAddress("screenram", o.ScreenRAMAddress, 0x400, FullScreenChars); err != nil {
+			return err
+		}
+	}
+	if o.ColorRAMAddress != 0 {
+		// colorram is copied to $d800 and is not read by the VIC, so any address will do.
+		if err := validateVICAddress("colorram", o.ColorRAMAddress, 0, FullScreenChars); err != nil {
+			return err
+		}
+	}
+	// blocks without a custom address follow the previous block, they must end in memory too.
+	for _, l := range []struct {
+		names [4]string
+		addr  [4]int
+		size  [4]int
+	}{
+		{[4]string{"bitmap", "screenram", "colorram", "colors"}, o.customLayout(o.BitmapAddress, 8000, FullScreenChars), [4]int{8000, FullScreenChars, FullScreenChars, 1}},
+		{[4]string{"charset", "screenram", "colorram", "colors"}, o.customLayout(o.CharsetAddress, 0x800, 0x400), [4]int{0x800, FullScreenChars, FullScreenChars, 5}},
+	} {
+		for i, addr := range l.addr {
+			if addr+l.size[i] > MaxMemory+1 {
+				return fmt.Errorf("%s at %#04x with length %#04x does not fit in memory, set its address explicitly", l.names[i], addr, l.size[i])
+			}
+		}
+	}
+	return nil
+}
+
+// validateBank returns an error if a custom screenram is not located in the same VIC bank as the bitmap or charset.
+func (m memoryLayout) validateBank(o Options, bitmapName string) error {
+	if o.ScreenRAMAddress == 0 {
+		return nil
+	}
+	if vicBank(int(m.Screen)) != vicBank(int(m.Bitmap)) {
+		return fmt.Errorf("screenram %s and %s %s must be located in the same vic bank", m.Screen, bitmapName, m.Bitmap)
+	}
+	return nil
+}
+
+// A displayerBank describes a displayer variant with the picture located in VIC bank bank.
+// The displayer prg expects the picture data at layout and uses the reserved memory areas at runtime.
+type displayerBank struct {
+	bank     int
+	prg      []byte
+	layout   memoryLayout
+	reserved [][2]Word
+}
+
+// koalaBanks and hiresBanks contain the displayer variants, in order of preference.
+// Bank 0 loads the picture in koala format at $2000, the other banks load the data blocks to their final location.
+var (
+	koalaBanks = []displayerBank{
+		{0, koalaDisplay, defaultBitmapLayout, [][2]Word{{0x4800, 0x8e50}}},
+		{1, koalaDisplayBank1, memoryLayout{0x6000, 0x5400, 0x5800, 0x5be8}, [][2]Word{{0x5c00, 0x5fe8}, {0x8000, 0xc651}}},
+		{2, koalaDisplayBank2, memoryLayout{0xa000, 0x8400, 0x8800, 0x8be8}, [][2]Word{{0x8c00, 0x8fe8}, {0x3daf, 0x8400}}},
+		{3, koalaDisplayBank3, memoryLayout{0xe000, 0xc400, 0xc800, 0xcbe8}, [][2]Word{{0xcc00, 0xcfe8}, {0x7c00, 0xc251}}},
+	}
+	hiresBanks = []displayerBank{
+		{0, hiresDisplay, defaultBitmapLayout, [][2]Word{{0x4800, 0x6b29}}},
+		{1, hiresDisplayBank1, memoryLayout{0x6000, 0x5800, 0x5be8, 0}, [][2]Word{{0x5c00, 0x5fe8}, {0x8000, 0xa329}}},
+		{2, hiresDisplayBank2, memoryLayout{0xa000, 0x8800, 0x8be8, 0}, [][2]Word{{0x8c00, 0x8fe8}, {0x6400, 0x8729}}},
+		{3, hiresDisplayBank3, memoryLayout{0xe000, 0xc800, 0xcbe8, 0}, [][2]Word{{0xcc00, 0xcfe8}, {0xa000, 0xc329}}},
+	}
+)
+
+// link returns a new Linker containing the displayer and the picture data returned by data.
+func (b displayerBank) link(opt Options, data func(memoryLayout) LinkMap) (*Linker, error) {
+	link := NewLinker(b.layout.Bitmap, opt.VeryVerbose)
+	m := data(b.layout)
+	if _, err := link.WriteNamedMap(m, b.layout.names(m, "bitmap")); err != nil {
+		return nil, fmt.Errorf("link.WriteMap failed: %w", err)
+	}
+	for _, r := range b.reserved {
+		link.Block(r[0], r[1])
+		link.Name(r[0], r[1], "fade")
+	}
+	if _, err := link.WriteNamedPrg(b.prg, "displayer"); err != nil {
+		return nil, fmt.Errorf("link.WritePrg failed: %w", err)
+	}
+	return link, nil
+}
+
+// forcedBank returns true if o forces the vic bank of the displayer, bank 0 is only forced by ForceDisplayerBank.
+func (o Options) forcedBank() bool {
+	return o.ForceDisplayerBank || o.DisplayerBank != 0
+}
+
+// bankLinkable is implemented by the koala and hires pictures, their displayers can locate the picture in any vic bank.
+// The layout of the displayerBank returned by linkBank locates the symbols.
+type bankLinkable interface {
+	linkBank() (*Linker, displayerBank, error)
+	symbols(m memoryLayout) []c64Symbol
+}
+
+// linkDisplayer links the displayer variant in banks with the picture data returned by data and the optional sid.
+// Unless opt forces a bank, the first bank that does not collide with the sid is used.
+// If the sid collides in all banks, the first bank where the sid can be relocated is used.
+func linkDisplayer(opt Options, banks []displayerBank, data func(memoryLayout) LinkMap) (*Linker, displayerBank, error) {
+	if opt.DisplayerBank < 0 || opt.DisplayerBank > 3 {
+		return nil, displayerBank{}, fmt.Errorf("incorrect vic bank %d, only 0-3 are allowed", opt.DisplayerBank)
+	}
+	forced := opt.forcedBank()
+	var start, end int
+	if opt.IncludeSID != "" {
+		s, err := sid.LoadSID(opt.IncludeSID)
+		if err != nil {
+			return nil, displayerBank{}, fmt.Errorf("sid.LoadSID failed: %w", err)
+		}
+		start = int(s.LoadAddress())
+		end = start + len(s.RawBytes())
+	}
+	relocate := false
+	var lastErr error
+	for {
+		for _, b := range banks {
+			if forced && b.bank != opt.DisplayerBank {
+				continue
+			}
+			link, err := b.link(opt, data)
+			if err != nil {
+				return nil, b, err
+			}
+			if !relocate && !forced && end > start && link.Overlaps(Word(start), end-start) {
+				continue
+			}
+			if err = injectSID(link, opt); err != nil {
+				if relocate {
+					lastErr = err
+					continue
+				}
+				return nil, b, fmt.Errorf("injectSID failed: %w", err)
+			}
+			if b.bank != 0 && !opt.Quiet {
+				fmt.Printf("using displayer with picture in vic bank %d\n", b.bank)
+			}
+			return link, b, nil
+		}
+		if relocate || opt.NoSIDRelocation || forced {
+			break
+		}
+		relocate = true
+	}
+	if lastErr != nil {
+		return nil, displayerBank{}, fmt.Errorf("injectSID failed: %w", lastErr)
+	}
+	return nil, displayerBank{}, fmt.Errorf("sid %q (%s - %s) overlaps the displayer in all vic banks", opt.IncludeSID, Word(start), Word(end))
+}
@@ -0,0 +1,106 @@
+package png2prg
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayout(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, memoryLayout{0x2000, 0x3f40, 0x4328, 0x4710}, Options{}.bitmapLayout())
+	assert.Equal(t, memoryLayout{0x2000, 0x2800, 0x2c00, 0x2fe8}, Options{}.charsetLayout())
+	assert.Equal(t, memoryLayout{0x6000, 0x4400, 0x47e8, 0x4bd0}, Options{BitmapAddress: 0x6000, ScreenRAMAddress: 0x4400}.bitmapLayout())
+	assert.Equal(t, memoryLayout{0x4800, 0x5000, 0x5400, 0x57e8}, Options{CharsetAddress: 0x4800}.charsetLayout())
+
+	type tc struct {
+		opt   Options
+		valid bool
+	}
+	testCases := []tc{
+		{Options{}, true},
+		{Options{BitmapAddress: 0x6000, ScreenRAMAddress: 0x5c00, ColorRAMAddress: 0x8000}, true},
+		{Options{BitmapAddress: 0xe000, ScreenRAMAddress: 0xc000}, true},
+		{Options{CharsetAddress: 0x3800, ScreenRAMAddress: 0x0400}, true},
+		{Options{BitmapAddress: 0x4400}, false},
+		{Options{BitmapAddress: 0x8000}, false},
+		{Options{CharsetAddress: 0x1800}, false},
+		{Options{CharsetAddress: 0x4400}, false},
+		{Options{ScreenRAMAddress: 0x9400}, false},
+		{Options{ScreenRAMAddress: 0x5c10}, false},
+		{Options{ColorRAMAddress: 0xfe00}, false},
+		{Options{BitmapAddress: 0xe000}, false},
+		{Options{ScreenRAMAddress: 0xfc00}, false},
+		{Options{ColorRAMAddress: 0xfc18}, false},
+		{Options{BitmapAddress: 0xe000, ScreenRAMAddress: 0xc000, ColorRAMAddress: 0xfc10}, true},
+		{Options{BitmapAddress: 0x6000, Display: true}, false},
+	}
+	for _, c := range testCases {
+		err := c.opt.validateLayout()
+		if c.valid {
+			assert.Nil(t, err, "%+v", c.opt)
+		} else {
+			assert.NotNil(t, err, "%+v", c.opt)
+		}
+	}
+
+	m := Options{BitmapAddress: 0x6000, ScreenRAMAddress: 0x0400}.bitmapLayout()
+	assert.NotNil(t, m.validateBank(Options{ScreenRAMAddress: 0x0400}, "bitmap"))
+	m = Options{BitmapAddress: 0x6000, ScreenRAMAddress: 0x4400}.bitmapLayout()
+	assert.Nil(t, m.validateBank(Options{ScreenRAMAddress: 0x4400}, "bitmap"))
+}
+
+func TestLinkDisplayer(t *testing.T) {
+	t.Parallel()
+	data := func(m memoryLayout) LinkMap {
+		return LinkMap{
+			m.Bitmap:   make([]byte, 8000),
+			m.Screen:   make([]byte, FullScreenChars),
+			m.ColorRAM: make([]byte, FullScreenChars),
+			m.Colors:   []byte{0},
+		}
+	}
+	type tc struct {
+		sid   string
+		bank  int
+		force bool
+		want  int
+	}
+	testCases := []tc{
+		{"", 0, false, 0},
+		{"testdata/512_Rap.sid", 0, false, 0},
+		{"testdata/All_in_the_Past.sid", 0, false, 1},
+		{"testdata/All_in_the_Past.sid", 0, true, 0},
+		{"testdata/All_in_the_Past.sid", 2, false, 2},
+		{"testdata/Ocean_Reloaded_90.sid", 0, false, 0},
+		{"", 0, true, 0},
+		{"", 2, true, 2},
+	}
+	for _, c := range testCases {
+		l, b, err := linkDisplayer(Options{IncludeSID: c.sid, DisplayerBank: c.bank, ForceDisplayerBank: c.force, Quiet: true}, koalaBanks, data)
+		if assert.NoError(t, err, "%+v", c) {
+			assert.Equal(t, c.want, b.bank, "%+v", c)
+			assert.NotNil(t, l)
+		}
+	}
+	_, _, err := linkDisplayer(Options{IncludeSID: "testdata/Ocean_Reloaded_90.sid", DisplayerBank: 1, Quiet: true, NoSIDRelocation: true}, koalaBanks, data)
+	assert.Error(t, err)
+	l, b, err := linkDisplayer(Options{IncludeSID: "testdata/Ocean_Reloaded_90.sid", DisplayerBank: 1, Quiet: true}, koalaBanks, data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, b.bank)
+		assert.NotEqual(t, Word(0x90), l.payload[DisplayerSettingsStart+3], "relocated init address")
+	}
+}
+
+func TestDisplayerBankSymbols(t *testing.T) {
+	t.Parallel()
+	opt := Options{Quiet: true, Display: true, NoCrunch: true, Symbols: true, IncludeSID: "testdata/All_in_the_Past.sid"}
+	c, err := NewFromPath(opt, "testdata/floris_untitled.png")
+	require.NoError(t, err)
+	_, err = c.WriteTo(io.Discard)
+	require.NoError(t, err)
+	require.Contains(t, c.Symbols, c64Symbol{"bitmap", 0x6000})
+	assert.Contains(t, c.Symbols, c64Symbol{"screenram", 0x5400})
+}
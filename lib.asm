@@ -23,6 +23,36 @@
 		bmi !-
 }
 
+// mute_sids silences the sid at $d400 and the extra sids set in music_sids.
+// Expects a = 0 and the io area to be visible.
+.macro mute_sids() {
+		sta $d418
+		ldx #1
+	!loop:
+		lda music_sids,x
+		beq !next+
+		pha
+		asl
+		asl
+		asl
+		asl
+		sta !smc+ + 1
+		pla
+		lsr
+		lsr
+		lsr
+		lsr
+		ora #$d0
+		sta !smc+ + 2
+		lda #0
+		ldy #$18
+	!smc:
+		sta $d418,y
+	!next:
+		dex
+		bpl !loop-
+}
+
 .macro setBank(addr) {
 	lda #toDD00(addr)
 	sta $dd00
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
 )
 
 type Word uint16
@@ -33,6 +34,7 @@ type Linker struct {
 	payload [MaxMemory + 1]byte
 	block   [MaxMemory + 1]bool
 	used    [MaxMemory + 1]bool
+	names   []namedArea
 }
 
 // NewLinker returns an empty linker with cursor set to start. When verbose is true, WriteTo also writes the memory map to os.Stdout.
@@ -54,6 +56,16 @@ func (l *Linker) Block(start, end Word) {
 	}
 }
 
+// Overlaps returns true if any byte in the memory area from start with length is used or blocked.
+func (l *Linker) Overlaps(start Word, length int) bool {
+	for i := int(start); i < int(start)+length && i <= MaxMemory; i++ {
+		if l.used[i] || l.block[i] {
+			return true
+		}
+	}
+	return false
+}
+
 // Cursor returns the current cursor or memory address where the next Write will be stored.
 func (l *Linker) Cursor() Word {
 	return l.cursor
@@ -80,10 +92,22 @@ func (l *Linker) CursorWrite(cursor Word, b []byte) (n int, err error) {
 
 type LinkMap map[Word][]byte
 
-// MapWrite writes all byteslices to the linker at their respective addresses.
+// WriteMap writes all byteslices to the linker at their respective addresses, in order of address.
+// Returns an error describing both blocks if byteslices in m overlap each other.
 func (l *Linker) WriteMap(m LinkMap) (n int, err error) {
-	for c, bin := range m {
-		p, err := l.CursorWrite(c, bin)
+	addrs := make([]Word, 0, len(m))
+	for c := range m {
+		addrs = append(addrs, c)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	for i := 1; i < len(addrs); i++ {
+		prev, cur := addrs[i-1], addrs[i]
+		if int(prev)+len(m[prev]) > int(cur) {
+			return n, fmt.Errorf("linker.WriteMap: memory overlap error, %s - %s overlaps %s - %s", prev, Word(int(prev)+len(m[prev])), cur, Word(int(cur)+len(m[cur])))
+		}
+	}
+	for _, c := range addrs {
+		p, err := l.CursorWrite(c, m[c])
 		n += p
 		if err != nil {
 			return n, err
@@ -48,3 +48,24 @@ func TestLinker(t *testing.T) {
 	assert.Equal(t, Word(0xffff), l.StartAddress())
 	assert.Equal(t, Word(0x0), l.EndAddress())
 }
+
+func TestLinkerWriteMapOverlap(t *testing.T) {
+	t.Parallel()
+	l := NewLinker(0, false)
+	_, err := l.WriteMap(LinkMap{
+		0x2000: make([]byte, 8000),
+		0x3f40: make([]byte, 1000),
+		0x4328: make([]byte, 1000),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, Word(0x2000), l.StartAddress())
+	assert.Equal(t, Word(0x4710), l.EndAddress())
+
+	l = NewLinker(0, false)
+	_, err = l.WriteMap(LinkMap{
+		0x2000: make([]byte, 8000),
+		0x3c00: make([]byte, 1000),
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "0x3c00")
+}
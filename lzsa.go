@@ -0,0 +1,339 @@
+package png2prg
+
+import (
+	"fmt"
+)
+
+// lzsa1 crunches raw blocks in the lzsa1 format, byte compatible with lzsa -r -f1.
+// See lzsa_boot.asm for details.
+//
+// https://github.com/emmanuel-marty/lzsa
+const (
+	lzMinMatch      = 3
+	lzMaxOffset     = 0xffff
+	lzMaxLength     = 0xffff
+	lzShortOffset   = 0x100
+	lzChainDepth    = 64
+	lzGoodLength    = 256 // stop searching for longer matches
+	lzEndOfDataCost = 5   // token, offset and 16 bit match length 0
+)
+
+// Addresses in the zeropage code of lzsa_boot.prg, patched when crunching.
+const (
+	lzBootCode     = 0x0820 // the zeropage code in lzsa_boot.prg, followed by the crunched data
+	lzBootZeroPage = 0x02   // the zeropage code is copied here
+	lzBootSrc      = 0x02
+	lzBootDst      = 0x04
+	lzBootPages    = 0x0c
+	lzBootMoveFrom = 0x11
+	lzBootMoveTo   = 0x14
+	lzBootJump     = 0x9b
+)
+
+// lzCruncher crunches raw lzsa1 blocks, decrunched by lzsa_boot.prg.
+type lzCruncher struct{}
+
+func (lzCruncher) String() string {
+	return CruncherLZSA1
+}
+
+func (lzCruncher) Crunch(prg []byte) ([]byte, error) {
+	if len(prg) < 3 {
+		return nil, fmt.Errorf("prg of %d bytes is too short to crunch", len(prg))
+	}
+	crunched, _ := lzCrunch(prg[2:])
+	return crunched, nil
+}
+
+// CrunchSFX appends the crunched data to lzsa_boot.prg. The boot moves the crunched data up in memory, so the
+// decrunched data never overwrites crunched data that is not read yet.
+func (lzCruncher) CrunchSFX(prg []byte, jumpTo Word) ([]byte, error) {
+	if len(prg) < 3 {
+		return nil, fmt.Errorf("prg of %d bytes is too short to crunch", len(prg))
+	}
+	dst := NewWord(prg[0], prg[1])
+	if dst < 0x0200 {
+		return nil, fmt.Errorf("cannot decrunch to %s, the boot uses zeropage and stack", dst)
+	}
+	crunched, margin := lzCrunch(prg[2:])
+	boot := append([]byte{}, lzsaBoot...)
+	from := 0x0801 + len(boot) - 2
+	to := int(dst) + margin
+	if to < from {
+		to = from
+	}
+	if to+len(crunched) > MaxMemory+1 {
+		return nil, fmt.Errorf("crunched data of %d bytes at %s does not fit in memory", len(crunched), Word(to))
+	}
+	pages := (len(crunched) + 0xff) >> 8
+	if pages > 0xff {
+		return nil, fmt.Errorf("crunched data of %d bytes is too long", len(crunched))
+	}
+
+	patch := func(addr int, bb ...byte) {
+		copy(boot[2+lzBootCode-0x0801+addr-lzBootZeroPage:], bb)
+	}
+	patch(lzBootSrc, Word(to).Bytes()...)
+	patch(lzBootDst, dst.Bytes()...)
+	patch(lzBootPages, byte(pages))
+	patch(lzBootMoveFrom, Word(from+len(crunched)-0x100).Bytes()...)
+	patch(lzBootMoveTo, Word(to+len(crunched)-0x100).Bytes()...)
+	patch(lzBootJump, jumpTo.Bytes()...)
+	return append(boot, crunched...), nil
+}
+
+type lzMatch struct {
+	offset int
+	length int
+}
+
+// lzLiteralsCost returns the number of extra bytes to encode n literals.
+func lzLiteralsCost(n int) int {
+	switch {
+	case n < 7:
+		return 0
+	case n < 0x100:
+		return 1
+	case n < 0x200:
+		return 2
+	}
+	return 3
+}
+
+// lzMatchCost returns the number of bytes to encode the offset and length of m, excluding the token.
+func lzMatchCost(m lzMatch) int {
+	n := 1
+	if m.offset > lzShortOffset {
+		n++
+	}
+	switch {
+	case m.length-lzMinMatch < 15:
+	case m.length < 0x100:
+		n++
+	case m.length < 0x200:
+		n += 2
+	default:
+		n += 3
+	}
+	return n
+}
+
+// lzFindMatches returns the longest match with a short offset and the longest match with any offset at each
+// position of src.
+func lzFindMatches(src []byte) (short, long []lzMatch) {
+	short, long = make([]lzMatch, len(src)), make([]lzMatch, len(src))
+	head := make([]int32, 1<<16)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, len(src))
+	hash := func(i int) int {
+		return int((uint32(src[i])<<16|uint32(src[i+1])<<8|uint32(src[i+2]))*2654435761) >> 16 & 0xffff
+	}
+	for i := 0; i+lzMinMatch <= len(src); i++ {
+		h := hash(i)
+		prev[i] = head[h]
+		head[h] = int32(i)
+
+		// continue long matches, instead of searching again.
+		if i > 0 && long[i-1].length > lzGoodLength {
+			long[i] = lzMatch{long[i-1].offset, long[i-1].length - 1}
+			if short[i-1].length > lzGoodLength {
+				short[i] = lzMatch{short[i-1].offset, short[i-1].length - 1}
+			}
+			continue
+		}
+		maxLength := len(src) - i
+		if maxLength > lzMaxLength {
+			maxLength = lzMaxLength
+		}
+		for j, depth := int(prev[i]), 0; j >= 0 && depth < lzChainDepth && i-j <= lzMaxOffset; j, depth = int(prev[j]), depth+1 {
+			l := 0
+			for l < maxLength && src[j+l] == src[i+l] {
+				l++
+			}
+			if l < lzMinMatch {
+				continue
+			}
+			if i-j <= lzShortOffset && l > short[i].length {
+				short[i] = lzMatch{i - j, l}
+			}
+			if l > long[i].length {
+				long[i] = lzMatch{i - j, l}
+			}
+			if l >= lzGoodLength || l == maxLength {
+				break
+			}
+		}
+	}
+	return short, long
+}
+
+// lzCrunch returns src crunched as a raw lzsa1 block, using an optimal parse of the matches found.
+// The returned margin is the minimum distance between the start of the decrunched and crunched data, for the
+// decrunched data not to overwrite crunched data that is not read yet when decrunching in place.
+func lzCrunch(src []byte) (crunched []byte, margin int) {
+	n := len(src)
+	short, long := lzFindMatches(src)
+
+	// after[p] is the cost of the cheapest match at p and everything after it.
+	// cost[i] is the cost of src[i:], starting with a command at i.
+	const inf = 1 << 30
+	after := make([]int, n+1)
+	best := make([]lzMatch, n+1)
+	cost := make([]int, n+1)
+	literals := make([]int, n+1) // -1 means the end of data command with all literals
+	cost[n] = lzEndOfDataCost
+	literals[n] = -1
+	after[n] = inf
+
+	// the windows keep the positions p in i+7 to i+255 and i+256 to i+511 with increasing after[p]+p, for literal
+	// runs with 1 and 2 extra bytes.
+	// tail is the position p from i+512 with the lowest after[p]+p, for literal runs with 3 extra bytes.
+	windows := [2]lzWindow{{first: 7, last: 0xff}, {first: 0x100, last: 0x1ff}}
+	tail := -1
+	g := func(p int) int {
+		if after[p] == inf {
+			return inf
+		}
+		return after[p] + p
+	}
+	for i := n - 1; i >= 0; i-- {
+		after[i] = inf
+		for _, m := range [2]lzMatch{short[i], long[i]} {
+			if m.length < lzMinMatch {
+				continue
+			}
+			try := func(l int) {
+				if l > m.length {
+					l = m.length
+				}
+				mm := lzMatch{m.offset, l}
+				if c := lzMatchCost(mm) + cost[i+l]; c < after[i] {
+					after[i] = c
+					best[i] = mm
+				}
+			}
+			for l := lzMinMatch; l <= 18 && l <= m.length; l++ {
+				try(l)
+			}
+			try(0xff)
+			try(0x1ff)
+			try(m.length)
+		}
+
+		for w := range windows {
+			windows[w].slide(i, n, g)
+		}
+		if p := i + 0x200; p < n && (tail < 0 || g(p) <= g(tail)) {
+			tail = p
+		}
+
+		cost[i] = 1 + lzLiteralsCost(n-i) + n - i + lzEndOfDataCost - 1
+		literals[i] = -1
+		consider := func(p int) {
+			if p < n && after[p] != inf {
+				k := p - i
+				if c := 1 + lzLiteralsCost(k) + k + after[p]; c < cost[i] {
+					cost[i] = c
+					literals[i] = k
+				}
+			}
+		}
+		for k := 0; k < 7; k++ {
+			consider(i + k)
+		}
+		for _, w := range windows {
+			if len(w.q) > 0 {
+				consider(w.q[0])
+			}
+		}
+		if tail >= 0 {
+			consider(tail)
+		}
+	}
+
+	crunched = make([]byte, 0, cost[0])
+	// writeLength writes the extra length bytes of n, long is the extra byte value for a 16 bit length, which is
+	// the base subtracted from 256. The next value means 256 plus the next byte.
+	writeLength := func(n, base int) {
+		long := byte(0x100 - base)
+		switch {
+		case n < 0x100:
+			crunched = append(crunched, byte(n-base))
+		case n < 0x200:
+			crunched = append(crunched, long+1, byte(n))
+		default:
+			crunched = append(crunched, long, byte(n), byte(n>>8))
+		}
+	}
+	for i := 0; ; {
+		if d := i - len(crunched); d > margin {
+			margin = d
+		}
+		k := literals[i]
+		if k < 0 {
+			k = n - i
+		}
+		token := byte(k) << 4
+		if k >= 7 {
+			token = 7 << 4
+		}
+		if literals[i] < 0 {
+			crunched = append(crunched, token|0x0f)
+			if k >= 7 {
+				writeLength(k, 7)
+			}
+			crunched = append(crunched, src[i:]...)
+			crunched = append(crunched, 0x00, 0x100-18, 0x00, 0x00)
+			break
+		}
+		m := best[i+k]
+		if m.offset > lzShortOffset {
+			token |= 0x80
+		}
+		if m.length-lzMinMatch < 15 {
+			token |= byte(m.length - lzMinMatch)
+		} else {
+			token |= 0x0f
+		}
+		crunched = append(crunched, token)
+		if k >= 7 {
+			writeLength(k, 7)
+		}
+		crunched = append(crunched, src[i:i+k]...)
+		offset := -m.offset
+		crunched = append(crunched, byte(offset))
+		if m.offset > lzShortOffset {
+			crunched = append(crunched, byte(offset>>8))
+		}
+		if m.length-lzMinMatch >= 15 {
+			writeLength(m.length, 18)
+		}
+		i += k + m.length
+	}
+	if d := n - len(crunched); d > margin {
+		margin = d
+	}
+	return crunched, margin
+}
+
+// lzWindow keeps the positions first to last bytes after the current position in a monotonic queue, with the
+// lowest g(p) in front.
+type lzWindow struct {
+	first, last int
+	q           []int
+}
+
+// slide moves the window to position i of n bytes.
+func (w *lzWindow) slide(i, n int, g func(p int) int) {
+	if p := i + w.first; p < n {
+		for len(w.q) > 0 && g(w.q[len(w.q)-1]) >= g(p) {
+			w.q = w.q[:len(w.q)-1]
+		}
+		w.q = append(w.q, p)
+	}
+	for len(w.q) > 0 && w.q[0] > i+w.last {
+		w.q = w.q[1:]
+	}
+}
@@ -0,0 +1,184 @@
+.import source "lib.asm"
+
+// self-extracting boot for a raw lzsa1 block, as crunched by lzsa -r -f1.
+// the boot copies the zeropage code into place, which moves the crunched data up in memory, decrunches it in place
+// and jumps to the start address. png2prg appends the crunched data and patches the settings in the zeropage code.
+//
+// https://github.com/emmanuel-marty/lzsa/blob/master/BlockFormat_LZSA1.md
+// an lzsa1 block is a sequence of commands, each starting with a token byte: O|LLL|MMMM
+// LLL is the number of literals 0-6, 7 means an extra byte follows: 0-248 = 7-255 literals,
+// 250 = 256 plus the next byte, 249 = 16 bit count.
+// the literals follow, then the match offset as a negative number: 8 bit when O is 0, 16 bit when O is 1.
+// MMMM is the match length minus 3, 15 means an extra byte follows: 0-237 = 18-255 bytes,
+// 239 = 256 plus the next byte, 238 = 16 bit length.
+// a 16 bit match length of 0 ends the block.
+
+.pc = $0801 "basic upstart"
+		.byte <basicend, >basicend, <year(), >year(), $9e
+		.text toIntString(start)
+basicend:
+		.byte 0, 0, 0
+
+.pc = * "start"
+start:
+		sei
+		lda #$34
+		sta $01
+		ldx #zp_end - zp_start
+	!:	lda zp_source - 1,x
+		sta zp_start - 1,x
+		dex
+		bne !-
+		jmp move
+
+zp_source:
+.pseudopc $02 {
+zp_start:
+src:
+		.word 0                 // crunched data after moving, set by png2prg
+dst:
+		.word 0                 // decrunch address, set by png2prg
+match:
+		.word 0
+len:
+		.word 0
+token:
+		.byte 0
+
+// move copies the crunched data backwards a page at a time, from the end of the loaded file to the end of the
+// crunched data in its final place.
+move:
+		ldx #0                  // number of pages, set by png2prg
+		ldy #0
+	!:	dey
+move_from:
+		lda $ffff,y             // last page of the loaded data, set by png2prg
+move_to:
+		sta $ffff,y             // last page of the moved data, set by png2prg
+		tya
+		bne !-
+		dec move_from + 2
+		dec move_to + 2
+		dex
+		bne !-
+
+next_token:
+		jsr get_byte
+		sta token
+		lsr
+		lsr
+		lsr
+		lsr
+		and #7
+		beq match_offset        // no literals
+		cmp #7
+		bcc !+
+		jsr get_length
+		jmp copy_literals
+	!:	sta len
+		lda #0
+		sta len + 1
+copy_literals:
+		jsr get_byte
+		jsr put_byte
+		jsr dec_len
+		bne copy_literals
+
+match_offset:
+		jsr get_byte
+		sta match
+		lda #$ff
+		bit token               // bit 7 = 16 bit offset
+		bpl !+
+		jsr get_byte
+	!:	sta match + 1
+		clc
+		lda match
+		adc dst
+		sta match
+		lda match + 1
+		adc dst + 1
+		sta match + 1
+
+		lda token
+		and #$0f
+		cmp #$0f
+		bcc !+
+		lda #18
+		jsr get_length
+		lda len
+		ora len + 1
+		beq done                // 16 bit length 0 = end of data
+		bne copy_match
+	!:	adc #3
+		sta len
+		lda #0
+		sta len + 1
+copy_match:
+		ldy #0
+		lda (match),y
+		inc match
+		bne !+
+		inc match + 1
+	!:	jsr put_byte
+		jsr dec_len
+		bne copy_match
+		beq next_token
+
+done:
+		lda #$37                // interrupts stay disabled until the displayer is set up
+		sta $01
+jump:
+		jmp $0000               // start address, set by png2prg
+
+// get_length reads the extra length byte and adds it to base a. a sum of 256 means a 16 bit length follows,
+// a higher sum means the next byte plus 256 follows.
+get_length:
+		sta len
+		lda #0
+		sta len + 1
+		jsr get_byte
+		clc
+		adc len
+		beq !long+              // the sum is 0 only with carry set, base 0 is never used
+		sta len
+		bcc !+
+		jsr get_byte
+		sta len
+		inc len + 1
+	!:	rts
+!long:
+		jsr get_byte
+		sta len
+		jsr get_byte
+		sta len + 1
+		rts
+
+get_byte:
+		ldy #0
+		lda (src),y
+		inc src
+		bne !+
+		inc src + 1
+	!:	rts
+
+put_byte:
+		ldy #0
+		sta (dst),y
+		inc dst
+		bne !+
+		inc dst + 1
+	!:	rts
+
+// dec_len decrements len and returns with the zero flag set when it reaches 0.
+dec_len:
+		lda len
+		bne !+
+		dec len + 1
+	!:	dec len
+		lda len
+		ora len + 1
+		rts
+zp_end:
+}
+crunched_data:
@@ -0,0 +1,105 @@
+package png2prg
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runSFX runs the self-extracting sfx until it jumps to jumpTo and returns the cpu.
+func runSFX(t *testing.T, sfx []byte, jumpTo Word) *cpu6502 {
+	t.Helper()
+	c := newCPU6502()
+	c.load(uint16(NewWord(sfx[0], sfx[1])), sfx[2:], false)
+	c.pc = 0x080d
+	for steps := 0; c.pc != uint16(jumpTo); steps++ {
+		require.Less(t, steps, 20000000, "sfx did not jump to %s", jumpTo)
+		require.NoError(t, c.step())
+	}
+	return c
+}
+
+func TestLZSACrunchSFX(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(64))
+	data := make([]byte, 300)
+	for i := 0; i < 40; i++ {
+		data = append(data, data[len(data)-r.Intn(1000)%len(data):][:r.Intn(30)]...)
+		for j := r.Intn(8); j > 0; j-- {
+			data = append(data, byte(r.Intn(4)))
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		data = append(data, byte(r.Intn(256)))
+	}
+	data = append(data, bytes.Repeat([]byte{0x55, 0xaa}, 3000)...)
+	data = append(data, data[100:5100]...)
+	prg := append(Word(0x0801).Bytes(), data...)
+
+	sfx, err := lzCruncher{}.CrunchSFX(prg, 0x0828)
+	require.NoError(t, err)
+	assert.Less(t, len(sfx), len(prg)*2/3)
+	c := runSFX(t, sfx, 0x0828)
+	assert.Equal(t, data, c.mem[0x0801:0x0801+len(data)])
+	assert.Equal(t, byte(0x37), c.mem[1])
+
+	_, err = lzCruncher{}.CrunchSFX(append(Word(0x00c0).Bytes(), data...), 0x0828)
+	assert.Error(t, err)
+}
+
+func TestLZSABlockFormat(t *testing.T) {
+	t.Parallel()
+	// 1 literal, a match of 9 at offset -1, the end of data with 0 literals, a fake offset and a 16 bit length 0
+	crunched, _ := lzCrunch(bytes.Repeat([]byte{'a'}, 10))
+	assert.Equal(t, []byte{0x16, 'a', 0xff, 0x0f, 0x00, 0xee, 0x00, 0x00}, crunched)
+
+	// literal runs and match lengths around the extra byte encodings
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{7, 18, 255, 256, 257, 511, 512, 1000} {
+		literals := make([]byte, n)
+		r.Read(literals)
+		data := append(append([]byte{}, literals...), literals[:n-n%3]...)
+		prg := append(Word(0x1000).Bytes(), data...)
+		sfx, err := lzCruncher{}.CrunchSFX(prg, 0x1000)
+		require.NoError(t, err)
+		c := runSFX(t, sfx, 0x1000)
+		assert.Equal(t, data, c.mem[0x1000:0x1000+len(data)], "length %d", n)
+	}
+}
+
+func TestLZSACrunchDisplayer(t *testing.T) {
+	t.Parallel()
+	opt := Options{Quiet: true, Display: true, NoCrunch: true, IncludeSID: testSID}
+	c, err := NewFromPath(opt, inFile)
+	require.NoError(t, err)
+	want := &bytes.Buffer{}
+	_, err = c.WriteTo(want)
+	require.NoError(t, err)
+
+	opt.NoCrunch = false
+	opt.Cruncher = CruncherLZSA1
+	c, err = NewFromPath(opt, inFile)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	_, err = c.WriteTo(buf)
+	require.NoError(t, err)
+	sfx := buf.Bytes()
+	assert.Less(t, len(sfx), want.Len())
+
+	cpu := runSFX(t, sfx, displayerJumpTo)
+	prg := want.Bytes()
+	start := int(NewWord(prg[0], prg[1]))
+	assert.Equal(t, prg[2:], cpu.mem[start:start+len(prg)-2])
+
+	opt.Cruncher = "exomizer"
+	_, err = NewFromPath(opt, inFile)
+	assert.Error(t, err)
+
+	opt.Cruncher = CruncherLZSA1
+	opt.Slideshow = true
+	_, err = NewFromPath(opt, inFile)
+	assert.Error(t, err)
+}
@@ -0,0 +1,263 @@
+package png2prg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+)
+
+// Kinds of memory segments in a MemoryMap.
+const (
+	SegmentUsed    = "used"    // linked into the .prg
+	SegmentBlocked = "blocked" // kept free for the displayer, like the generated fade code
+	SegmentFree    = "free"
+)
+
+// A MemorySegment is a named area of memory from Start up to, but not including, End.
+type MemorySegment struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Length int    `json:"length"`
+}
+
+func (s MemorySegment) String() string {
+	return fmt.Sprintf("%s %s - %s (%d bytes)", s.Name, Word(s.Start), Word(s.End-1), s.Length)
+}
+
+// A MemoryMap contains the segments of all 64K memory, in order of address.
+type MemoryMap struct {
+	Segments []MemorySegment `json:"segments"`
+}
+
+// A namedArea is an area of memory named with Linker.Name.
+type namedArea struct {
+	start, end int
+	name       string
+}
+
+// Name names the memory area from start to end in the memory map, like "bitmap" or "fade".
+// Later names take precedence over earlier names of overlapping areas, an end of 0 names up to $ffff.
+func (l *Linker) Name(start, end Word, name string) {
+	e := int(end)
+	if e == 0 {
+		e = MaxMemory + 1
+	}
+	l.names = append(l.names, namedArea{int(start), e, name})
+}
+
+// NameMap names the blocks of m that have a name in names.
+func (l *Linker) NameMap(m LinkMap, names map[Word]string) {
+	for addr, name := range names {
+		if b, ok := m[addr]; ok && len(b) > 0 {
+			l.Name(addr, Word(int(addr)+len(b)), name)
+		}
+	}
+}
+
+// WriteNamedPrg writes the prg like WritePrg and names its memory area in the memory map.
+func (l *Linker) WriteNamedPrg(prg []byte, name string) (n int, err error) {
+	if n, err = l.WritePrg(prg); err != nil {
+		return n, err
+	}
+	l.Name(NewWord(prg[0], prg[1]), l.cursor, name)
+	return n, nil
+}
+
+// WriteNamedMap writes m like WriteMap and names the blocks of m that have a name in names.
+func (l *Linker) WriteNamedMap(m LinkMap, names map[Word]string) (n int, err error) {
+	if n, err = l.WriteMap(m); err != nil {
+		return n, err
+	}
+	l.NameMap(m, names)
+	return n, nil
+}
+
+// MemoryMap returns the MemoryMap of l. Unnamed used memory is named "data", unnamed blocked memory "blocked".
+func (l *Linker) MemoryMap() MemoryMap {
+	var label [MaxMemory + 1]uint16
+	for i, a := range l.names {
+		for j := a.start; j < a.end && j <= MaxMemory; j++ {
+			label[j] = uint16(i + 1)
+		}
+	}
+	segment := func(addr int) (kind, name string) {
+		switch {
+		case l.used[addr]:
+			kind, name = SegmentUsed, "data"
+		case l.block[addr]:
+			kind, name = SegmentBlocked, "blocked"
+		default:
+			return SegmentFree, "free"
+		}
+		if label[addr] > 0 {
+			name = l.names[label[addr]-1].name
+		}
+		return kind, name
+	}
+	m := MemoryMap{}
+	for addr := 0; addr <= MaxMemory; addr++ {
+		kind, name := segment(addr)
+		if n := len(m.Segments); n > 0 && m.Segments[n-1].Kind == kind && m.Segments[n-1].Name == name {
+			m.Segments[n-1].End++
+			m.Segments[n-1].Length++
+			continue
+		}
+		m.Segments = append(m.Segments, MemorySegment{Name: name, Kind: kind, Start: addr, End: addr + 1, Length: 1})
+	}
+	return m
+}
+
+// Free returns the free segments of m of at least length bytes.
+func (m MemoryMap) Free(length int) (free []MemorySegment) {
+	for _, s := range m.Segments {
+		if s.Kind == SegmentFree && s.Length >= length {
+			free = append(free, s)
+		}
+	}
+	return free
+}
+
+// WriteTo writes m in json format to w.
+func (m MemoryMap) WriteTo(w io.Writer) (n int64, err error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return n, fmt.Errorf("json.MarshalIndent failed: %w", err)
+	}
+	k, err := w.Write(append(b, '\n'))
+	return int64(k), err
+}
+
+// Memory chart formats for MemoryMap.WriteChartTo.
+const (
+	MemoryChartPNG = "png"
+	MemoryChartSVG = "svg"
+)
+
+// WriteChartTo writes m as a chart in format png or svg to w.
+// The png shows a pixel for each byte, a row for each page. The svg shows a row for each 4K, with a legend.
+func (m MemoryMap) WriteChartTo(w io.Writer, format string) error {
+	switch format {
+	case MemoryChartPNG:
+		return m.writePNG(w)
+	case MemoryChartSVG:
+		return m.writeSVG(w)
+	}
+	return fmt.Errorf("unknown memory chart format %q, use %s or %s", format, MemoryChartPNG, MemoryChartSVG)
+}
+
+// chartColors returns the sorted segment names of m, except free, and their c64 colors of the vice palette.
+// Colors are assigned in order of address, free memory is black and unnamed blocked memory dark grey.
+func (m MemoryMap) chartColors() (names []string, colors map[string]color.RGBA) {
+	cycle := []C64Color{2, 5, 6, 7, 8, 10, 13, 14, 3, 4, 9, 12, 15, 1}
+	pal := paletteSources[0].Colors
+	colors = map[string]color.RGBA{"free": rgba(pal[0])}
+	for _, s := range m.Segments {
+		if _, ok := colors[s.Name]; ok {
+			continue
+		}
+		if s.Name == "blocked" {
+			colors[s.Name] = rgba(pal[11])
+		} else {
+			colors[s.Name] = rgba(pal[cycle[len(colors)%len(cycle)]])
+		}
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names, colors
+}
+
+// chartPixelSize is the size in pixels of each byte in the png memory chart.
+const chartPixelSize = 2
+
+func (m MemoryMap) writePNG(w io.Writer) error {
+	_, colors := m.chartColors()
+	img := image.NewRGBA(image.Rect(0, 0, 0x100*chartPixelSize, 0x100*chartPixelSize))
+	for _, s := range m.Segments {
+		col := colors[s.Name]
+		for addr := s.Start; addr < s.End; addr++ {
+			x, y := (addr&0xff)*chartPixelSize, (addr>>8)*chartPixelSize
+			for dy := 0; dy < chartPixelSize; dy++ {
+				for dx := 0; dx < chartPixelSize; dx++ {
+					img.SetRGBA(x+dx, y+dy, col)
+				}
+			}
+		}
+	}
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("png.Encode failed: %w", err)
+	}
+	return nil
+}
+
+// svg memory chart dimensions, each row shows 4K with 4 bytes per pixel.
+const (
+	svgRowBytes  = 0x1000
+	svgRowHeight = 24
+	svgLabels    = 56
+	svgWidth     = svgLabels + svgRowBytes/4
+)
+
+func (m MemoryMap) writeSVG(w io.Writer) error {
+	names, colors := m.chartColors()
+	rows := (MaxMemory + 1) / svgRowBytes
+	height := rows*svgRowHeight + (len(names)+1)*svgRowHeight
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"12\">\n", svgWidth, height)
+	hex := func(c color.RGBA) string {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	for row := 0; row < rows; row++ {
+		fmt.Fprintf(bw, "<text x=\"0\" y=\"%d\">%s</text>\n", row*svgRowHeight+16, Word(row*svgRowBytes))
+	}
+	for _, s := range m.Segments {
+		// a segment is split into a rect for each row it covers
+		for start := s.Start; start < s.End; {
+			row := start / svgRowBytes
+			end := (row + 1) * svgRowBytes
+			if s.End < end {
+				end = s.End
+			}
+			fmt.Fprintf(bw, "<rect x=\"%g\" y=\"%d\" width=\"%g\" height=\"%d\" fill=\"%s\"><title>%s</title></rect>\n",
+				svgLabels+float64(start%svgRowBytes)/4, row*svgRowHeight+2, float64(end-start)/4, svgRowHeight-4, hex(colors[s.Name]), s)
+			start = end
+		}
+	}
+	for i, name := range append(names, "free") {
+		y := (rows+i+1)*svgRowHeight - 4
+		fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"16\" height=\"16\" fill=\"%s\" stroke=\"gray\"/>\n", svgLabels, y-12, hex(colors[name]))
+		fmt.Fprintf(bw, "<text x=\"%d\" y=\"%d\">%s</text>\n", svgLabels+24, y, name)
+	}
+	fmt.Fprintln(bw, "</svg>")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("bufio.Flush failed: %w", err)
+	}
+	return nil
+}
+
+// linkable is implemented by converted images that link their data, and the displayer if enabled, in a Linker.
+// Converter.WriteTo keeps the Linker for MemoryMap.
+type linkable interface {
+	link() (*Linker, error)
+}
+
+// writeLinked links l and writes the resulting .prg to w.
+func writeLinked(w io.Writer, l linkable) (n int64, err error) {
+	link, err := l.link()
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// writeLinkTo writes link to w and keeps it for MemoryMap.
+func (c *Converter) writeLinkTo(w io.Writer, link *Linker) (n int64, err error) {
+	c.link = link
+	return link.WriteTo(w)
+}
@@ -0,0 +1,120 @@
+package png2prg
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryMap(t *testing.T) {
+	t.Parallel()
+	l := NewLinker(0, false)
+	_, err := l.WriteNamedPrg([]byte{0x01, 0x08, 1, 2, 3, 4}, "displayer")
+	require.NoError(t, err)
+	_, err = l.WriteNamedMap(LinkMap{0x2000: make([]byte, 0x100), 0x2100: {1}, 0x3000: {2}}, map[Word]string{0x2000: "bitmap"})
+	require.NoError(t, err)
+	l.Block(0x4000, 0x5000)
+	l.Block(0xf000, 0xff00)
+	l.Name(0xf000, 0, "fade")
+
+	m := l.MemoryMap()
+	expect := []MemorySegment{
+		{"free", SegmentFree, 0, 0x801, 0x801},
+		{"displayer", SegmentUsed, 0x801, 0x805, 4},
+		{"free", SegmentFree, 0x805, 0x2000, 0x2000 - 0x805},
+		{"bitmap", SegmentUsed, 0x2000, 0x2100, 0x100},
+		{"data", SegmentUsed, 0x2100, 0x2101, 1},
+		{"free", SegmentFree, 0x2101, 0x3000, 0x3000 - 0x2101},
+		{"data", SegmentUsed, 0x3000, 0x3001, 1},
+		{"free", SegmentFree, 0x3001, 0x4000, 0x4000 - 0x3001},
+		{"blocked", SegmentBlocked, 0x4000, 0x5000, 0x1000},
+		{"free", SegmentFree, 0x5000, 0xf000, 0xa000},
+		{"fade", SegmentBlocked, 0xf000, 0xff00, 0xf00},
+		{"free", SegmentFree, 0xff00, 0x10000, 0x100},
+	}
+	assert.Equal(t, expect, m.Segments)
+	assert.Equal(t, []MemorySegment{expect[2], expect[9]}, m.Free(0x1000))
+	assert.Equal(t, "bitmap 0x2000 - 0x20ff (256 bytes)", m.Segments[3].String())
+
+	buf := &bytes.Buffer{}
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+	var got MemoryMap
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, m, got)
+}
+
+func TestMemoryMapChart(t *testing.T) {
+	t.Parallel()
+	l := NewLinker(0, false)
+	_, err := l.WriteNamedMap(LinkMap{0x2000: make([]byte, 0x1800)}, map[Word]string{0x2000: "bitmap"})
+	require.NoError(t, err)
+	m := l.MemoryMap()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, m.WriteChartTo(buf, MemoryChartPNG))
+	img, err := png.Decode(buf)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 0x100*chartPixelSize, 0x100*chartPixelSize), img.Bounds())
+	_, colors := m.chartColors()
+	assert.Equal(t, colors["bitmap"], img.At(0, 0x20*chartPixelSize))
+	assert.Equal(t, colors["free"], img.At(0, 0))
+
+	buf.Reset()
+	require.NoError(t, m.WriteChartTo(buf, MemoryChartSVG))
+	svg := buf.String()
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, "<title>bitmap 0x2000 - 0x37ff (6144 bytes)</title>")
+
+	assert.Error(t, m.WriteChartTo(buf, "jpg"))
+}
+
+func TestConverterMemoryMap(t *testing.T) {
+	t.Parallel()
+	for _, noCrunch := range []bool{false, true} {
+		opt := Options{Quiet: true, Display: true, NoCrunch: noCrunch, IncludeSID: "testdata/Rivalry_tune_5.sid"}
+		c, err := NewFromPath(opt, "testdata/cisco_heat.png")
+		require.NoError(t, err)
+		_, ok := c.MemoryMap()
+		assert.False(t, ok)
+		_, err = c.WriteTo(&bytes.Buffer{})
+		require.NoError(t, err)
+		m, ok := c.MemoryMap()
+		require.True(t, ok, "nocrunch %v", noCrunch)
+		names := map[string]bool{}
+		for _, s := range m.Segments {
+			names[s.Name] = true
+		}
+		for _, name := range []string{"displayer", "bitmap", "screen", "colorram", "sid", "fade"} {
+			assert.True(t, names[name], "nocrunch %v: segment %q not found", noCrunch, name)
+		}
+	}
+}
+
+func TestConverterMemoryMapAnimation(t *testing.T) {
+	t.Parallel()
+	files, err := filepath.Glob("testdata/evoluer/PIC0[1-3].png")
+	require.NoError(t, err)
+	for _, noCrunch := range []bool{false, true} {
+		c, err := NewFromPath(Options{Quiet: true, Display: true, NoCrunch: noCrunch}, files...)
+		require.NoError(t, err)
+		_, err = c.WriteTo(&bytes.Buffer{})
+		require.NoError(t, err)
+		m, ok := c.MemoryMap()
+		require.True(t, ok, "nocrunch %v", noCrunch)
+		names := map[string]bool{}
+		for _, s := range m.Segments {
+			names[s.Name] = true
+		}
+		for _, name := range []string{"displayer", "animation frames", "zeropage"} {
+			assert.True(t, names[name], "nocrunch %v: segment %q not found", noCrunch, name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package png2prg
+
+import (
+	"fmt"
+)
+
+// The animation player is assembled at playerOrigin, using the zeropage addresses from playerZeroPageOrigin.
+// The reloc build is assembled at playerRelocOrigin and playerRelocZeroPage, the differences between both builds
+// are the bytes to change when relocating.
+const (
+	playerOrigin         = 0x1000
+	playerRelocOrigin    = 0x3000
+	playerZeroPageOrigin = 0x08
+	playerRelocZeroPage  = 0x10
+	playerZeroPageLength = 8
+)
+
+// Offsets of the entrypoints and settings of the animation player.
+const (
+	playerInit       = 0
+	playerStep       = 3
+	playerPlay       = 6
+	playerFrames     = 9
+	playerBitmapHigh = 11
+	playerScreenRAM  = 12
+	playerFrameDelay = 14
+	playerCharLength = 15
+)
+
+// relocatePlayer returns the animation player code relocated to the page aligned addr, using the zeropage addresses
+// from zp to zp+7.
+func relocatePlayer(addr Word, zp int) ([]byte, error) {
+	if addr.Low() != 0 {
+		return nil, fmt.Errorf("player address %s must be page aligned", addr)
+	}
+	if zp < 2 || zp+playerZeroPageLength > 0x100 {
+		return nil, fmt.Errorf("player zeropage %#02x is out of range, it must be in $02-$%02x", zp, 0x100-playerZeroPageLength)
+	}
+	a, b := animPlayer[2:], animPlayerReloc[2:]
+	if len(a) != len(b) {
+		return nil, fmt.Errorf("player builds differ in length: %d != %d", len(a), len(b))
+	}
+	if int(addr)+len(a) > MaxMemory+1 {
+		return nil, fmt.Errorf("player at %s with length %#04x does not fit in memory", addr, len(a))
+	}
+	code := make([]byte, len(a))
+	for i := range a {
+		switch b[i] - a[i] {
+		case 0:
+			code[i] = a[i]
+		case (playerRelocOrigin - playerOrigin) >> 8:
+			code[i] = a[i] - playerOrigin>>8 + addr.High()
+		case playerRelocZeroPage - playerZeroPageOrigin:
+			code[i] = a[i] - playerZeroPageOrigin + byte(zp)
+		default:
+			return nil, fmt.Errorf("cannot relocate byte %s of the player: %#02x != %#02x", Word(playerOrigin+i), a[i], b[i])
+		}
+	}
+	return code, nil
+}
+
+// linkAnimationPlayer links the first picture of the transitions tt located at m, followed by the frames and the
+// relocated player. charLength is the number of bytes per char in the frames.
+// The player is located at opt.PlayerAddress, or the first page after the frames.
+func linkAnimationPlayer(opt Options, m memoryLayout, picture LinkMap, imgs []Charer, tt []transition, delays []byte, charLength byte) (link *Linker, symbols []c64Symbol, err error) {
+	if err = m.validateBank(opt, "bitmap"); err != nil {
+		return nil, nil, err
+	}
+	link = NewLinker(m.Bitmap, opt.VeryVerbose)
+	if _, err = link.WriteNamedMap(picture, m.names(picture, "bitmap")); err != nil {
+		return nil, nil, fmt.Errorf("link.WriteMap error: %w", err)
+	}
+	start := link.EndAddress()
+	frames, err := processAnimation(opt, imgs, tt, delays)
+	if err != nil {
+		return nil, nil, fmt.Errorf("processAnimation failed: %w", err)
+	}
+	end, tableSymbols := appendFrameTable(opt, start, frames, animationEnd(opt, start, framesLength(frames)))
+	link.SetCursor(start)
+	for _, bin := range append(frames, end) {
+		if _, err = link.Write(bin); err != nil {
+			return nil, nil, fmt.Errorf("link.Write error: %w", err)
+		}
+	}
+	link.Name(start, link.Cursor(), "animation frames")
+	if !opt.Quiet {
+		fmt.Printf("memory usage for picture and animation: %s - %s\n", link.StartAddress(), link.EndAddress())
+	}
+
+	addr := Word(opt.PlayerAddress)
+	if addr == 0 {
+		next := (int(start) + framesLength(frames) + len(end) + 0xff) &^ 0xff
+		if next > MaxMemory {
+			return nil, nil, fmt.Errorf("no page left for the player after the animation frames, use -player-address")
+		}
+		addr = Word(next)
+	}
+	zp := opt.PlayerZeroPage
+	if zp == 0 {
+		zp = animationZeroPageStart
+	}
+	code, err := relocatePlayer(addr, zp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("relocatePlayer failed: %w", err)
+	}
+	code[playerFrames], code[playerFrames+1] = start.Low(), start.High()
+	code[playerBitmapHigh] = m.Bitmap.High()
+	code[playerScreenRAM], code[playerScreenRAM+1] = m.Screen.Low(), m.Screen.High()
+	code[playerFrameDelay] = byte(opt.FrameDelay)
+	code[playerCharLength] = charLength
+	if _, err = link.CursorWrite(addr, code); err != nil {
+		return nil, nil, fmt.Errorf("link.CursorWrite error: %w", err)
+	}
+	link.Name(addr, link.Cursor(), "player")
+	if !opt.Quiet {
+		fmt.Printf("memory usage for player: %s - %s, zeropage: $%02x - $%02x\n", addr, addr+Word(len(code)), zp, zp+playerZeroPageLength-1)
+	}
+
+	symbols = append([]c64Symbol{
+		{"animation", int(start)},
+		{"player_init", int(addr + playerInit)},
+		{"player_step", int(addr + playerStep)},
+		{"player_play", int(addr + playerPlay)},
+		{"player_zp", zp},
+	}, tableSymbols...)
+	return link, symbols, nil
+}
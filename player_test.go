@@ -0,0 +1,49 @@
+package png2prg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelocatePlayer(t *testing.T) {
+	t.Parallel()
+	code, err := relocatePlayer(playerOrigin, playerZeroPageOrigin)
+	require.NoError(t, err)
+	assert.Equal(t, animPlayer[2:], code)
+
+	code, err = relocatePlayer(0xc000, 0xf0)
+	require.NoError(t, err)
+	require.Len(t, code, len(animPlayer)-2)
+	for _, entry := range []int{playerInit, playerStep, playerPlay} {
+		assert.Equal(t, byte(0x4c), code[entry])
+		assert.Equal(t, byte(0xc0), code[entry+2]&0xf0)
+	}
+	// init loads the address of the first frame into the first zeropage address.
+	assert.Equal(t, []byte{0xad, playerFrames, 0xc0, 0x85, 0xf0}, code[playerCharLength+3:playerCharLength+8])
+
+	_, err = relocatePlayer(0xc080, 0xf0)
+	assert.Error(t, err)
+	_, err = relocatePlayer(0xc000, 0xf9)
+	assert.Error(t, err)
+	_, err = relocatePlayer(0xff00, 0x08)
+	assert.Error(t, err)
+}
+
+func TestLinkAnimationPlayerNoPageLeft(t *testing.T) {
+	t.Parallel()
+	opt := Options{Quiet: true}
+	kk := []Koala{{}, {}}
+	kk[1].Bitmap[0] = 1
+	tt, err := animationTransitions(opt, len(kk))
+	require.NoError(t, err)
+	m := memoryLayout{Bitmap: 0xe000}
+	_, _, err = linkAnimationPlayer(opt, m, LinkMap{m.Bitmap: kk[0].Bitmap[:]}, makeCharer(kk), tt, koalaFrameDelays(kk), 10)
+	assert.ErrorContains(t, err, "no page left")
+
+	opt.PlayerAddress = 0xc000
+	_, symbols, err := linkAnimationPlayer(opt, m, LinkMap{m.Bitmap: kk[0].Bitmap[:]}, makeCharer(kk), tt, koalaFrameDelays(kk), 10)
+	require.NoError(t, err)
+	assert.Contains(t, symbols, c64Symbol{"player_init", 0xc000 + playerInit})
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/gif"
 	_ "image/jpeg"
 	_ "image/png"
@@ -44,7 +45,7 @@ const (
 	CharsetColorRAMAddress  = 0x2c00
 
 	DisplayerSettingsStart = 0x081a
-	displayerJumpTo        = "$0828"
+	displayerJumpTo        = 0x0828
 )
 
 // An Options struct contains all settings to be used for an instance of png2prg.
@@ -72,6 +73,10 @@ type Options struct {
 	NoGuess             bool
 	GraphicsMode        string
 	Interlace           bool
+	// InterlaceFormat selects the interlace output format without displayer: drazlace, truepaint, hires or gunpaint.
+	// By default drazlace is used when the frames share screenram and colorram, truepaint otherwise, and hires for
+	// 2 hires images. The hires layout is specific to png2prg, gunpaint only applies to ifli.
+	InterlaceFormat     string
 	D016Offset          int
 	ForceBorderColor    int
 	IncludeSID          string
@@ -82,9 +87,92 @@ type Options struct {
 	ForceYOffset        int
 	CurrentGraphicsType GraphicsType
 
+	// Custom addresses of the data blocks, 0 means default.
+	// Blocks without a custom address follow the previous block.
+	BitmapAddress    int
+	CharsetAddress   int
+	ScreenRAMAddress int
+	ColorRAMAddress  int
+
+	// DisplayerBank forces the vic bank (1-3) of the picture in the koala and hires displayers, set ForceDisplayerBank
+	// to force bank 0. By default bank 0 is used unless the sid overlaps, then the first bank that fits is used.
+	DisplayerBank      int
+	ForceDisplayerBank bool
+
+	// NoSIDRelocation disables relocating sids that overlap the displayer, picture or zeropage addresses in use.
+	NoSIDRelocation bool
+
+	// SubTune selects the song (1-n) of the sid, 0 means the start song from the header.
+	SubTune int
+
+	// Slideshow treats multiple images as independent pictures instead of animation frames.
+	// Each picture is shown for SlideDelay seconds, 0 means until space is pressed.
+	Slideshow  bool
+	SlideDelay int
+
+	// NTSC converts the frame delays of animated gifs to 60 Hz frames instead of 50 Hz.
+	NTSC bool
+
+	// Playback is the animation playback mode: loop (default), pingpong or once.
+	// FrameOrder optionally sets the order of the frames, like "0,1,2,1,0,3".
+	Playback   string
+	FrameOrder string
+
+	// Keyframes inserts a full-screen keyframe every n frames and adds a frame offset table to animations.
+	Keyframes int
+
+	// Stream splits koala and hires animations into separately crunched segments, loaded from disk by the displayer.
+	Stream bool
+
+	// FitMemory drops the fade and truncates koala and hires animations when the frames do not fit in memory.
+	FitMemory bool
+
+	// SpriteSheet animates each row of a single sprite sheet as an object, with the sprites in the row as its frames.
+	SpriteSheet bool
+
+	// Player writes koala and hires animations with a relocatable player routine instead of the displayer.
+	// The player is located at PlayerAddress, 0 means the first page after the frames.
+	// It uses 8 zeropage addresses from PlayerZeroPage, 0 means $08.
+	Player         bool
+	PlayerAddress  int
+	PlayerZeroPage int
+
+	// Cruncher is the name of the cruncher used to pack the displayers and score brute-force results:
+	// tscrunch (default) or lzsa1. Streamed animations and slideshows only support tscrunch, as their displayers
+	// decrunch the segments with TSCrunch.
+	Cruncher string
+
+	// BruteForceHeuristics is a comma separated list of heuristics to permute in brute-force mode, besides the
+	// bitpair colors: npcc, nbc, npe, fpe or all. Each heuristic doubles the number of attempts.
+	BruteForceHeuristics string
+	// BruteForceStrategy is the brute-force search strategy: permute (default) tries all permutations of the 8 most
+	// used colors, climb hill-climbs over all colors of the image with restarts.
+	BruteForceStrategy string
+	// BruteForceBudget is the maximum number of brute-force attempts, 0 means all permutations for permute and 500
+	// for climb.
+	BruteForceBudget int
+	// BruteForceCache is the directory to store brute-force winners in, keyed by the pixels, graphics mode,
+	// png2prg version and all options that change the conversion. Unchanged images reuse the cached winner instead of
+	// brute forcing.
+	BruteForceCache string
+	// BruteForceObjective is a comma separated list of objectives to minimize in brute-force mode, each with an
+	// optional weight: crunched (default), raw, chars or delta. For example "crunched,chars:16".
+	BruteForceObjective string
+
 	Trd bool // has side effect of enforcing screenram colors in level area
 }
 
+// conversionOptions returns o without the settings that do not change the converted output, like logging, output
+// paths and the number of workers. New options change the output by default, so they are never left out by accident.
+func (o Options) conversionOptions() Options {
+	o.OutFile, o.TargetDir = "", ""
+	o.Verbose, o.VeryVerbose, o.Quiet = false, false, false
+	o.NumWorkers = 0
+	o.Symbols = false
+	o.BruteForceCache = ""
+	return o
+}
+
 func (o Options) NoFadeByte() byte {
 	if o.NoFade {
 		return 1
@@ -107,6 +195,8 @@ const (
 	mixedCharset
 	petsciiCharset
 	ecmCharset
+	singleColorInterlaceBitmap // 2 hires bitmaps, color flicker or pixel shift
+	interlaceFLIBitmap         // 2 multicolor fli bitmaps, color flicker
 )
 
 func StringToGraphicsType(s string) GraphicsType {
@@ -131,6 +221,10 @@ func StringToGraphicsType(s string) GraphicsType {
 		return petsciiCharset
 	case "ecm":
 		return ecmCharset
+	case "hiresinterlace":
+		return singleColorInterlaceBitmap
+	case "ifli":
+		return interlaceFLIBitmap
 	}
 	return unknownGraphicsType
 }
@@ -157,11 +251,20 @@ func (t GraphicsType) String() string {
 		return "petscii"
 	case ecmCharset:
 		return "ecm"
+	case singleColorInterlaceBitmap:
+		return "hiresinterlace"
+	case interlaceFLIBitmap:
+		return "ifli"
 	default:
 		return "unknown"
 	}
 }
 
+// blended returns true if t shows blended colors by alternating 2 frames of a single source image.
+func (t GraphicsType) blended() bool {
+	return t == singleColorInterlaceBitmap || t == interlaceFLIBitmap
+}
+
 type sourceImage struct {
 	sourceFilename  string
 	opt             Options
@@ -175,6 +278,7 @@ type sourceImage struct {
 	p               Palette
 	bpc             []*Color
 	bpcCache        [FullScreenChars]map[C64Color]byte
+	prevBpcCache    *[FullScreenChars]map[C64Color]byte
 	bpcBitpairCount [MaxColors]map[byte]int
 	border          Color
 	bg              Color
@@ -182,6 +286,7 @@ type sourceImage struct {
 	charColors      [FullScreenChars][]Color
 	sumColors       [MaxColors]int
 	ecmColors       []Color
+	blend           *blendPalette
 }
 
 func (img *sourceImage) At(x, y int) color.Color {
@@ -250,10 +355,15 @@ type Symbolser interface {
 }
 
 func (img Koala) Symbols() []c64Symbol {
+	return img.symbols(img.opt.bitmapLayout())
+}
+
+// symbols returns the symbols of img located at m.
+func (img Koala) symbols(m memoryLayout) []c64Symbol {
 	return []c64Symbol{
-		{"bitmap", BitmapAddress},
-		{"screenram", BitmapScreenRAMAddress},
-		{"colorram", BitmapColorRAMAddress},
+		{"bitmap", int(m.Bitmap)},
+		{"screenram", int(m.Screen)},
+		{"colorram", int(m.ColorRAM)},
 		{"d020color", int(img.BorderColor)},
 		{"d021color", int(img.BackgroundColor)},
 	}
@@ -268,9 +378,14 @@ type Hires struct {
 }
 
 func (img Hires) Symbols() []c64Symbol {
+	return img.symbols(img.opt.bitmapLayout())
+}
+
+// symbols returns the symbols of img located at m.
+func (img Hires) symbols(m memoryLayout) []c64Symbol {
 	return []c64Symbol{
-		{"bitmap", BitmapAddress},
-		{"screenram", BitmapScreenRAMAddress},
+		{"bitmap", int(m.Bitmap)},
+		{"screenram", int(m.Screen)},
 		{"d020color", int(img.BorderColor)},
 	}
 }
@@ -289,9 +404,10 @@ type MultiColorCharset struct {
 }
 
 func (img MultiColorCharset) Symbols() []c64Symbol {
+	m := img.opt.charsetLayout()
 	return []c64Symbol{
-		{"bitmap", BitmapAddress},
-		{"screenram", CharsetScreenRAMAddress},
+		{"bitmap", int(m.Bitmap)},
+		{"screenram", int(m.Screen)},
 		{"charcolor", int(img.CharColor)},
 		{"d020color", int(img.BorderColor)},
 		{"d021color", int(img.BackgroundColor)},
@@ -349,10 +465,11 @@ type SingleColorCharset struct {
 }
 
 func (img SingleColorCharset) Symbols() []c64Symbol {
+	m := img.opt.charsetLayout()
 	return []c64Symbol{
-		{"bitmap", BitmapAddress},
-		{"screenram", CharsetScreenRAMAddress},
-		{"colorram", CharsetColorRAMAddress},
+		{"bitmap", int(m.Bitmap)},
+		{"screenram", int(m.Screen)},
+		{"colorram", int(m.ColorRAM)},
 		{"d020color", int(img.BorderColor)},
 		{"d021color", int(img.BackgroundColor)},
 	}
@@ -408,10 +525,11 @@ type MixedCharset struct {
 }
 
 func (img MixedCharset) Symbols() []c64Symbol {
+	m := img.opt.charsetLayout()
 	return []c64Symbol{
-		{"bitmap", BitmapAddress},
-		{"screenram", CharsetScreenRAMAddress},
-		{"colorram", CharsetColorRAMAddress},
+		{"bitmap", int(m.Bitmap)},
+		{"screenram", int(m.Screen)},
+		{"colorram", int(m.ColorRAM)},
 		{"d020color", int(img.BorderColor)},
 		{"d021color", int(img.BackgroundColor)},
 		{"d022color", int(img.D022Color)},
@@ -467,9 +585,10 @@ type PETSCIICharset struct {
 }
 
 func (img PETSCIICharset) Symbols() []c64Symbol {
+	m := img.opt.charsetLayout()
 	return []c64Symbol{
-		{"screenram", CharsetScreenRAMAddress},
-		{"colorram", CharsetColorRAMAddress},
+		{"screenram", int(m.Screen)},
+		{"colorram", int(m.ColorRAM)},
 		{"d020color", int(img.BorderColor)},
 		{"d021color", int(img.BackgroundColor)},
 	}
@@ -489,10 +608,11 @@ type ECMCharset struct {
 }
 
 func (img ECMCharset) Symbols() []c64Symbol {
+	m := img.opt.charsetLayout()
 	return []c64Symbol{
-		{"bitmap", BitmapAddress},
-		{"screenram", CharsetScreenRAMAddress},
-		{"colorram", CharsetColorRAMAddress},
+		{"bitmap", int(m.Bitmap)},
+		{"screenram", int(m.Screen)},
+		{"colorram", int(m.ColorRAM)},
 		{"d020color", int(img.BorderColor)},
 		{"d021color", int(img.BackgroundColor)},
 		{"d022color", int(img.D022Color)},
@@ -554,6 +674,24 @@ var koalaDisplay []byte
 //go:embed "display_hires.prg"
 var hiresDisplay []byte
 
+//go:embed "display_koala_bank1.prg"
+var koalaDisplayBank1 []byte
+
+//go:embed "display_koala_bank2.prg"
+var koalaDisplayBank2 []byte
+
+//go:embed "display_koala_bank3.prg"
+var koalaDisplayBank3 []byte
+
+//go:embed "display_hires_bank1.prg"
+var hiresDisplayBank1 []byte
+
+//go:embed "display_hires_bank2.prg"
+var hiresDisplayBank2 []byte
+
+//go:embed "display_hires_bank3.prg"
+var hiresDisplayBank3 []byte
+
 //go:embed "display_mc_charset.prg"
 var mcCharsetDisplay []byte
 
@@ -587,9 +725,33 @@ var koalaDisplayAnimAlternative []byte
 //go:embed "display_hires_anim.prg"
 var hiresDisplayAnim []byte
 
+//go:embed "display_sprites_anim.prg"
+var spritesDisplayAnim []byte
+
+//go:embed "display_koala_anim_stream.prg"
+var koalaDisplayAnimStream []byte
+
+//go:embed "display_hires_anim_stream.prg"
+var hiresDisplayAnimStream []byte
+
 //go:embed "display_mci_bitmap.prg"
 var mciBitmapDisplay []byte
 
+//go:embed "display_hires_interlace.prg"
+var hiresInterlaceDisplay []byte
+
+//go:embed "display_ifli.prg"
+var ifliDisplay []byte
+
+//go:embed "display_mci_anim.prg"
+var mciDisplayAnim []byte
+
+//go:embed "anim_player.prg"
+var animPlayer []byte
+
+//go:embed "anim_player_reloc.prg"
+var animPlayerReloc []byte
+
 //go:embed "display_mixed_charset.prg"
 var mixedCharsetDisplay []byte
 
@@ -602,6 +764,12 @@ var petsciiCharsetDisplayAnim []byte
 //go:embed "display_ecm_charset.prg"
 var ecmCharsetDisplay []byte
 
+//go:embed "display_slideshow.prg"
+var slideshowDisplay []byte
+
+//go:embed "lzsa_boot.prg"
+var lzsaBoot []byte
+
 //go:embed "tools/rom_charset_lowercase.prg"
 var romCharsetLowercasePrg []byte
 
@@ -619,6 +787,8 @@ func init() {
 	displayers[mixedCharset] = mixedCharsetDisplay
 	displayers[petsciiCharset] = petsciiCharsetDisplay
 	displayers[ecmCharset] = ecmCharsetDisplay
+	displayers[singleColorInterlaceBitmap] = hiresInterlaceDisplay
+	displayers[interlaceFLIBitmap] = ifliDisplay
 }
 
 // newHeader returns a copy of the displayer code for GraphicsType t as a byte slice in .prg format.
@@ -634,6 +804,12 @@ type Converter struct {
 	images            []sourceImage
 	Symbols           []c64Symbol
 	FinalGraphicsType GraphicsType
+	// Segments contains the segments of a streamed animation, to be written to separate files.
+	Segments []StreamSegment
+	// BruteForceWinner contains the flags of the brute-force winner, like "-bpc 0,11,12,15 -npcc".
+	BruteForceWinner string
+	// link is the Linker of the .prg written by WriteTo, if any.
+	link *Linker
 }
 
 // New processes the input pngs and the returns the Converter.
@@ -649,6 +825,30 @@ func New(opt Options, pngs ...io.Reader) (*Converter, error) {
 	if opt.GraphicsMode != "" && opt.CurrentGraphicsType == unknownGraphicsType {
 		opt.CurrentGraphicsType = StringToGraphicsType(opt.GraphicsMode)
 	}
+	if err := opt.validateLayout(); err != nil {
+		return nil, fmt.Errorf("validateLayout failed: %w", err)
+	}
+	if opt.DisplayerBank < 0 || opt.DisplayerBank > 3 {
+		return nil, fmt.Errorf("incorrect vic bank %d, only 0-3 are allowed", opt.DisplayerBank)
+	}
+	if _, err := NewCruncher(opt.Cruncher); err != nil {
+		return nil, err
+	}
+	if opt.Cruncher == CruncherLZSA1 && (opt.Slideshow || opt.Stream) {
+		return nil, fmt.Errorf("-cruncher %s is not supported for slideshows and streamed animations, their displayers only decrunch tscrunch", opt.Cruncher)
+	}
+	if _, err := bruteForceVariants(opt, opt.BruteForceHeuristics, unknownGraphicsType); err != nil {
+		return nil, err
+	}
+	if err := validateBruteForceStrategy(opt.BruteForceStrategy); err != nil {
+		return nil, err
+	}
+	if _, err := parseBruteObjective(opt.BruteForceObjective); err != nil {
+		return nil, err
+	}
+	if err := validateInterlaceFormat(opt.InterlaceFormat); err != nil {
+		return nil, err
+	}
 	c := &Converter{opt: opt}
 	for index, ir := range pngs {
 		ii, err := NewSourceImages(opt, index, ir)
@@ -678,18 +878,20 @@ func NewSourceImages(opt Options, index int, r io.Reader) (imgs []sourceImage, e
 		if opt.Verbose {
 			log.Printf("file %q has %d frames", path, len(g.Image))
 		}
-		for i, rawImage := range g.Image {
+		for i, frame := range gifFrames(g) {
 			if opt.VeryVerbose {
 				log.Printf("processing frame %d", i)
 			}
 			img := sourceImage{
 				sourceFilename: path,
 				opt:            opt,
-				image:          rawImage,
+				image:          frame,
 			}
-			img.p, img.hiresPixels, err = NewPalette(img.image, false, opt.Verbose)
-			if err != nil {
-				return nil, fmt.Errorf("NewPalette failed: %w", err)
+			if g.Delay[i] > 0 {
+				img.opt.FrameDelay = gifDelayToFrames(g.Delay[i], opt.NTSC)
+			}
+			if err = img.setPalette(); err != nil {
+				return nil, fmt.Errorf("img.setPalette failed: %w", err)
 			}
 			if err = img.setPreferredBitpairColors(opt.BitpairColorsString); err != nil {
 				return nil, fmt.Errorf("setPreferredBitpairColors %q failed: %w", opt.BitpairColorsString, err)
@@ -719,9 +921,8 @@ func NewSourceImages(opt Options, index int, r io.Reader) (imgs []sourceImage, e
 	if err = img.checkBounds(); err != nil {
 		return nil, fmt.Errorf("img.checkBounds failed: %w", err)
 	}
-	img.p, img.hiresPixels, err = NewPalette(img.image, false, opt.Verbose)
-	if err != nil {
-		return nil, fmt.Errorf("NewPalette failed: %w", err)
+	if err = img.setPalette(); err != nil {
+		return nil, fmt.Errorf("img.setPalette failed: %w", err)
 	}
 	if err = img.setPreferredBitpairColors(opt.BitpairColorsString); err != nil {
 		return nil, fmt.Errorf("setPreferredBitpairColors %q failed: %w", opt.BitpairColorsString, err)
@@ -730,6 +931,62 @@ func NewSourceImages(opt Options, index int, r io.Reader) (imgs []sourceImage, e
 	return imgs, nil
 }
 
+// gifFrames composites the frames of g according to their disposal method and returns them as full size images.
+func gifFrames(g *gif.GIF) []image.Image {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	var bg color.Color = color.Transparent
+	if p, ok := g.Config.ColorModel.(color.Palette); ok && int(g.BackgroundIndex) < len(p) {
+		bg = p[g.BackgroundIndex]
+	}
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, image.NewUniform(bg), image.Point{}, draw.Src)
+
+	frames := make([]image.Image, 0, len(g.Image))
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		if g.Disposal != nil && g.Disposal[i] == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames = append(frames, cloneRGBA(canvas))
+		if g.Disposal == nil {
+			continue
+		}
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames
+}
+
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	c := *img
+	c.Pix = append([]byte(nil), img.Pix...)
+	return &c
+}
+
+// gifDelayToFrames converts the gif delay in 1/100s to the nearest number of PAL or NTSC frames, in range 1-255.
+func gifDelayToFrames(delay int, ntsc bool) int {
+	hz := 50
+	if ntsc {
+		hz = 60
+	}
+	frames := (delay*hz + 50) / 100
+	switch {
+	case frames < 1:
+		return 1
+	case frames > 0xff:
+		return 0xff
+	}
+	return frames
+}
+
 // NewSourceImage returns a new sourceImage after bounds check.
 func NewSourceImage(opt Options, index int, in image.Image) (img sourceImage, err error) {
 	img = sourceImage{
@@ -737,8 +994,8 @@ func NewSourceImage(opt Options, index int, in image.Image) (img sourceImage, er
 		opt:            opt,
 		image:          in,
 	}
-	if img.p, img.hiresPixels, err = NewPalette(img.image, false, opt.Verbose); err != nil {
-		return img, fmt.Errorf("NewPalette failed: %w", err)
+	if err = img.setPalette(); err != nil {
+		return img, fmt.Errorf("img.setPalette failed: %w", err)
 	}
 	if err = img.setPreferredBitpairColors(opt.BitpairColorsString); err != nil {
 		return img, fmt.Errorf("setPreferredBitpairColors %q failed: %w", opt.BitpairColorsString, err)
@@ -764,12 +1021,16 @@ func NewFromPath(opt Options, filenames ...string) (*Converter, error) {
 	return New(opt, in...)
 }
 
-// WriteTo processes the image(s) and writes the resulting .prg to w.
+// WriteTo processes the image(s) and writes the resulting .prg to out, see MemoryMap for its memory layout.
 // Returns error when analysis or conversion fails.
 func (c *Converter) WriteTo(w io.Writer) (n int64, err error) {
 	if len(c.images) == 0 {
 		return 0, fmt.Errorf("no images found")
 	}
+	c.link = nil
+	if c.opt.Slideshow {
+		return c.WriteSlideshowTo(w)
+	}
 	img := &c.images[0]
 	if c.opt.Verbose {
 		log.Printf("processing file %q", img.sourceFilename)
@@ -783,88 +1044,213 @@ func (c *Converter) WriteTo(w io.Writer) (n int64, err error) {
 		return 0, fmt.Errorf("analyze %q failed: %w", img.sourceFilename, err)
 	}
 
-	if (len(c.images) == 1 && img.graphicsType == multiColorInterlaceBitmap) || (len(c.images) == 2 && c.opt.Interlace) {
+	if img.blend != nil && len(c.images) > 1 {
+		return 0, fmt.Errorf("blended colors are only supported in a single image, not %d images", len(c.images))
+	}
+	if (len(c.images) == 1 && (img.graphicsType == multiColorInterlaceBitmap || img.graphicsType == singleColorInterlaceBitmap)) || (len(c.images) == 2 && c.opt.Interlace) {
 		if !c.opt.Quiet {
 			fmt.Printf("interlace mode\n")
 		}
-		var rgba0, rgba1 *image.RGBA
-		if img.graphicsType == multiColorInterlaceBitmap {
-			rgba0, rgba1 = img.SplitInterlace()
-			c.opt.ForceBorderColor = int(img.border.C64Color)
-			if !c.opt.Quiet {
-				fmt.Println("interlaced pic was split")
-			}
-			c.opt.CurrentGraphicsType = multiColorBitmap
-			c.opt.GraphicsMode = multiColorBitmap.String()
+		if err = c.splitInterlace(); err != nil {
+			return n, err
+		}
+		c.FinalGraphicsType = img.graphicsType
+		return c.WriteInterlaceTo(w)
+	}
+	if len(c.images) > 1 && (img.graphicsType == multiColorInterlaceBitmap || c.opt.Interlace) {
+		if !c.opt.Quiet {
+			fmt.Printf("interlace animation mode\n")
+		}
+		return c.WriteInterlaceAnimationTo(w)
+	}
+	if len(c.images) > 1 || (c.opt.SpriteSheet && c.opt.Display) {
+		return c.WriteAnimationTo(w)
+	}
 
-			i0, err := NewSourceImage(c.opt, 0, rgba0)
-			if err != nil {
-				return n, fmt.Errorf("NewSourceImages %q failed: %w", img.sourceFilename, err)
+	wt, err := c.convert()
+	if err != nil {
+		return 0, err
+	}
+	if c.opt.Display && c.opt.DisplayerBank != 0 {
+		switch wt.(type) {
+		case Koala, Hires:
+		default:
+			return 0, fmt.Errorf("vic bank %d is only supported by the koala and hires displayers, not %s", c.opt.DisplayerBank, img.graphicsType)
+		}
+	}
+
+	s, ok := wt.(Symbolser)
+	if c.opt.Symbols && !ok && len(c.Symbols) == 0 {
+		return 0, fmt.Errorf("symbols not supported %T for %q", wt, img.sourceFilename)
+	}
+	var symbols []c64Symbol
+	if ok {
+		symbols = s.Symbols()
+	}
+
+	switch l := wt.(type) {
+	case bankLinkable:
+		link, b, err := l.linkBank()
+		if err != nil {
+			return 0, fmt.Errorf("link %q failed: %w", img.sourceFilename, err)
+		}
+		if b.prg != nil {
+			symbols = l.symbols(b.layout)
+		}
+		c.link, wt = link, link
+	case linkable:
+		link, err := l.link()
+		if err != nil {
+			return 0, fmt.Errorf("link %q failed: %w", img.sourceFilename, err)
+		}
+		c.link, wt = link, link
+	}
+
+	t1 := time.Now()
+	if c.opt.Display && !c.opt.NoCrunch {
+		wt, err = injectCrunch(wt, c.opt)
+		if err != nil {
+			return 0, fmt.Errorf("injectCrunch failed: %w", err)
+		}
+	}
+	n, err = wt.WriteTo(w)
+	if err != nil {
+		return n, fmt.Errorf("WriteTo failed: %w", err)
+	}
+	if !c.opt.Quiet && c.opt.Display && !c.opt.NoCrunch {
+		fmt.Printf("crunched in %s\n", time.Since(t1))
+	}
+	if c.opt.Symbols {
+		c.Symbols = append(c.Symbols, symbols...)
+	}
+	return n, nil
+}
+
+// splitInterlace splits the first image into 2 koala images if it is a multicolor interlace image,
+// or into 2 hires images if it is a hires interlace image drawn in blended colors.
+// Then it analyzes both images for interlace conversion.
+func (c *Converter) splitInterlace() error {
+	img := &c.images[0]
+	var bg *Color
+	if img.graphicsType == multiColorInterlaceBitmap || img.graphicsType == singleColorInterlaceBitmap {
+		var rgba0, rgba1 *image.RGBA
+		var err error
+		c.opt.CurrentGraphicsType = multiColorBitmap
+		switch {
+		case img.graphicsType == singleColorInterlaceBitmap:
+			if rgba0, rgba1, err = img.SplitBlendHires(); err != nil {
+				return fmt.Errorf("img.SplitBlendHires %q failed: %w", img.sourceFilename, err)
 			}
-			i1, err := NewSourceImage(c.opt, 1, rgba1)
-			if err != nil {
-				return n, fmt.Errorf("NewSourceImages %q failed: %w", img.sourceFilename, err)
+			c.opt.CurrentGraphicsType = singleColorBitmap
+		case img.blend != nil:
+			var bgcol C64Color
+			if rgba0, rgba1, bgcol, err = img.SplitBlendMulticolor(); err != nil {
+				return fmt.Errorf("img.SplitBlendMulticolor %q failed: %w", img.sourceFilename, err)
 			}
-			c.images = []sourceImage{i0, i1}
+			// both frames need the same background color, even if one of them does not use it
+			bg = &img.blend.colors[bgcol]
+		default:
+			rgba0, rgba1 = img.SplitInterlace()
 		}
+		if img.blend != nil {
+			// color flicker shows both frames at the same position
+			c.opt.D016Offset = 0
+		}
+		c.opt.ForceBorderColor = int(img.border.C64Color)
+		if !c.opt.Quiet {
+			fmt.Println("interlaced pic was split")
+		}
+		c.opt.GraphicsMode = c.opt.CurrentGraphicsType.String()
 
-		if err = c.images[0].analyze(); err != nil {
-			return n, fmt.Errorf("analyze %q failed: %w", c.images[0].sourceFilename, err)
+		i0, err := NewSourceImage(c.opt, 0, rgba0)
+		if err != nil {
+			return fmt.Errorf("NewSourceImages %q failed: %w", img.sourceFilename, err)
 		}
-		if err = c.images[1].analyze(); err != nil {
-			return n, fmt.Errorf("analyze %q failed: %w", c.images[1].sourceFilename, err)
+		i1, err := NewSourceImage(c.opt, 1, rgba1)
+		if err != nil {
+			return fmt.Errorf("NewSourceImages %q failed: %w", img.sourceFilename, err)
 		}
-		c.FinalGraphicsType = img.graphicsType
-		return c.WriteInterlaceTo(w)
+		c.images = []sourceImage{i0, i1}
 	}
-	if len(c.images) > 1 {
-		return c.WriteAnimationTo(w)
+
+	if err := c.images[0].analyze(); err != nil {
+		return fmt.Errorf("analyze %q failed: %w", c.images[0].sourceFilename, err)
 	}
+	if err := c.images[1].analyze(); err != nil {
+		return fmt.Errorf("analyze %q failed: %w", c.images[1].sourceFilename, err)
+	}
+	if bg != nil {
+		c.images[0].bg, c.images[1].bg = *bg, *bg
+	}
+	return nil
+}
 
+// convert converts the first, already analyzed, image to its graphics type and returns the result.
+// If conversion to the detected charset type fails and no graphics mode is forced, it falls back to a bitmap type.
+func (c *Converter) convert() (wt io.WriterTo, err error) {
+	img := &c.images[0]
 	bruteforce := func(gfxtype GraphicsType, maxColors int) error {
 		if !c.opt.BruteForce {
 			return nil
 		}
+		switch gfxtype {
+		case ecmCharset, singleColorCharset, singleColorSprites, multiColorSprites:
+			// these modes may use less colors than they support
+			if n := img.p.NumColors(); n < maxColors {
+				maxColors = n
+			}
+		}
 		if err = c.BruteForceBitpairColors(gfxtype, maxColors); err != nil {
 			return fmt.Errorf("BruteForceBitpairColors %q failed: %w", img.sourceFilename, err)
 		}
 		if err = img.setPreferredBitpairColors(c.opt.BitpairColorsString); err != nil {
 			return fmt.Errorf("img.setPreferredBitpairColors %q failed: %w", c.opt.BitpairColorsString, err)
 		}
+		if gfxtype == ecmCharset {
+			img.ecmColors = nil
+			if err = img.findECMColors(); err != nil {
+				return fmt.Errorf("img.findECMColors failed: %w", err)
+			}
+		}
 		return nil
 	}
 
-	var wt io.WriterTo
 	switch img.graphicsType {
 	case multiColorBitmap:
 		if err = bruteforce(multiColorBitmap, 4); err != nil {
-			return 0, err
+			return nil, err
 		}
 		if wt, err = img.Koala(); err != nil {
-			return 0, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
+			return nil, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
 		}
 	case singleColorBitmap:
 		if err = bruteforce(singleColorBitmap, 2); err != nil {
-			return 0, err
+			return nil, err
 		}
 		if wt, err = img.Hires(); err != nil {
-			return 0, fmt.Errorf("img.Hires %q failed: %w", img.sourceFilename, err)
+			return nil, fmt.Errorf("img.Hires %q failed: %w", img.sourceFilename, err)
 		}
 	case singleColorCharset:
 		if c.opt.GraphicsMode != "" {
+			if err = bruteforce(singleColorCharset, 2); err != nil {
+				return nil, err
+			}
 			if wt, err = img.SingleColorCharset(nil); err != nil {
-				return 0, fmt.Errorf("img.SingleColorCharset %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.SingleColorCharset %q failed: %w", img.sourceFilename, err)
 			}
 		} else {
 			if wt, err = img.PETSCIICharset(); err != nil {
+				if err = bruteforce(singleColorCharset, 2); err != nil {
+					return nil, err
+				}
 				if wt, err = img.SingleColorCharset(nil); err != nil {
 					fmt.Printf("falling back to %s because img.SingleColorCharset %q failed: %v\n", singleColorBitmap, img.sourceFilename, err)
 					img.graphicsType = singleColorBitmap
 					if err = bruteforce(singleColorBitmap, 2); err != nil {
-						return 0, err
+						return nil, err
 					}
 					if wt, err = img.Hires(); err != nil {
-						return 0, fmt.Errorf("img.Hires %q failed: %w", img.sourceFilename, err)
+						return nil, fmt.Errorf("img.Hires %q failed: %w", img.sourceFilename, err)
 					}
 				}
 			} else if !c.opt.Quiet {
@@ -874,128 +1260,125 @@ func (c *Converter) WriteTo(w io.Writer) (n int64, err error) {
 		}
 	case petsciiCharset:
 		if wt, err = img.PETSCIICharset(); err != nil {
-			return 0, fmt.Errorf("img.PETSCIICharset %q failed: %w", img.sourceFilename, err)
+			return nil, fmt.Errorf("img.PETSCIICharset %q failed: %w", img.sourceFilename, err)
 		}
 	case ecmCharset:
-		if wt, err = img.ECMCharset(nil); err != nil {
+		if err = bruteforce(ecmCharset, 4); err == nil {
+			wt, err = img.ECMCharset(nil)
+		}
+		if err != nil {
 			if c.opt.GraphicsMode != "" {
-				return 0, fmt.Errorf("img.ECMCharset %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.ECMCharset %q failed: %w", img.sourceFilename, err)
 			}
 			fmt.Printf("falling back to %s because img.ECMCharset %q failed: %v\n", singleColorBitmap, img.sourceFilename, err)
 			img.graphicsType = singleColorBitmap
 			if err = bruteforce(singleColorBitmap, 2); err != nil {
-				return 0, err
+				return nil, err
 			}
 			if wt, err = img.Hires(); err != nil {
-				return 0, fmt.Errorf("img.Hires %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.Hires %q failed: %w", img.sourceFilename, err)
 			}
 		}
 	case multiColorCharset:
 		if err = bruteforce(multiColorCharset, 4); err != nil {
 			if c.opt.GraphicsMode != "" {
-				return 0, fmt.Errorf("img.MultiColorCharset %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.MultiColorCharset %q failed: %w", img.sourceFilename, err)
 			}
 			fmt.Printf("falling back to %s because bruteforce %q failed: %v\n", multiColorBitmap, img.sourceFilename, err)
 			img.graphicsType = multiColorBitmap
 			err = img.findBackgroundColor()
 			if err != nil {
-				return 0, fmt.Errorf("findBackgroundColor %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("findBackgroundColor %q failed: %w", img.sourceFilename, err)
 			}
 			if err = bruteforce(multiColorBitmap, 4); err != nil {
-				return 0, err
+				return nil, err
 			}
 			if wt, err = img.Koala(); err != nil {
-				return 0, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
 			}
 		}
 		if wt, err = img.MultiColorCharset(nil); err != nil {
 			if c.opt.GraphicsMode != "" {
-				return 0, fmt.Errorf("img.MultiColorCharset %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.MultiColorCharset %q failed: %w", img.sourceFilename, err)
 			}
 			fmt.Printf("falling back to %s because img.MultiColorCharset %q failed: %v\n", multiColorBitmap, img.sourceFilename, err)
 			img.graphicsType = multiColorBitmap
 			err = img.findBackgroundColor()
 			if err != nil {
-				return 0, fmt.Errorf("findBackgroundColor %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("findBackgroundColor %q failed: %w", img.sourceFilename, err)
 			}
 			if err = bruteforce(multiColorBitmap, 4); err != nil {
-				return 0, err
+				return nil, err
 			}
 			if wt, err = img.Koala(); err != nil {
-				return 0, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
 			}
 		}
 	case singleColorSprites:
+		if err = bruteforce(singleColorSprites, 2); err != nil {
+			return nil, err
+		}
 		if wt, err = img.SingleColorSprites(); err != nil {
-			return 0, fmt.Errorf("img.SingleColorSprites %q failed: %w", img.sourceFilename, err)
+			return nil, fmt.Errorf("img.SingleColorSprites %q failed: %w", img.sourceFilename, err)
 		}
 	case multiColorSprites:
+		if err = bruteforce(multiColorSprites, 4); err != nil {
+			return nil, err
+		}
 		if wt, err = img.MultiColorSprites(); err != nil {
-			return 0, fmt.Errorf("img.MultiColorSprites %q failed: %w", img.sourceFilename, err)
+			return nil, fmt.Errorf("img.MultiColorSprites %q failed: %w", img.sourceFilename, err)
+		}
+	case interlaceFLIBitmap:
+		if wt, err = img.IFLI(); err != nil {
+			return nil, fmt.Errorf("img.IFLI %q failed: %w", img.sourceFilename, err)
 		}
 	case mixedCharset:
 		if err = bruteforce(mixedCharset, 4); err != nil {
 			if c.opt.GraphicsMode != "" {
-				return 0, fmt.Errorf("img.MixedCharset %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.MixedCharset %q failed: %w", img.sourceFilename, err)
 			}
 			fmt.Printf("falling back to %s because bruteforce %s for %q failed: %v\n", multiColorBitmap, mixedCharset, img.sourceFilename, err)
 			img.graphicsType = multiColorBitmap
 			img.findBgCandidates(false)
 			if err = img.findBackgroundColor(); err != nil {
-				return 0, fmt.Errorf("img.findBackgroundColor %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.findBackgroundColor %q failed: %w", img.sourceFilename, err)
 			}
 			if err = bruteforce(multiColorBitmap, 4); err != nil {
-				return 0, err
+				return nil, err
 			}
 			if wt, err = img.Koala(); err != nil {
-				return 0, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
 			}
 		}
 		if wt, err = img.MixedCharset(nil); err != nil {
 			if c.opt.GraphicsMode != "" {
-				return 0, fmt.Errorf("img.MixedCharset %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.MixedCharset %q failed: %w", img.sourceFilename, err)
 			}
 			fmt.Printf("falling back to %s because %s for %q failed: %v\n", multiColorBitmap, mixedCharset, img.sourceFilename, err)
 			img.graphicsType = multiColorBitmap
 			img.findBgCandidates(false)
 			if err = img.findBackgroundColor(); err != nil {
-				return 0, fmt.Errorf("img.findBackgroundColor %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.findBackgroundColor %q failed: %w", img.sourceFilename, err)
 			}
 			if err = bruteforce(multiColorBitmap, 4); err != nil {
-				return 0, err
+				return nil, err
 			}
 			if wt, err = img.Koala(); err != nil {
-				return 0, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
+				return nil, fmt.Errorf("img.Koala %q failed: %w", img.sourceFilename, err)
 			}
 		}
 	default:
-		return 0, fmt.Errorf("unsupported graphicsType %q for %q", img.graphicsType, img.sourceFilename)
-	}
-
-	if c.opt.Symbols {
-		if s, ok := wt.(Symbolser); ok {
-			c.Symbols = append(c.Symbols, s.Symbols()...)
-		}
-		if len(c.Symbols) == 0 {
-			return 0, fmt.Errorf("symbols not supported %T for %q", wt, img.sourceFilename)
-		}
+		return nil, fmt.Errorf("unsupported graphicsType %q for %q", img.graphicsType, img.sourceFilename)
 	}
+	return wt, nil
+}
 
-	t1 := time.Now()
-	if c.opt.Display && !c.opt.NoCrunch {
-		wt, err = injectCrunch(wt, c.opt.Verbose)
-		if err != nil {
-			return 0, fmt.Errorf("injectCrunch failed: %w", err)
-		}
-	}
-	n, err = wt.WriteTo(w)
-	if err != nil {
-		return n, fmt.Errorf("WriteTo failed: %w", err)
-	}
-	if !c.opt.Quiet && c.opt.Display && !c.opt.NoCrunch {
-		fmt.Printf("TSCrunched in %s\n", time.Since(t1))
+// MemoryMap returns the memory map of the .prg linked by the last WriteTo, false if it was not linked.
+func (c *Converter) MemoryMap() (MemoryMap, bool) {
+	if c.link == nil {
+		return MemoryMap{}, false
 	}
-	return n, nil
+	return c.link.MemoryMap(), true
 }
 
 // WriteSymbolsTo writes c.Symbols to w in text format.
@@ -1019,20 +1402,28 @@ var TSCOptions = TSCrunch.Options{
 	PRG:    true,
 	QUIET:  true,
 	Fast:   true,
-	JumpTo: displayerJumpTo,
+	JumpTo: Word(displayerJumpTo).String(),
 }
 
-// injectCrunch drains the input io.WriterTo and returns a new TSCrunch WriterTo.
-func injectCrunch(c io.WriterTo, verbose bool) (io.WriterTo, error) {
+// injectCrunch drains the input io.WriterTo and returns a WriterTo of the self-extracting .prg,
+// crunched with opt.Cruncher and starting the displayer.
+func injectCrunch(c io.WriterTo, opt Options) (io.WriterTo, error) {
+	cr, err := NewCruncher(opt.Cruncher)
+	if err != nil {
+		return nil, err
+	}
 	buf := &bytes.Buffer{}
-	if _, err := c.WriteTo(buf); err != nil {
+	if _, err = c.WriteTo(buf); err != nil {
 		return nil, fmt.Errorf("WriteTo buffer failed: %w", err)
 	}
-	c, err := TSCrunch.New(TSCOptions, buf)
+	sfx, err := cr.CrunchSFX(buf.Bytes(), displayerJumpTo)
 	if err != nil {
-		return nil, fmt.Errorf("tscrunch.New failed: %w", err)
+		return nil, fmt.Errorf("%s CrunchSFX failed: %w", cr, err)
 	}
-	return c, nil
+	if opt.Verbose {
+		log.Printf("crunched %d bytes to %d bytes with %s", buf.Len(), len(sfx), cr)
+	}
+	return bytes.NewBuffer(sfx), nil
 }
 
 // defaultHeader returns the startaddress of a file located at BitmapAddress.
@@ -1042,172 +1433,314 @@ func defaultHeader() []byte {
 }
 
 // injectSID injects the sid, it's start song and init/play addresses in predefined locations in the linker.
-// If sidFilename is empty, nothing happens and a nil error is returned.
+// If opt.IncludeSID is empty, nothing happens and a nil error is returned.
+// If the sid overlaps used or blocked memory, including zeropage, it is relocated unless opt.NoSIDRelocation is set.
 // Must be called *after* displayer code is linked.
-func injectSID(l *Linker, sidFilename string, quiet bool) error {
-	if sidFilename == "" {
+func injectSID(l *Linker, opt Options) error {
+	if opt.IncludeSID == "" {
 		return nil
 	}
-	s, err := sid.LoadSID(sidFilename)
+	s, err := sid.LoadSID(opt.IncludeSID)
 	if err != nil {
 		return fmt.Errorf("sid.LoadSID failed: %w", err)
 	}
-	if _, err = l.WritePrg(s.Bytes()); err != nil {
-		return fmt.Errorf("link.WritePrg failed: %w", err)
+	song, err := sidSong(s, opt.SubTune)
+	if err != nil {
+		return err
 	}
-	startSong := s.StartSong().LowByte()
-	if startSong > 0 {
-		startSong--
+	chips, err := extraSIDs(s)
+	if err != nil {
+		return err
+	}
+	prg := s.Bytes()
+	init, play := Word(s.InitAddress()), Word(s.PlayAddress())
+	if !opt.NoSIDRelocation && (l.Overlaps(Word(s.LoadAddress()), len(s.RawBytes())) || sidZeroPageCollides(s, song, l)) {
+		r, err := relocateSID(s, song, l)
+		if err != nil {
+			return fmt.Errorf("relocateSID %q failed: %w", opt.IncludeSID, err)
+		}
+		if r != nil {
+			prg, init, play = r.Prg(), r.Init, r.Play
+			if !opt.Quiet {
+				if load := Word(s.LoadAddress()); r.Load != load {
+					fmt.Printf("relocated sid from %s to %s - %s\n", load, r.Load, Word(int(r.Load)+len(r.Data)))
+				}
+				if r.ZeroPageShift != 0 {
+					fmt.Printf("remapped sid zeropage addresses by %+d\n", r.ZeroPageShift)
+				}
+			}
+		}
 	}
-	l.SetByte(DisplayerSettingsStart, startSong)
-	init := s.InitAddress()
-	l.SetByte(DisplayerSettingsStart+2, init.LowByte(), init.HighByte())
-	play := s.PlayAddress()
-	l.SetByte(DisplayerSettingsStart+5, play.LowByte(), play.HighByte())
-	if !quiet {
-		fmt.Printf("injected %q: %s\n", sidFilename, s)
+	if _, err = l.WriteNamedPrg(prg, "sid"); err != nil {
+		return fmt.Errorf("link.WritePrg failed: %w", err)
+	}
+	l.SetByte(DisplayerSettingsStart, song)
+	l.SetByte(DisplayerSettingsStart+2, init.Low(), init.High())
+	l.SetByte(DisplayerSettingsStart+5, play.Low(), play.High())
+	l.SetByte(DisplayerSettingsStart+7, chips...)
+	if !opt.Quiet {
+		fmt.Printf("injected %q: %s\n", opt.IncludeSID, s)
+		if s.Songs() > 1 {
+			fmt.Printf("using subtune %d of %d\n", song+1, s.Songs())
+		}
+		for _, c := range chips {
+			if c != 0 {
+				fmt.Printf("using extra sid at %s\n", sidChipAddress(c))
+			}
+		}
 	}
 	return nil
 }
 
-func (k Koala) WriteTo(w io.Writer) (n int64, err error) {
-	bgBorder := k.BackgroundColor | k.BorderColor<<4
-	link := NewLinker(BitmapAddress, k.opt.VeryVerbose)
-	_, err = link.WriteMap(LinkMap{
-		BitmapAddress: k.Bitmap[:],
-		0x3f40:        k.ScreenColor[:],
-		0x4328:        k.D800Color[:],
-		0x4710:        []byte{bgBorder},
-	})
-	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+// sidSong returns the zero based song number to initialize s with.
+// If subTune is 0, the start song from the header is used.
+func sidSong(s sid.SID, subTune int) (byte, error) {
+	songs := int(s.Songs())
+	if subTune == 0 {
+		subTune = int(s.StartSong())
+		if subTune == 0 {
+			subTune = 1
+		}
 	}
-	if !k.opt.Display {
-		return link.WriteTo(w)
+	if subTune < 1 || subTune > songs || subTune > 0x100 {
+		return 0, fmt.Errorf("incorrect subtune %d, the sid contains %d song(s)", subTune, songs)
 	}
-	link.Block(0x4800, 0x8e50)
+	return byte(subTune - 1), nil
+}
 
-	if _, err = link.WritePrg(multiColorBitmap.newHeader()); err != nil {
-		return n, fmt.Errorf("link.WritePrg failed: %w", err)
+// extraSIDs returns the second and third sid chip bytes from a PSID/RSID v3 or v4 header, 0 means not used.
+// The byte contains bits 11-4 of the address, so $42 means $d420.
+func extraSIDs(s sid.SID) ([]byte, error) {
+	chips := []byte{0, 0}
+	if s.Version() >= 3 && len(s) > 0x7a {
+		chips[0] = s[0x7a]
+	}
+	if s.Version() >= 4 && len(s) > 0x7b {
+		chips[1] = s[0x7b]
 	}
-	if err = injectSID(link, k.opt.IncludeSID, k.opt.Quiet); err != nil {
-		return n, fmt.Errorf("injectSID failed: %w", err)
+	for _, c := range chips {
+		if c == 0 {
+			continue
+		}
+		if c&1 != 0 || !(c >= 0x42 && c <= 0x7e || c >= 0xe0) {
+			return nil, fmt.Errorf("incorrect extra sid address %s in header", sidChipAddress(c))
+		}
 	}
-	return link.WriteTo(w)
+	return chips, nil
 }
 
-func (h Hires) WriteTo(w io.Writer) (n int64, err error) {
-	link := NewLinker(BitmapAddress, h.opt.VeryVerbose)
-	_, err = link.WriteMap(LinkMap{
-		BitmapAddress: h.Bitmap[:],
-		0x3f40:        h.ScreenColor[:],
-		0x4328:        []byte{h.BorderColor},
-	})
+// sidChipAddress returns the base address of the sid chip header byte c.
+func sidChipAddress(c byte) Word {
+	return 0xd000 | Word(c)<<4
+}
+
+func (k Koala) WriteTo(w io.Writer) (n int64, err error) {
+	return writeLinked(w, k)
+}
+
+func (k Koala) link() (link *Linker, err error) {
+	link, _, err = k.linkBank()
+	return link, err
+}
+
+// linkBank links k, with the displayer if enabled, and returns the displayerBank used.
+// The bank is empty without displayer.
+func (k Koala) linkBank() (link *Linker, b displayerBank, err error) {
+	if !k.opt.Display {
+		m := k.opt.bitmapLayout()
+		if err = m.validateBank(k.opt, "bitmap"); err != nil {
+			return nil, b, err
+		}
+		link = NewLinker(m.Bitmap, k.opt.VeryVerbose)
+		lm := k.linkMap(m)
+		if _, err = link.WriteNamedMap(lm, m.names(lm, "bitmap")); err != nil {
+			return nil, b, fmt.Errorf("link.WriteMap failed: %w", err)
+		}
+		return link, b, nil
+	}
+	link, b, err = linkDisplayer(k.opt, koalaBanks, k.linkMap)
 	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+		return nil, b, fmt.Errorf("linkDisplayer failed: %w", err)
 	}
-	if !h.opt.Display {
-		return link.WriteTo(w)
+	return link, b, nil
+}
+
+// linkMap returns the LinkMap of k's data blocks located at m.
+func (k Koala) linkMap(m memoryLayout) LinkMap {
+	bgBorder := k.BackgroundColor | k.BorderColor<<4
+	return LinkMap{
+		m.Bitmap:   k.Bitmap[:],
+		m.Screen:   k.ScreenColor[:],
+		m.ColorRAM: k.D800Color[:],
+		m.Colors:   []byte{bgBorder},
 	}
-	link.Block(0x4800, 0x6b29)
+}
 
-	if _, err = link.WritePrg(singleColorBitmap.newHeader()); err != nil {
-		return n, fmt.Errorf("link.WritePrg failed: %w", err)
+func (h Hires) WriteTo(w io.Writer) (n int64, err error) {
+	return writeLinked(w, h)
+}
+
+func (h Hires) link() (link *Linker, err error) {
+	link, _, err = h.linkBank()
+	return link, err
+}
+
+// linkBank links h, with the displayer if enabled, and returns the displayerBank used.
+// The bank is empty without displayer.
+func (h Hires) linkBank() (link *Linker, b displayerBank, err error) {
+	if !h.opt.Display {
+		m := h.opt.bitmapLayout()
+		if err = m.validateBank(h.opt, "bitmap"); err != nil {
+			return nil, b, err
+		}
+		link = NewLinker(m.Bitmap, h.opt.VeryVerbose)
+		lm := h.linkMap(m)
+		if _, err = link.WriteNamedMap(lm, m.names(lm, "bitmap")); err != nil {
+			return nil, b, fmt.Errorf("link.WriteMap failed: %w", err)
+		}
+		return link, b, nil
 	}
-	if err = injectSID(link, h.opt.IncludeSID, h.opt.Quiet); err != nil {
-		return n, fmt.Errorf("injectSID failed: %w", err)
+	link, b, err = linkDisplayer(h.opt, hiresBanks, h.linkMap)
+	if err != nil {
+		return nil, b, fmt.Errorf("linkDisplayer failed: %w", err)
+	}
+	return link, b, nil
+}
+
+// linkMap returns the LinkMap of h's data blocks located at m.
+// The border color is stored at the colorram address.
+func (h Hires) linkMap(m memoryLayout) LinkMap {
+	return LinkMap{
+		m.Bitmap:   h.Bitmap[:],
+		m.Screen:   h.ScreenColor[:],
+		m.ColorRAM: []byte{h.BorderColor},
 	}
-	return link.WriteTo(w)
 }
 
 func (c MultiColorCharset) WriteTo(w io.Writer) (n int64, err error) {
-	link := NewLinker(BitmapAddress, c.opt.VeryVerbose)
-	_, err = link.WriteMap(LinkMap{
-		BitmapAddress:           c.Bitmap[:],
-		CharsetScreenRAMAddress: c.Screen[:],
-		CharsetColorRAMAddress:  c.D800Color[:],
-		0x2fe8:                  []byte{c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color},
-	})
+	return writeLinked(w, c)
+}
+
+func (c MultiColorCharset) link() (link *Linker, err error) {
+	m := c.opt.charsetLayout()
+	if err = m.validateBank(c.opt, "charset"); err != nil {
+		return nil, err
+	}
+	link = NewLinker(m.Bitmap, c.opt.VeryVerbose)
+	lm := LinkMap{
+		m.Bitmap:   c.Bitmap[:],
+		m.Screen:   c.Screen[:],
+		m.ColorRAM: c.D800Color[:],
+		m.Colors:   []byte{c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color},
+	}
+	_, err = link.WriteNamedMap(lm, m.names(lm, "charset"))
 	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+		return nil, fmt.Errorf("link.WriteMap failed: %w", err)
 	}
 	if !c.opt.Display {
-		return link.WriteTo(w)
+		return link, nil
 	}
-	if _, err = link.WritePrg(mixedCharset.newHeader()); err != nil {
-		return n, fmt.Errorf("link.WritePrg failed: %w", err)
+	if _, err = link.WriteNamedPrg(mixedCharset.newHeader(), "displayer"); err != nil {
+		return nil, fmt.Errorf("link.WritePrg failed: %w", err)
 	}
-	if err = injectSID(link, c.opt.IncludeSID, c.opt.Quiet); err != nil {
-		return n, fmt.Errorf("injectSID failed: %w", err)
+	if err = injectSID(link, c.opt); err != nil {
+		return nil, fmt.Errorf("injectSID failed: %w", err)
 	}
-	return link.WriteTo(w)
+	return link, nil
 }
 
 func (c SingleColorCharset) WriteTo(w io.Writer) (n int64, err error) {
-	link := NewLinker(BitmapAddress, c.opt.VeryVerbose)
-	_, err = link.WriteMap(LinkMap{
-		BitmapAddress:           c.Bitmap[:],
-		CharsetScreenRAMAddress: c.Screen[:],
-		CharsetColorRAMAddress:  c.D800Color[:],
-		0x2fe8:                  []byte{c.BorderColor, c.BackgroundColor},
-	})
+	return writeLinked(w, c)
+}
+
+func (c SingleColorCharset) link() (link *Linker, err error) {
+	m := c.opt.charsetLayout()
+	if err = m.validateBank(c.opt, "charset"); err != nil {
+		return nil, err
+	}
+	link = NewLinker(m.Bitmap, c.opt.VeryVerbose)
+	lm := LinkMap{
+		m.Bitmap:   c.Bitmap[:],
+		m.Screen:   c.Screen[:],
+		m.ColorRAM: c.D800Color[:],
+		m.Colors:   []byte{c.BorderColor, c.BackgroundColor},
+	}
+	_, err = link.WriteNamedMap(lm, m.names(lm, "charset"))
 	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+		return nil, fmt.Errorf("link.WriteMap failed: %w", err)
 	}
 	if !c.opt.Display {
-		return link.WriteTo(w)
+		return link, nil
 	}
 	link.Block(0xac00, 0xcf28)
-	if _, err = link.WritePrg(singleColorCharset.newHeader()); err != nil {
-		return n, fmt.Errorf("link.WritePrg failed: %w", err)
+	if _, err = link.WriteNamedPrg(singleColorCharset.newHeader(), "displayer"); err != nil {
+		return nil, fmt.Errorf("link.WritePrg failed: %w", err)
 	}
-	if err = injectSID(link, c.opt.IncludeSID, c.opt.Quiet); err != nil {
-		return n, fmt.Errorf("injectSID failed: %w", err)
+	if err = injectSID(link, c.opt); err != nil {
+		return nil, fmt.Errorf("injectSID failed: %w", err)
 	}
-	return link.WriteTo(w)
+	return link, nil
 }
 
 func (c MixedCharset) WriteTo(w io.Writer) (n int64, err error) {
-	link := NewLinker(BitmapAddress, c.opt.VeryVerbose)
-	_, err = link.WriteMap(LinkMap{
-		BitmapAddress:           c.Bitmap[:],
-		CharsetScreenRAMAddress: c.Screen[:],
-		CharsetColorRAMAddress:  c.D800Color[:],
-		0x2fe8:                  []byte{c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color},
-	})
+	return writeLinked(w, c)
+}
+
+func (c MixedCharset) link() (link *Linker, err error) {
+	m := c.opt.charsetLayout()
+	if err = m.validateBank(c.opt, "charset"); err != nil {
+		return nil, err
+	}
+	link = NewLinker(m.Bitmap, c.opt.VeryVerbose)
+	lm := LinkMap{
+		m.Bitmap:   c.Bitmap[:],
+		m.Screen:   c.Screen[:],
+		m.ColorRAM: c.D800Color[:],
+		m.Colors:   []byte{c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color},
+	}
+	_, err = link.WriteNamedMap(lm, m.names(lm, "charset"))
 	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+		return nil, fmt.Errorf("link.WriteMap failed: %w", err)
 	}
 	if !c.opt.Display {
-		return link.WriteTo(w)
+		return link, nil
 	}
-	if _, err = link.WritePrg(mixedCharset.newHeader()); err != nil {
-		return n, fmt.Errorf("link.WritePrg failed: %w", err)
+	if _, err = link.WriteNamedPrg(mixedCharset.newHeader(), "displayer"); err != nil {
+		return nil, fmt.Errorf("link.WritePrg failed: %w", err)
 	}
-	if err = injectSID(link, c.opt.IncludeSID, c.opt.Quiet); err != nil {
-		return 0, fmt.Errorf("injectSID failed: %w", err)
+	if err = injectSID(link, c.opt); err != nil {
+		return nil, fmt.Errorf("injectSID failed: %w", err)
 	}
-	return link.WriteTo(w)
+	return link, nil
 }
 
 func (c PETSCIICharset) WriteTo(w io.Writer) (n int64, err error) {
-	link := NewLinker(BitmapAddress, c.opt.VeryVerbose)
-	_, err = link.WriteMap(LinkMap{
-		CharsetScreenRAMAddress: c.Screen[:],
-		CharsetColorRAMAddress:  c.D800Color[:],
-		0x2fe8:                  []byte{c.BorderColor, c.BackgroundColor},
-	})
+	return writeLinked(w, c)
+}
+
+func (c PETSCIICharset) link() (link *Linker, err error) {
+	m := c.opt.charsetLayout()
+	if bank := vicBank(int(m.Screen)); c.opt.ScreenRAMAddress != 0 && bank != 0 && bank != 2 {
+		return nil, fmt.Errorf("screenram %s must be located in vic bank 0 or 2 to use the rom charset", m.Screen)
+	}
+	link = NewLinker(m.Screen, c.opt.VeryVerbose)
+	lm := LinkMap{
+		m.Screen:   c.Screen[:],
+		m.ColorRAM: c.D800Color[:],
+		m.Colors:   []byte{c.BorderColor, c.BackgroundColor},
+	}
+	_, err = link.WriteNamedMap(lm, m.names(lm, "charset"))
 	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+		return nil, fmt.Errorf("link.WriteMap failed: %w", err)
 	}
 	if !c.opt.Display {
-		return link.WriteTo(w)
+		return link, nil
 	}
 	link.Block(0xac00, 0xcf28)
-	if _, err = link.WritePrg(petsciiCharset.newHeader()); err != nil {
-		return n, fmt.Errorf("link.WritePrg failed: %w", err)
+	if _, err = link.WriteNamedPrg(petsciiCharset.newHeader(), "displayer"); err != nil {
+		return nil, fmt.Errorf("link.WritePrg failed: %w", err)
 	}
-	link.SetByte(DisplayerSettingsStart+7, c.Lowercase)
+	link.SetByte(DisplayerSettingsStart+9, c.Lowercase)
 	if !c.opt.Quiet {
 		if c.Lowercase == 1 {
 			fmt.Println("lowercase rom charset found")
@@ -1215,34 +1748,43 @@ func (c PETSCIICharset) WriteTo(w io.Writer) (n int64, err error) {
 			fmt.Println("uppercase rom charset found")
 		}
 	}
-	if err = injectSID(link, c.opt.IncludeSID, c.opt.Quiet); err != nil {
-		return n, fmt.Errorf("injectSID failed: %w", err)
+	if err = injectSID(link, c.opt); err != nil {
+		return nil, fmt.Errorf("injectSID failed: %w", err)
 	}
-	return link.WriteTo(w)
+	return link, nil
 }
 
 func (c ECMCharset) WriteTo(w io.Writer) (n int64, err error) {
-	link := NewLinker(BitmapAddress, c.opt.VeryVerbose)
-	_, err = link.WriteMap(LinkMap{
-		BitmapAddress:           c.Bitmap[:],
-		CharsetScreenRAMAddress: c.Screen[:],
-		CharsetColorRAMAddress:  c.D800Color[:],
-		0x2fe8:                  []byte{c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color, c.D024Color},
-	})
+	return writeLinked(w, c)
+}
+
+func (c ECMCharset) link() (link *Linker, err error) {
+	m := c.opt.charsetLayout()
+	if err = m.validateBank(c.opt, "charset"); err != nil {
+		return nil, err
+	}
+	link = NewLinker(m.Bitmap, c.opt.VeryVerbose)
+	lm := LinkMap{
+		m.Bitmap:   c.Bitmap[:],
+		m.Screen:   c.Screen[:],
+		m.ColorRAM: c.D800Color[:],
+		m.Colors:   []byte{c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color, c.D024Color},
+	}
+	_, err = link.WriteNamedMap(lm, m.names(lm, "charset"))
 	if err != nil {
-		return n, fmt.Errorf("link.WriteMap failed: %w", err)
+		return nil, fmt.Errorf("link.WriteMap failed: %w", err)
 	}
 	if !c.opt.Display {
-		return link.WriteTo(w)
+		return link, nil
 	}
 	link.Block(0xac00, 0xcf28)
-	if _, err = link.WritePrg(ecmCharset.newHeader()); err != nil {
-		return n, fmt.Errorf("link.WritePrg failed: %w", err)
+	if _, err = link.WriteNamedPrg(ecmCharset.newHeader(), "displayer"); err != nil {
+		return nil, fmt.Errorf("link.WritePrg failed: %w", err)
 	}
-	if err = injectSID(link, c.opt.IncludeSID, c.opt.Quiet); err != nil {
-		return 0, fmt.Errorf("injectSID failed: %w", err)
+	if err = injectSID(link, c.opt); err != nil {
+		return nil, fmt.Errorf("injectSID failed: %w", err)
 	}
-	return link.WriteTo(w)
+	return link, nil
 }
 
 func (s SingleColorSprites) WriteTo(w io.Writer) (n int64, err error) {
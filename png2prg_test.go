@@ -2,7 +2,16 @@ package png2prg
 
 import (
 	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/staD020/sid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const inFile = "testdata/floris_untitled.png"
@@ -85,3 +94,101 @@ func BenchmarkNewFromPathNoCrunchParallel(b *testing.B) {
 		}
 	})
 }
+
+func TestInjectSID(t *testing.T) {
+	t.Parallel()
+	buf, err := os.ReadFile(testSID)
+	require.NoError(t, err)
+	s, err := sid.New(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, int(s.Version()), 2)
+	songs := int(s.Songs())
+
+	// make it a 2SID file with the second sid at $d420.
+	buf[5] = 3
+	buf[0x7a] = 0x42
+	filename := filepath.Join(t.TempDir(), "stereo.sid")
+	require.NoError(t, os.WriteFile(filename, buf, 0o644))
+
+	l := NewLinker(0, false)
+	_, err = l.WritePrg(koalaDisplay)
+	require.NoError(t, err)
+	require.NoError(t, injectSID(l, Options{IncludeSID: filename, SubTune: songs, Quiet: true}))
+	assert.Equal(t, byte(songs-1), l.payload[DisplayerSettingsStart])
+	assert.Equal(t, []byte{0x42, 0}, l.payload[DisplayerSettingsStart+7:DisplayerSettingsStart+9])
+
+	// the shipped charset displayers keep the extra sids in their settings, outside of the code
+	for _, prg := range [][]byte{mcCharsetDisplayMulti, scCharsetDisplayMulti} {
+		l = NewLinker(0, false)
+		_, err = l.WritePrg(prg)
+		require.NoError(t, err)
+		code := append([]byte{}, l.payload[displayerJumpTo:l.EndAddress()]...)
+		require.NoError(t, injectSID(l, Options{IncludeSID: filename, Quiet: true, NoSIDRelocation: true}))
+		assert.Equal(t, []byte{0x42, 0}, l.payload[DisplayerSettingsStart+7:DisplayerSettingsStart+9])
+		assert.Equal(t, code, l.payload[displayerJumpTo:int(displayerJumpTo)+len(code)])
+	}
+
+	l = NewLinker(0, false)
+	assert.Error(t, injectSID(l, Options{IncludeSID: filename, SubTune: songs + 1, Quiet: true}))
+	buf[0x7a] = 0x43
+	require.NoError(t, os.WriteFile(filename, buf, 0o644))
+	assert.Error(t, injectSID(l, Options{IncludeSID: filename, Quiet: true}))
+}
+
+func TestWriteSlideshowTo(t *testing.T) {
+	t.Parallel()
+	files := []string{"testdata/archmage_mc_god.png", "testdata/ecm/orion.png", "testdata/drazlace/clone_zootrope.png", "testdata/petscii/horizon.png"}
+	opt := Options{Quiet: true, Slideshow: true, NoCrunch: true, SlideDelay: 3, IncludeSID: testSID}
+	c, err := NewFromPath(opt, files...)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	_, err = c.WriteTo(buf)
+	require.NoError(t, err)
+	prg := buf.Bytes()
+	require.Greater(t, len(prg), len(slideshowDisplay))
+	assert.Equal(t, slideshowDisplay[2:DisplayerSettingsStart-0x7ff], prg[2:DisplayerSettingsStart-0x7ff])
+	settings := prg[DisplayerSettingsStart-0x7ff:]
+	assert.Equal(t, []byte{3, byte(len(files)), 0}, settings[9:12])
+
+	opt.SlideDelay = 256
+	c, err = NewFromPath(opt, files...)
+	require.NoError(t, err)
+	_, err = c.WriteTo(&bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestGifFrames(t *testing.T) {
+	t.Parallel()
+	black, white, red := color.RGBA{0, 0, 0, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0x88, 0, 0, 0xff}
+	pal := color.Palette{black, white, red}
+	frame := func(r image.Rectangle, index uint8) *image.Paletted {
+		img := image.NewPaletted(r, pal)
+		for i := range img.Pix {
+			img.Pix[i] = index
+		}
+		return img
+	}
+	g := &gif.GIF{
+		Image:    []*image.Paletted{frame(image.Rect(0, 0, 4, 4), 0), frame(image.Rect(1, 1, 3, 3), 1), frame(image.Rect(0, 0, 1, 1), 2), frame(image.Rect(3, 3, 4, 4), 2)},
+		Delay:    []int{20, 20, 20, 20},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalPrevious, gif.DisposalNone},
+		Config:   image.Config{ColorModel: pal, Width: 4, Height: 4},
+	}
+	frames := gifFrames(g)
+	require.Len(t, frames, 4)
+	for _, f := range frames {
+		assert.Equal(t, image.Rect(0, 0, 4, 4), f.Bounds())
+	}
+	assert.Equal(t, color.Color(black), frames[0].At(1, 1))
+	assert.Equal(t, color.Color(white), frames[1].At(1, 1))
+	assert.Equal(t, color.Color(black), frames[1].At(0, 0))
+	assert.Equal(t, color.Color(red), frames[2].At(0, 0))
+	assert.Equal(t, color.Color(black), frames[2].At(1, 1), "disposed to background")
+	assert.Equal(t, color.Color(black), frames[3].At(0, 0), "restored to previous")
+	assert.Equal(t, color.Color(red), frames[3].At(3, 3))
+
+	assert.Equal(t, 10, gifDelayToFrames(20, false))
+	assert.Equal(t, 12, gifDelayToFrames(20, true))
+	assert.Equal(t, 1, gifDelayToFrames(1, false))
+	assert.Equal(t, 0xff, gifDelayToFrames(1000, false))
+}
@@ -0,0 +1,331 @@
+package png2prg
+
+import (
+	"fmt"
+
+	"github.com/staD020/sid"
+)
+
+const (
+	// sidRelocatePlayCalls is the number of play calls emulated to analyze and verify a relocated sid, 3 minutes at 50Hz.
+	sidRelocatePlayCalls = 3 * 60 * 50
+	// sidTracePlayCalls is the number of play calls emulated to quickly find the zeropage addresses used by a sid, 10 seconds at 50Hz.
+	sidTracePlayCalls = 10 * 50
+	// sidMaxSteps is the maximum number of instructions for a single init or play call.
+	sidMaxSteps = 1_000_000
+	// sidLowestAddress and sidHighestAddress limit the memory area where relocated sids are placed.
+	// Below $0800 the displayers use the screen and vectors, $fffa-$ffff contains the cpu vectors.
+	sidLowestAddress  = 0x0800
+	sidHighestAddress = 0xfffa
+)
+
+// animationZeroPageStart and animationZeroPageEnd mark the zeropage addresses used by the animation displayers.
+const (
+	animationZeroPageStart = 0x08
+	animationZeroPageEnd   = 0x10
+)
+
+// A sidRelocation is the result of relocating the sid data to Load and remapping its zeropage addresses by ZeroPageShift.
+type sidRelocation struct {
+	Load          Word
+	Init          Word
+	Play          Word
+	ZeroPageShift int
+	Data          []byte
+}
+
+// Prg returns the relocated sid data including the 2 byte load address.
+func (r sidRelocation) Prg() []byte {
+	return append(r.Load.Bytes(), r.Data...)
+}
+
+// sidTune contains the parts of a .sid needed for emulation.
+type sidTune struct {
+	load, init, play Word
+	song             byte
+	data             []byte
+}
+
+func newSIDTune(s sid.SID, song byte) sidTune {
+	return sidTune{
+		load: Word(s.LoadAddress()),
+		init: Word(s.InitAddress()),
+		play: Word(s.PlayAddress()),
+		song: song,
+		data: s.RawBytes(),
+	}
+}
+
+func (t sidTune) end() int {
+	return int(t.load) + len(t.data)
+}
+
+// inPages returns true if v is the high byte of an address in the pages occupied by the tune.
+func (t sidTune) inPages(v byte) bool {
+	return v >= t.load.High() && int(v) <= (t.end()-1)>>8
+}
+
+// run emulates init and calls play calls of the tune loaded in c.
+// Before each call, clobber is called if set, to simulate the displayer using memory.
+func (t sidTune) run(c *cpu6502, calls int, clobber func()) error {
+	if clobber != nil {
+		clobber()
+	}
+	if err := c.call(uint16(t.init), t.song, sidMaxSteps); err != nil {
+		return fmt.Errorf("init failed: %w", err)
+	}
+	for i := 0; i < calls; i++ {
+		if clobber != nil {
+			clobber()
+		}
+		if err := c.call(uint16(t.play), 0, sidMaxSteps); err != nil {
+			return fmt.Errorf("play call %d failed: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// sidZeroPageCollides returns true if the zeropage addresses used by init and the first sidTracePlayCalls play calls of
+// the sid s collide with zeropage addresses used or blocked in l.
+// If the sid cannot be traced, a collision is assumed and relocateSID reports the problem.
+func sidZeroPageCollides(s sid.SID, song byte, l *Linker) bool {
+	if !l.Overlaps(2, 0xfe) {
+		return false
+	}
+	t := newSIDTune(s, song)
+	if t.play == 0 || t.end() > MaxMemory+1 {
+		return true
+	}
+	c := newCPU6502()
+	c.load(uint16(t.load), t.data, false)
+	c.trace = true
+	if err := t.run(c, sidTracePlayCalls, nil); err != nil {
+		return true
+	}
+	for i := 2; i < len(c.zpUsed); i++ {
+		if c.zpUsed[i] && l.Overlaps(Word(i), 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// relocateSID relocates the sid s, initialized with song, to a memory area that is free in l and remaps zeropage addresses that collide with
+// zeropage addresses used or blocked in l.
+// It returns nil if the sid does not need to be relocated.
+//
+// The player is emulated with origin tracking to find all bytes that are used as high byte of an address inside the
+// tune or as zeropage address. Code that was not executed is found by disassembling from the branches that were.
+// The relocated sid is verified by emulating it again with the original location and the reserved zeropage
+// addresses clobbered, expecting the exact same sequence of sid register writes.
+func relocateSID(s sid.SID, song byte, l *Linker) (*sidRelocation, error) {
+	t := newSIDTune(s, song)
+	if t.play == 0 {
+		return nil, fmt.Errorf("relocation of sids without play address is not supported")
+	}
+	if t.end() > MaxMemory+1 {
+		return nil, fmt.Errorf("sid data %s - %s does not fit in memory", t.load, Word(t.end()))
+	}
+	c := newCPU6502()
+	c.load(uint16(t.load), t.data, true)
+	c.trace = true
+	if err := t.run(c, sidRelocatePlayCalls, nil); err != nil {
+		return nil, fmt.Errorf("emulating original sid failed: %w", err)
+	}
+	c.trace = false
+	c.markUnexecutedCode(uint16(t.load), uint16(t.end()))
+
+	var reserved [0x100]bool
+	for i := 2; i < len(reserved); i++ {
+		reserved[i] = l.Overlaps(Word(i), 1)
+	}
+	shift, err := zeroPageShift(c.zpUsed, reserved)
+	if err != nil {
+		return nil, err
+	}
+	load := t.load
+	if l.Overlaps(t.load, len(t.data)) {
+		if load, err = findSIDArea(l, t.load, len(t.data)); err != nil {
+			return nil, err
+		}
+	}
+	if load == t.load && shift == 0 {
+		return nil, nil
+	}
+
+	delta := byte((load - t.load) >> 8)
+	r := &sidRelocation{Load: load, Init: t.init, Play: t.play, ZeroPageShift: shift, Data: make([]byte, len(t.data))}
+	for i, v := range t.data {
+		a := int(t.load) + i
+		hi := c.hiUse[a] && t.inPages(v)
+		zp := shift != 0 && c.zpUse[a] && v >= 2
+		switch {
+		case hi && zp:
+			return nil, fmt.Errorf("byte at %s is used as both address high byte and zeropage address", Word(a))
+		case hi:
+			v += delta
+		case zp:
+			v = byte(int(v) + shift)
+		}
+		r.Data[i] = v
+	}
+	if t.inPages(t.init.High()) {
+		r.Init += load - t.load
+	}
+	if t.inPages(t.play.High()) {
+		r.Play += load - t.load
+	}
+
+	if err = verifySIDRelocation(t, *r, c.sidWrites, reserved); err != nil {
+		return nil, fmt.Errorf("verification of relocated sid failed: %w", err)
+	}
+	return r, nil
+}
+
+// verifySIDRelocation emulates the relocated sid and compares its sid register writes to want.
+// The memory of the original tune is inverted and the reserved zeropage addresses are clobbered before each call,
+// so missed fixups are likely to show up as different register writes.
+func verifySIDRelocation(t sidTune, r sidRelocation, want []uint32, reserved [0x100]bool) error {
+	c := newCPU6502()
+	junk := make([]byte, len(t.data))
+	for i, v := range t.data {
+		junk[i] = ^v
+	}
+	c.load(uint16(t.load), junk, false)
+	c.load(uint16(r.Load), r.Data, false)
+	rt := sidTune{load: r.Load, init: r.Init, play: r.Play, song: t.song, data: r.Data}
+	clobber := func() {
+		for i, v := range reserved {
+			if v {
+				c.mem[i] ^= 0xff
+			}
+		}
+	}
+	if err := rt.run(c, sidRelocatePlayCalls, clobber); err != nil {
+		return err
+	}
+	got := c.sidWrites
+	for i := range want {
+		if i >= len(got) {
+			return fmt.Errorf("expected %d sid register writes, got %d", len(want), len(got))
+		}
+		if got[i] != want[i] {
+			return fmt.Errorf("sid register write %d differs: want $%02x to %s, got $%02x to %s", i, byte(want[i]), Word(want[i]>>8), byte(got[i]), Word(got[i]>>8))
+		}
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("expected %d sid register writes, got %d", len(want), len(got))
+	}
+	return nil
+}
+
+// zeroPageShift returns the offset to add to all used zeropage addresses to avoid the reserved ones.
+// It returns 0 if there is no collision.
+func zeroPageShift(used, reserved [0x100]bool) (int, error) {
+	fits := func(shift int) bool {
+		for i := 2; i < len(used); i++ {
+			if !used[i] {
+				continue
+			}
+			if j := i + shift; j < 2 || j > 0xff || reserved[j] {
+				return false
+			}
+		}
+		return true
+	}
+	if fits(0) {
+		return 0, nil
+	}
+	for d := 1; d < 0x100; d++ {
+		if fits(d) {
+			return d, nil
+		}
+		if fits(-d) {
+			return -d, nil
+		}
+	}
+	return 0, fmt.Errorf("no free zeropage addresses to remap the sid to")
+}
+
+// findSIDArea returns the lowest load address with the same low byte as load, where length bytes fit in free memory.
+// Whole pages need to be free, as the player may use the remainder of its last page.
+func findSIDArea(l *Linker, load Word, length int) (Word, error) {
+	offset := int(load.Low())
+	pages := (offset + length + 0xff) >> 8
+	for p := sidLowestAddress; p+offset+length <= sidHighestAddress; p += 0x100 {
+		if p < 0xe000 && p+pages*0x100 > 0xd000 {
+			// sid code cannot run under io.
+			continue
+		}
+		if !l.Overlaps(Word(p), pages*0x100) {
+			return Word(p + offset), nil
+		}
+	}
+	return 0, fmt.Errorf("no free memory area of %d pages found to relocate the sid to", pages)
+}
+
+// markUnexecutedCode disassembles code in start - end that was not executed during tracing, but is reachable from
+// executed branches, and marks its absolute operands as address high bytes.
+// A path is rejected if it runs into data accessed during tracing, undocumented or invalid opcodes, or leaves the area.
+func (c *cpu6502) markUnexecutedCode(start, end uint16) {
+	const maxInstructions = 0x1000
+	walk := func(entry uint16) []uint16 {
+		var found []uint16
+		visited := make(map[uint16]bool)
+		todo := []uint16{entry}
+		for len(todo) > 0 {
+			pc := todo[len(todo)-1]
+			todo = todo[:len(todo)-1]
+			if visited[pc] || c.executed[pc] {
+				continue
+			}
+			visited[pc] = true
+			o := opcodes[c.mem[pc]]
+			size := o.mode.size()
+			if o.op == opInvalid || o.op == opBRK || o.op >= opALR || pc < start || int(pc)+int(size) > int(end) {
+				return nil
+			}
+			for i := uint16(0); i < size; i++ {
+				if c.accessed[pc+i] || (i > 0 && c.executed[pc+i]) {
+					return nil
+				}
+			}
+			if found = append(found, pc); len(found) > maxInstructions {
+				return nil
+			}
+			target := uint16(c.mem[pc+2])<<8 | uint16(c.mem[pc+1])
+			inArea := func(a uint16) bool { return a >= start && a < end }
+			switch o.op {
+			case opRTS, opRTI:
+				continue
+			case opJMP:
+				if o.mode == modeAbs && inArea(target) {
+					todo = append(todo, target)
+				}
+				continue
+			case opJSR:
+				if inArea(target) {
+					todo = append(todo, target)
+				}
+			}
+			if o.mode == modeRel {
+				todo = append(todo, pc+2+uint16(int8(c.mem[pc+1])))
+			}
+			todo = append(todo, pc+size)
+		}
+		return found
+	}
+	for pc := int(start); pc < int(end); pc++ {
+		if !c.executed[pc] || opcodes[c.mem[pc]].mode != modeRel {
+			continue
+		}
+		next := uint16(pc) + 2
+		for _, entry := range []uint16{next, next + uint16(int8(c.mem[pc+1]))} {
+			for _, a := range walk(entry) {
+				if opcodes[c.mem[a]].mode.absolute() {
+					c.hiUse[a+2] = true
+				}
+			}
+		}
+	}
+}
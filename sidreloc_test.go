@@ -0,0 +1,95 @@
+package png2prg
+
+import (
+	"testing"
+
+	"github.com/staD020/sid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPU6502OriginTracking(t *testing.T) {
+	t.Parallel()
+	prg := []byte{
+		0xa9, 0x10, // lda #>table
+		0x85, 0xfc, // sta $fc
+		0xa9, 0x14, // lda #<table
+		0x85, 0xfb, // sta $fb
+		0xa0, 0x00, // ldy #0
+		0xb1, 0xfb, // lda ($fb),y
+		0x8d, 0x18, 0xd4, // sta $d418
+		0x4c, 0x12, 0x10, // jmp done
+		0x60, // done: rts
+		0xea, // nop
+		0x0f, // table: .byte $0f
+	}
+	c := newCPU6502()
+	c.load(0x1000, prg, true)
+	c.trace = true
+	require.NoError(t, c.call(0x1000, 0, 100))
+	assert.Equal(t, []uint32{0xd4180f}, c.sidWrites)
+	assert.True(t, c.hiUse[0x1001], "immediate used as pointer high byte")
+	assert.False(t, c.hiUse[0x1005], "immediate used as pointer low byte")
+	assert.True(t, c.hiUse[0x1011], "jmp operand")
+	assert.True(t, c.zpUse[0x100b], "zeropage operand")
+	assert.True(t, c.zpUsed[0xfb])
+	assert.True(t, c.zpUsed[0xfc])
+	assert.Equal(t, "lda ($fb),y", disassemble(c.mem[:], 0x100a))
+}
+
+func TestZeroPageShift(t *testing.T) {
+	t.Parallel()
+	var used, reserved [0x100]bool
+	used[0xfb], used[0xfc] = true, true
+	reserved[0x08] = true
+	shift, err := zeroPageShift(used, reserved)
+	require.NoError(t, err)
+	assert.Equal(t, 0, shift)
+
+	used[0x08], used[0x09] = true, true
+	shift, err = zeroPageShift(used, reserved)
+	require.NoError(t, err)
+	assert.Equal(t, 1, shift)
+}
+
+func TestSIDZeroPageCollides(t *testing.T) {
+	t.Parallel()
+	l := NewLinker(0, false)
+	l.Block(animationZeroPageStart, animationZeroPageEnd)
+	type tc struct {
+		filename string
+		want     bool
+	}
+	for _, c := range []tc{{"testdata/Snake_Disco.sid", false}, {"testdata/Rivalry_tune_5.sid", false}, {"testdata/Lift_Off_V2.sid", true}} {
+		s, err := sid.LoadSID(c.filename)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, sidZeroPageCollides(s, 0, l), c.filename)
+		assert.False(t, sidZeroPageCollides(s, 0, NewLinker(0, false)), c.filename)
+	}
+}
+
+func TestRelocateSID(t *testing.T) {
+	t.Parallel()
+	for _, filename := range []string{"testdata/Lift_Off_V2.sid", "testdata/Snake_Disco.sid", "testdata/Ocean_Reloaded_90.sid", "testdata/drazlace/sanxion.sid"} {
+		s, err := sid.LoadSID(filename)
+		require.NoError(t, err)
+		load := Word(s.LoadAddress())
+		length := len(s.RawBytes())
+
+		l := NewLinker(0, false)
+		r, err := relocateSID(s, 0, l)
+		require.NoError(t, err, filename)
+		assert.Nil(t, r, "%s does not need relocation", filename)
+
+		l.Block(0x0800, 0x2000)
+		l.Block(load, Word(int(load)+length))
+		l.Block(animationZeroPageStart, animationZeroPageEnd)
+		r, err = relocateSID(s, 0, l)
+		require.NoError(t, err, filename)
+		require.NotNil(t, r, filename)
+		assert.Equal(t, load.Low(), r.Load.Low(), filename)
+		assert.False(t, l.Overlaps(r.Load, length), filename)
+		assert.Equal(t, length, len(r.Data), filename)
+		assert.Equal(t, int(r.Init)-int(r.Load), int(s.InitAddress())-int(load), filename)
+	}
+}
@@ -0,0 +1,368 @@
+package png2prg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/staD020/TSCrunch"
+)
+
+// The slideshow displayer decrunches the pictures to vic bank 1, the second bitmap and screenram of interlace pictures to vic bank 3.
+const (
+	slideCharset         = 0x4000
+	slideCharsetScreen   = 0x4800
+	slideCharsetColorRAM = 0x4c00
+	slideCharsetColors   = 0x4fe8
+	slideColorRAM        = 0x5800
+	slideColors          = 0x5be8
+	slideScreen          = 0x5c00
+	slideBitmap          = 0x6000
+	slideScreen2         = 0xcc00
+	slideBitmap2         = 0xe000
+
+	slideBank1       = 0x02 // $dd00 value for vic bank 1
+	slideBank3       = 0x00 // $dd00 value for vic bank 3
+	slideBitmapD018  = 0x78 // screenram $5c00, bitmap $6000
+	slideBitmap2D018 = 0x38 // screenram $cc00, bitmap $e000
+	slideCharsetD018 = 0x20 // screenram $4800, charset $4000
+
+	slideFadeScreen   = 0x80
+	slideFadeColorRAM = 0x40
+	slideFadeScreen2  = 0x20
+
+	slideHeaderLength = 16
+	// slideFadeTable is generated by the displayer at runtime.
+	slideFadeTable = 0x0400
+)
+
+var slideBitmapLayout = memoryLayout{slideBitmap, slideScreen, slideColorRAM, slideColors}
+
+// A slide contains the vic settings and data chunks of a single picture in a slideshow.
+type slide struct {
+	name          string
+	graphicsType  GraphicsType
+	d011          byte
+	d016          byte
+	d018          byte
+	dd00          byte
+	colorRAM      Word // source of the colorram, 0 if not used
+	fade          byte
+	colors        [5]byte // d020-d024
+	interlace     byte    // d016 eor value for interlace
+	d018Interlace byte    // d018 and dd00 of the second interlace frame, d018 is 0 if not interlaced
+	dd00Interlace byte
+	chunks        []LinkMap
+}
+
+// header returns the slide header as expected by the slideshow displayer, chunkList is the address of the chunk list.
+func (s slide) header(chunkList Word) []byte {
+	return []byte{
+		s.d011, s.d016, s.d018, s.dd00, s.colorRAM.High(), s.fade,
+		s.colors[0], s.colors[1], s.colors[2], s.colors[3], s.colors[4],
+		s.interlace, chunkList.Low(), chunkList.High(), s.d018Interlace, s.dd00Interlace,
+	}
+}
+
+// A slider can be shown in a slideshow.
+type slider interface {
+	slide() slide
+}
+
+// A slideChunk is a contiguous part of a slide, crunched separately.
+type slideChunk struct {
+	slide    int
+	addr     Word
+	data     []byte
+	crunched []byte
+}
+
+// newSlideChunk links the data blocks in m into a single contiguous chunk.
+func newSlideChunk(slide int, m LinkMap) (slideChunk, error) {
+	l := NewLinker(0, false)
+	if _, err := l.WriteMap(m); err != nil {
+		return slideChunk{}, fmt.Errorf("link.WriteMap failed: %w", err)
+	}
+	return slideChunk{slide: slide, addr: l.StartAddress(), data: l.Bytes()}, nil
+}
+
+// crunch TSCrunches the chunk data, to be decrunched to ch.addr.
+func (ch *slideChunk) crunch() error {
+	t, err := TSCrunch.New(TSCrunch.Options{PRG: true, QUIET: true}, bytes.NewReader(append(ch.addr.Bytes(), ch.data...)))
+	if err != nil {
+		return fmt.Errorf("tscrunch.New failed: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = t.WriteTo(buf); err != nil {
+		return fmt.Errorf("tscrunch.WriteTo failed: %w", err)
+	}
+	ch.crunched = buf.Bytes()
+	return nil
+}
+
+func (k Koala) slide() slide {
+	return slide{
+		d011:     0x3b,
+		d016:     0x18,
+		d018:     slideBitmapD018,
+		dd00:     slideBank1,
+		colorRAM: slideColorRAM,
+		fade:     slideFadeScreen | slideFadeColorRAM,
+		colors:   [5]byte{k.BorderColor, k.BackgroundColor},
+		chunks:   []LinkMap{k.linkMap(slideBitmapLayout)},
+	}
+}
+
+func (h Hires) slide() slide {
+	return slide{
+		d011:   0x3b,
+		d016:   0x08,
+		d018:   slideBitmapD018,
+		dd00:   slideBank1,
+		fade:   slideFadeScreen,
+		colors: [5]byte{h.BorderColor, h.BorderColor},
+		chunks: []LinkMap{h.linkMap(memoryLayout{Bitmap: slideBitmap, Screen: slideScreen, ColorRAM: slideColors})},
+	}
+}
+
+// charsetSlide returns the slide for all charset modes, the mc bit in colorram of multicolor charsets prevents fading colorram.
+func charsetSlide(d011, d016 byte, charset []byte, screen, colorRAM *[1000]byte, colors ...byte) slide {
+	s := slide{
+		d011:     d011,
+		d016:     d016,
+		d018:     slideCharsetD018,
+		dd00:     slideBank1,
+		colorRAM: slideCharsetColorRAM,
+		chunks: []LinkMap{{
+			slideCharset:         charset,
+			slideCharsetScreen:   screen[:],
+			slideCharsetColorRAM: colorRAM[:],
+			slideCharsetColors:   colors,
+		}},
+	}
+	if d016&0x10 == 0 {
+		s.fade = slideFadeColorRAM
+	}
+	copy(s.colors[:], colors)
+	return s
+}
+
+func (c MultiColorCharset) slide() slide {
+	return charsetSlide(0x1b, 0x18, c.Bitmap[:], &c.Screen, &c.D800Color, c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color)
+}
+
+func (c SingleColorCharset) slide() slide {
+	return charsetSlide(0x1b, 0x08, c.Bitmap[:], &c.Screen, &c.D800Color, c.BorderColor, c.BackgroundColor)
+}
+
+func (c MixedCharset) slide() slide {
+	return charsetSlide(0x1b, 0x18, c.Bitmap[:], &c.Screen, &c.D800Color, c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color)
+}
+
+// slide returns the slide with a copy of the rom charset, as vic bank 1 has no character rom.
+func (c PETSCIICharset) slide() slide {
+	rom := romCharsetUppercasePrg
+	if c.Lowercase == 1 {
+		rom = romCharsetLowercasePrg
+	}
+	return charsetSlide(0x1b, 0x08, rom[2:MaxChars*8+2], &c.Screen, &c.D800Color, c.BorderColor, c.BackgroundColor)
+}
+
+func (c ECMCharset) slide() slide {
+	return charsetSlide(0x5b, 0x08, c.Bitmap[:], &c.Screen, &c.D800Color, c.BorderColor, c.BackgroundColor, c.D022Color, c.D023Color, c.D024Color)
+}
+
+// interlaceSlide returns the slide for a multicolor interlace picture, like the interlace displayer it uses the colorram of k1.
+func interlaceSlide(k0, k1 Koala, d016Offset int) slide {
+	bank1 := k0.linkMap(slideBitmapLayout)
+	bank1[slideColorRAM] = k1.D800Color[:]
+	return slide{
+		d011:          0x3b,
+		d016:          0x18,
+		d018:          slideBitmapD018,
+		dd00:          slideBank1,
+		colorRAM:      slideColorRAM,
+		fade:          slideFadeScreen | slideFadeColorRAM | slideFadeScreen2,
+		colors:        [5]byte{k0.BorderColor, k0.BackgroundColor},
+		interlace:     byte(d016Offset),
+		d018Interlace: slideBitmap2D018,
+		dd00Interlace: slideBank3,
+		chunks: []LinkMap{
+			bank1,
+			{slideScreen2: k1.ScreenColor[:]},
+			{slideBitmap2: k1.Bitmap[:]},
+		},
+	}
+}
+
+// newSlide analyzes and converts img as a standalone picture and returns it as slide.
+func (c *Converter) newSlide(img sourceImage) (s slide, err error) {
+	sc := &Converter{opt: c.opt, images: []sourceImage{img}}
+	if err = sc.images[0].analyze(); err != nil {
+		return s, fmt.Errorf("analyze %q failed: %w", img.sourceFilename, err)
+	}
+	if sc.images[0].graphicsType == multiColorInterlaceBitmap {
+		if err = sc.splitInterlace(); err != nil {
+			return s, err
+		}
+		k0, k1, _, err := sc.interlaceKoalas()
+		if err != nil {
+			return s, err
+		}
+		s = interlaceSlide(k0, k1, c.opt.D016Offset)
+		s.name, s.graphicsType = img.sourceFilename, multiColorInterlaceBitmap
+		return s, nil
+	}
+	wt, err := sc.convert()
+	if err != nil {
+		return s, err
+	}
+	sl, ok := wt.(slider)
+	if !ok {
+		return s, fmt.Errorf("%s %q is not supported in slideshows", sc.images[0].graphicsType, img.sourceFilename)
+	}
+	s = sl.slide()
+	s.name, s.graphicsType = img.sourceFilename, sc.images[0].graphicsType
+	return s, nil
+}
+
+// WriteSlideshowTo converts all images as standalone pictures and writes the slideshow displayer .prg to w.
+// Each picture is crunched separately and decrunched by the displayer in turn, showing it for opt.SlideDelay seconds.
+func (c *Converter) WriteSlideshowTo(w io.Writer) (n int64, err error) {
+	switch {
+	case len(c.images) == 0:
+		return n, fmt.Errorf("no images found")
+	case len(c.images) > 0xff:
+		return n, fmt.Errorf("too many images for a slideshow: %d, the maximum is 255", len(c.images))
+	case c.opt.CustomLayout():
+		return n, fmt.Errorf("custom addresses are not supported for slideshows")
+	case c.opt.DisplayerBank != 0:
+		return n, fmt.Errorf("vic bank %d is not supported for slideshows", c.opt.DisplayerBank)
+	case c.opt.SlideDelay < 0 || c.opt.SlideDelay > 0xff:
+		return n, fmt.Errorf("incorrect slide delay %d, only 0-255 seconds are allowed", c.opt.SlideDelay)
+	}
+
+	slides := make([]slide, 0, len(c.images))
+	chunks := []*slideChunk{}
+	for i := range c.images {
+		s, err := c.newSlide(c.images[i])
+		if err != nil {
+			return n, fmt.Errorf("newSlide %d failed: %w", i, err)
+		}
+		slides = append(slides, s)
+		for _, m := range s.chunks {
+			ch, err := newSlideChunk(i, m)
+			if err != nil {
+				return n, fmt.Errorf("newSlideChunk %q failed: %w", s.name, err)
+			}
+			chunks = append(chunks, &ch)
+		}
+	}
+
+	t0 := time.Now()
+	for _, ch := range chunks {
+		if err = ch.crunch(); err != nil {
+			return n, fmt.Errorf("crunch %q failed: %w", slides[ch.slide].name, err)
+		}
+	}
+	if !c.opt.Quiet {
+		fmt.Printf("TSCrunched %d slides in %s\n", len(slides), time.Since(t0))
+	}
+
+	link := NewLinker(0, c.opt.VeryVerbose)
+	if _, err = link.WriteNamedPrg(slideshowDisplay, "displayer"); err != nil {
+		return n, fmt.Errorf("link.WritePrg failed: %w", err)
+	}
+	// reserve the slide headers and chunk lists, they are filled in when all chunks are placed.
+	headers := link.Cursor()
+	size := len(slides) * slideHeaderLength
+	for _, s := range slides {
+		size += len(s.chunks)*4 + 2
+	}
+	if _, err = link.Write(make([]byte, size)); err != nil {
+		return n, fmt.Errorf("link.Write failed: %w", err)
+	}
+	link.Name(headers, link.Cursor(), "slide headers")
+	for _, ch := range chunks {
+		link.Block(ch.addr, ch.addr+Word(len(ch.data)))
+		link.Name(ch.addr, ch.addr+Word(len(ch.data)), "slides")
+	}
+	link.Block(slideFadeTable, slideFadeTable+0x100)
+	link.Name(slideFadeTable, slideFadeTable+0x100, "fade")
+	link.Block(0xf8, 0xff)
+	link.SetByte(DisplayerSettingsStart+9, byte(c.opt.SlideDelay), byte(len(slides)), c.opt.NoFadeByte())
+	if err = injectSID(link, c.opt); err != nil {
+		return n, fmt.Errorf("injectSID failed: %w", err)
+	}
+
+	// place the largest chunks first, to make the most of fragmented memory.
+	sorted := make([]*slideChunk, len(chunks))
+	copy(sorted, chunks)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].crunched) > len(sorted[j].crunched) })
+	src := make(map[*slideChunk]Word, len(chunks))
+	for _, ch := range sorted {
+		addr, err := findFreeArea(link, headers, len(ch.crunched))
+		if err != nil {
+			return n, fmt.Errorf("slide %q does not fit in memory: %w", slides[ch.slide].name, err)
+		}
+		if _, err = link.CursorWrite(addr, ch.crunched); err != nil {
+			return n, fmt.Errorf("link.CursorWrite failed: %w", err)
+		}
+		link.Name(addr, link.Cursor(), "crunched slides")
+		src[ch] = addr
+	}
+
+	list := headers + Word(len(slides)*slideHeaderLength)
+	for i, s := range slides {
+		link.SetByte(headers+Word(i*slideHeaderLength), s.header(list)...)
+		size := 0
+		for _, ch := range chunks {
+			if ch.slide != i {
+				continue
+			}
+			link.SetByte(list, src[ch].Low(), src[ch].High(), ch.addr.Low(), ch.addr.High())
+			list += 4
+			size += len(ch.crunched)
+		}
+		link.SetByte(list, 0, 0)
+		list += 2
+		if !c.opt.Quiet {
+			fmt.Printf("slide %d: %q in %s format, crunched to %d bytes\n", i+1, s.name, s.graphicsType, size)
+		}
+	}
+
+	if c.opt.NoCrunch {
+		return c.writeLinkTo(w, link)
+	}
+	c.link = link
+	t1 := time.Now()
+	wt, err := injectCrunch(link, c.opt)
+	if err != nil {
+		return n, fmt.Errorf("injectCrunch failed: %w", err)
+	}
+	if n, err = wt.WriteTo(w); err != nil {
+		return n, fmt.Errorf("WriteTo failed: %w", err)
+	}
+	if !c.opt.Quiet {
+		fmt.Printf("crunched in %s\n", time.Since(t1))
+	}
+	return n, nil
+}
+
+// findFreeArea returns the lowest address from start where length bytes fit in memory that is not used or blocked in l.
+// The io area is skipped, as the displayer reads the data with io visible.
+func findFreeArea(l *Linker, start Word, length int) (Word, error) {
+	free := 0
+	for a := int(start); a < sidHighestAddress; a++ {
+		if (a >= 0xd000 && a < 0xe000) || l.used[a] || l.block[a] {
+			free = 0
+			continue
+		}
+		if free++; free == length {
+			return Word(a - length + 1), nil
+		}
+	}
+	return 0, fmt.Errorf("no free memory area of %d bytes found", length)
+}
@@ -0,0 +1,260 @@
+package png2prg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// The sprite animation displayer shows the sprites from $2000 in vic bank 0, which leaves room for 128 sprites.
+const (
+	spriteAnimSprites      = 0x2000
+	spriteAnimMaxSprites   = 0x80
+	spriteAnimMaxObjects   = 8
+	spriteAnimHeaderLength = 6 + 8*spriteAnimMaxObjects
+	spriteAnimXStart       = 0x40
+	spriteAnimYStart       = 0x32
+	spriteLength           = 64
+)
+
+// A spriteObject is an independently animated hardware sprite, showing sprites[i] for delays[i] frames.
+// A delay of 0 uses the displayer's frame delay.
+type spriteObject struct {
+	x, y    byte
+	sprites []int
+	delays  []byte
+}
+
+// A spriteAnimation contains the objects and unique sprites of a sprite animation.
+type spriteAnimation struct {
+	objects []spriteObject
+	sprites [][]byte
+}
+
+// addSprite adds sprite s to the unique sprites of a and returns its index.
+func (a *spriteAnimation) addSprite(s []byte) (int, error) {
+	for i := range a.sprites {
+		if bytes.Equal(a.sprites[i], s) {
+			return i, nil
+		}
+	}
+	if len(a.sprites) >= spriteAnimMaxSprites {
+		return 0, fmt.Errorf("too many unique sprites, the maximum is %d", spriteAnimMaxSprites)
+	}
+	a.sprites = append(a.sprites, s)
+	return len(a.sprites) - 1, nil
+}
+
+// newSpriteAnimation returns the animation of the sprite sheets in frames, each containing columns x rows sprites.
+// Each sprite position on the sheet is an object, animated through the frames in the order of opt.FrameOrder and opt.Playback.
+func newSpriteAnimation(opt Options, frames [][]byte, columns, rows int, delays []byte) (spriteAnimation, error) {
+	a := spriteAnimation{}
+	if columns*rows > spriteAnimMaxObjects {
+		return a, fmt.Errorf("too many sprites per frame: %d, the maximum is %d", columns*rows, spriteAnimMaxObjects)
+	}
+	seq, err := animationSequence(opt, len(frames))
+	if err != nil {
+		return a, fmt.Errorf("animationSequence failed: %w", err)
+	}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < columns; x++ {
+			o := spriteObject{x: byte(spriteAnimXStart + x*SpriteWidth), y: byte(spriteAnimYStart + y*SpriteHeight)}
+			offset := (y*columns + x) * spriteLength
+			for _, f := range seq {
+				i, err := a.addSprite(frames[f][offset : offset+spriteLength])
+				if err != nil {
+					return a, err
+				}
+				o.sprites = append(o.sprites, i)
+				o.delays = append(o.delays, delays[f])
+			}
+			a.objects = append(a.objects, o)
+		}
+	}
+	return a, nil
+}
+
+// newSpriteSheetAnimation returns the animation of a single sprite sheet, containing columns x rows sprites.
+// Each row is an object, animated through the sprites in the row. Empty sprites at the end of a row are skipped,
+// so each object can have a different number of steps. Rows without sprites are skipped altogether.
+func newSpriteSheetAnimation(sheet []byte, columns, rows int) (spriteAnimation, error) {
+	a := spriteAnimation{}
+	empty := make([]byte, spriteLength)
+	for y := 0; y < rows; y++ {
+		n := columns
+		for n > 0 && bytes.Equal(sheet[(y*columns+n-1)*spriteLength:(y*columns+n)*spriteLength], empty) {
+			n--
+		}
+		if n == 0 {
+			continue
+		}
+		if len(a.objects) >= spriteAnimMaxObjects {
+			return a, fmt.Errorf("too many rows with sprites, the maximum is %d", spriteAnimMaxObjects)
+		}
+		o := spriteObject{x: spriteAnimXStart, y: byte(spriteAnimYStart + len(a.objects)*SpriteHeight)}
+		for x := 0; x < n; x++ {
+			offset := (y*columns + x) * spriteLength
+			i, err := a.addSprite(sheet[offset : offset+spriteLength])
+			if err != nil {
+				return a, err
+			}
+			o.sprites = append(o.sprites, i)
+			o.delays = append(o.delays, 0)
+		}
+		a.objects = append(a.objects, o)
+	}
+	if len(a.objects) == 0 {
+		return a, fmt.Errorf("no sprites found on the sheet")
+	}
+	return a, nil
+}
+
+// spriteAnimColors contains the colors of the sprite animation displayer.
+type spriteAnimColors struct {
+	multiColor  bool
+	background  byte
+	d025, d026  byte
+	spriteColor byte
+}
+
+// linkSpriteAnimation links the sprite animation displayer, object table and sprites of a.
+func linkSpriteAnimation(opt Options, a spriteAnimation, colors spriteAnimColors) (link *Linker, symbols []c64Symbol, err error) {
+	if len(a.objects) > spriteAnimMaxObjects {
+		return nil, nil, fmt.Errorf("too many objects: %d, the maximum is %d", len(a.objects), spriteAnimMaxObjects)
+	}
+	link = NewLinker(0, opt.VeryVerbose)
+	if _, err = link.WriteNamedPrg(spritesDisplayAnim, "displayer"); err != nil {
+		return nil, nil, fmt.Errorf("link.WritePrg error: %w", err)
+	}
+	link.SetByte(DisplayerSettingsStart+9, byte(opt.FrameDelay), byte(opt.WaitSeconds))
+
+	header := link.EndAddress() - spriteAnimHeaderLength
+	d01c := byte(0)
+	if colors.multiColor {
+		d01c = 0xff
+	}
+	loop := byte(1)
+	if opt.Playback == PlaybackOnce {
+		loop = 0
+	}
+	link.SetByte(header, d01c, colors.background, colors.d025, colors.d026, loop, byte(len(a.objects)))
+	table := link.EndAddress()
+	for i, o := range a.objects {
+		if len(o.sprites) > 0xff {
+			return nil, nil, fmt.Errorf("object %d has too many steps: %d, the maximum is %d", i, len(o.sprites), 0xff)
+		}
+		ptrs := make([]byte, len(o.sprites))
+		for j, s := range o.sprites {
+			ptrs[j] = byte((spriteAnimSprites&0x3fff)/spriteLength + s)
+		}
+		link.SetByte(header+6+Word(i), o.x)
+		link.SetByte(header+6+8+Word(i), o.y)
+		link.SetByte(header+6+16+Word(i), colors.spriteColor)
+		link.SetByte(header+6+24+Word(i), byte(len(o.sprites)))
+		link.SetByte(header+6+32+Word(i), table.Low())
+		link.SetByte(header+6+40+Word(i), table.High())
+		link.SetByte(header+6+48+Word(i), (table + Word(len(ptrs))).Low())
+		link.SetByte(header+6+56+Word(i), (table + Word(len(ptrs))).High())
+		if _, err = link.CursorWrite(table, append(ptrs, o.delays...)); err != nil {
+			return nil, nil, fmt.Errorf("link.CursorWrite error: %w", err)
+		}
+		symbols = append(symbols, c64Symbol{fmt.Sprintf("object%d", i), int(table)})
+		table = link.Cursor()
+	}
+	link.Name(header+spriteAnimHeaderLength, table, "objects")
+	if !opt.Quiet {
+		fmt.Printf("memory usage for displayer code and objects: %s - %s\n", link.StartAddress(), link.EndAddress())
+	}
+
+	link.SetCursor(spriteAnimSprites)
+	for _, s := range a.sprites {
+		if _, err = link.Write(s); err != nil {
+			return nil, nil, fmt.Errorf("link.Write error: %w", err)
+		}
+	}
+	link.Name(spriteAnimSprites, link.Cursor(), "sprites")
+	if !opt.Quiet {
+		fmt.Printf("memory usage for %d sprites: %s - %s\n", len(a.sprites), Word(spriteAnimSprites), link.EndAddress())
+	}
+
+	link.Block(animationZeroPageStart, animationZeroPageEnd)
+	link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+	if err = injectSID(link, opt); err != nil {
+		return nil, nil, fmt.Errorf("injectSID failed: %w", err)
+	}
+	symbols = append(symbols,
+		c64Symbol{"sprites", spriteAnimSprites},
+		c64Symbol{"anim_header", int(header)},
+		c64Symbol{"objects", len(a.objects)},
+	)
+	return link, symbols, nil
+}
+
+// newSpriteAnimationOf returns the animation of the sprite sheets in frames, or of a single sheet with opt.SpriteSheet set.
+func newSpriteAnimationOf(opt Options, frames [][]byte, columns, rows int, delays []byte) (spriteAnimation, error) {
+	if len(frames) == 1 && opt.SpriteSheet {
+		return newSpriteSheetAnimation(frames[0], columns, rows)
+	}
+	return newSpriteAnimation(opt, frames, columns, rows, delays)
+}
+
+// WriteSingleColorSpritesAnimationTo processes ss and writes the sprite animation displayer .prg to w.
+func WriteSingleColorSpritesAnimationTo(w io.Writer, ss []SingleColorSprites) (n int64, err error) {
+	link, _, err := linkSingleColorSpritesAnimation(ss)
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// linkSingleColorSpritesAnimation returns the Linker written by WriteSingleColorSpritesAnimationTo, and the symbols of the objects.
+func linkSingleColorSpritesAnimation(ss []SingleColorSprites) (link *Linker, symbols []c64Symbol, err error) {
+	frames, delays := make([][]byte, len(ss)), make([]byte, len(ss))
+	for i, s := range ss {
+		if s.Columns != ss[0].Columns || s.Rows != ss[0].Rows {
+			return nil, nil, fmt.Errorf("frame %d has %dx%d sprites, expected %dx%d", i, s.Columns, s.Rows, ss[0].Columns, ss[0].Rows)
+		}
+		frames[i], delays[i] = s.Bitmap, byte(s.opt.FrameDelay)
+	}
+	opt := ss[0].opt
+	a, err := newSpriteAnimationOf(opt, frames, int(ss[0].Columns), int(ss[0].Rows), delays)
+	if err != nil {
+		return nil, nil, err
+	}
+	return linkSpriteAnimation(opt, a, spriteAnimColors{
+		background:  ss[0].BackgroundColor,
+		spriteColor: ss[0].SpriteColor,
+	})
+}
+
+// WriteMultiColorSpritesAnimationTo processes ss and writes the sprite animation displayer .prg to w.
+func WriteMultiColorSpritesAnimationTo(w io.Writer, ss []MultiColorSprites) (n int64, err error) {
+	link, _, err := linkMultiColorSpritesAnimation(ss)
+	if err != nil {
+		return n, err
+	}
+	return link.WriteTo(w)
+}
+
+// linkMultiColorSpritesAnimation returns the Linker written by WriteMultiColorSpritesAnimationTo, and the symbols of the objects.
+func linkMultiColorSpritesAnimation(ss []MultiColorSprites) (link *Linker, symbols []c64Symbol, err error) {
+	frames, delays := make([][]byte, len(ss)), make([]byte, len(ss))
+	for i, s := range ss {
+		if s.Columns != ss[0].Columns || s.Rows != ss[0].Rows {
+			return nil, nil, fmt.Errorf("frame %d has %dx%d sprites, expected %dx%d", i, s.Columns, s.Rows, ss[0].Columns, ss[0].Rows)
+		}
+		frames[i], delays[i] = s.Bitmap, byte(s.opt.FrameDelay)
+	}
+	opt := ss[0].opt
+	a, err := newSpriteAnimationOf(opt, frames, int(ss[0].Columns), int(ss[0].Rows), delays)
+	if err != nil {
+		return nil, nil, err
+	}
+	return linkSpriteAnimation(opt, a, spriteAnimColors{
+		multiColor:  true,
+		background:  ss[0].BackgroundColor,
+		d025:        ss[0].D025Color,
+		d026:        ss[0].D026Color,
+		spriteColor: ss[0].SpriteColor,
+	})
+}
@@ -0,0 +1,51 @@
+package png2prg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSprite returns a sprite with its first byte set to v.
+func testSprite(v byte) []byte {
+	s := make([]byte, spriteLength)
+	s[0] = v
+	return s
+}
+
+func TestNewSpriteAnimation(t *testing.T) {
+	t.Parallel()
+	frames := [][]byte{
+		append(testSprite(1), testSprite(4)...),
+		append(testSprite(2), testSprite(4)...),
+		append(testSprite(3), testSprite(5)...),
+	}
+	a, err := newSpriteAnimation(Options{Playback: PlaybackPingPong}, frames, 2, 1, []byte{6, 6, 12})
+	require.NoError(t, err)
+	require.Len(t, a.objects, 2)
+	assert.Len(t, a.sprites, 5)
+	assert.Equal(t, []int{0, 1, 2, 1}, a.objects[0].sprites)
+	assert.Equal(t, []int{3, 3, 4, 3}, a.objects[1].sprites)
+	assert.Equal(t, []byte{6, 6, 12, 6}, a.objects[1].delays)
+	assert.Equal(t, byte(spriteAnimXStart+SpriteWidth), a.objects[1].x)
+
+	_, err = newSpriteAnimation(Options{}, frames, 9, 1, []byte{0, 0, 0})
+	assert.Error(t, err)
+}
+
+func TestNewSpriteSheetAnimation(t *testing.T) {
+	t.Parallel()
+	sheet := []byte{}
+	for _, v := range []byte{1, 2, 3, 4, 0, 0, 0, 0, 0, 1, 2, 0} {
+		sheet = append(sheet, testSprite(v)...)
+	}
+	a, err := newSpriteSheetAnimation(sheet, 3, 4)
+	require.NoError(t, err)
+	require.Len(t, a.objects, 3)
+	assert.Equal(t, []int{0, 1, 2}, a.objects[0].sprites)
+	assert.Equal(t, []int{3}, a.objects[1].sprites)
+	assert.Equal(t, []int{0, 1}, a.objects[2].sprites)
+	assert.Equal(t, byte(spriteAnimYStart+2*SpriteHeight), a.objects[2].y)
+	assert.Len(t, a.sprites, 4)
+}
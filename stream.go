@@ -0,0 +1,274 @@
+package png2prg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/staD020/TSCrunch"
+)
+
+// The streaming displayer plays the frames from two segment buffers, while loading and decrunching
+// the next segment from disk into the free buffer.
+const (
+	streamBuffer0     = 0x4800
+	streamBuffer1     = 0x7800
+	streamBufferSize  = 0x3000
+	streamLoadAddress = 0xa800
+	streamLoadSize    = 0x2800
+
+	// streamLoader is the jmp to the loader, followed by the length of the segment filename and the filename.
+	streamLoader          = 0x082b
+	streamSegmentName     = 0x082e
+	streamMaxNameLength   = 16
+	streamMaxSegments     = 0x100
+	streamDecrunchZPStart = 0xf8
+)
+
+// A StreamSegment is a separately crunched part of a streamed animation, loaded by the streaming displayer.
+// Name is the filename as loaded by the displayer, File the filename on the host filesystem.
+type StreamSegment struct {
+	File     string `json:"file"`
+	Name     string `json:"name"`
+	Frames   int    `json:"frames"`
+	Offsets  []int  `json:"offsets"`
+	Length   int    `json:"length"`
+	Crunched int    `json:"crunched"`
+	data     []byte
+	crunched []byte
+}
+
+// WriteTo writes the crunched segment including the load address to w.
+func (s StreamSegment) WriteTo(w io.Writer) (n int64, err error) {
+	m, err := w.Write(append(Word(streamLoadAddress).Bytes(), s.crunched...))
+	return int64(m), err
+}
+
+// crunch TSCrunches the segment data, to be decrunched to a segment buffer.
+func (s *StreamSegment) crunch() error {
+	t, err := TSCrunch.New(TSCrunch.Options{PRG: true, QUIET: true}, bytes.NewReader(append(Word(streamBuffer0).Bytes(), s.data...)))
+	if err != nil {
+		return fmt.Errorf("tscrunch.New failed: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = t.WriteTo(buf); err != nil {
+		return fmt.Errorf("tscrunch.WriteTo failed: %w", err)
+	}
+	s.crunched = buf.Bytes()
+	s.Crunched = len(s.crunched)
+	if s.Crunched > streamLoadSize {
+		return fmt.Errorf("crunched segment %q is %d bytes, the maximum is %d", s.Name, s.Crunched, streamLoadSize)
+	}
+	return nil
+}
+
+// WriteStreamIndexTo writes the index of the stream segments in json format to w.
+func (c *Converter) WriteStreamIndexTo(w io.Writer) (n int64, err error) {
+	b, err := json.MarshalIndent(struct {
+		Segments []StreamSegment `json:"segments"`
+	}{c.Segments}, "", "  ")
+	if err != nil {
+		return n, fmt.Errorf("json.MarshalIndent failed: %w", err)
+	}
+	m, err := w.Write(append(b, '\n'))
+	return int64(m), err
+}
+
+// streamName returns the base of the segment filenames on disk in petscii, derived from outFile.
+func streamName(outFile string) string {
+	base := strings.ToUpper(strings.TrimSuffix(filepath.Base(outFile), filepath.Ext(outFile)))
+	name := make([]byte, 0, streamMaxNameLength-2)
+	for i := 0; i < len(base) && len(name) < streamMaxNameLength-2; i++ {
+		switch c := base[i]; {
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.':
+			name = append(name, c)
+		default:
+			name = append(name, '-')
+		}
+	}
+	if len(name) == 0 || base == "." {
+		return "ANIM"
+	}
+	return string(name)
+}
+
+// splitSegments divides the frames into segments of at most size bytes, including the end of segment marker.
+func splitSegments(frames [][]byte, size int) ([][][]byte, error) {
+	segments := [][][]byte{}
+	cur, length := [][]byte{}, 0
+	for i, f := range frames {
+		if len(f)+3 > size {
+			return nil, fmt.Errorf("frame %d is %d bytes, which does not fit in a segment of %d bytes", i, len(f), size)
+		}
+		if length+len(f)+3 > size {
+			segments = append(segments, cur)
+			cur, length = [][]byte{}, 0
+		}
+		cur = append(cur, f)
+		length += len(f)
+	}
+	return append(segments, cur), nil
+}
+
+// newStreamSegments splits the frames into crunched segments, named after opt.OutFile.
+func newStreamSegments(opt Options, frames [][]byte) ([]StreamSegment, error) {
+	split, err := splitSegments(frames, streamBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(split) > streamMaxSegments {
+		return nil, fmt.Errorf("too many segments: %d, the maximum is %d", len(split), streamMaxSegments)
+	}
+	name := streamName(opt.OutFile)
+	file := strings.TrimSuffix(filepath.Base(opt.OutFile), ".prg")
+	if file == "" || file == "." {
+		file = strings.ToLower(name)
+	}
+	t0 := time.Now()
+	segments := make([]StreamSegment, len(split))
+	for i, ff := range split {
+		s := &segments[i]
+		s.File = fmt.Sprintf("%s%02x.prg", file, i)
+		s.Name = fmt.Sprintf("%s%02X", name, i)
+		s.Frames = len(ff)
+		s.Offsets = frameOffsets(ff)
+		for _, f := range ff {
+			s.data = append(s.data, f...)
+		}
+		// the displayer switches to the other buffer at the end of the segment, the address is ignored.
+		s.data = append(s.data, 0xff, 0x00, 0x00)
+		s.Length = len(s.data)
+		if err = s.crunch(); err != nil {
+			return nil, fmt.Errorf("crunch segment %d failed: %w", i, err)
+		}
+		if opt.Verbose {
+			fmt.Printf("segment %d: %q %d frames, %d bytes crunched to %d bytes\n", i, s.Name, s.Frames, s.Length, s.Crunched)
+		}
+	}
+	if !opt.Quiet {
+		fmt.Printf("TSCrunched %d segments in %s\n", len(segments), time.Since(t0))
+	}
+	return segments, nil
+}
+
+// linkStream links the first segment and the settings of the streaming displayer.
+// It returns the symbols of the segments and the loader.
+func linkStream(link *Linker, opt Options, segments []StreamSegment) ([]c64Symbol, error) {
+	name := segments[0].Name[:len(segments[0].Name)-2]
+	loop := byte(1)
+	if opt.Playback == PlaybackOnce {
+		loop = 0
+	}
+	link.SetByte(DisplayerSettingsStart+9, byte(opt.FrameDelay), byte(opt.WaitSeconds), byte(len(segments)), loop)
+	link.SetByte(streamSegmentName, byte(len(name)+2))
+	link.SetByte(streamSegmentName+1, []byte(name)...)
+	link.Block(streamBuffer1, streamBuffer1+streamBufferSize)
+	link.Name(streamBuffer1, streamBuffer1+streamBufferSize, "stream buffer")
+	link.Block(streamLoadAddress, streamLoadAddress+streamLoadSize)
+	link.Name(streamLoadAddress, streamLoadAddress+streamLoadSize, "stream load")
+	link.Block(animationZeroPageStart, animationZeroPageEnd)
+	link.Name(animationZeroPageStart, animationZeroPageEnd, "zeropage")
+	link.Block(streamDecrunchZPStart, 0x100)
+	if _, err := link.CursorWrite(streamBuffer0, segments[0].data); err != nil {
+		return nil, fmt.Errorf("link.CursorWrite error: %w", err)
+	}
+	link.Name(streamBuffer0, link.Cursor(), "animation frames")
+	if !opt.Quiet {
+		fmt.Printf("memory usage for first segment: %s - %s\n", Word(streamBuffer0), link.EndAddress())
+	}
+	return []c64Symbol{
+		{"segments", len(segments)},
+		{"segment_buffer0", streamBuffer0},
+		{"segment_buffer1", streamBuffer1},
+		{"segment_load", streamLoadAddress},
+		{"loader", streamLoader},
+		{"segment_name", streamSegmentName + 1},
+	}, nil
+}
+
+// linkKoalaStream processes kk into segments and links the streaming displayer, picture and first segment.
+func linkKoalaStream(kk []Koala) (link *Linker, segments []StreamSegment, symbols []c64Symbol, err error) {
+	opt := kk[0].opt
+	tt, err := animationTransitions(opt, len(kk))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+	}
+	framePrgs, err := processAnimation(opt, makeCharer(kk), tt, koalaFrameDelays(kk))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("processAnimation error: %w", err)
+	}
+	if segments, err = newStreamSegments(opt, framePrgs); err != nil {
+		return nil, nil, nil, fmt.Errorf("newStreamSegments failed: %w", err)
+	}
+
+	link = NewLinker(0, opt.VeryVerbose)
+	if _, err = link.WriteNamedPrg(koalaDisplayAnimStream, "displayer"); err != nil {
+		return nil, nil, nil, fmt.Errorf("link.WritePrg error: %w", err)
+	}
+	if !opt.Quiet {
+		fmt.Printf("memory usage for displayer code: %s - %s\n", link.StartAddress(), link.EndAddress())
+	}
+	k := kk[tt[0].from]
+	picture := LinkMap{
+		BitmapAddress:                k.Bitmap[:],
+		BitmapScreenRAMAddress:       k.ScreenColor[:],
+		BitmapColorRAMAddress:        k.D800Color[:],
+		BitmapColorRAMAddress + 1000: {k.BackgroundColor | k.BorderColor<<4},
+	}
+	if _, err = link.WriteNamedMap(picture, defaultBitmapLayout.names(picture, "bitmap")); err != nil {
+		return nil, nil, nil, fmt.Errorf("link.WriteMap error: %w", err)
+	}
+	if !opt.Quiet {
+		fmt.Printf("memory usage for picture: 0x%04x - %s\n", BitmapAddress, link.EndAddress())
+	}
+	if symbols, err = linkStream(link, opt, segments); err != nil {
+		return nil, nil, nil, err
+	}
+	if err = injectSID(link, opt); err != nil {
+		return nil, nil, nil, fmt.Errorf("injectSID failed: %w", err)
+	}
+	return link, segments, symbols, nil
+}
+
+// linkHiresStream processes hh into segments and links the streaming displayer, picture and first segment.
+func linkHiresStream(hh []Hires) (link *Linker, segments []StreamSegment, symbols []c64Symbol, err error) {
+	opt := hh[0].opt
+	tt, err := animationTransitions(opt, len(hh))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("animationTransitions failed: %w", err)
+	}
+	framePrgs, err := processAnimation(opt, makeCharer(hh), tt, hiresFrameDelays(hh))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("processAnimation error: %w", err)
+	}
+	if segments, err = newStreamSegments(opt, framePrgs); err != nil {
+		return nil, nil, nil, fmt.Errorf("newStreamSegments failed: %w", err)
+	}
+
+	link = NewLinker(0, opt.VeryVerbose)
+	if _, err = link.WriteNamedPrg(hiresDisplayAnimStream, "displayer"); err != nil {
+		return nil, nil, nil, fmt.Errorf("link.WritePrg error: %w", err)
+	}
+	if !opt.Quiet {
+		fmt.Printf("memory usage for displayer code: %s - %s\n", link.StartAddress(), link.EndAddress())
+	}
+	h := hh[tt[0].from]
+	picture := h.linkMap(defaultBitmapLayout)
+	if _, err = link.WriteNamedMap(picture, defaultBitmapLayout.names(picture, "bitmap")); err != nil {
+		return nil, nil, nil, fmt.Errorf("link.WriteMap error: %w", err)
+	}
+	if !opt.Quiet {
+		fmt.Printf("memory usage for picture: %#04x - %s\n", BitmapAddress, link.EndAddress())
+	}
+	if symbols, err = linkStream(link, opt, segments); err != nil {
+		return nil, nil, nil, err
+	}
+	if err = injectSID(link, opt); err != nil {
+		return nil, nil, nil, fmt.Errorf("injectSID failed: %w", err)
+	}
+	return link, segments, symbols, nil
+}
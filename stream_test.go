@@ -0,0 +1,58 @@
+package png2prg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSegments(t *testing.T) {
+	t.Parallel()
+	frames := [][]byte{make([]byte, 4), make([]byte, 5), make([]byte, 2), make([]byte, 7)}
+	segments, err := splitSegments(frames, 11)
+	require.NoError(t, err)
+	require.Len(t, segments, 3)
+	assert.Len(t, segments[0], 1)
+	assert.Len(t, segments[1], 2)
+	assert.Len(t, segments[2], 1)
+
+	_, err = splitSegments(frames, 9)
+	assert.Error(t, err)
+}
+
+func TestStreamName(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "ANIM", streamName(""))
+	assert.Equal(t, "TANK", streamName("out/tank.prg"))
+	assert.Equal(t, "MY-ANIM-V2.1", streamName("my_anim v2.1.prg"))
+	assert.Equal(t, "A-VERY-LONG-AN", streamName("a_very_long_animation.prg"))
+}
+
+func TestStreamSegments(t *testing.T) {
+	t.Parallel()
+	kk := make([]Koala, 3)
+	for i := range kk {
+		kk[i].opt = Options{OutFile: "tank.prg", Quiet: true}
+		kk[i].ScreenColor[500] = byte(i)
+	}
+	tt, err := animationTransitions(kk[0].opt, len(kk))
+	require.NoError(t, err)
+	prgs, err := processAnimation(kk[0].opt, makeCharer(kk), tt, koalaFrameDelays(kk))
+	require.NoError(t, err)
+	segments, err := newStreamSegments(kk[0].opt, prgs)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	s := segments[0]
+	assert.Equal(t, "tank00.prg", s.File)
+	assert.Equal(t, "TANK00", s.Name)
+	assert.Equal(t, 3, s.Frames)
+	assert.Equal(t, []byte{0xff, 0x00, 0x00}, s.data[len(s.data)-3:])
+
+	buf := &bytes.Buffer{}
+	_, err = s.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0xa8}, buf.Bytes()[:2])
+	assert.Equal(t, s.Crunched+2, buf.Len())
+}